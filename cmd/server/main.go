@@ -1,17 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/acme/autocert"
 
+	"pitch-deck-generator/internal/chaos"
+	"pitch-deck-generator/internal/graph"
 	"pitch-deck-generator/internal/handler"
+	"pitch-deck-generator/internal/hooks"
+	"pitch-deck-generator/internal/mail"
 	"pitch-deck-generator/internal/middleware"
+	"pitch-deck-generator/internal/migrations"
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/notion"
 	"pitch-deck-generator/internal/progress"
+	"pitch-deck-generator/internal/repository"
+	"pitch-deck-generator/internal/sentry"
 	"pitch-deck-generator/internal/service"
 	"pitch-deck-generator/internal/storage"
+	"pitch-deck-generator/internal/telegram"
+	"pitch-deck-generator/internal/tracing"
 )
 
 func main() {
@@ -19,6 +40,33 @@ func main() {
 		log.Println("No .env file found, using default environment variables")
 	}
 
+	// server migrate [up|down|status] updates the database schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		command := "up"
+		if len(os.Args) > 2 {
+			command = os.Args[2]
+		}
+		if err := runMigrations(command); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	// server init sets up a new deployment: the database schema and the storage buckets
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := initDeployment(); err != nil {
+			log.Fatalf("Init failed: %v", err)
+		}
+		log.Println("Deployment ready, start the server")
+		return
+	}
+
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		if err := runMigrations("up"); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
+
 	// Initialize components
 	storageService, err := storage.NewSupabaseStorage()
 	if err != nil {
@@ -27,26 +75,392 @@ func main() {
 
 	log.Println("start the server")
 
+	encryption, err := service.ConfigureEncryption()
+	if err != nil {
+		log.Fatalf("Failed to configure encryption at rest: %v", err)
+	}
+	if !encryption {
+		log.Println("Encryption at rest disabled: ENCRYPTION_KEY is not set")
+	}
+
+	if !tracing.Configure() {
+		log.Println("Tracing disabled: OTEL_EXPORTER_OTLP_ENDPOINT is not set")
+	}
+
+	errorTracking, err := sentry.Configure()
+	if err != nil {
+		log.Fatalf("Failed to configure error tracking: %v", err)
+	}
+	if !errorTracking {
+		log.Println("Error tracking disabled: SENTRY_DSN is not set")
+	}
+
+	webhooks, err := hooks.ConfigureWebhooks()
+	if err != nil {
+		log.Fatalf("Failed to configure pipeline webhooks: %v", err)
+	}
+	if webhooks > 0 {
+		log.Printf("Pipeline webhooks enabled: %d", webhooks)
+	}
+
+	customLLM, err := service.ConfigureCustomLLM()
+	if err != nil {
+		log.Fatalf("Failed to configure the LLM: %v", err)
+	}
+	if customLLM {
+		log.Printf("Decks generated by %s at %s instead of Gemini", os.Getenv("LLM_MODEL"), os.Getenv("LLM_BASE_URL"))
+	}
+
+	if !service.ConfigureMalwareScanning() {
+		log.Println("Malware scanning of uploads disabled: CLAMAV_ADDR is not set")
+	}
+
+	sso, err := middleware.ConfigureSSO(service.ResolveSSOIdentity)
+	if err != nil {
+		log.Fatalf("Failed to configure SSO: %v", err)
+	}
+	if !sso {
+		log.Println("SSO disabled: OIDC_PROVIDERS is not set")
+	}
+
+	// One deployment serves the white-label customers listed in the tenants table
+	if service.MultiTenant() {
+		middleware.ConfigureTenants(service.TenantForHost, service.EnrollTenantUser)
+		log.Println("Multi-tenant mode enabled")
+	}
+
+	if chaos.Enabled() {
+		log.Println("WARNING: fault injection is enabled, do not use this configuration in production")
+	}
+
+	// Decks are stored through the REST API unless a direct database connection is configured
+	var deckRepository model.DeckRepository
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		postgres, err := repository.NewPostgres(context.Background(), databaseURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer postgres.Close()
+		deckRepository = postgres
+		if os.Getenv("SUPABASE_RLS") == "true" {
+			log.Println("WARNING: SUPABASE_RLS has no effect with DATABASE_URL, the direct connection bypasses row level security")
+		}
+	} else {
+		deckRepository, err = repository.NewPostgREST()
+		if err != nil {
+			log.Fatalf("Failed to initialize deck repository: %v", err)
+		}
+	}
+
 	progressTracker := progress.NewTracker()
 
-	pitchDeckService := service.NewPitchDeckService(storageService, progressTracker)
+	renderer := service.StartRenderer()
+	if err := service.HashViewerScripts(); err != nil {
+		log.Printf("Deck viewers run without the scripts of the renderer: %v", err)
+	}
+
+	if os.Getenv("SKIP_STARTUP_CHECKS") == "true" {
+		log.Println("Startup checks skipped: SKIP_STARTUP_CHECKS is set")
+	} else if err := service.SelfCheck(storageService); err != nil {
+		log.Fatalf("Startup check failed: %v", err)
+	}
+
+	pitchDeckService := service.NewPitchDeckService(storage.NewResilient(storageService), deckRepository, progressTracker)
 	pitchDeckHandler := handler.NewPitchDeckHandler(pitchDeckService, progressTracker)
 
+	notionClient, err := notion.NewClient()
+	if err != nil {
+		log.Printf("Notion integration disabled: %v", err)
+	}
+	integrationService := service.NewIntegrationService(pitchDeckService, notionClient)
+	integrationHandler := handler.NewIntegrationHandler(integrationService)
+
+	uploadService := service.NewUploadService(pitchDeckService)
+	uploadHandler := handler.NewUploadHandler(uploadService)
+
+	analyticsService := service.NewAnalyticsService(pitchDeckService)
+	analyticsHandler := handler.NewAnalyticsHandler(analyticsService)
+
+	projectService := service.NewProjectService(pitchDeckService)
+	projectHandler := handler.NewProjectHandler(projectService)
+
+	organizationService := service.NewOrganizationService(pitchDeckService)
+	organizationHandler := handler.NewOrganizationHandler(organizationService)
+
+	guestService := service.NewGuestService(pitchDeckService)
+	guestHandler := handler.NewGuestHandler(guestService)
+	guestService.Start()
+
+	draftService := service.NewDraftService(pitchDeckService)
+	draftHandler := handler.NewDraftHandler(draftService)
+
+	intakeService := service.NewIntakeService(pitchDeckService)
+	intakeHandler := handler.NewIntakeHandler(intakeService)
+
+	telegramClient, err := telegram.NewClient()
+	if err != nil {
+		log.Printf("Telegram bot disabled: %v", err)
+	}
+	telegramService := service.NewTelegramService(intakeService, pitchDeckService, telegramClient)
+	telegramHandler := handler.NewTelegramHandler(telegramService)
+
+	auditService := service.NewAuditService(pitchDeckService)
+	auditHandler := handler.NewAuditHandler(auditService)
+
+	planService := service.NewPlanService(pitchDeckService)
+	planHandler := handler.NewPlanHandler(planService)
+
+	notificationService := service.NewNotificationService()
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+
+	mailClient, err := mail.NewClient()
+	if err != nil {
+		log.Printf("Weekly digest emails disabled: %v", err)
+	}
+	digestService := service.NewDigestService(mailClient)
+	digestHandler := handler.NewDigestHandler(digestService)
+	digestService.Start()
+
+	accountService := service.NewAccountService(pitchDeckService)
+	accountHandler := handler.NewAccountHandler(accountService)
+
+	tenantService := service.NewTenantService()
+	tenantHandler := handler.NewTenantHandler(tenantService)
+
+	domainService := service.NewDomainService()
+	domainHandler := handler.NewDomainHandler(domainService)
+
+	// Resumes the generations interrupted by a shutdown or a crash
+	jobMonitor := service.NewJobMonitor(pitchDeckService)
+	jobMonitor.Start()
+
+	// Publishes and unpublishes the decks at the times scheduled by their owners
+	pitchDeckService.StartPublishing()
+
+	// Moves the files of the private decks rendered before the private buckets
+	pitchDeckService.MovePrivateFiles()
+
+	janitor := service.NewJanitor(pitchDeckService)
+	janitor.Start()
+
+	// Pauses new generations when the disk fills up
+	diskMonitor := service.NewDiskMonitor(janitor)
+	diskMonitor.Start()
+
+	storageGC := service.NewStorageGC(pitchDeckService)
+	storageGC.Start()
+
+	// Completes the uploads whose record could not be written
+	outboxReconciler := service.NewOutboxReconciler(pitchDeckService)
+	outboxReconciler.Start()
+
+	adminService := service.NewAdminService(pitchDeckService, storageGC)
+	adminHandler := handler.NewAdminHandler(adminService)
+
+	promptService := service.NewPromptService()
+	promptHandler := handler.NewPromptHandler(promptService)
+
+	graphQLHandler := handler.NewGraphQLHandler(&graph.Resolver{
+		DeckService:      pitchDeckService,
+		ProjectService:   projectService,
+		AnalyticsService: analyticsService,
+		UploadService:    uploadService,
+	})
+
 	// Setup router
 	r := gin.Default()
+	r.Use(middleware.Tracing())
+	r.Use(middleware.ErrorTracking())
+
+	// Guest decks are limited per client address, read from X-Forwarded-For only
+	// when the request comes through one of the TRUSTED_PROXIES (comma separated)
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		if err := r.SetTrustedProxies(strings.Split(strings.ReplaceAll(proxies, " ", ""), ",")); err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+	}
 
 	// Configure middleware
-	r.Use(middleware.CORS())
+	corsMiddleware, err := middleware.CORS()
+	if err != nil {
+		log.Fatalf("Failed to configure CORS: %v", err)
+	}
+	r.Use(corsMiddleware)
+
+	// Deck viewers run generated HTML, they may be isolated on their own domain
+	viewerMiddleware, err := middleware.ViewerOrigin(service.IsViewerDomain, "/d/", "/embed/", "/s/")
+	if err != nil {
+		log.Fatalf("Failed to configure the viewer origin: %v", err)
+	}
+	r.Use(viewerMiddleware)
+	r.Use(middleware.Tenant())
+	r.Use(middleware.Compress())
+	// IDs from the URL end up in database filters and file paths, only well formed
+	// ones get there
+	r.Use(middleware.ValidParams(handler.ParamRules()))
+	r.Use(middleware.Limits(map[string]middleware.RouteLimit{
+		"GET /api/progress/:deckId":                     {Timeout: middleware.NoTimeout},
+		"POST /api/pitch-decks/import":                  {Timeout: 2 * time.Minute},
+		"POST /api/pitch-decks/import/content":          {MaxBody: 4 << 20},
+		"POST /api/upload-image":                        {Timeout: 2 * time.Minute},
+		"PUT /api/uploads/:fileId":                      {Timeout: 2 * time.Minute},
+		"PATCH /api/uploads/resumable/:uploadId":        {Timeout: 2 * time.Minute, MaxBody: 32 << 20},
+		"POST /api/intake/sessions/:sessionId/messages": {Timeout: 2 * time.Minute},
+		"POST /api/intake/sessions/:sessionId/logo":     {Timeout: 2 * time.Minute},
+		"POST /api/pitch-decks/:deckId/export/notion":   {Timeout: 2 * time.Minute},
+		"GET /api/pitch-decks/:deckId/export.html":      {Timeout: 2 * time.Minute},
+		"GET /api/pitch-decks/:deckId/file/:kind":       {Timeout: 2 * time.Minute},
+		"GET /s/:token/download":                        {Timeout: 2 * time.Minute},
+		"POST /api/themes/:theme/preview":               {Timeout: 2 * time.Minute},
+		"GET /api/me/export":                            {Timeout: 5 * time.Minute},
+		"DELETE /api/me":                                {Timeout: 2 * time.Minute},
+	}))
 
 	// Setup routes
 	api := r.Group("/api")
 	{
 		api.POST("/pitch-decks", middleware.JWTAuth(), pitchDeckHandler.Create)
+		api.POST("/pitch-decks/demo", pitchDeckHandler.Demo)
+		api.POST("/pitch-decks/estimate", middleware.JWTAuth(), pitchDeckHandler.Estimate)
+		api.GET("/templates", pitchDeckHandler.Templates)
+		api.GET("/themes", pitchDeckHandler.Themes)
+		api.GET("/tenant", tenantHandler.Branding)
+		api.GET("/themes/:theme/preview.png", pitchDeckHandler.ThemePreview)
+		api.POST("/themes/:theme/preview", middleware.JWTAuth(), pitchDeckHandler.PreviewTheme)
+		api.POST("/pitch-decks/import", middleware.JWTAuth(), pitchDeckHandler.Import)
+		api.POST("/pitch-decks/import/content", middleware.JWTAuth(), pitchDeckHandler.ImportContent)
+		api.GET("/pitch-decks/:deckId/content", middleware.JWTAuth(), pitchDeckHandler.ExportContent)
+		api.GET("/pitch-decks/:deckId/export.html", middleware.JWTAuth(), pitchDeckHandler.ExportHTML)
+		api.GET("/pitch-decks/:deckId/file/:kind", middleware.JWTAuth(), pitchDeckHandler.File)
 		api.GET("/pitch-decks/:deckId", middleware.JWTAuth(), pitchDeckHandler.Get)
 		api.PATCH("/pitch-decks/:deckId/visibility", middleware.JWTAuth(), pitchDeckHandler.UpdateVisibility)
+		api.POST("/pitch-decks/:deckId/views", middleware.RateLimit(30, time.Minute), pitchDeckHandler.RecordView)
+		api.PUT("/pitch-decks/:deckId/slug", middleware.JWTAuth(), pitchDeckHandler.UpdateSlug)
+		api.PUT("/pitch-decks/:deckId/schedule", middleware.JWTAuth(), pitchDeckHandler.SchedulePublishing)
+		api.PUT("/pitch-decks/:deckId/expiry", middleware.JWTAuth(), pitchDeckHandler.SetExpiry)
+		api.POST("/pitch-decks/:deckId/retry", middleware.JWTAuth(), pitchDeckHandler.Retry)
+		api.PUT("/pitch-decks/:deckId/slides/order", middleware.JWTAuth(), pitchDeckHandler.ReorderSlides)
+		api.POST("/pitch-decks/:deckId/slides", middleware.JWTAuth(), pitchDeckHandler.InsertSlide)
+		api.DELETE("/pitch-decks/:deckId/slides/:slide", middleware.JWTAuth(), pitchDeckHandler.DeleteSlide)
+		api.GET("/pitch-decks/:deckId/versions", middleware.JWTAuth(), pitchDeckHandler.Versions)
+		api.GET("/pitch-decks/:deckId/versions/:a/diff/:b", middleware.JWTAuth(), pitchDeckHandler.DiffVersions)
+		api.POST("/pitch-decks/:deckId/feedback", middleware.JWTAuth(), pitchDeckHandler.SubmitFeedback)
+		api.GET("/pitch-decks/:deckId/audit-log", middleware.JWTAuth(), auditHandler.DeckAuditLog)
 		api.GET("/pitch-decks", middleware.JWTAuth(), pitchDeckHandler.ListUserDecks)
+		api.GET("/pitch-decks/search", middleware.JWTAuth(), pitchDeckHandler.Search)
+		api.GET("/pitch-decks/export.csv", middleware.JWTAuth(), pitchDeckHandler.Export)
+		api.GET("/pitch-decks/export.xlsx", middleware.JWTAuth(), pitchDeckHandler.Export)
 		api.POST("/upload-image", middleware.JWTAuth(), pitchDeckHandler.UploadImage)
-		api.GET("/progress/:deckId", pitchDeckHandler.GetProgress)
+		api.GET("/progress/:deckId", middleware.QueryToken(), middleware.JWTAuth(), pitchDeckHandler.GetProgress)
+		api.GET("/plan", middleware.JWTAuth(), planHandler.CurrentPlan)
+		api.GET("/me/storage", middleware.JWTAuth(), planHandler.StorageUsage)
+
+		api.GET("/notifications", middleware.JWTAuth(), notificationHandler.List)
+		api.POST("/notifications/read", middleware.JWTAuth(), notificationHandler.MarkAllRead)
+		api.POST("/notifications/:notificationId/read", middleware.JWTAuth(), notificationHandler.MarkRead)
+		api.GET("/me/export", middleware.JWTAuth(), accountHandler.Export)
+		api.DELETE("/me", middleware.JWTAuth(), accountHandler.Erase)
+		api.GET("/me/email-preferences", middleware.JWTAuth(), digestHandler.Preferences)
+		api.PUT("/me/email-preferences", middleware.JWTAuth(), digestHandler.UpdatePreferences)
+		api.GET("/me/domains", middleware.JWTAuth(), domainHandler.List)
+		api.POST("/me/domains", middleware.JWTAuth(), domainHandler.Add)
+		api.POST("/me/domains/:domain/verify", middleware.JWTAuth(), domainHandler.Verify)
+		api.DELETE("/me/domains/:domain", middleware.JWTAuth(), domainHandler.Remove)
+
+		api.POST("/intake/sessions", middleware.JWTAuth(), intakeHandler.StartSession)
+		api.GET("/intake/sessions/:sessionId", middleware.JWTAuth(), intakeHandler.GetSession)
+		api.POST("/intake/sessions/:sessionId/messages", middleware.JWTAuth(), intakeHandler.Reply)
+		api.POST("/intake/sessions/:sessionId/logo", middleware.JWTAuth(), intakeHandler.AttachLogo)
+		api.POST("/intake/sessions/:sessionId/generate", middleware.JWTAuth(), intakeHandler.Generate)
+
+		api.POST("/guest/pitch-decks", guestHandler.Create)
+		api.GET("/guest/pitch-decks/:deckId", guestHandler.Get)
+		api.POST("/guest/pitch-decks/:deckId/claim", middleware.JWTAuth(), guestHandler.Claim)
+
+		api.GET("/drafts", middleware.JWTAuth(), draftHandler.List)
+		api.POST("/drafts", middleware.JWTAuth(), draftHandler.Create)
+		api.GET("/drafts/:draftId", middleware.JWTAuth(), draftHandler.Get)
+		api.PUT("/drafts/:draftId", middleware.JWTAuth(), draftHandler.Save)
+		api.DELETE("/drafts/:draftId", middleware.JWTAuth(), draftHandler.Delete)
+		api.POST("/drafts/:draftId/generate", middleware.JWTAuth(), draftHandler.Generate)
+
+		api.GET("/graphql", middleware.JWTAuth(), graphQLHandler.Query)
+		api.POST("/graphql", middleware.JWTAuth(), graphQLHandler.Query)
+
+		api.GET("/projects", middleware.JWTAuth(), projectHandler.List)
+		api.POST("/projects", middleware.JWTAuth(), projectHandler.Create)
+		api.PUT("/projects/:projectId", middleware.JWTAuth(), projectHandler.Update)
+		api.DELETE("/projects/:projectId", middleware.JWTAuth(), projectHandler.Delete)
+		api.PUT("/pitch-decks/:deckId/project", middleware.JWTAuth(), projectHandler.AssignDeck)
+
+		api.GET("/organizations", middleware.JWTAuth(), organizationHandler.List)
+		api.POST("/organizations", middleware.JWTAuth(), organizationHandler.Create)
+		api.GET("/organizations/:orgId/members", middleware.JWTAuth(), organizationHandler.ListMembers)
+		api.PATCH("/organizations/:orgId/members/:userId", middleware.JWTAuth(), organizationHandler.UpdateMember)
+		api.DELETE("/organizations/:orgId/members/:userId", middleware.JWTAuth(), organizationHandler.RemoveMember)
+		api.POST("/organizations/:orgId/invites", middleware.JWTAuth(), organizationHandler.Invite)
+		api.PUT("/organizations/:orgId/branding", middleware.JWTAuth(), organizationHandler.UpdateViewerBranding)
+		api.POST("/organizations/:orgId/branding/verify", middleware.JWTAuth(), organizationHandler.VerifyCustomDomain)
+		api.POST("/invites/:token/accept", middleware.JWTAuth(), organizationHandler.AcceptInvite)
+		api.PUT("/pitch-decks/:deckId/organization", middleware.JWTAuth(), organizationHandler.ShareDeck)
+		api.PUT("/projects/:projectId/organization", middleware.JWTAuth(), organizationHandler.ShareProject)
+
+		api.GET("/uploads", middleware.JWTAuth(), uploadHandler.List)
+		api.OPTIONS("/uploads/resumable", uploadHandler.TusOptions)
+		api.POST("/uploads/resumable", middleware.JWTAuth(), uploadHandler.CreateResumable)
+		api.HEAD("/uploads/resumable/:uploadId", middleware.JWTAuth(), uploadHandler.ResumableOffset)
+		api.PATCH("/uploads/resumable/:uploadId", middleware.JWTAuth(), uploadHandler.AppendResumable)
+		api.DELETE("/uploads/resumable/:uploadId", middleware.JWTAuth(), uploadHandler.DeleteResumable)
+		api.PUT("/uploads/:fileId", middleware.JWTAuth(), uploadHandler.Replace)
+		api.POST("/uploads/:fileId/rerender", middleware.JWTAuth(), uploadHandler.RerenderDecks)
+
+		api.GET("/integrations/notion/authorize", middleware.JWTAuth(), integrationHandler.NotionAuthorizeURL)
+		api.POST("/integrations/notion/connect", middleware.JWTAuth(), integrationHandler.ConnectNotion)
+		api.POST("/pitch-decks/:deckId/export/notion", middleware.JWTAuth(), integrationHandler.ExportToNotion)
+		api.GET("/integrations/telegram/link", middleware.JWTAuth(), telegramHandler.LinkURL)
+		api.POST("/integrations/telegram/webhook", telegramHandler.Webhook)
+
+		api.POST("/pitch-decks/:deckId/links", middleware.JWTAuth(), analyticsHandler.CreateLink)
+		api.GET("/pitch-decks/:deckId/links", middleware.JWTAuth(), analyticsHandler.ListLinks)
+		api.DELETE("/pitch-decks/:deckId/links/:linkId", middleware.JWTAuth(), analyticsHandler.RevokeLink)
+		api.GET("/pitch-decks/:deckId/analytics", middleware.JWTAuth(), analyticsHandler.GetAnalytics)
+	}
+
+	// Operations and moderation, restricted to administrators
+	admin := api.Group("/admin", middleware.JWTAuth(), middleware.AdminOnly())
+	{
+		admin.GET("/pitch-decks", adminHandler.ListDecks)
+		admin.GET("/pitch-decks/:deckId", adminHandler.GetDeck)
+		admin.POST("/pitch-decks/:deckId/retry", adminHandler.RetryDeck)
+		admin.POST("/pitch-decks/:deckId/takedown", adminHandler.TakeDownDeck)
+		admin.DELETE("/pitch-decks/:deckId", adminHandler.DeleteDeck)
+		admin.GET("/failures", adminHandler.ListFailures)
+		admin.GET("/feedback", adminHandler.ListFeedback)
+		admin.POST("/storage/gc", adminHandler.RunStorageGC)
+		admin.POST("/search/reindex", adminHandler.ReindexSearch)
+		admin.GET("/prompts/:name/versions", promptHandler.ListVersions)
+		admin.POST("/prompts/:name/versions", promptHandler.CreateVersion)
+		admin.GET("/prompts/:name/versions/:version", promptHandler.GetVersion)
+		admin.POST("/prompts/:name/versions/:version/publish", promptHandler.PublishVersion)
+		admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+	}
+
+	// Open format of deck content, for other tools to validate against
+	r.GET("/schema/pitch-deck-content/v1.json", pitchDeckHandler.ContentSchema)
+
+	// Viewer of public decks, served on the custom domains of their owners too
+	r.GET("/d/:deck", pitchDeckHandler.View)
+
+	// Embeddable viewer of public decks
+	r.GET("/embed/:deckId", pitchDeckHandler.Embed)
+	r.GET("/oembed", pitchDeckHandler.OEmbed)
+
+	// Public share links, tracked per viewer
+	share := r.Group("/s")
+	{
+		share.GET("/:token", analyticsHandler.View)
+		share.POST("/:token/events", analyticsHandler.RecordEvent)
+		share.GET("/:token/download", analyticsHandler.Download)
 	}
 
 	// Start server
@@ -55,7 +469,98 @@ func main() {
 		port = "8080"
 	}
 
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Clients sending their headers slowly are disconnected, the route limits apply
+	// once they are read
+	srv := &http.Server{Addr: ":" + port, Handler: r, ReadHeaderTimeout: 10 * time.Second}
+
+	// Custom domains point at the server directly, which gets their certificates from
+	// Let's Encrypt, cached in AUTOCERT_DIR. The HTTP server answers the challenges.
+	var tlsSrv *http.Server
+	if certDir := os.Getenv("AUTOCERT_DIR"); certDir != "" {
+		certManager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: service.HostPolicy,
+			Cache:      autocert.DirCache(certDir),
+			Email:      os.Getenv("ACME_EMAIL"),
+		}
+		srv.Handler = certManager.HTTPHandler(r)
+
+		tlsPort := os.Getenv("TLS_PORT")
+		if tlsPort == "" {
+			tlsPort = "443"
+		}
+		tlsSrv = &http.Server{Addr: ":" + tlsPort, Handler: r, TLSConfig: certManager.TLSConfig(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := tlsSrv.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("Failed to start TLS server: %v", err)
+			}
+		}()
+	} else {
+		log.Println("TLS for custom domains disabled: AUTOCERT_DIR is not set")
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownTimeout, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT"))
+	if err != nil || shutdownTimeout <= 0 {
+		shutdownTimeout = 25 * time.Second
+	}
+	log.Printf("Shutting down, waiting up to %s for running generations", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Generations are drained first, their progress streams keep connections open
+	pitchDeckService.Shutdown(shutdownCtx)
+
+	// Leave a moment to the other requests even when the generations took all the time
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelHTTP()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		log.Printf("Failed to shut down server: %v", err)
+	}
+	if tlsSrv != nil {
+		if err := tlsSrv.Shutdown(httpCtx); err != nil {
+			log.Printf("Failed to shut down TLS server: %v", err)
+		}
+	}
+	renderer.Stop()
+
+	// The spans and errors of the last requests and generations are sent before exiting
+	traceCtx, cancelTraces := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelTraces()
+	tracing.Shutdown(traceCtx)
+	sentry.Flush(traceCtx)
+	log.Println("Server stopped")
+}
+
+// initDeployment prepares a new deployment: it migrates the database and creates the
+// storage buckets
+func initDeployment() error {
+	if err := runMigrations("up"); err != nil {
+		return err
+	}
+	storageService, err := storage.NewSupabaseStorage()
+	if err != nil {
+		return err
+	}
+	return service.ProvisionBuckets(storageService)
+}
+
+// runMigrations applies a migrate command to the database of DATABASE_URL, the REST
+// API cannot change the schema
+func runMigrations(command string) error {
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return fmt.Errorf("DATABASE_URL is required to run migrations")
 	}
+	return migrations.Run(context.Background(), databaseURL, command)
 }
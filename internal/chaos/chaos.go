@@ -0,0 +1,86 @@
+// Package chaos injects failures at configurable rates so that the retry,
+// partial failure and progress reporting paths can be verified in staging.
+// It is disabled unless CHAOS_ENABLED is true.
+package chaos
+
+import (
+	"errors"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Faults that can be injected, each with the variable holding its rate (0 to 1)
+const (
+	LLMTimeout   = "llm_timeout"
+	StorageError = "storage_error"
+	RenderCrash  = "render_crash"
+)
+
+var rateVariables = map[string]string{
+	LLMTimeout:   "CHAOS_LLM_TIMEOUT_RATE",
+	StorageError: "CHAOS_STORAGE_ERROR_RATE",
+	RenderCrash:  "CHAOS_RENDER_CRASH_RATE",
+}
+
+// ErrInjected is wrapped by every injected failure so it can be told apart in logs
+var ErrInjected = errors.New("injected fault")
+
+var (
+	loadOnce   sync.Once
+	rates      map[string]float64
+	llmTimeout time.Duration
+)
+
+// load reads the configuration on first use, after the .env file was loaded
+func load() {
+	loadOnce.Do(func() {
+		if os.Getenv("CHAOS_ENABLED") != "true" {
+			return
+		}
+
+		rates = make(map[string]float64)
+		for fault, variable := range rateVariables {
+			rate, err := strconv.ParseFloat(os.Getenv(variable), 64)
+			if err != nil || rate <= 0 {
+				continue
+			}
+			rates[fault] = min(rate, 1)
+		}
+
+		// Simulated timeouts wait before failing, like a real one would
+		llmTimeout, _ = time.ParseDuration(os.Getenv("CHAOS_LLM_TIMEOUT_AFTER"))
+	})
+}
+
+// Enabled reports whether fault injection is configured, and logs the rates in use
+func Enabled() bool {
+	load()
+	if len(rates) == 0 {
+		return false
+	}
+	for fault, rate := range rates {
+		log.Printf("Fault injection enabled: %s at %.0f%%", fault, rate*100)
+	}
+	return true
+}
+
+// Inject reports whether the fault must be injected for the current call
+func Inject(fault string) bool {
+	load()
+	rate, ok := rates[fault]
+	if !ok || rand.Float64() >= rate {
+		return false
+	}
+	log.Printf("Injecting fault %s", fault)
+	return true
+}
+
+// LLMTimeoutDelay is how long a simulated LLM timeout waits before failing
+func LLMTimeoutDelay() time.Duration {
+	load()
+	return llmTimeout
+}
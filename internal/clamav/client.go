@@ -0,0 +1,107 @@
+package clamav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout = 2 * time.Minute
+	// Bytes sent per INSTREAM chunk, below the StreamMaxLength of clamd
+	chunkSize = 64 << 10
+)
+
+// Client scans files with a clamd daemon, streaming them over TCP
+type Client struct {
+	addr    string
+	timeout time.Duration
+}
+
+// Result is the verdict of clamd on a file
+type Result struct {
+	Infected bool
+	// Name of the signature the file matched, e.g. "Eicar-Signature"
+	Signature string
+}
+
+// NewClient configures the client from CLAMAV_ADDR (e.g. "clamav:3310") and
+// CLAMAV_TIMEOUT
+func NewClient() (*Client, error) {
+	addr := os.Getenv("CLAMAV_ADDR")
+	if addr == "" {
+		return nil, fmt.Errorf("CLAMAV_ADDR is not set")
+	}
+	timeout, err := time.ParseDuration(os.Getenv("CLAMAV_TIMEOUT"))
+	if err != nil || timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Client{addr: addr, timeout: timeout}, nil
+}
+
+// ScanFile scans a file on disk
+func (c *Client) ScanFile(path string) (*Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file to scan: %w", err)
+	}
+	defer file.Close()
+	return c.Scan(file)
+}
+
+// Scan streams content to clamd with the INSTREAM command and returns its verdict
+func (c *Client) Scan(content io.Reader) (*Result, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, readErr := content.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return nil, fmt.Errorf("failed to send to clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read file to scan: %w", readErr)
+		}
+	}
+	// A chunk of length zero ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseReply reads "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+func parseReply(reply string) (*Result, error) {
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	}
+	return nil, fmt.Errorf("clamd failed to scan: %s", reply)
+}
@@ -0,0 +1,102 @@
+// Package envelope encrypts data with AES-256-GCM into text that names the key it was
+// sealed with, so it can be kept in files, text and JSON columns alike
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of the keys, in bytes
+const KeySize = 32
+
+const prefix = "enc:v1:"
+
+// NewKey generates a random key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with key, identified by keyID in the result
+func Seal(keyID string, key, plaintext []byte) (string, error) {
+	if strings.Contains(keyID, ":") {
+		return "", fmt.Errorf("invalid key ID %q", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The key ID is authenticated, a sealed value cannot be passed off as another key's
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(keyID))
+	return prefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// IsSealed tells whether text was produced by Seal, other text is stored in clear
+func IsSealed(text string) bool {
+	return strings.HasPrefix(text, prefix)
+}
+
+// KeyID returns the ID of the key text was sealed with
+func KeyID(text string) (string, error) {
+	keyID, _, err := split(text)
+	return keyID, err
+}
+
+// Open decrypts text sealed with key
+func Open(key []byte, text string) ([]byte, error) {
+	keyID, payload, err := split(text)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed data: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed data: too short")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+func split(text string) (keyID, payload string, err error) {
+	rest, ok := strings.CutPrefix(text, prefix)
+	if !ok {
+		return "", "", fmt.Errorf("data is not sealed")
+	}
+	keyID, payload, ok = strings.Cut(rest, ":")
+	if !ok {
+		return "", "", fmt.Errorf("invalid sealed data: missing key ID")
+	}
+	return keyID, payload, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("keys must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,52 @@
+package graph
+
+//go:generate go tool gqlgen generate
+
+import (
+	"context"
+	"fmt"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Resolver exposes the services of the REST API to GraphQL queries
+type Resolver struct {
+	DeckService      model.PitchDeckService
+	ProjectService   model.ProjectService
+	AnalyticsService model.AnalyticsService
+	UploadService    model.UploadService
+}
+
+type contextKey struct{}
+
+type baseURLKey struct{}
+
+// WithUserID returns a context carrying the authenticated user the queries are scoped to
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// WithBaseURL returns a context carrying the address the API is served from, which
+// the files of private decks are served under
+func WithBaseURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, baseURLKey{}, baseURL)
+}
+
+func userIDFrom(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value(contextKey{}).(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("%w: not authenticated", model.ErrForbidden)
+	}
+	return userID, nil
+}
+
+// withFileProxy replaces the object URLs of the files of private decks by the
+// endpoint serving them
+func withFileProxy(ctx context.Context, decks ...model.PitchDeckInfo) []model.PitchDeckInfo {
+	baseURL, _ := ctx.Value(baseURLKey{}).(string)
+	proxied := make([]model.PitchDeckInfo, len(decks))
+	for i, deck := range decks {
+		proxied[i] = deck.WithFileProxy(baseURL)
+	}
+	return proxied
+}
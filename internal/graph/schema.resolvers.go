@@ -0,0 +1,145 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.78
+
+import (
+	"context"
+	"pitch-deck-generator/internal/model"
+)
+
+// Project is the resolver for the project field.
+func (r *deckResolver) Project(ctx context.Context, obj *model.PitchDeckInfo) (*model.Project, error) {
+	if obj.ProjectID == "" {
+		return nil, nil
+	}
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ProjectService.GetProject(obj.ProjectID, userID)
+}
+
+// ShareLinks is the resolver for the shareLinks field.
+func (r *deckResolver) ShareLinks(ctx context.Context, obj *model.PitchDeckInfo) ([]model.ShareLink, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AnalyticsService.ListShareLinks(obj.ID, userID)
+}
+
+// Analytics is the resolver for the analytics field.
+func (r *deckResolver) Analytics(ctx context.Context, obj *model.PitchDeckInfo) (*model.DeckAnalytics, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.AnalyticsService.GetAnalytics(obj.ID, userID)
+}
+
+// RecentViews is the resolver for the recentViews field.
+func (r *deckResolver) RecentViews(ctx context.Context, obj *model.PitchDeckInfo, limit *int) ([]model.DeckView, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n := 5
+	if limit != nil && *limit > 0 {
+		n = min(*limit, 100)
+	}
+	return r.AnalyticsService.RecentViews(obj.ID, userID, n)
+}
+
+// Versions is the resolver for the versions field.
+func (r *deckResolver) Versions(ctx context.Context, obj *model.PitchDeckInfo) ([]model.DeckVersion, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.DeckService.Versions(obj.ID, userID)
+}
+
+// LatestVersion is the resolver for the latestVersion field.
+func (r *deckResolver) LatestVersion(ctx context.Context, obj *model.PitchDeckInfo) (*model.DeckVersion, error) {
+	versions, err := r.Versions(ctx, obj)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[0], nil
+}
+
+// Decks is the resolver for the decks field.
+func (r *projectResolver) Decks(ctx context.Context, obj *model.Project) ([]model.PitchDeckInfo, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	decks, err := r.DeckService.ListUserDecks(ctx, userID, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	return withFileProxy(ctx, decks...), nil
+}
+
+// Decks is the resolver for the decks field.
+func (r *queryResolver) Decks(ctx context.Context, projectID *string) ([]model.PitchDeckInfo, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	project := ""
+	if projectID != nil {
+		project = *projectID
+	}
+	decks, err := r.DeckService.ListUserDecks(ctx, userID, project)
+	if err != nil {
+		return nil, err
+	}
+	return withFileProxy(ctx, decks...), nil
+}
+
+// Deck is the resolver for the deck field.
+func (r *queryResolver) Deck(ctx context.Context, id string) (*model.PitchDeckInfo, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deck, err := r.DeckService.GetForUser(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &withFileProxy(ctx, *deck)[0], nil
+}
+
+// Projects is the resolver for the projects field.
+func (r *queryResolver) Projects(ctx context.Context) ([]model.Project, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.ProjectService.ListProjects(userID)
+}
+
+// Uploads is the resolver for the uploads field.
+func (r *queryResolver) Uploads(ctx context.Context) ([]model.UserFile, error) {
+	userID, err := userIDFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.UploadService.ListUploads(userID)
+}
+
+// Deck returns DeckResolver implementation.
+func (r *Resolver) Deck() DeckResolver { return &deckResolver{r} }
+
+// Project returns ProjectResolver implementation.
+func (r *Resolver) Project() ProjectResolver { return &projectResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type deckResolver struct{ *Resolver }
+type projectResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
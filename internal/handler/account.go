@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	service model.AccountService
+}
+
+func NewAccountHandler(service model.AccountService) *AccountHandler {
+	return &AccountHandler{
+		service: service,
+	}
+}
+
+// Export downloads a ZIP of all the data of the user
+func (h *AccountHandler) Export(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	data, err := h.service.Export(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="pitchtree-export-%s.zip"`, time.Now().Format("2006-01-02")))
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// Erase deletes the account of the user with all their data. It cannot be undone.
+func (h *AccountHandler) Erase(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.Erase(userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
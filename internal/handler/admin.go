@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	service model.AdminService
+}
+
+func NewAdminHandler(service model.AdminService) *AdminHandler {
+	return &AdminHandler{
+		service: service,
+	}
+}
+
+type moderationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListDecks lists all decks, filtered with ?status=failed to inspect failed generations
+func (h *AdminHandler) ListDecks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	decks, err := h.service.ListDecks(c.Query("status"), limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"decks": decks,
+	})
+}
+
+func (h *AdminHandler) GetDeck(c *gin.Context) {
+	deck, events, err := h.service.GetDeck(c.Param("deckId"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deck":   deck,
+		"events": events,
+	})
+}
+
+func (h *AdminHandler) RetryDeck(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.RetryDeck(c.Param("deckId"), userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck rendering restarted",
+	})
+}
+
+// ListFailures lists the failed generations waiting for a retry, with ?all=true
+// including those already retried
+func (h *AdminHandler) ListFailures(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	failures, err := h.service.ListFailures(c.Query("all") == "true", limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"failures": failures,
+	})
+}
+
+// ListFeedback lists the latest ratings of the decks and the average rating of each
+// prompt version, to compare them
+func (h *AdminHandler) ListFeedback(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	offset, _ := strconv.Atoi(c.Query("offset"))
+
+	feedback, stats, err := h.service.ListFeedback(limit, offset)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feedback":       feedback,
+		"promptVersions": stats,
+	})
+}
+
+func (h *AdminHandler) DeleteDeck(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req moderationRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.service.DeleteDeck(c.Param("deckId"), userID.(string), req.Reason); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck deleted successfully",
+	})
+}
+
+func (h *AdminHandler) TakeDownDeck(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req moderationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.TakeDownDeck(c.Param("deckId"), userID.(string), req.Reason); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck taken down",
+	})
+}
+
+// ReindexSearch starts indexing the content of every rendered deck for search
+func (h *AdminHandler) ReindexSearch(c *gin.Context) {
+	if err := h.service.ReindexSearch(); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Search reindex started",
+	})
+}
+
+// RunStorageGC runs the storage reconciliation and returns the orphans found
+func (h *AdminHandler) RunStorageGC(c *gin.Context) {
+	report, err := h.service.RunStorageGC()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	service model.AnalyticsService
+}
+
+func NewAnalyticsHandler(service model.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		service: service,
+	}
+}
+
+func (h *AnalyticsHandler) CreateLink(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Label     string     `json:"label"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	// The label and expiry are optional, an empty body is accepted
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	link, err := h.service.CreateShareLink(deckID, userID.(string), req.Label, req.ExpiresAt)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *AnalyticsHandler) ListLinks(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	links, err := h.service.ListShareLinks(deckID, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"links": links,
+	})
+}
+
+// RevokeLink stops a share link working, its analytics are kept
+func (h *AnalyticsHandler) RevokeLink(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.RevokeShareLink(c.Param("deckId"), c.Param("linkId"), userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AnalyticsHandler) GetAnalytics(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	analytics, err := h.service.GetAnalytics(deckID, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, analytics)
+}
+
+// View serves the instrumented deck viewer of a share link
+func (h *AnalyticsHandler) View(c *gin.Context) {
+	html, err := h.service.ViewerHTML(c.Param("token"), c.Request.Host)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Share links are opened directly or framed by the app
+	setViewerHeaders(c, strings.TrimSpace("'self' "+appOrigin()))
+	// Revalidated on every open, so a revoked link stops working
+	respondCachedData(c, time.Time{}, "private, no-cache", "text/html; charset=utf-8", []byte(html))
+}
+
+// RecordEvent receives the events sent by the viewer with sendBeacon
+func (h *AnalyticsHandler) RecordEvent(c *gin.Context) {
+	var event model.ViewEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RecordEvent(c.Param("token"), event); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Download serves the PDF of a share link. Interrupted downloads resume with range
+// requests, which are not counted as new downloads.
+func (h *AnalyticsHandler) Download(c *gin.Context) {
+	resumed := c.GetHeader("Range") != "" && !strings.HasPrefix(c.GetHeader("Range"), "bytes=0-")
+	download, err := h.service.DownloadPDF(c.Param("token"), c.Query("sid"), resumed)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/pdf")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, download.Filename))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", contentETag(download.Content))
+	// Sets Accept-Ranges and Content-Length, and answers range and conditional requests
+	http.ServeContent(c.Writer, c.Request, download.Filename, download.ModTime, bytes.NewReader(download.Content))
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	service model.AuditService
+}
+
+func NewAuditHandler(service model.AuditService) *AuditHandler {
+	return &AuditHandler{
+		service: service,
+	}
+}
+
+// DeckAuditLog lists the lifecycle events of a deck, newest first
+func (h *AuditHandler) DeckAuditLog(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	events, err := h.service.DeckAuditLog(c.Param("deckId"), userID.(string), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+	})
+}
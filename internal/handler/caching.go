@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastModified returns the most recent update time of the decks
+func lastModified(decks ...model.PitchDeckInfo) time.Time {
+	var latest time.Time
+	for _, deck := range decks {
+		modified := deck.CreatedAt
+		if deck.UpdatedAt != nil {
+			modified = *deck.UpdatedAt
+		}
+		if modified.After(latest) {
+			latest = modified
+		}
+	}
+	return latest
+}
+
+// respondCached writes a JSON body with ETag and Last-Modified headers, and answers
+// 304 Not Modified when the client already holds the same representation
+func respondCached(c *gin.Context, modified time.Time, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+
+	respondCachedData(c, modified, "private, no-cache", "application/json; charset=utf-8", data)
+}
+
+// respondCachedData writes a body with an ETag from its content and Last-Modified
+// when modified is known, or 304 Not Modified when the client holds the same content
+func respondCachedData(c *gin.Context, modified time.Time, cacheControl, contentType string, data []byte) {
+	etag := contentETag(data)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl)
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if match := c.GetHeader("If-None-Match"); match != "" {
+		if etagMatches(match, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if since := c.GetHeader("If-Modified-Since"); since != "" && !modified.IsZero() {
+		if t, err := http.ParseTime(since); err == nil && !modified.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// contentETag returns a strong entity tag derived from a body
+func contentETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks an If-None-Match header, which may list several (weak) tags
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
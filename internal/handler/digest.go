@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type DigestHandler struct {
+	service model.DigestService
+}
+
+func NewDigestHandler(service model.DigestService) *DigestHandler {
+	return &DigestHandler{
+		service: service,
+	}
+}
+
+// Preferences returns the emails the user agreed to receive
+func (h *DigestHandler) Preferences(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	prefs, err := h.service.Preferences(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences opts the user in or out of the weekly digest, sent to the email
+// address of their account
+func (h *DigestHandler) UpdatePreferences(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		WeeklyDigest bool `json:"weeklyDigest"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, _ := c.Get("claims")
+	mapClaims, _ := claims.(jwt.MapClaims)
+	email, _ := mapClaims["email"].(string)
+
+	prefs, err := h.service.UpdatePreferences(userID.(string), email, req.WeeklyDigest)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
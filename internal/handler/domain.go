@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DomainHandler struct {
+	service model.DomainService
+}
+
+func NewDomainHandler(service model.DomainService) *DomainHandler {
+	return &DomainHandler{
+		service: service,
+	}
+}
+
+// List returns the custom domains of the user with the record verifying each
+func (h *DomainHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	domains, err := h.service.List(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"domains": domains,
+	})
+}
+
+// Add registers a domain of the user, to verify with the TXT record returned
+func (h *DomainHandler) Add(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.service.Add(userID.(string), req.Domain)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain)
+}
+
+// Verify checks the TXT record of a domain of the user
+func (h *DomainHandler) Verify(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	domain, err := h.service.Verify(userID.(string), c.Param("domain"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+func (h *DomainHandler) Remove(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.Remove(userID.(string), c.Param("domain")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Domain removed successfully",
+	})
+}
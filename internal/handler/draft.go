@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DraftHandler struct {
+	service model.DraftService
+}
+
+func NewDraftHandler(service model.DraftService) *DraftHandler {
+	return &DraftHandler{
+		service: service,
+	}
+}
+
+type draftRequest struct {
+	Data model.PitchDeckData `json:"data"`
+	Step int                 `json:"step"`
+}
+
+func (h *DraftHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req draftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	draft, err := h.service.CreateDraft(userID.(string), req.Data, req.Step)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, draft)
+}
+
+func (h *DraftHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	drafts, err := h.service.ListDrafts(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"drafts": drafts,
+	})
+}
+
+func (h *DraftHandler) Get(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	draft, err := h.service.GetDraft(c.Param("draftId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+// Save replaces the answers of a draft, called by the wizard as it autosaves
+func (h *DraftHandler) Save(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req draftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	draft, err := h.service.SaveDraft(c.Param("draftId"), userID.(string), req.Data, req.Step)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+func (h *DraftHandler) Delete(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.DeleteDraft(c.Param("draftId"), userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Draft deleted successfully",
+	})
+}
+
+// Generate starts the generation of a deck from a draft
+func (h *DraftHandler) Generate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	deckInfo, err := h.service.Generate(userContext(c), c.Param("draftId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pitch deck generation started",
+		"deckId":  deckInfo.ID,
+	})
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"pitch-deck-generator/internal/graph"
+
+	gqlhandler "github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+)
+
+// Rejects queries nesting too many lists, such as decks > project > decks > analytics
+const maxQueryComplexity = 500
+
+type GraphQLHandler struct {
+	server *gqlhandler.Server
+}
+
+func NewGraphQLHandler(resolver *graph.Resolver) *GraphQLHandler {
+	server := gqlhandler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	server.AddTransport(transport.GET{})
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(extension.FixedComplexityLimit(maxQueryComplexity))
+
+	return &GraphQLHandler{
+		server: server,
+	}
+}
+
+// Query executes a GraphQL query on behalf of the authenticated user
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	ctx := graph.WithBaseURL(graph.WithUserID(userContext(c), userID.(string)), requestBaseURL(c))
+	h.server.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header holding the claim token of a guest deck, kept out of URLs and their logs
+const claimTokenHeader = "X-Claim-Token"
+
+type GuestHandler struct {
+	service model.GuestService
+}
+
+func NewGuestHandler(service model.GuestService) *GuestHandler {
+	return &GuestHandler{
+		service: service,
+	}
+}
+
+// Create starts the generation of a deck without an account. The claim token in the
+// response is needed to follow the deck and to attach it to an account later.
+func (h *GuestHandler) Create(c *gin.Context) {
+	var data model.PitchDeckData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	guest, err := h.service.Create(data, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Pitch deck generation started",
+		"deckId":     guest.DeckID,
+		"claimToken": guest.ClaimToken,
+		"expiresAt":  guest.ExpiresAt,
+	})
+}
+
+// Get returns a guest deck to the holder of its claim token
+func (h *GuestHandler) Get(c *gin.Context) {
+	deckInfo, err := h.service.GetDeck(c.Param("deckId"), c.GetHeader(claimTokenHeader))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deckInfo)
+}
+
+// Claim attaches a guest deck to the account of the signed in user
+func (h *GuestHandler) Claim(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		ClaimToken string `json:"claimToken" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deckInfo, err := h.service.Claim(c.Param("deckId"), req.ClaimToken, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deckInfo)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IntakeHandler struct {
+	service model.IntakeService
+}
+
+func NewIntakeHandler(service model.IntakeService) *IntakeHandler {
+	return &IntakeHandler{
+		service: service,
+	}
+}
+
+// StartSession opens a conversational intake and returns its first question
+func (h *IntakeHandler) StartSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	session, err := h.service.StartSession(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+func (h *IntakeHandler) GetSession(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	session, err := h.service.GetSession(c.Param("sessionId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// Reply sends the answer of the founder and returns the session with the next question
+func (h *IntakeHandler) Reply(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.service.Reply(c.Param("sessionId"), userID.(string), req.Message)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// AttachLogo sets the company logo from an image uploaded with /upload-image
+func (h *IntakeHandler) AttachLogo(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.service.AttachLogo(c.Param("sessionId"), userID.(string), req.URL)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (h *IntakeHandler) Generate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	deckInfo, err := h.service.Generate(c.Param("sessionId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pitch deck generation started",
+		"deckId":  deckInfo.ID,
+	})
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IntegrationHandler struct {
+	service model.IntegrationService
+}
+
+func NewIntegrationHandler(service model.IntegrationService) *IntegrationHandler {
+	return &IntegrationHandler{
+		service: service,
+	}
+}
+
+func (h *IntegrationHandler) NotionAuthorizeURL(c *gin.Context) {
+	url, err := h.service.NotionAuthorizeURL(c.Query("state"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url": url,
+	})
+}
+
+func (h *IntegrationHandler) ConnectNotion(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ConnectNotion(userID.(string), req.Code); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notion workspace connected",
+	})
+}
+
+func (h *IntegrationHandler) ExportToNotion(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var opts model.NotionExportOptions
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	pageURL, err := h.service.ExportToNotion(deckID, userID.(string), opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck exported to Notion",
+		"url":     pageURL,
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	service model.NotificationService
+}
+
+func NewNotificationHandler(service model.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		service: service,
+	}
+}
+
+// List returns the latest notifications of the user, only the unread ones with
+// ?unread=true, and the number of unread notifications for the bell
+func (h *NotificationHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	unreadOnly := c.Query("unread") == "true"
+
+	feed, err := h.service.List(userID.(string), unreadOnly, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, feed)
+}
+
+// MarkRead marks a notification as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.MarkRead(userID.(string), c.Param("notificationId")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MarkAllRead marks every notification of the user as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.MarkAllRead(userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
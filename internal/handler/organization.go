@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationHandler struct {
+	service model.OrganizationService
+}
+
+func NewOrganizationHandler(service model.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		service: service,
+	}
+}
+
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.service.CreateOrganization(userID.(string), req.Name)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+func (h *OrganizationHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	orgs, err := h.service.ListOrganizations(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organizations": orgs,
+	})
+}
+
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	orgID := c.Param("orgId")
+	userID, _ := c.Get("userID")
+
+	members, err := h.service.ListMembers(orgID, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+	})
+}
+
+func (h *OrganizationHandler) Invite(c *gin.Context) {
+	orgID := c.Param("orgId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	invite, err := h.service.InviteMember(orgID, userID.(string), req.Email, req.Role)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+func (h *OrganizationHandler) AcceptInvite(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	member, err := h.service.AcceptInvite(c.Param("token"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
+}
+
+func (h *OrganizationHandler) UpdateMember(c *gin.Context) {
+	orgID := c.Param("orgId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.UpdateMemberRole(orgID, userID.(string), c.Param("userId"), req.Role); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member role updated successfully",
+	})
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	orgID := c.Param("orgId")
+	userID, _ := c.Get("userID")
+
+	if err := h.service.RemoveMember(orgID, userID.(string), c.Param("userId")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Member removed successfully",
+	})
+}
+
+// UpdateViewerBranding sets the logo, colors and custom domain of the public viewer
+// of the decks shared with an organization
+func (h *OrganizationHandler) UpdateViewerBranding(c *gin.Context) {
+	orgID := c.Param("orgId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		model.ViewerBranding
+		CustomDomain string `json:"customDomain"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org, err := h.service.UpdateViewerBranding(orgID, userID.(string), req.ViewerBranding, req.CustomDomain)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// VerifyCustomDomain checks the TXT record of the custom domain of an organization,
+// whose viewers are served on it once verified
+func (h *OrganizationHandler) VerifyCustomDomain(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	org, err := h.service.VerifyCustomDomain(c.Param("orgId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+type shareRequest struct {
+	// OrgID is the organization to share with, empty to stop sharing
+	OrgID string `json:"orgId"`
+}
+
+func (h *OrganizationHandler) ShareDeck(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req shareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ShareDeck(deckID, req.OrgID, userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck sharing updated successfully",
+	})
+}
+
+func (h *OrganizationHandler) ShareProject(c *gin.Context) {
+	projectID := c.Param("projectId")
+	userID, _ := c.Get("userID")
+
+	var req shareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ShareProject(projectID, req.OrgID, userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project sharing updated successfully",
+	})
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"pitch-deck-generator/internal/middleware"
+	"pitch-deck-generator/internal/service"
+)
+
+// ParamRules returns the forms of the route parameters, checked by
+// middleware.ValidParams before the handlers run
+func ParamRules() map[string]func(string) bool {
+	return map[string]func(string) bool{
+		"deckId":         middleware.IsUUID,
+		"deck":           service.IsDeckRef,
+		"draftId":        middleware.IsUUID,
+		"fileId":         middleware.IsUUID,
+		"kind":           service.IsDeckFileKind,
+		"linkId":         middleware.IsUUID,
+		"notificationId": middleware.IsUUID,
+		"orgId":          middleware.IsUUID,
+		"projectId":      middleware.IsUUID,
+		"sessionId":      middleware.IsUUID,
+		"uploadId":       middleware.IsUUID,
+		"userId":         middleware.IsUUID,
+		"token":          service.IsShareToken,
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"pitch-deck-generator/internal/middleware"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testDeckID = "0b6f3c8e-4a5d-4f1e-9c7b-2d8e1f0a3b4c"
+
+// fakeDecks serves the viewer of any deck, recording the decks asked for. The
+// methods not overridden panic, no test reaches them.
+type fakeDecks struct {
+	model.PitchDeckService
+	requested []string
+}
+
+func (f *fakeDecks) PublicHTML(deckID, host string) (string, error) {
+	f.requested = append(f.requested, deckID)
+	return "<html><body></body></html>", nil
+}
+
+func (f *fakeDecks) EmbedHTML(deckID, host string) (string, error) {
+	f.requested = append(f.requested, deckID)
+	return "<html><body></body></html>", nil
+}
+
+func (f *fakeDecks) RecordView(deckID, clientIP string) error {
+	return nil
+}
+
+func (f *fakeDecks) DeckFile(deckID, userID, kind string) (*model.FileDownload, error) {
+	f.requested = append(f.requested, deckID+"/"+kind)
+	return &model.FileDownload{Filename: "deck." + kind, Content: []byte("%PDF")}, nil
+}
+
+type fakeAnalytics struct {
+	model.AnalyticsService
+	requested []string
+}
+
+func (f *fakeAnalytics) ViewerHTML(token, host string) (string, error) {
+	f.requested = append(f.requested, token)
+	return "<html><body></body></html>", nil
+}
+
+// newTestRouter routes the static and file routes as the server does, the file route
+// behind a stand-in of the authentication
+func newTestRouter(decks *fakeDecks, analytics *fakeAnalytics) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ValidParams(ParamRules()))
+
+	deckHandler := NewPitchDeckHandler(decks, nil)
+	analyticsHandler := NewAnalyticsHandler(analytics)
+	authenticated := func(c *gin.Context) { c.Set("userID", testDeckID) }
+
+	r.GET("/d/:deck", deckHandler.View)
+	r.GET("/embed/:deckId", deckHandler.Embed)
+	r.GET("/s/:token", analyticsHandler.View)
+	r.GET("/api/pitch-decks/:deckId/file/:kind", authenticated, deckHandler.File)
+	return r
+}
+
+func TestTraversalIsNotFound(t *testing.T) {
+	paths := []string{
+		"/d/..",
+		"/d/%2e%2e",
+		"/d/..%2F..%2Fetc%2Fpasswd",
+		"/d/%2E%2E%2Foutputs",
+		"/embed/..",
+		"/embed/%2e%2e",
+		"/embed/..%2F" + testDeckID,
+		"/s/..",
+		"/s/%2e%2e",
+		"/s/..%2F..%2Fetc%2Fpasswd",
+		"/api/pitch-decks/../file/pdf",
+		"/api/pitch-decks/%2e%2e/file/pdf",
+		"/api/pitch-decks/..%2F" + testDeckID + "/file/pdf",
+		"/api/pitch-decks/" + testDeckID + "/file/..",
+		"/api/pitch-decks/" + testDeckID + "/file/%2e%2e",
+		"/api/pitch-decks/" + testDeckID + "/file/..%2F..%2Fetc%2Fpasswd",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			decks, analytics := &fakeDecks{}, &fakeAnalytics{}
+			w := httptest.NewRecorder()
+			newTestRouter(decks, analytics).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("GET %s answered %d, want %d", path, w.Code, http.StatusNotFound)
+			}
+			if len(decks.requested) > 0 || len(analytics.requested) > 0 {
+				t.Errorf("GET %s reached the service with %v %v", path, decks.requested, analytics.requested)
+			}
+		})
+	}
+}
+
+func TestValidParamsAreServed(t *testing.T) {
+	paths := []string{
+		"/d/" + testDeckID,
+		"/d/acme-series-a",
+		"/embed/" + testDeckID,
+		"/s/AAECAwQFBgcICQoLDA0ODw",
+		"/api/pitch-decks/" + testDeckID + "/file/pdf",
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			decks, analytics := &fakeDecks{}, &fakeAnalytics{}
+			w := httptest.NewRecorder()
+			newTestRouter(decks, analytics).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+			if w.Code != http.StatusOK {
+				t.Errorf("GET %s answered %d, want %d", path, w.Code, http.StatusOK)
+			}
+		})
+	}
+}
@@ -1,16 +1,27 @@
 package handler
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"pitch-deck-generator/internal/model"
 	"pitch-deck-generator/internal/progress"
+	"pitch-deck-generator/internal/repository"
+	"pitch-deck-generator/internal/sentry"
+	"pitch-deck-generator/internal/tracing"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PitchDeckHandler struct {
@@ -38,9 +49,9 @@ func (h *PitchDeckHandler) Create(c *gin.Context) {
 		return
 	}
 
-	deckInfo, err := h.service.Create(data, userID.(string))
+	deckInfo, err := h.service.Create(userContext(c), data, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -50,15 +61,35 @@ func (h *PitchDeckHandler) Create(c *gin.Context) {
 	})
 }
 
+// Estimate returns the expected tokens, cost and credits of generating a deck from
+// the answers, so users can shorten long inputs before starting the generation
+func (h *PitchDeckHandler) Estimate(c *gin.Context) {
+	var data model.PitchDeckData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	estimate, err := h.service.Estimate(data, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
+
 func (h *PitchDeckHandler) Get(c *gin.Context) {
 	deckID := c.Param("deckId")
-	deckInfo, err := h.service.Get(deckID)
+	userID, _ := c.Get("userID")
+	deckInfo, err := h.service.GetForUser(deckID, userID.(string))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Deck not found"})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, deckInfo)
+	respondCached(c, lastModified(*deckInfo), deckInfo.WithFileProxy(requestBaseURL(c)))
 }
 
 func (h *PitchDeckHandler) UpdateVisibility(c *gin.Context) {
@@ -72,10 +103,15 @@ func (h *PitchDeckHandler) UpdateVisibility(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	err := h.service.UpdateVisibility(deckID, userID.(string), req.IsPublic)
+	err = h.service.UpdateVisibility(userContext(c), deckID, userID.(string), req.IsPublic, revision)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -84,19 +120,468 @@ func (h *PitchDeckHandler) UpdateVisibility(c *gin.Context) {
 	})
 }
 
-func (h *PitchDeckHandler) ListUserDecks(c *gin.Context) {
+// UpdateSlug sets the custom slug the deck is shared under
+func (h *PitchDeckHandler) UpdateSlug(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Slug string `json:"slug"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	slug, err := h.service.UpdateSlug(deckID, userID.(string), req.Slug, revision)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"slug": slug,
+	})
+}
+
+// SchedulePublishing sets when the deck becomes public and private again, a missing
+// time clears it from the schedule
+func (h *PitchDeckHandler) SchedulePublishing(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		PublishAt   *time.Time `json:"publishAt"`
+		UnpublishAt *time.Time `json:"unpublishAt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := h.service.SchedulePublishing(deckID, userID.(string), req.PublishAt, req.UnpublishAt, revision); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"publishAt":   req.PublishAt,
+		"unpublishAt": req.UnpublishAt,
+	})
+}
+
+// SetExpiry sets the time after which the deck is no longer shared, a missing time
+// removes the expiry
+func (h *PitchDeckHandler) SetExpiry(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := h.service.SetExpiry(deckID, userID.(string), req.ExpiresAt, revision); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"expiresAt": req.ExpiresAt,
+	})
+}
+
+// Retry runs a failed generation again from the answers or content of the deck
+func (h *PitchDeckHandler) Retry(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := h.service.Retry(c.Param("deckId"), userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck generation restarted",
+	})
+}
+
+// ReorderSlides puts the slides of a deck in a new order and renders it again
+func (h *PitchDeckHandler) ReorderSlides(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Order []int `json:"order" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := h.service.ReorderSlides(c.Param("deckId"), userID.(string), req.Order, revision); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Slides reordered, the deck is being rendered",
+	})
+}
+
+// DeleteSlide removes a slide, by its number, and renders the deck again
+func (h *PitchDeckHandler) DeleteSlide(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	number, err := strconv.Atoi(c.Param("slide"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid slide number"})
+		return
+	}
+
+	revision, err := requestRevision(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if err := h.service.DeleteSlide(c.Param("deckId"), userID.(string), number, revision); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Slide deleted, the deck is being rendered",
+	})
+}
+
+// InsertSlide adds a slide made from a template at a position, the end of the deck
+// by default, and renders the deck again
+func (h *PitchDeckHandler) InsertSlide(c *gin.Context) {
 	userID, _ := c.Get("userID")
-	decks, err := h.service.ListUserDecks(userID.(string))
+
+	var req struct {
+		Position int    `json:"position"`
+		Template string `json:"template"`
+		Title    string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	revision, err := requestRevision(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
+		return
+	}
+
+	if err := h.service.InsertSlide(c.Param("deckId"), userID.(string), req.Position, req.Template, req.Title, revision); err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"decks": decks,
+		"message": "Slide inserted, the deck is being rendered",
 	})
 }
 
+// Versions lists the versions of a deck, one per render
+func (h *PitchDeckHandler) Versions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	versions, err := h.service.Versions(c.Param("deckId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": versions,
+	})
+}
+
+// DiffVersions compares the slides of two versions of a deck
+func (h *PitchDeckHandler) DiffVersions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	from, errFrom := strconv.Atoi(c.Param("a"))
+	to, errTo := strconv.Atoi(c.Param("b"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version number"})
+		return
+	}
+
+	diff, err := h.service.DiffVersions(c.Param("deckId"), userID.(string), from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// SubmitFeedback rates a generated deck from 1 to 5, or one of its slides when slide
+// is set, with an optional comment
+func (h *PitchDeckHandler) SubmitFeedback(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		Rating  int    `json:"rating" binding:"required"`
+		Comment string `json:"comment"`
+		Slide   *int   `json:"slide"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	feedback, err := h.service.SubmitFeedback(c.Param("deckId"), userID.(string), req.Rating, req.Comment, req.Slide)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, feedback)
+}
+
+// Export downloads the deck inventory of the user, the format is taken from the
+// extension of the route (export.csv or export.xlsx)
+func (h *PitchDeckHandler) Export(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	format := strings.TrimPrefix(filepath.Ext(c.FullPath()), ".")
+
+	data, err := h.service.ExportDecks(userID.(string), format)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="pitch-decks-%s.%s"`, time.Now().Format("2006-01-02"), format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// View serves the viewer of a public deck by ID or slug, on the custom domain of its
+// owner too
+func (h *PitchDeckHandler) View(c *gin.Context) {
+	html, err := h.service.PublicHTML(c.Param("deck"), c.Request.Host)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.recordView(c, c.Param("deck"))
+
+	setViewerHeaders(c, strings.TrimSpace("'self' "+appOrigin()))
+	// Revalidated on every view, so a deck made private stops being served
+	respondCachedData(c, time.Time{}, "public, no-cache", "text/html; charset=utf-8", []byte(html))
+}
+
+// Embed serves the iframe viewer of a public deck
+func (h *PitchDeckHandler) Embed(c *gin.Context) {
+	html, err := h.service.EmbedHTML(c.Param("deckId"), c.Request.Host)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	h.recordView(c, c.Param("deckId"))
+
+	// Allow the viewer to be framed by any site
+	setViewerHeaders(c, "*")
+	respondCachedData(c, time.Time{}, "public, no-cache", "text/html; charset=utf-8", []byte(html))
+}
+
+// recordView counts a view of a deck served by its viewer, in the background so the
+// viewer is not held up by the database. A client is counted once per deck within
+// a while, reloads and revalidations are not.
+func (h *PitchDeckHandler) recordView(c *gin.Context, deckID string) {
+	clientIP := c.ClientIP()
+	go func() {
+		if err := h.service.RecordView(deckID, clientIP); err != nil {
+			log.Printf("Failed to record a view of deck %s: %v", deckID, err)
+		}
+	}()
+}
+
+// OEmbed answers oEmbed consumers (Notion, Medium...) for public deck URLs
+func (h *PitchDeckHandler) OEmbed(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Only the json format is supported"})
+		return
+	}
+
+	maxWidth, _ := strconv.Atoi(c.Query("maxwidth"))
+	maxHeight, _ := strconv.Atoi(c.Query("maxheight"))
+
+	embed, err := h.service.OEmbed(c.Query("url"), requestBaseURL(c), maxWidth, maxHeight)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, embed)
+}
+
+// requestBaseURL returns the scheme and host the API was reached on, behind a proxy too
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// RecordView counts a view of a shared deck shown by a client, e.g. an app rendering
+// it itself. The viewers served by View and Embed count their views already.
+func (h *PitchDeckHandler) RecordView(c *gin.Context) {
+	if err := h.service.RecordView(c.Param("deckId"), c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+const (
+	defaultDeckPageSize = 50
+	maxDeckPageSize     = 200
+)
+
+// ListUserDecks lists a page of the decks of the user. The projectId query parameter
+// filters on a project ("none" for decks without project), status, visibility
+// (public or private), createdAfter and createdBefore (RFC 3339 or YYYY-MM-DD) filter
+// further. sort (created_at or name), order, limit and offset page through them, and
+// groupBy=project groups the page.
+func (h *PitchDeckHandler) ListUserDecks(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	opts := model.DeckListOptions{
+		ProjectID:  c.Query("projectId"),
+		Status:     c.Query("status"),
+		Visibility: c.Query("visibility"),
+		Sort:       c.Query("sort"),
+		Order:      c.Query("order"),
+		Limit:      defaultDeckPageSize,
+	}
+
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil || opts.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+		opts.Limit = min(opts.Limit, maxDeckPageSize)
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if opts.Offset, err = strconv.Atoi(offset); err != nil || opts.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a positive number"})
+			return
+		}
+	}
+	if opts.CreatedAfter, err = parseDateQuery(c, "createdAfter"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.CreatedBefore, err = parseDateQuery(c, "createdBefore"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.service.ListDecks(userContext(c), userID.(string), opts)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	proxied := *page
+	proxied.Decks = withFileProxy(c, page.Decks)
+	page = &proxied
+
+	if c.Query("groupBy") == "project" {
+		respondCached(c, lastModified(page.Decks...), gin.H{
+			"groups": groupDecksByProject(page.Decks),
+			"total":  page.Total,
+			"limit":  page.Limit,
+			"offset": page.Offset,
+		})
+		return
+	}
+
+	respondCached(c, lastModified(page.Decks...), page)
+}
+
+// parseDateQuery parses a query parameter holding a timestamp or a date, zero when absent
+func parseDateQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", name)
+}
+
+// withFileProxy replaces the object URLs of the files of private decks by the endpoint
+// serving them
+func withFileProxy(c *gin.Context, decks []model.PitchDeckInfo) []model.PitchDeckInfo {
+	proxied := make([]model.PitchDeckInfo, len(decks))
+	for i, deck := range decks {
+		proxied[i] = deck.WithFileProxy(requestBaseURL(c))
+	}
+	return proxied
+}
+
+// groupDecksByProject groups decks by project ID, keeping the order of first appearance.
+// Decks without project are grouped under an empty project ID.
+func groupDecksByProject(decks []model.PitchDeckInfo) []gin.H {
+	groups := []gin.H{}
+	index := make(map[string]int)
+	for _, deck := range decks {
+		i, ok := index[deck.ProjectID]
+		if !ok {
+			i = len(groups)
+			index[deck.ProjectID] = i
+			groups = append(groups, gin.H{"projectId": deck.ProjectID, "decks": []model.PitchDeckInfo{}})
+		}
+		groups[i]["decks"] = append(groups[i]["decks"].([]model.PitchDeckInfo), deck)
+	}
+	return groups
+}
+
 func (h *PitchDeckHandler) UploadImage(c *gin.Context) {
 	// Get the file from the request
 
@@ -124,10 +609,16 @@ func (h *PitchDeckHandler) UploadImage(c *gin.Context) {
 	}
 
 	// Upload to storage
-	url, err := h.service.UploadImage(filePath)
+	userID, _ := c.Get("userID")
+	url, err := h.service.UploadImage(filePath, file.Filename, userID.(string))
 	if err != nil {
 		// Clean up local file
 		os.Remove(filePath)
+		if errors.Is(err, model.ErrQuotaExceeded) || errors.Is(err, model.ErrInvalidInput) || errors.Is(err, model.ErrUnavailable) {
+			respondError(c, err)
+			return
+		}
+		reportError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
 		return
 	}
@@ -140,19 +631,225 @@ func (h *PitchDeckHandler) UploadImage(c *gin.Context) {
 	})
 }
 
-func (h *PitchDeckHandler) GetProgress(c *gin.Context) {
-	deckID := c.Param("deckId")
-	token := c.Query("token") // Get token from query parameter
+func (h *PitchDeckHandler) Import(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	file, err := c.FormFile("deck")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No deck uploaded"})
+		return
+	}
+
+	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+		return
+	}
+
+	// Save the deck locally, the service removes it once the text is extracted
+	filePath := filepath.Join("uploads", uuid.New().String()+filepath.Ext(file.Filename))
+	if err := c.SaveUploadedFile(file, filePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	deckInfo, err := h.service.Import(userContext(c), filePath, file.Filename, c.PostForm("theme"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pitch deck import started",
+		"deckId":  deckInfo.ID,
+	})
+}
+
+// Search finds the decks of the user matching the q query parameter, by name and content
+func (h *PitchDeckHandler) Search(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	results, err := h.service.Search(userID.(string), c.Query("q"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	for i := range results {
+		results[i].Deck = results[i].Deck.WithFileProxy(requestBaseURL(c))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+	})
+}
+
+// ExportContent returns the content of a deck in the open content format
+func (h *PitchDeckHandler) ExportContent(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	content, err := h.service.ExportContent(c.Param("deckId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	content.Schema = requestBaseURL(c) + "/schema/pitch-deck-content/v1.json"
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, c.Param("deckId")))
+	c.JSON(http.StatusOK, content)
+}
+
+// ExportHTML downloads the deck as a single HTML file that works offline
+func (h *PitchDeckHandler) ExportHTML(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	data, filename, err := h.service.StandaloneHTML(c.Param("deckId"), userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+}
+
+// File serves the PDF, HTML or markdown file of a deck to the users it is shared
+// with, fetched from the storage so private decks never expose their object URLs
+func (h *PitchDeckHandler) File(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	kind := c.Param("kind")
+
+	file, err := h.service.DeckFile(c.Param("deckId"), userID.(string), kind)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	switch kind {
+	case "html":
+		setViewerHeaders(c, strings.TrimSpace("'self' "+appOrigin()))
+	case "md":
+		c.Header("Content-Type", "text/markdown; charset=utf-8")
+	}
+	disposition := "inline"
+	if c.Query("download") == "true" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`%s; filename="%s"`, disposition, file.Filename))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", contentETag(file.Content))
+	// Sets Accept-Ranges and Content-Length, and answers range and conditional requests
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.ModTime, bytes.NewReader(file.Content))
+}
+
+// ImportContent creates a deck from a document in the open content format
+func (h *PitchDeckHandler) ImportContent(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var content model.DeckContent
+	if err := c.ShouldBindJSON(&content); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deckInfo, err := h.service.ImportContent(userContext(c), content, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Pitch deck import started",
+		"deckId":  deckInfo.ID,
+	})
+}
+
+// ContentSchema publishes the JSON schema of the open content format
+func (h *PitchDeckHandler) ContentSchema(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "application/schema+json", h.service.ContentSchema())
+}
+
+// Templates lists the industry templates, the frontend sets the industry of a deck
+// to one of their IDs to use it
+func (h *PitchDeckHandler) Templates(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, gin.H{
+		"templates": h.service.IndustryTemplates(),
+	})
+}
+
+// Themes lists the themes with their colors and the URL of their preview
+func (h *PitchDeckHandler) Themes(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.JSON(http.StatusOK, gin.H{
+		"themes": h.service.Themes(requestBaseURL(c), c.GetString("tenantID")),
+	})
+}
 
-	// Validate token and get userID
-	userID, err := validateToken(token)
+// ThemePreview returns the preview of a theme, its example deck rendered to PNG
+func (h *PitchDeckHandler) ThemePreview(c *gin.Context) {
+	image, err := h.service.ThemePreview(c.Param("theme"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		respondError(c, err)
 		return
 	}
 
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/png", image)
+}
+
+// PreviewTheme renders the first slides of a deck of the user, or of the answers of
+// the form before a deck is generated, in a theme
+func (h *PitchDeckHandler) PreviewTheme(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		DeckID string               `json:"deckId"`
+		Data   *model.PitchDeckData `json:"data"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	image, err := h.service.PreviewTheme(c.Param("theme"), userID.(string), req.DeckID, req.Data)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", image)
+}
+
+// Demo returns the sample deck in a theme, rendered once without calling the LLM.
+// It is processing the first time, requesting it again returns its current state.
+func (h *PitchDeckHandler) Demo(c *gin.Context) {
+	var req struct {
+		Theme string `json:"theme"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deckInfo, err := h.service.Demo(req.Theme)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deckInfo)
+}
+
+// GetProgress streams the progress of a generation as server-sent events. EventSource
+// cannot set headers, the token comes in the query, see middleware.QueryToken.
+func (h *PitchDeckHandler) GetProgress(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
 	// Get progress channel
-	ch, exists := h.progress.GetChannel(deckID, userID)
+	ch, exists := h.progress.GetChannel(deckID, userID.(string))
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No progress found for this deck"})
 		return
@@ -170,27 +867,73 @@ func (h *PitchDeckHandler) GetProgress(c *gin.Context) {
 	}
 }
 
-// Add this helper function
-func validateToken(tokenString string) (string, error) {
-	jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
+// userContext returns the request context carrying the token of the user, so the
+// records read and written for them are subject to row level security when enabled
+func userContext(c *gin.Context) context.Context {
+	return repository.WithAccessToken(c.Request.Context(), c.GetString("accessToken"))
+}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(jwtSecret), nil
-	})
+// respondError maps service errors to the matching HTTP status code
+func respondError(c *gin.Context, err error) {
+	// A change made from an earlier revision gets the deck as it is now, to merge it
+	var stale *model.StaleError
+	if errors.As(err, &stale) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": stale.Current})
+		return
+	}
 
-	if err != nil || !token.Valid {
-		return "", fmt.Errorf("invalid token")
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, model.ErrInvalidInput):
+		status = http.StatusBadRequest
+	case errors.Is(err, model.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, model.ErrConflict):
+		status = http.StatusConflict
+	case errors.Is(err, model.ErrUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, model.ErrQuotaExceeded):
+		status = http.StatusPaymentRequired
+	case errors.Is(err, model.ErrExpired):
+		status = http.StatusGone
+	}
+	if status == http.StatusInternalServerError {
+		reportError(c, err)
 	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		if userID, ok := claims["sub"].(string); ok {
-			return userID, nil
-		}
+// reportError reports an unexpected error of a request to the error tracker, with
+// its route, its user and its trace
+func reportError(c *gin.Context, err error) {
+	if !sentry.Enabled() {
+		return
+	}
+	tags := map[string]string{"route": c.FullPath()}
+	if traceID := tracing.TraceID(trace.SpanFromContext(c.Request.Context())); traceID != "" {
+		tags["trace_id"] = traceID
+	}
+	sentry.CaptureError(err, sentry.Context{
+		UserID:  c.GetString("userID"),
+		Tags:    tags,
+		Request: c.Request,
+	})
+}
+
+// requestRevision returns the revision of the deck a change is made from, sent in the
+// If-Match header as the revision field of the deck, 0 when the client sent none
+func requestRevision(c *gin.Context) (int, error) {
+	match := c.GetHeader("If-Match")
+	if match == "" {
+		return 0, nil
+	}
+	revision, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(match, "W/"), `"`))
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("%w: If-Match must be the revision of the deck", model.ErrInvalidInput)
 	}
-	return "", fmt.Errorf("user ID not found in token")
+	return revision, nil
 }
 
 // Add other handler methods...
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"pitch-deck-generator/internal/middleware"
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/progress"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testOwnerID = "5e2a9d41-7c3b-4b8f-a1d6-0f9e8c7b6a55"
+
+// ownedDecks holds one deck of testOwnerID, answering for the other users as the
+// service does for a user outside of its organization
+type ownedDecks struct {
+	model.PitchDeckService
+}
+
+func (ownedDecks) GetForUser(deckID, userID string) (*model.PitchDeckInfo, error) {
+	if deckID != testDeckID {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if userID != testOwnerID {
+		return nil, fmt.Errorf("%w: belongs to another user", model.ErrForbidden)
+	}
+	return &model.PitchDeckInfo{ID: deckID, UserID: testOwnerID}, nil
+}
+
+func TestGetDeckIsReservedToItsMembers(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		deckID string
+		want   int
+	}{
+		{"owner", testOwnerID, testDeckID, http.StatusOK},
+		{"non-member", "9c1d2e3f-4a5b-4c6d-8e7f-0a1b2c3d4e5f", testDeckID, http.StatusForbidden},
+		{"unknown deck", testOwnerID, "1f2e3d4c-5b6a-4978-8a9b-0c1d2e3f4a5b", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			authenticated := func(c *gin.Context) { c.Set("userID", tt.userID) }
+			r.GET("/api/pitch-decks/:deckId", authenticated, NewPitchDeckHandler(ownedDecks{}, nil).Get)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/pitch-decks/"+tt.deckID, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("GET as %s answered %d, want %d", tt.name, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+// newIdentityProvider serves the discovery document and signing key of an OIDC
+// provider over TLS, trusted by the default transport for the test
+func newIdentityProvider(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = srv.Client().Transport
+	t.Cleanup(func() { http.DefaultTransport = transport })
+	return srv
+}
+
+func TestProgressAcceptsSSOTokens(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := newIdentityProvider(t, key)
+	t.Setenv("SUPABASE_JWT_SECRET", "test-secret")
+	t.Setenv("OIDC_PROVIDERS", fmt.Sprintf(`[{"issuer": %q, "audience": "pitchtree"}]`, provider.URL))
+	resolve := func(issuer, subject, email string, linkEmail bool) (string, error) {
+		return testOwnerID, nil
+	}
+	if _, err := middleware.ConfigureSSO(resolve); err != nil {
+		t.Fatal(err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": provider.URL,
+		"aud": "pitchtree",
+		"sub": "okta-user",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test"
+	ssoToken, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"sso token", ssoToken, http.StatusOK},
+		{"forged token", ssoToken[:strings.LastIndex(ssoToken, ".")] + ".c2lnbmF0dXJl", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := progress.NewTracker()
+			ch := tracker.CreateChannel(testDeckID, testOwnerID)
+			ch <- `{"status":"completed"}`
+			close(ch)
+
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.GET("/api/progress/:deckId", middleware.QueryToken(), middleware.JWTAuth(), NewPitchDeckHandler(nil, tracker).GetProgress)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/progress/"+testDeckID+"?token="+tt.token, nil))
+
+			if w.Code != tt.want {
+				t.Errorf("GET with %s answered %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
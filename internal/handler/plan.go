@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PlanHandler struct {
+	service model.PlanService
+}
+
+func NewPlanHandler(service model.PlanService) *PlanHandler {
+	return &PlanHandler{
+		service: service,
+	}
+}
+
+// CurrentPlan returns the plan of the user, its limits and the allowance left
+func (h *PlanHandler) CurrentPlan(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	usage, err := h.service.CurrentPlan(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// StorageUsage returns the storage taken by the uploads of the user and what is
+// left of what their plan includes
+func (h *PlanHandler) StorageUsage(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	usage, err := h.service.StorageUsage(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProjectHandler struct {
+	service model.ProjectService
+}
+
+func NewProjectHandler(service model.ProjectService) *ProjectHandler {
+	return &ProjectHandler{
+		service: service,
+	}
+}
+
+type projectRequest struct {
+	Name            string         `json:"name"`
+	BrandKit        model.BrandKit `json:"brandKit"`
+	AudiencePersona string         `json:"audiencePersona"`
+}
+
+func (r projectRequest) project() model.Project {
+	return model.Project{
+		Name:            r.Name,
+		BrandKit:        r.BrandKit,
+		AudiencePersona: r.AudiencePersona,
+	}
+}
+
+func (h *ProjectHandler) Create(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req projectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.service.CreateProject(userID.(string), req.project())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func (h *ProjectHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	projects, err := h.service.ListProjects(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+	})
+}
+
+func (h *ProjectHandler) Update(c *gin.Context) {
+	projectID := c.Param("projectId")
+	userID, _ := c.Get("userID")
+
+	var req projectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, err := h.service.UpdateProject(projectID, userID.(string), req.project())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func (h *ProjectHandler) Delete(c *gin.Context) {
+	projectID := c.Param("projectId")
+	userID, _ := c.Get("userID")
+
+	if err := h.service.DeleteProject(projectID, userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project deleted successfully",
+	})
+}
+
+// AssignDeck moves a deck into a project, an empty projectId removes it from its project
+func (h *ProjectHandler) AssignDeck(c *gin.Context) {
+	deckID := c.Param("deckId")
+	userID, _ := c.Get("userID")
+
+	var req struct {
+		ProjectID string `json:"projectId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.AssignDeck(deckID, req.ProjectID, userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deck project updated successfully",
+	})
+}
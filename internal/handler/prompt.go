@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromptHandler struct {
+	service model.PromptService
+}
+
+func NewPromptHandler(service model.PromptService) *PromptHandler {
+	return &PromptHandler{
+		service: service,
+	}
+}
+
+type promptVersionRequest struct {
+	Template string `json:"template" binding:"required"`
+	Notes    string `json:"notes"`
+}
+
+// ListVersions lists the versions of a prompt and the one new generations use, null
+// while the template built into the server is used
+func (h *PromptHandler) ListVersions(c *gin.Context) {
+	versions, active, err := h.service.ListVersions(c.Param("name"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"versions": versions,
+		"active":   active,
+	})
+}
+
+func (h *PromptHandler) GetVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	prompt, err := h.service.GetVersion(c.Param("name"), version)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
+
+// CreateVersion saves an edited template as a new unpublished version
+func (h *PromptHandler) CreateVersion(c *gin.Context) {
+	var req promptVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	prompt, err := h.service.CreateVersion(c.Param("name"), req.Template, req.Notes, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, prompt)
+}
+
+// PublishVersion makes a version the one used by new generations
+func (h *PromptHandler) PublishVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	prompt, err := h.service.PublishVersion(c.Param("name"), version, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prompt)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Resumable uploads implement the core protocol of tus 1.0.0 with its creation,
+// expiration and termination extensions (https://tus.io/protocols/resumable-upload)
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+	// Content type of the chunks sent with PATCH
+	tusChunkType = "application/offset+octet-stream"
+)
+
+// TusOptions describes the protocol supported by the server
+func (h *UploadHandler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.Itoa(model.MaxResumableVideoSize))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateResumable starts an upload of Upload-Length bytes. The filename and filetype
+// keys of Upload-Metadata name the file and set its size limit.
+func (h *UploadHandler) CreateResumable(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length must be the size of the file in bytes"})
+		return
+	}
+	metadata := tusMetadata(c.GetHeader("Upload-Metadata"))
+
+	upload, err := h.service.CreateResumableUpload(userID.(string), length, metadata["filename"], metadata["filetype"])
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidInput) && length > 0 {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		}
+		respondError(c, err)
+		return
+	}
+
+	tusUploadHeaders(c, upload)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Status(http.StatusCreated)
+}
+
+// ResumableOffset answers how many bytes of an upload were received, for the client
+// to resume it. The URL of the file is set once it is stored.
+func (h *UploadHandler) ResumableOffset(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	upload, err := h.service.GetResumableUpload(c.Param("uploadId"), userID.(string))
+	if err != nil {
+		// Answers to HEAD have no body
+		respondTusError(c, err)
+		return
+	}
+
+	tusUploadHeaders(c, upload)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// AppendResumable receives a chunk of an upload, sent from the Upload-Offset already
+// received
+func (h *UploadHandler) AppendResumable(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if c.ContentType() != tusChunkType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Chunks must be sent as " + tusChunkType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset must be the number of bytes already uploaded"})
+		return
+	}
+
+	upload, err := h.service.AppendResumableUpload(c.Param("uploadId"), userID.(string), offset, c.Request.Body)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	tusUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteResumable abandons an upload
+func (h *UploadHandler) DeleteResumable(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := h.service.DeleteResumableUpload(c.Param("uploadId"), userID.(string)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// tusResumable checks that the client speaks the version of the protocol of the
+// server, every answer says which one it is
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported tus version, expected " + tusVersion})
+		return false
+	}
+	return true
+}
+
+func tusUploadHeaders(c *gin.Context, upload *model.ResumableUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.FileURL != "" {
+		c.Header("Upload-File-Url", upload.FileURL)
+	} else {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// respondTusError answers a HEAD request with the status of an error
+func respondTusError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, model.ErrNotFound), errors.Is(err, model.ErrForbidden):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrExpired):
+		status = http.StatusGone
+	}
+	if status == http.StatusInternalServerError {
+		reportError(c, err)
+	}
+	c.Status(status)
+}
+
+// tusMetadata decodes Upload-Metadata, comma separated keys each followed by a
+// base64 value
+func tusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(value)
+	}
+	return metadata
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/telegram"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TelegramHandler struct {
+	service model.TelegramService
+}
+
+func NewTelegramHandler(service model.TelegramService) *TelegramHandler {
+	return &TelegramHandler{
+		service: service,
+	}
+}
+
+// LinkURL returns the deep link that connects a Telegram chat to the user
+func (h *TelegramHandler) LinkURL(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	url, err := h.service.LinkURL(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url": url,
+	})
+}
+
+// Webhook receives the updates of the bot. Failures are only logged since
+// Telegram keeps redelivering an update until it is acknowledged.
+func (h *TelegramHandler) Webhook(c *gin.Context) {
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.service.HandleUpdate(c.GetHeader("X-Telegram-Bot-Api-Secret-Token"), update)
+	if errors.Is(err, model.ErrForbidden) {
+		respondError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to handle telegram update %d: %v", update.UpdateID, err)
+	}
+
+	c.Status(http.StatusOK)
+}
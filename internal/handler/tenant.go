@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TenantHandler struct {
+	service model.TenantService
+}
+
+func NewTenantHandler(service model.TenantService) *TenantHandler {
+	return &TenantHandler{
+		service: service,
+	}
+}
+
+// Branding returns the branding of the tenant served on the hostname of the request,
+// for white-label apps to brand their pages before users sign in
+func (h *TenantHandler) Branding(c *gin.Context) {
+	branding, err := h.service.Branding(c.GetString("tenantID"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, branding)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type UploadHandler struct {
+	service model.UploadService
+}
+
+func NewUploadHandler(service model.UploadService) *UploadHandler {
+	return &UploadHandler{
+		service: service,
+	}
+}
+
+func (h *UploadHandler) List(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	files, err := h.service.ListUploads(userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"uploads": files,
+	})
+}
+
+func (h *UploadHandler) Replace(c *gin.Context) {
+	fileID := c.Param("fileId")
+	userID, _ := c.Get("userID")
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+		return
+	}
+
+	filePath := filepath.Join("uploads", uuid.New().String()+filepath.Ext(file.Filename))
+	if err := c.SaveUploadedFile(file, filePath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+	defer os.Remove(filePath)
+
+	upload, affected, err := h.service.ReplaceUpload(fileID, userID.(string), filePath)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"upload":        upload,
+		"affectedDecks": affected,
+	})
+}
+
+func (h *UploadHandler) RerenderDecks(c *gin.Context) {
+	fileID := c.Param("fileId")
+	userID, _ := c.Get("userID")
+
+	queued, err := h.service.RerenderAffectedDecks(fileID, userID.(string))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Re-rendering started",
+		"deckIds": queued,
+	})
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/url"
+	"os"
+	"strings"
+
+	"pitch-deck-generator/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setViewerHeaders sets the Content-Security-Policy of a deck viewer page. Only the
+// scripts of the renderer and the viewer, hashed at startup, are allowed: scripts,
+// event handlers and javascript: URLs that would get past the sanitization of the
+// markdown do not run. frameAncestors lists the sites allowed to frame the page.
+func setViewerHeaders(c *gin.Context, frameAncestors string) {
+	scripts := "'none'"
+	if hashes := service.ViewerScriptHashes(); len(hashes) > 0 {
+		scripts = strings.Join(hashes, " ")
+	}
+
+	policy := []string{
+		"default-src 'none'",
+		"script-src " + scripts,
+		"style-src 'unsafe-inline' https:",
+		"img-src 'self' https: data: blob:",
+		"font-src https: data:",
+		"media-src 'self' https:",
+		// Players of the product demo videos
+		"frame-src https://www.youtube-nocookie.com https://www.loom.com",
+		"connect-src 'self'",
+		"base-uri 'none'",
+		"form-action 'none'",
+		"frame-ancestors " + frameAncestors,
+	}
+	c.Header("Content-Security-Policy", strings.Join(policy, "; "))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+}
+
+// appOrigin returns the origin of the frontend from APP_URL, empty when not set
+func appOrigin() string {
+	appURL, err := url.Parse(os.Getenv("APP_URL"))
+	if err != nil || appURL.Scheme == "" || appURL.Host == "" {
+		return ""
+	}
+	return appURL.Scheme + "://" + appURL.Host
+}
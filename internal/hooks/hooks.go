@@ -0,0 +1,140 @@
+// Package hooks runs extensions around the stages of the generation of a deck,
+// such as a compliance check of a company, without changing the pipeline. Hooks are
+// Go functions registered at startup, typically from the init function of a file
+// added to the build, or webhooks configured in PIPELINE_WEBHOOKS.
+//
+// A hook may change what it is given, the answers of the form or the markdown, and
+// fails the generation by returning an error.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Stage is a point of the pipeline hooks run at
+type Stage string
+
+const (
+	// Before the prompt is built, the answers of the form may be changed. Decks
+	// imported from a file have no form and skip it.
+	PrePrompt Stage = "pre-prompt"
+	// After the LLM answered, its markdown may be changed
+	PostLLM Stage = "post-llm"
+	// Before the markdown is rendered, it may be changed. Decks rendered again from
+	// their stored or edited markdown go through it too.
+	PreRender Stage = "pre-render"
+	// After the PDF and HTML are rendered and before they are stored, the files may
+	// be checked but not changed
+	PostRender Stage = "post-render"
+)
+
+var stages = map[Stage]bool{PrePrompt: true, PostLLM: true, PreRender: true, PostRender: true}
+
+var metrics = expvar.NewMap("hooks")
+
+// Payload is what the hooks of a stage are given, and may change
+type Payload struct {
+	Stage    Stage  `json:"stage"`
+	DeckID   string `json:"deckId"`
+	UserID   string `json:"userId"`
+	TenantID string `json:"tenantId,omitempty"`
+	// Answers of the form, at the pre-prompt stage
+	Data *model.PitchDeckData `json:"data,omitempty"`
+	// Markdown of the deck, from the post-LLM stage on
+	Markdown string `json:"markdown,omitempty"`
+	// Local paths of the rendered files by kind ("pdf", "html"), at the post-render
+	// stage. Webhooks are not given them.
+	Files map[string]string `json:"-"`
+}
+
+// Hook runs at a stage. Returning an error fails the generation, see Rejected.
+type Hook func(ctx context.Context, payload *Payload) error
+
+// RejectedError is the failure of a generation a hook did not let through
+type RejectedError struct {
+	Hook   string
+	Stage  Stage
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by %s at %s: %s", e.Hook, e.Stage, e.Reason)
+}
+
+// Rejected returns the error a hook fails a generation with, whose reason is shown
+// to the user
+func Rejected(reason string) error {
+	return &RejectedError{Reason: reason}
+}
+
+type registered struct {
+	name string
+	hook Hook
+	// Failures other than rejections are logged and ignored, the generation goes on
+	optional bool
+}
+
+var (
+	mu    sync.RWMutex
+	hooks = make(map[Stage][]registered)
+)
+
+// Register adds a hook to a stage, run after the hooks registered before it. It
+// panics on an unknown stage, hooks are registered at startup.
+func Register(stage Stage, name string, hook Hook) {
+	register(stage, registered{name: name, hook: hook})
+}
+
+func register(stage Stage, r registered) {
+	if !stages[stage] {
+		panic(fmt.Sprintf("hooks: unknown stage %q", stage))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	hooks[stage] = append(hooks[stage], r)
+}
+
+// Registered reports whether hooks run at a stage
+func Registered(stage Stage) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(hooks[stage]) > 0
+}
+
+// Run runs the hooks of a stage in turn on the payload, each given what the previous
+// one returned. The first failure stops the stage, as a *RejectedError when the hook
+// rejected the payload.
+func Run(ctx context.Context, stage Stage, payload *Payload) error {
+	mu.RLock()
+	stageHooks := hooks[stage]
+	mu.RUnlock()
+
+	payload.Stage = stage
+	for _, r := range stageHooks {
+		err := r.hook(ctx, payload)
+		if err == nil {
+			metrics.Add("runs", 1)
+			continue
+		}
+
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			metrics.Add("rejections", 1)
+			return &RejectedError{Hook: r.name, Stage: stage, Reason: rejected.Reason}
+		}
+		metrics.Add("failures", 1)
+		if r.optional {
+			log.Printf("Hook %s failed at %s on deck %s, ignored: %v", r.name, stage, payload.DeckID, err)
+			continue
+		}
+		return fmt.Errorf("hook %s failed at %s: %w", r.name, stage, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/tracing"
+)
+
+const (
+	defaultWebhookTimeout = 30 * time.Second
+	// Bytes of a webhook response read at most
+	maxWebhookResponse = 4 << 20
+)
+
+var webhookClient = &http.Client{Transport: tracing.Transport(nil)}
+
+// webhook is a hook run by a service of the operator, listed in PIPELINE_WEBHOOKS
+type webhook struct {
+	Name  string `json:"name"`
+	Stage Stage  `json:"stage"`
+	URL   string `json:"url"`
+	// Key of the HMAC-SHA256 signature of the requests, sent in X-Pitchtree-Signature
+	Secret   string `json:"secret"`
+	Timeout  string `json:"timeout"`
+	Optional bool   `json:"optional"`
+
+	timeout time.Duration
+}
+
+// webhookResponse is the answer of a webhook. Fields left out are not changed, a
+// reason rejects the payload.
+type webhookResponse struct {
+	Data     *model.PitchDeckData `json:"data"`
+	Markdown *string              `json:"markdown"`
+	Reject   string               `json:"reject"`
+}
+
+// ConfigureWebhooks registers the webhooks of PIPELINE_WEBHOOKS, a JSON list of
+// {"name", "stage", "url", "secret", "timeout", "optional"}. Each is sent the
+// payload of its stage as JSON in a POST, and answers with {"data"} (pre-prompt) or
+// {"markdown"} (post-LLM, pre-render) to change it, {"reject": "<reason>"} to fail
+// the generation, or an empty body to let it through. An optional webhook that
+// fails is ignored rather than failing the generation.
+func ConfigureWebhooks() (int, error) {
+	raw := os.Getenv("PIPELINE_WEBHOOKS")
+	if raw == "" {
+		return 0, nil
+	}
+	var webhooks []*webhook
+	if err := json.Unmarshal([]byte(raw), &webhooks); err != nil {
+		return 0, fmt.Errorf("invalid PIPELINE_WEBHOOKS: %w", err)
+	}
+
+	for _, w := range webhooks {
+		if w.Name == "" {
+			return 0, fmt.Errorf("invalid PIPELINE_WEBHOOKS: webhook for %s has no name", w.URL)
+		}
+		if !stages[w.Stage] {
+			return 0, fmt.Errorf("invalid PIPELINE_WEBHOOKS: unknown stage %q of %s", w.Stage, w.Name)
+		}
+		if !strings.HasPrefix(w.URL, "https://") && !strings.HasPrefix(w.URL, "http://") {
+			return 0, fmt.Errorf("invalid PIPELINE_WEBHOOKS: url of %s must be an http(s) URL", w.Name)
+		}
+		w.timeout = defaultWebhookTimeout
+		if w.Timeout != "" {
+			timeout, err := time.ParseDuration(w.Timeout)
+			if err != nil || timeout <= 0 {
+				return 0, fmt.Errorf("invalid PIPELINE_WEBHOOKS: timeout of %s must be a duration", w.Name)
+			}
+			w.timeout = timeout
+		}
+	}
+	for _, w := range webhooks {
+		register(w.Stage, registered{name: w.Name, hook: w.run, optional: w.Optional})
+	}
+	return len(webhooks), nil
+}
+
+func (w *webhook) run(ctx context.Context, payload *Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Pitchtree-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
+	}
+
+	var answer webhookResponse
+	if err := json.Unmarshal(respBody, &answer); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if answer.Reject != "" {
+		return Rejected(answer.Reject)
+	}
+	if answer.Data != nil && payload.Stage == PrePrompt {
+		payload.Data = answer.Data
+	}
+	if answer.Markdown != nil && (payload.Stage == PostLLM || payload.Stage == PreRender) {
+		payload.Markdown = *answer.Markdown
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultPort = "587"
+
+// Client sends emails through an SMTP server, with STARTTLS when the server offers it
+type Client struct {
+	addr string
+	auth smtp.Auth
+	from mail.Address
+}
+
+// Message is an email with a plain text body and an optional HTML alternative
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// NewClient configures the client from SMTP_HOST, SMTP_PORT, SMTP_USERNAME,
+// SMTP_PASSWORD and MAIL_FROM (e.g. "PitchTree <digest@pitchtree.app>")
+func NewClient() (*Client, error) {
+	host := os.Getenv("SMTP_HOST")
+	from := os.Getenv("MAIL_FROM")
+	if host == "" || from == "" {
+		return nil, fmt.Errorf("SMTP_HOST and MAIL_FROM are not set")
+	}
+
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = defaultPort
+	}
+
+	client := &Client{
+		addr: host + ":" + port,
+		from: *sender,
+	}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		client.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return client, nil
+}
+
+// Send delivers a message to one recipient
+func (c *Client) Send(msg Message) error {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	data, err := c.compose(*to, msg)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(c.addr, c.auth, c.from.Address, []string{to.Address}, data); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) compose(to mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", c.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), domain(c.from.Address)))
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "8bit")
+		buf.WriteString("\r\n")
+		buf.WriteString(crlf(msg.Text))
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose email: %w", err)
+		}
+		w.Write([]byte(crlf(part.body)))
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compose email: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func domain(address string) string {
+	if i := strings.LastIndex(address, "@"); i != -1 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
+
+// crlf ends the lines of a body with CRLF, as SMTP requires
+func crlf(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
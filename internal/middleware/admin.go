@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AdminOnly restricts a route to administrators: users whose token carries the
+// admin role in its app_metadata, which only the service role can set, or whose
+// ID is listed in ADMIN_USER_IDS. It must run after JWTAuth.
+func AdminOnly() gin.HandlerFunc {
+	allowlist := make(map[string]bool)
+	for _, id := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			allowlist[id] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		claims, _ := c.Get("claims")
+		mapClaims, _ := claims.(jwt.MapClaims)
+
+		if !allowlist[userID] && !hasAdminRole(mapClaims) {
+			log.Printf("Admin route %s denied to user %s", c.FullPath(), userID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func hasAdminRole(claims jwt.MapClaims) bool {
+	appMetadata, ok := claims["app_metadata"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	role, _ := appMetadata["role"].(string)
+	return role == "admin"
+}
@@ -12,7 +12,8 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWTAuth validates the Supabase JWT token
+// JWTAuth validates the Supabase JWT token, or the OIDC token of an identity provider
+// configured with ConfigureSSO
 func JWTAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
@@ -38,6 +39,33 @@ func JWTAuth() gin.HandlerFunc {
 		tokenString := parts[1]
 		log.Printf("Token received: %s", tokenString[:10])
 
+		// Tokens of enterprise identity providers are exchanged for a token of their user
+		if provider := ssoProviderFor(tokenString); provider != nil {
+			claims, err := provider.verify(tokenString)
+			if err != nil {
+				log.Printf("Rejected SSO token of %s: %v", provider.Issuer, err)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			userID, session, accessToken, err := provider.authenticate(claims)
+			if err != nil {
+				log.Printf("Failed to sign in SSO user of %s: %v", provider.Issuer, err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Single sign-on is temporarily unavailable"})
+				c.Abort()
+				return
+			}
+			c.Set("userID", userID)
+			traceUser(c, userID)
+			c.Set("claims", session)
+			c.Set("accessToken", accessToken)
+			if !authorizeTenant(c, userID, session) {
+				return
+			}
+			c.Next()
+			return
+		}
+
 		// Get the JWT secret from environment variables
 		jwtSecret := os.Getenv("SUPABASE_JWT_SECRET")
 		if jwtSecret == "" {
@@ -74,6 +102,9 @@ func JWTAuth() gin.HandlerFunc {
 			// Store user information in the context
 			userID, _ := claims["sub"].(string)
 			c.Set("userID", userID)
+			traceUser(c, userID)
+			c.Set("claims", claims)
+			c.Set("accessToken", tokenString)
 
 			// Check if token is expired
 			if exp, ok := claims["exp"].(float64); ok {
@@ -83,8 +114,24 @@ func JWTAuth() gin.HandlerFunc {
 					return
 				}
 			}
+
+			if !authorizeTenant(c, userID, claims) {
+				return
+			}
 		}
 
 		c.Next()
 	}
 }
+
+// QueryToken accepts the token of the user in the token query parameter, for the
+// clients that cannot set the Authorization header such as EventSource. JWTAuth
+// then validates it like any other token, SSO tokens included.
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
@@ -1,20 +1,80 @@
 package middleware
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// CORS returns the CORS middleware configuration
-func CORS() gin.HandlerFunc {
-	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
+var (
+	defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "If-None-Match", "If-Modified-Since", "If-Match", "X-Claim-Token",
+		"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata"}
+	// Headers of the resumable upload protocol read by browsers
+	tusExposedHeaders = []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+		"Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-File-Url"}
+)
+
+// CORS returns the CORS middleware configured from the environment:
+//   - CORS_ALLOWED_ORIGINS: comma separated origins, each with at most one wildcard
+//     (e.g. "https://app.example.com,https://*.preview.example.com"), or "*"
+//   - CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS: comma separated, replacing the defaults
+//   - CORS_ALLOW_CREDENTIALS: false to disallow credentials, allowed by default
+//
+// Browsers reject credentials with any origin, so they are never allowed with "*".
+// Without CORS_ALLOWED_ORIGINS every origin is allowed without credentials.
+func CORS() (gin.HandlerFunc, error) {
+	config := cors.Config{
+		AllowMethods:     envList("CORS_ALLOWED_METHODS", defaultCORSMethods),
+		AllowHeaders:     envList("CORS_ALLOWED_HEADERS", defaultCORSHeaders),
+		ExposeHeaders:    append([]string{"Content-Length", "ETag", "Last-Modified"}, tusExposedHeaders...),
+		AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") != "false",
+		AllowWildcard:    true,
 		MaxAge:           12 * time.Hour,
-	})
+	}
+
+	origins := envList("CORS_ALLOWED_ORIGINS", nil)
+	switch {
+	case len(origins) == 0:
+		log.Println("CORS_ALLOWED_ORIGINS not set, allowing every origin without credentials")
+		config.AllowAllOrigins = true
+		config.AllowCredentials = false
+	case len(origins) == 1 && origins[0] == "*":
+		config.AllowAllOrigins = true
+		config.AllowCredentials = false
+	default:
+		for _, origin := range origins {
+			if origin == "*" {
+				return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS: * cannot be combined with other origins")
+			}
+			if strings.Count(origin, "*") > 1 {
+				return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS: %s has more than one wildcard", origin)
+			}
+		}
+		config.AllowOrigins = origins
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+	return cors.New(config), nil
+}
+
+// envList splits a comma separated variable, fallback is returned when it is empty
+func envList(name string, fallback []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	return values
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"pitch-deck-generator/internal/sentry"
+	"pitch-deck-generator/internal/tracing"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	sentrygin "github.com/getsentry/sentry-go/gin"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrorTracking reports the panics of the handlers to the error tracker with the gin
+// middleware of sentry-go, tagged with their route and trace. The panic goes on to
+// the recovery of gin, which answers the request and logs it.
+func ErrorTracking() gin.HandlerFunc {
+	report := sentrygin.New(sentrygin.Options{Repanic: true})
+	return func(c *gin.Context) {
+		if !sentry.Enabled() {
+			c.Next()
+			return
+		}
+
+		// The middleware reports with the hub of the request, when it has one
+		hub := sentrygo.CurrentHub().Clone()
+		hub.Scope().SetTag("route", c.FullPath())
+		if traceID := tracing.TraceID(trace.SpanFromContext(c.Request.Context())); traceID != "" {
+			hub.Scope().SetTag("trace_id", traceID)
+		}
+		c.Request = c.Request.WithContext(sentrygo.SetHubOnContext(c.Request.Context(), hub))
+		report(c)
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+	defaultMaxBodySize    = 1 << 20
+	defaultMaxUploadSize  = 32 << 20
+)
+
+// NoTimeout disables the timeout of a route, for streams
+const NoTimeout time.Duration = -1
+
+// RouteLimit overrides the limits of a route, zero fields keep the default
+type RouteLimit struct {
+	Timeout time.Duration
+	MaxBody int64
+}
+
+// Limits enforces a timeout and a maximum body size on every request, so slow or
+// broken clients cannot hold the server. Routes are limited by REQUEST_TIMEOUT and
+// by MAX_BODY_SIZE, or MAX_UPLOAD_SIZE for multipart uploads (in bytes), unless
+// overridden in routes, keyed by method and registered path, e.g.
+// "POST /api/pitch-decks/import".
+//
+// Bodies over their limit are answered with 413. The timeout is the deadline of the
+// request context and of reading the body: a handler failing once it has passed is
+// answered with 408, as is one that has not answered by then.
+func Limits(routes map[string]RouteLimit) gin.HandlerFunc {
+	timeout := envDuration("REQUEST_TIMEOUT", defaultRequestTimeout)
+	maxBody := envSize("MAX_BODY_SIZE", defaultMaxBodySize)
+	maxUpload := envSize("MAX_UPLOAD_SIZE", defaultMaxUploadSize)
+
+	return func(c *gin.Context) {
+		limit := routes[c.Request.Method+" "+c.FullPath()]
+		if limit.Timeout == 0 {
+			limit.Timeout = timeout
+		}
+		if limit.MaxBody == 0 {
+			limit.MaxBody = maxBody
+			if strings.HasPrefix(c.ContentType(), "multipart/") {
+				limit.MaxBody = maxUpload
+			}
+		}
+
+		if c.Request.ContentLength > limit.MaxBody {
+			abortWithLimit(c, http.StatusRequestEntityTooLarge, tooLarge(limit.MaxBody))
+			return
+		}
+
+		body := &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, limit.MaxBody)}
+		c.Request.Body = body
+
+		ctx := c.Request.Context()
+		if limit.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, limit.Timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+			// Not every connection supports deadlines, the context still applies
+			_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Now().Add(limit.Timeout))
+		}
+
+		// Errors answered by the handler because of a limit are replaced
+		rejection := func(status int) (int, gin.H, bool) {
+			switch {
+			case status < http.StatusBadRequest:
+				return 0, nil, false
+			case body.exceeded.Load():
+				return http.StatusRequestEntityTooLarge, tooLarge(limit.MaxBody), true
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				return http.StatusRequestTimeout, timedOut(limit.Timeout), true
+			}
+			return 0, nil, false
+		}
+
+		writer := &limitWriter{ResponseWriter: c.Writer, rejection: rejection}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if !c.Writer.Written() {
+			if status, response, ok := rejection(http.StatusInternalServerError); ok {
+				abortWithLimit(c, status, response)
+			}
+		}
+	}
+}
+
+func tooLarge(limit int64) gin.H {
+	return gin.H{
+		"error": fmt.Sprintf("Request body exceeds the limit of %d bytes", limit),
+		"code":  "body_too_large",
+		"limit": limit,
+	}
+}
+
+func timedOut(timeout time.Duration) gin.H {
+	return gin.H{
+		"error":   fmt.Sprintf("Request did not complete within %s", timeout),
+		"code":    "timeout",
+		"timeout": timeout.String(),
+	}
+}
+
+func abortWithLimit(c *gin.Context, status int, response gin.H) {
+	// The rest of the body is not read, the client must not reuse the connection
+	c.Header("Connection", "close")
+	c.AbortWithStatusJSON(status, response)
+}
+
+// limitedBody records whether the handler tried to read past the size limit
+type limitedBody struct {
+	io.ReadCloser
+	exceeded atomic.Bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		b.exceeded.Store(true)
+	}
+	return n, err
+}
+
+// limitWriter replaces the error answered by a handler when it was caused by a limit,
+// e.g. a JSON body cut at the size limit and reported as invalid
+type limitWriter struct {
+	gin.ResponseWriter
+	rejection func(status int) (int, gin.H, bool)
+	replaced  bool
+}
+
+func (w *limitWriter) WriteHeader(code int) {
+	if w.replaced {
+		return
+	}
+	if status, response, ok := w.rejection(code); ok && !w.Written() {
+		w.replaced = true
+		data, _ := json.Marshal(response)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Connection", "close")
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(status)
+		w.ResponseWriter.Write(data)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *limitWriter) Write(data []byte) (int, error) {
+	if w.replaced {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *limitWriter) WriteString(s string) (int, error) {
+	if w.replaced {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+func envSize(name string, fallback int64) int64 {
+	size, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil || size <= 0 {
+		return fallback
+	}
+	return size
+}
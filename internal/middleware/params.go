@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ValidParams answers Not found to the requests with a route parameter that is not
+// valid by its rule, before handlers look it up in the database or the filesystem.
+// Parameters without a rule are left to the handlers.
+func ValidParams(rules map[string]func(string) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, param := range c.Params {
+			if valid, ok := rules[param.Key]; ok && !valid(param.Value) {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// IsUUID reports whether a parameter is an ID in the canonical form of UUIDs, the
+// form of the IDs the API hands out
+func IsUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	_, err := uuid.Parse(value)
+	return err == nil
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit lets each client address make at most limit requests to a route per
+// window, further requests are answered with 429 until the window ends. Counts are
+// kept in memory, per instance, which is enough to keep one client from flooding
+// an unauthenticated route.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	type counter struct {
+		count   int
+		resetAt time.Time
+	}
+	var (
+		mu       sync.Mutex
+		counters = map[string]*counter{}
+	)
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		mu.Lock()
+		entry := counters[c.ClientIP()]
+		if entry == nil || now.After(entry.resetAt) {
+			// Drop the ended windows now and then so the map does not grow unbounded
+			if len(counters) > 10000 {
+				for ip, counter := range counters {
+					if now.After(counter.resetAt) {
+						delete(counters, ip)
+					}
+				}
+			}
+			entry = &counter{resetAt: now.Add(window)}
+			counters[c.ClientIP()] = entry
+		}
+		entry.count++
+		exceeded, retryAfter := entry.count > limit, entry.resetAt.Sub(now)
+		mu.Unlock()
+
+		if exceeded {
+			c.Header("Retry-After", fmt.Sprintf("%.0f", retryAfter.Seconds()+0.5))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": fmt.Sprintf("At most %d requests are allowed every %s", limit, window),
+				"code":  "rate_limited",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,323 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// Keys of a provider are fetched again for an unknown key ID at most this often
+	ssoKeyRefreshInterval = time.Minute
+	// How long the user of an identity is cached
+	ssoUserTTL = 10 * time.Minute
+)
+
+var ssoSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// IdentityResolver returns the user an identity of a provider is mapped to. With
+// linkEmail, an identity signing in for the first time is mapped to the existing
+// account with its email.
+type IdentityResolver func(issuer, subject, email string, linkEmail bool) (string, error)
+
+// ssoProvider is an enterprise identity provider whose OIDC tokens are accepted
+type ssoProvider struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// Email domains of the organization, its users are mapped to their existing
+	// account on first sign in. Others always get a new account.
+	EmailDomains []string `json:"emailDomains"`
+	// Tenant its users belong to in multi-tenant mode
+	Tenant string `json:"tenant"`
+
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+type ssoUser struct {
+	userID    string
+	expiresAt time.Time
+}
+
+var sso struct {
+	providers map[string]*ssoProvider
+	resolve   IdentityResolver
+	secret    []byte
+	// users caches the user of identities, by issuer and subject
+	users sync.Map
+}
+
+// ConfigureSSO accepts the OIDC tokens of the identity providers in OIDC_PROVIDERS,
+// a JSON list of {"issuer", "audience", "emailDomains", "tenant"} (e.g. an Okta
+// authorization server or an Azure AD tenant, with the client ID of the app as
+// audience). JWTAuth exchanges them for a Supabase token of the user they are mapped
+// to by resolve, so the rest of the API, row level security included, sees a
+// regular user.
+func ConfigureSSO(resolve IdentityResolver) (bool, error) {
+	raw := os.Getenv("OIDC_PROVIDERS")
+	if raw == "" {
+		return false, nil
+	}
+	var providers []*ssoProvider
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return false, fmt.Errorf("invalid OIDC_PROVIDERS: %w", err)
+	}
+	secret := os.Getenv("SUPABASE_JWT_SECRET")
+	if secret == "" {
+		return false, fmt.Errorf("SUPABASE_JWT_SECRET is required to sign in SSO users")
+	}
+
+	sso.providers = make(map[string]*ssoProvider, len(providers))
+	for _, provider := range providers {
+		if !strings.HasPrefix(provider.Issuer, "https://") {
+			return false, fmt.Errorf("invalid OIDC_PROVIDERS: issuer %q must be an https URL", provider.Issuer)
+		}
+		if provider.Audience == "" {
+			return false, fmt.Errorf("invalid OIDC_PROVIDERS: issuer %s has no audience", provider.Issuer)
+		}
+		if _, ok := sso.providers[provider.Issuer]; ok {
+			return false, fmt.Errorf("invalid OIDC_PROVIDERS: issuer %s is listed twice", provider.Issuer)
+		}
+		sso.providers[provider.Issuer] = provider
+	}
+	sso.resolve = resolve
+	sso.secret = []byte(secret)
+	return len(sso.providers) > 0, nil
+}
+
+// ssoProviderFor returns the provider that issued a token, nil for the tokens of
+// Supabase and of unknown issuers
+func ssoProviderFor(tokenString string) *ssoProvider {
+	if len(sso.providers) == 0 {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return nil
+	}
+	issuer, _ := claims.GetIssuer()
+	return sso.providers[issuer]
+}
+
+// verify validates a token of the provider, returning its claims
+func (p *ssoProvider) verify(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, p.key,
+		jwt.WithValidMethods(ssoSigningMethods),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.Audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if subject, _ := claims.GetSubject(); subject == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+	return claims, nil
+}
+
+// authenticate maps the identity of a verified token to its user, and returns a
+// Supabase token of the user expiring with it
+func (p *ssoProvider) authenticate(claims jwt.MapClaims) (string, jwt.MapClaims, string, error) {
+	subject, _ := claims.GetSubject()
+	email := ssoEmail(claims)
+
+	cacheKey := p.Issuer + "\x00" + subject
+	var userID string
+	if cached, ok := sso.users.Load(cacheKey); ok && time.Now().Before(cached.(ssoUser).expiresAt) {
+		userID = cached.(ssoUser).userID
+	} else {
+		var err error
+		if userID, err = sso.resolve(p.Issuer, subject, email, p.linksEmail(claims, email)); err != nil {
+			return "", nil, "", err
+		}
+		sso.users.Store(cacheKey, ssoUser{userID: userID, expiresAt: time.Now().Add(ssoUserTTL)})
+	}
+
+	appMetadata := map[string]interface{}{"provider": "sso", "issuer": p.Issuer}
+	if p.Tenant != "" {
+		appMetadata["tenant_id"] = p.Tenant
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+	session := jwt.MapClaims{
+		"sub":   userID,
+		"email": email,
+		"role":  "authenticated",
+		"aud":   "authenticated",
+		"iat":   time.Now().Unix(),
+		"exp":   expiresAt.Unix(),
+		// Never the claims of the provider, app_metadata grants the admin role
+		"app_metadata": appMetadata,
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, session).SignedString(sso.secret)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to sign session token: %w", err)
+	}
+	return userID, session, accessToken, nil
+}
+
+// linksEmail tells whether the identity may be mapped to the existing account with
+// its email: a verified address in a domain of the organization
+func (p *ssoProvider) linksEmail(claims jwt.MapClaims, email string) bool {
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range p.EmailDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ssoEmail returns the email of a token, Azure AD only sets it as an optional claim
+// and otherwise names users by their principal name
+func ssoEmail(claims jwt.MapClaims) string {
+	for _, name := range []string{"email", "upn", "preferred_username"} {
+		if value, _ := claims[name].(string); strings.Contains(value, "@") {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// key returns the public key a token was signed with, fetching the keys of the
+// provider again when it rotated them
+func (p *ssoProvider) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key := p.lookup(kid); key != nil {
+		return key, nil
+	}
+	if time.Since(p.fetchedAt) < ssoKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	keys, err := fetchProviderKeys(p.Issuer)
+	p.fetchedAt = time.Now()
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	if key := p.lookup(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *ssoProvider) lookup(kid string) interface{} {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// jsonWebKey is a public key of a JWK set, RSA or EC
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchProviderKeys returns the signing keys of an issuer by ID, from the JWK set
+// named in its discovery document
+func fetchProviderKeys(issuer string) (map[string]interface{}, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := fetchJSON(strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to load discovery document: %w", err)
+	}
+	if discovery.Issuer != issuer || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %q", issuer, discovery.Issuer)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := fetchJSON(discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to load signing keys: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped, the others may still sign tokens
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func fetchJSON(url string, out interface{}) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var tenancy struct {
+	forHost func(host string) (string, error)
+	enroll  func(userID, tenantID string) error
+}
+
+// ConfigureTenants turns on multi-tenant mode: Tenant finds the tenant of requests
+// from their hostname with forHost, and JWTAuth checks with enroll that users sign
+// in to their tenant, enrolling them on their first sign in
+func ConfigureTenants(forHost func(host string) (string, error), enroll func(userID, tenantID string) error) {
+	tenancy.forHost = forHost
+	tenancy.enroll = enroll
+}
+
+// Tenant sets the tenantID of requests from the hostname they were sent to, empty
+// for the hostnames of no tenant
+func Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenancy.forHost == nil {
+			c.Next()
+			return
+		}
+
+		host, _, err := net.SplitHostPort(c.Request.Host)
+		if err != nil {
+			host = c.Request.Host
+		}
+		tenantID, err := tenancy.forHost(host)
+		if err != nil {
+			log.Printf("Failed to resolve tenant of %s: %v", host, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
+			c.Abort()
+			return
+		}
+		c.Set("tenantID", tenantID)
+		c.Next()
+	}
+}
+
+// authorizeTenant checks that a user signs in to the tenant of the hostname, or to
+// the tenant named in the app_metadata of their token, which only the service role
+// can set. The tenant of the user replaces the one of the hostname.
+func authorizeTenant(c *gin.Context, userID string, claims jwt.MapClaims) bool {
+	if tenancy.enroll == nil {
+		return true
+	}
+
+	tenantID := c.GetString("tenantID")
+	if appMetadata, ok := claims["app_metadata"].(map[string]interface{}); ok {
+		if claimed, _ := appMetadata["tenant_id"].(string); claimed != "" {
+			if tenantID != "" && tenantID != claimed {
+				c.JSON(http.StatusForbidden, gin.H{"error": "This account belongs to another workspace"})
+				c.Abort()
+				return false
+			}
+			tenantID = claimed
+		}
+	}
+
+	if err := tenancy.enroll(userID, tenantID); err != nil {
+		if errors.Is(err, model.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account belongs to another workspace"})
+		} else {
+			log.Printf("Failed to enroll user %s in tenant %q: %v", userID, tenantID, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable"})
+		}
+		c.Abort()
+		return false
+	}
+	c.Set("tenantID", tenantID)
+	return true
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"os"
+
+	"pitch-deck-generator/internal/sentry"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing records a server span for each request with otelgin, continuing the trace
+// of the caller. Handlers reach it through the context of the request, so the calls
+// they make to Supabase and the LLM are recorded as its children.
+func Tracing() gin.HandlerFunc {
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "pitchtree-backend"
+	}
+	// Spans are named after the route, not the path, to be grouped by endpoint
+	return otelgin.Middleware(service, otelgin.WithSpanNameFormatter(func(c *gin.Context) string {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		return c.Request.Method + " " + route
+	}))
+}
+
+// traceUser records the authenticated user on the span of the request, and on the
+// errors it reports
+func traceUser(c *gin.Context, userID string) {
+	trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("user.id", userID))
+	sentry.SetUser(c.Request.Context(), userID)
+}
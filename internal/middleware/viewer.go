@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ViewerOrigin serves the deck viewers, the routes under prefixes, from the origin
+// of VIEWER_URL: a separate domain, whose pages cannot read the storage or call the
+// API with the credentials of the app origin. Viewer requests reaching another host
+// are redirected to it, and only viewer routes are served on it. Without
+// VIEWER_URL the viewers are served from every host. The custom domains of
+// organizations, told by customDomain, serve the viewer routes only, without redirect.
+func ViewerOrigin(customDomain func(host string) bool, prefixes ...string) (gin.HandlerFunc, error) {
+	var parsed *url.URL
+	var origin string
+	if viewerURL := os.Getenv("VIEWER_URL"); viewerURL != "" {
+		var err error
+		parsed, err = url.Parse(viewerURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("VIEWER_URL must be an absolute URL, got %q", viewerURL)
+		}
+		origin = parsed.Scheme + "://" + parsed.Host
+	}
+
+	return func(c *gin.Context) {
+		viewer := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				viewer = true
+				break
+			}
+		}
+		onViewerHost := parsed != nil && strings.EqualFold(c.Request.Host, parsed.Host)
+		onCustomDomain := !onViewerHost && customDomain(c.Request.Host)
+
+		switch {
+		case onCustomDomain && viewer:
+			c.Next()
+		case viewer && parsed != nil && !onViewerHost:
+			c.Redirect(http.StatusTemporaryRedirect, origin+c.Request.URL.RequestURI())
+			c.Abort()
+		case !viewer && (onViewerHost || onCustomDomain):
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		default:
+			c.Next()
+		}
+	}, nil
+}
@@ -0,0 +1,102 @@
+// Package migrations creates and updates the database schema. Migrations are SQL files
+// embedded in the binary, applied in order by goose and recorded in the
+// goose_db_version table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// Run applies a command to the database: up applies the pending migrations, down
+// reverts the last one and status lists them
+func Run(ctx context.Context, databaseURL, command string) error {
+	db, err := sql.Open("pgx", withoutPoolParams(databaseURL))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	fsys, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return err
+	}
+
+	// Instances starting together wait for each other instead of applying the same migrations
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return err
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, fsys, goose.WithSessionLocker(locker))
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	switch command {
+	case "up":
+		results, err := provider.Up(ctx)
+		for _, result := range results {
+			log.Printf("Applied migration %s in %s", result.Source.Path, result.Duration)
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			log.Println("Database schema is up to date")
+		}
+		return nil
+	case "down":
+		result, err := provider.Down(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Reverted migration %s", result.Source.Path)
+		return nil
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := ""
+			if status.State == goose.StateApplied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%-8s %-30s %s\n", status.State, status.Source.Path, appliedAt)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q, expected up, down or status", command)
+}
+
+// withoutPoolParams removes the pool_ parameters of the repository connection pool
+// from a database URL, a single connection would send them to the server as settings
+func withoutPoolParams(databaseURL string) string {
+	u, err := url.Parse(databaseURL)
+	if err != nil || u.Scheme == "" {
+		return databaseURL
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(key, "pool_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
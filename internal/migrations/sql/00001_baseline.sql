@@ -0,0 +1,210 @@
+-- Schema of the tables created by hand in Supabase before migrations. Tables are created
+-- only when missing, so existing databases adopt this migration without changes.
+--
+-- The API uses the service key, which bypasses row level security. It is enabled
+-- without policies so the public anon key cannot read the tables.
+
+-- +goose Up
+create table if not exists organizations (
+  id uuid primary key,
+  name text not null,
+  created_by uuid not null,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists organization_members (
+  org_id uuid not null references organizations(id) on delete cascade,
+  user_id uuid not null,
+  role text not null check (role in ('viewer', 'editor', 'owner')),
+  created_at timestamptz not null default now(),
+  primary key (org_id, user_id)
+);
+create index if not exists organization_members_user_id_idx on organization_members (user_id);
+
+create table if not exists organization_invites (
+  id uuid primary key,
+  org_id uuid not null references organizations(id) on delete cascade,
+  email text not null,
+  role text not null check (role in ('viewer', 'editor', 'owner')),
+  token text not null unique,
+  invited_by uuid not null,
+  created_at timestamptz not null default now(),
+  accepted_at timestamptz
+);
+
+create table if not exists projects (
+  id uuid primary key,
+  user_id uuid not null,
+  org_id uuid references organizations(id) on delete set null,
+  name text not null,
+  brand_kit jsonb not null default '{}',
+  audience_persona text not null default '',
+  created_at timestamptz not null default now()
+);
+create index if not exists projects_user_id_idx on projects (user_id);
+
+create table if not exists pitch_decks (
+  id uuid primary key,
+  user_id uuid not null,
+  name text not null,
+  slug text unique,
+  pdf_url text not null default '',
+  html_url text not null default '',
+  markdown_url text,
+  project_id uuid references projects(id) on delete set null,
+  org_id uuid references organizations(id) on delete set null,
+  is_public boolean not null default false,
+  status text not null default 'processing',
+  error_code text,
+  error_message text,
+  view_count integer not null default 0,
+  last_viewed_at timestamptz,
+  taken_down_at timestamptz,
+  takedown_reason text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists pitch_decks_user_id_idx on pitch_decks (user_id, created_at desc);
+create index if not exists pitch_decks_org_id_idx on pitch_decks (org_id) where org_id is not null;
+create index if not exists pitch_decks_status_idx on pitch_decks (status);
+
+-- +goose StatementBegin
+create or replace function set_updated_at() returns trigger as $$
+begin
+  new.updated_at = now();
+  return new;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+drop trigger if exists pitch_decks_updated_at on pitch_decks;
+create trigger pitch_decks_updated_at before update on pitch_decks
+  for each row execute function set_updated_at();
+
+-- +goose StatementBegin
+create or replace function record_deck_view(deck_id uuid) returns void as $$
+  update pitch_decks set view_count = view_count + 1, last_viewed_at = now()
+  where id = deck_id and is_public;
+$$ language sql;
+-- +goose StatementEnd
+
+-- Answers a deck was generated from, for the content export
+create table if not exists deck_inputs (
+  deck_id uuid primary key references pitch_decks(id) on delete cascade,
+  user_id uuid not null,
+  data jsonb not null,
+  created_at timestamptz not null default now()
+);
+
+-- Not tied to pitch_decks, the events of a deck are kept after it is deleted
+create table if not exists audit_log (
+  id uuid primary key,
+  deck_id uuid not null,
+  actor_id uuid not null,
+  action text not null,
+  metadata jsonb,
+  created_at timestamptz not null default now()
+);
+create index if not exists audit_log_deck_id_idx on audit_log (deck_id, created_at desc);
+
+create table if not exists user_files (
+  id uuid primary key,
+  user_id uuid not null,
+  original_name text not null,
+  file_url text not null,
+  storage_path text not null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists user_files_user_id_idx on user_files (user_id, created_at desc);
+create index if not exists user_files_storage_path_idx on user_files (storage_path);
+
+create table if not exists share_links (
+  id uuid primary key,
+  deck_id uuid not null references pitch_decks(id) on delete cascade,
+  user_id uuid not null,
+  token text not null unique,
+  label text not null default '',
+  created_at timestamptz not null default now()
+);
+create index if not exists share_links_deck_id_idx on share_links (deck_id);
+
+create table if not exists deck_view_events (
+  id uuid primary key,
+  link_id uuid not null references share_links(id) on delete cascade,
+  deck_id uuid not null references pitch_decks(id) on delete cascade,
+  session_id text not null,
+  event_type text not null,
+  slide integer not null default 0,
+  duration_ms bigint not null default 0,
+  created_at timestamptz not null default now()
+);
+create index if not exists deck_view_events_deck_id_idx on deck_view_events (deck_id, created_at);
+
+create table if not exists notion_connections (
+  user_id uuid primary key,
+  access_token text not null,
+  workspace_id text not null,
+  workspace_name text not null default '',
+  bot_id text not null default '',
+  created_at timestamptz not null default now()
+);
+
+create table if not exists intake_sessions (
+  id uuid primary key,
+  user_id uuid not null,
+  data jsonb not null default '{}',
+  messages jsonb not null default '[]',
+  step integer not null default 0,
+  complete boolean not null default false,
+  deck_id uuid references pitch_decks(id) on delete set null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+-- One-time tokens of the Telegram deep links, valid for an hour
+create table if not exists telegram_links (
+  token text primary key,
+  user_id uuid not null,
+  created_at timestamptz not null default now()
+);
+
+create table if not exists telegram_chats (
+  chat_id bigint primary key,
+  user_id uuid not null,
+  session_id uuid references intake_sessions(id) on delete set null,
+  linked_at timestamptz not null default now()
+);
+
+alter table organizations enable row level security;
+alter table organization_members enable row level security;
+alter table organization_invites enable row level security;
+alter table projects enable row level security;
+alter table pitch_decks enable row level security;
+alter table deck_inputs enable row level security;
+alter table audit_log enable row level security;
+alter table user_files enable row level security;
+alter table share_links enable row level security;
+alter table deck_view_events enable row level security;
+alter table notion_connections enable row level security;
+alter table intake_sessions enable row level security;
+alter table telegram_links enable row level security;
+alter table telegram_chats enable row level security;
+
+-- +goose Down
+drop table if exists telegram_chats;
+drop table if exists telegram_links;
+drop table if exists intake_sessions;
+drop table if exists notion_connections;
+drop table if exists deck_view_events;
+drop table if exists share_links;
+drop table if exists user_files;
+drop table if exists audit_log;
+drop table if exists deck_inputs;
+drop function if exists record_deck_view(uuid);
+drop table if exists pitch_decks;
+drop function if exists set_updated_at();
+drop table if exists projects;
+drop table if exists organization_invites;
+drop table if exists organization_members;
+drop table if exists organizations;
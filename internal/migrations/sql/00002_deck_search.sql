@@ -0,0 +1,44 @@
+-- Full-text search over deck names and the text of rendered decks. The simple
+-- configuration is used since decks are written in many languages.
+
+-- +goose Up
+create table if not exists deck_search (
+  deck_id uuid primary key references pitch_decks(id) on delete cascade,
+  content text not null,
+  document tsvector generated always as (to_tsvector('simple', content)) stored,
+  updated_at timestamptz not null default now()
+);
+create index if not exists deck_search_document_idx on deck_search using gin (document);
+create index if not exists pitch_decks_name_search_idx on pitch_decks using gin (to_tsvector('simple', name));
+alter table deck_search enable row level security;
+
+-- +goose StatementBegin
+create or replace function index_deck(p_deck_id uuid, p_content text) returns void as $$
+  insert into deck_search (deck_id, content) values (p_deck_id, p_content)
+  on conflict (deck_id) do update set content = excluded.content, updated_at = now();
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create or replace function search_decks(p_user_id uuid, p_org_ids uuid[], p_query text, p_limit int)
+returns table (id uuid, title text, snippet text) as $$
+  select d.id,
+    ts_headline('simple', d.name, q, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+    ts_headline('simple', coalesce(s.content, ''), q,
+      'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5')
+  from pitch_decks d
+  left join deck_search s on s.deck_id = d.id,
+  websearch_to_tsquery('simple', p_query) q
+  where (d.user_id = p_user_id or d.org_id = any(p_org_ids))
+    and (to_tsvector('simple', d.name) @@ q or s.document @@ q)
+  order by ts_rank(setweight(to_tsvector('simple', d.name), 'A') ||
+    setweight(coalesce(s.document, ''::tsvector), 'B'), q) desc, d.created_at desc
+  limit p_limit;
+$$ language sql stable;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists search_decks(uuid, uuid[], text, int);
+drop function if exists index_deck(uuid, text);
+drop index if exists pitch_decks_name_search_idx;
+drop table if exists deck_search;
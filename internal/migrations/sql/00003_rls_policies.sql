@@ -0,0 +1,56 @@
+-- Policies applied to the requests made with the token of a user when SUPABASE_RLS is
+-- enabled. The service key bypasses them. They rely on the auth schema of Supabase and
+-- are skipped on databases without it.
+
+-- +goose Up
+-- +goose StatementBegin
+do $$
+begin
+  if not exists (select 1 from pg_namespace where nspname = 'auth') then
+    raise notice 'auth schema not found, row level security policies skipped';
+    return;
+  end if;
+
+  drop policy if exists "members read their memberships" on organization_members;
+  create policy "members read their memberships" on organization_members
+    for select to authenticated
+    using (user_id = auth.uid());
+
+  drop policy if exists "users read their decks" on pitch_decks;
+  create policy "users read their decks" on pitch_decks
+    for select to authenticated
+    using (user_id = auth.uid() or org_id in (
+      select org_id from organization_members where user_id = auth.uid()));
+
+  drop policy if exists "users create their decks" on pitch_decks;
+  create policy "users create their decks" on pitch_decks
+    for insert to authenticated
+    with check (user_id = auth.uid());
+
+  drop policy if exists "editors update decks" on pitch_decks;
+  create policy "editors update decks" on pitch_decks
+    for update to authenticated
+    using (user_id = auth.uid() or org_id in (
+      select org_id from organization_members
+      where user_id = auth.uid() and role in ('editor', 'owner')));
+
+  drop policy if exists "users read their deck answers" on deck_inputs;
+  create policy "users read their deck answers" on deck_inputs
+    for select to authenticated
+    using (user_id = auth.uid());
+
+  drop policy if exists "users save their deck answers" on deck_inputs;
+  create policy "users save their deck answers" on deck_inputs
+    for insert to authenticated
+    with check (user_id = auth.uid());
+end;
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+drop policy if exists "users save their deck answers" on deck_inputs;
+drop policy if exists "users read their deck answers" on deck_inputs;
+drop policy if exists "editors update decks" on pitch_decks;
+drop policy if exists "users create their decks" on pitch_decks;
+drop policy if exists "users read their decks" on pitch_decks;
+drop policy if exists "members read their memberships" on organization_members;
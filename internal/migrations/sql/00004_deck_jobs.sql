@@ -0,0 +1,60 @@
+-- State of the background generations, so the jobs of a crashed or restarted instance
+-- are found and resumed. Running jobs send heartbeats, a job whose heartbeat is stale
+-- was lost with its instance.
+
+-- +goose Up
+create table if not exists deck_jobs (
+  deck_id uuid primary key references pitch_decks(id) on delete cascade,
+  state text not null check (state in ('running', 'queued', 'finished')),
+  instance_id text not null,
+  attempts integer not null default 0,
+  heartbeat_at timestamptz not null default now(),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists deck_jobs_state_idx on deck_jobs (state, heartbeat_at) where state <> 'finished';
+alter table deck_jobs enable row level security;
+
+-- Starting a job counts an attempt
+-- +goose StatementBegin
+create or replace function transition_deck_job(p_deck_id uuid, p_instance text, p_state text) returns void as $$
+  insert into deck_jobs (deck_id, state, instance_id, attempts)
+  values (p_deck_id, p_state, p_instance, case when p_state = 'running' then 1 else 0 end)
+  on conflict (deck_id) do update set
+    state = excluded.state,
+    instance_id = excluded.instance_id,
+    attempts = deck_jobs.attempts + excluded.attempts,
+    heartbeat_at = now(),
+    updated_at = now();
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create or replace function heartbeat_deck_jobs(p_deck_ids uuid[]) returns void as $$
+  update deck_jobs set heartbeat_at = now()
+  where deck_id = any(p_deck_ids) and state = 'running';
+$$ language sql;
+-- +goose StatementEnd
+
+-- Hands the queued and stale jobs to an instance. Rows are locked so concurrent
+-- instances never claim the same job.
+-- +goose StatementBegin
+create or replace function claim_deck_jobs(p_instance text, p_stale_after interval)
+returns table (deck_id uuid, state text, attempts integer) as $$
+  with claimable as (
+    select j.deck_id, j.state from deck_jobs j
+    where j.state = 'queued' or (j.state = 'running' and j.heartbeat_at < now() - p_stale_after)
+    for update skip locked
+  )
+  update deck_jobs j set instance_id = p_instance, state = 'running', heartbeat_at = now(), updated_at = now()
+  from claimable c
+  where j.deck_id = c.deck_id
+  returning j.deck_id, c.state, j.attempts;
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists claim_deck_jobs(text, interval);
+drop function if exists heartbeat_deck_jobs(uuid[]);
+drop function if exists transition_deck_job(uuid, text, text);
+drop table if exists deck_jobs;
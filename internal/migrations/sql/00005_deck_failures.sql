@@ -0,0 +1,21 @@
+-- Dead-letter queue of the generations that failed, with what is needed to find out
+-- why: the stage that failed, the raw error of the LLM or renderer and Marp's output
+
+-- +goose Up
+create table if not exists deck_failures (
+  id uuid primary key,
+  deck_id uuid not null references pitch_decks(id) on delete cascade,
+  stage text not null,
+  code text not null,
+  message text not null,
+  error text not null default '',
+  stderr text not null default '',
+  created_at timestamptz not null default now(),
+  retried_at timestamptz
+);
+create index if not exists deck_failures_deck_id_idx on deck_failures (deck_id);
+create index if not exists deck_failures_pending_idx on deck_failures (created_at desc) where retried_at is null;
+alter table deck_failures enable row level security;
+
+-- +goose Down
+drop table if exists deck_failures;
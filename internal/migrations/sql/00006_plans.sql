@@ -0,0 +1,48 @@
+-- Subscription tiers and their limits. Users without a row in user_plans are on the
+-- free plan. A null limit is unlimited.
+
+-- +goose Up
+create table if not exists plans (
+  id text primary key,
+  name text not null,
+  decks_per_month integer,
+  max_uploads integer,
+  watermark boolean not null default false,
+  custom_themes boolean not null default false
+);
+alter table plans enable row level security;
+
+insert into plans (id, name, decks_per_month, max_uploads, watermark, custom_themes) values
+  ('free', 'Free', 3, 20, true, false),
+  ('pro', 'Pro', null, 1000, false, true)
+on conflict (id) do nothing;
+
+create table if not exists user_plans (
+  user_id uuid primary key,
+  plan_id text not null references plans(id),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+alter table user_plans enable row level security;
+
+-- The plan of a user with their decks created this month (UTC) and their uploads
+-- +goose StatementBegin
+create or replace function user_plan(p_user_id uuid)
+returns table (
+  id text, name text, decks_per_month integer, max_uploads integer,
+  watermark boolean, custom_themes boolean, decks_this_month integer, uploads integer
+) as $$
+  select p.id, p.name, p.decks_per_month, p.max_uploads, p.watermark, p.custom_themes,
+    (select count(*)::integer from pitch_decks d
+      where d.user_id = p_user_id
+        and d.created_at >= date_trunc('month', now() at time zone 'utc') at time zone 'utc'),
+    (select count(*)::integer from user_files f where f.user_id = p_user_id)
+  from plans p
+  where p.id = coalesce((select up.plan_id from user_plans up where up.user_id = p_user_id), 'free');
+$$ language sql stable;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists user_plan(uuid);
+drop table if exists user_plans;
+drop table if exists plans;
@@ -0,0 +1,52 @@
+-- Generation settings each plan allows: the models decks can be generated with and
+-- the longest output they can ask for.
+
+-- +goose Up
+alter table plans
+  add column if not exists models text[] not null default array['gemini-1.5-flash-latest'],
+  add column if not exists max_output_tokens integer not null default 4000;
+
+update plans set models = array['gemini-1.5-flash-latest'], max_output_tokens = 4000 where id = 'free';
+update plans set models = array['gemini-1.5-flash-latest', 'gemini-1.5-pro-latest'], max_output_tokens = 8192 where id = 'pro';
+
+-- The return type changes, the function cannot be replaced
+drop function if exists user_plan(uuid);
+
+-- +goose StatementBegin
+create function user_plan(p_user_id uuid)
+returns table (
+  id text, name text, decks_per_month integer, max_uploads integer,
+  watermark boolean, custom_themes boolean, models text[], max_output_tokens integer,
+  decks_this_month integer, uploads integer
+) as $$
+  select p.id, p.name, p.decks_per_month, p.max_uploads, p.watermark, p.custom_themes,
+    p.models, p.max_output_tokens,
+    (select count(*)::integer from pitch_decks d
+      where d.user_id = p_user_id
+        and d.created_at >= date_trunc('month', now() at time zone 'utc') at time zone 'utc'),
+    (select count(*)::integer from user_files f where f.user_id = p_user_id)
+  from plans p
+  where p.id = coalesce((select up.plan_id from user_plans up where up.user_id = p_user_id), 'free');
+$$ language sql stable;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists user_plan(uuid);
+
+-- +goose StatementBegin
+create function user_plan(p_user_id uuid)
+returns table (
+  id text, name text, decks_per_month integer, max_uploads integer,
+  watermark boolean, custom_themes boolean, decks_this_month integer, uploads integer
+) as $$
+  select p.id, p.name, p.decks_per_month, p.max_uploads, p.watermark, p.custom_themes,
+    (select count(*)::integer from pitch_decks d
+      where d.user_id = p_user_id
+        and d.created_at >= date_trunc('month', now() at time zone 'utc') at time zone 'utc'),
+    (select count(*)::integer from user_files f where f.user_id = p_user_id)
+  from plans p
+  where p.id = coalesce((select up.plan_id from user_plans up where up.user_id = p_user_id), 'free');
+$$ language sql stable;
+-- +goose StatementEnd
+
+alter table plans drop column if exists max_output_tokens, drop column if exists models;
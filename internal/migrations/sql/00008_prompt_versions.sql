@@ -0,0 +1,37 @@
+-- Versions of the LLM prompt templates, edited by administrators. Versions are never
+-- changed once created; the version published last is used for new generations and
+-- each deck records the version it was generated with.
+
+-- +goose Up
+create table if not exists prompt_versions (
+  id uuid primary key default gen_random_uuid(),
+  name text not null,
+  version integer not null,
+  template text not null,
+  notes text not null default '',
+  created_by uuid,
+  created_at timestamptz not null default now(),
+  published_at timestamptz,
+  unique (name, version)
+);
+create index if not exists prompt_versions_published_idx on prompt_versions (name, published_at desc) where published_at is not null;
+alter table prompt_versions enable row level security;
+
+alter table pitch_decks add column if not exists prompt_version_id uuid references prompt_versions(id);
+
+-- Numbers the versions of each prompt from 1, the unique constraint rejects the
+-- second of two concurrent versions
+-- +goose StatementBegin
+create or replace function create_prompt_version(p_name text, p_template text, p_notes text, p_created_by uuid)
+returns setof prompt_versions as $$
+  insert into prompt_versions (name, version, template, notes, created_by)
+  select p_name, coalesce(max(version), 0) + 1, p_template, p_notes, p_created_by
+  from prompt_versions where name = p_name
+  returning *;
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists create_prompt_version(text, text, text, uuid);
+alter table pitch_decks drop column if exists prompt_version_id;
+drop table if exists prompt_versions;
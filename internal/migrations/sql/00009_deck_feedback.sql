@@ -0,0 +1,57 @@
+-- Ratings of the generated decks by their users, of a whole deck or of one slide,
+-- with the prompt version the deck was generated with to compare the versions
+
+-- +goose Up
+create table if not exists deck_feedback (
+  id uuid primary key default gen_random_uuid(),
+  deck_id uuid not null references pitch_decks(id) on delete cascade,
+  user_id uuid not null,
+  slide integer check (slide >= 1),
+  rating smallint not null check (rating between 1 and 5),
+  comment text not null default '',
+  prompt_version_id uuid references prompt_versions(id),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+-- A user rates a deck and each of its slides once, rating again replaces the rating
+create unique index if not exists deck_feedback_user_idx on deck_feedback (deck_id, user_id, slide) nulls not distinct;
+create index if not exists deck_feedback_created_at_idx on deck_feedback (created_at desc);
+alter table deck_feedback enable row level security;
+
+-- +goose StatementBegin
+create or replace function submit_deck_feedback(
+  p_deck_id uuid, p_user_id uuid, p_slide integer, p_rating smallint, p_comment text
+) returns setof deck_feedback as $$
+  insert into deck_feedback (deck_id, user_id, slide, rating, comment, prompt_version_id)
+  select p_deck_id, p_user_id, p_slide, p_rating, p_comment, d.prompt_version_id
+  from pitch_decks d where d.id = p_deck_id
+  on conflict (deck_id, user_id, slide) do update set
+    rating = excluded.rating,
+    comment = excluded.comment,
+    updated_at = now()
+  returning *;
+$$ language sql;
+-- +goose StatementEnd
+
+-- Ratings of the whole decks per prompt version, a null version is the template
+-- built into the server
+-- +goose StatementBegin
+create or replace function prompt_feedback_stats()
+returns table (
+  prompt_version_id uuid, name text, version integer,
+  decks integer, ratings integer, average_rating numeric
+) as $$
+  select f.prompt_version_id, pv.name, pv.version,
+    count(distinct f.deck_id)::integer, count(*)::integer, round(avg(f.rating), 2)
+  from deck_feedback f
+  left join prompt_versions pv on pv.id = f.prompt_version_id
+  where f.slide is null
+  group by f.prompt_version_id, pv.name, pv.version
+  order by pv.name, pv.version desc nulls last;
+$$ language sql stable;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists prompt_feedback_stats();
+drop function if exists submit_deck_feedback(uuid, uuid, integer, smallint, text);
+drop table if exists deck_feedback;
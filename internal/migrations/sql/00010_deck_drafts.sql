@@ -0,0 +1,18 @@
+-- Answers of the deck form saved while it is being filled, so the wizard can be
+-- resumed after a refresh or on another device
+
+-- +goose Up
+create table if not exists deck_drafts (
+  id uuid primary key,
+  user_id uuid not null,
+  data jsonb not null default '{}',
+  step integer not null default 0 check (step >= 0),
+  deck_id uuid references pitch_decks(id) on delete set null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+create index if not exists deck_drafts_user_idx on deck_drafts (user_id, updated_at desc);
+alter table deck_drafts enable row level security;
+
+-- +goose Down
+drop table if exists deck_drafts;
@@ -0,0 +1,47 @@
+-- Decks generated without an account. Each one is owned by a random guest ID until
+-- its claim token attaches it to the account of the user, and is deleted when it
+-- expires unclaimed.
+
+-- +goose Up
+create table if not exists guest_decks (
+  deck_id uuid primary key references pitch_decks(id) on delete cascade,
+  guest_id uuid not null,
+  claim_token text not null unique,
+  -- Hash of the client address, to limit the decks generated from it
+  ip_hash text not null,
+  created_at timestamptz not null default now(),
+  expires_at timestamptz not null,
+  claimed_by uuid,
+  claimed_at timestamptz
+);
+create index if not exists guest_decks_ip_idx on guest_decks (ip_hash, created_at desc);
+create index if not exists guest_decks_expires_at_idx on guest_decks (expires_at) where claimed_by is null;
+alter table guest_decks enable row level security;
+
+-- Attaches a guest deck, its answers and its uploads to a user, once and before it
+-- expires. Returns no row when the token does not match.
+-- +goose StatementBegin
+create or replace function claim_guest_deck(p_deck_id uuid, p_claim_token text, p_user_id uuid)
+returns setof guest_decks as $$
+declare
+  claimed guest_decks;
+begin
+  update guest_decks set claimed_by = p_user_id, claimed_at = now()
+  where deck_id = p_deck_id and claim_token = p_claim_token
+    and claimed_by is null and expires_at > now()
+  returning * into claimed;
+  if not found then
+    return;
+  end if;
+
+  update pitch_decks set user_id = p_user_id where id = p_deck_id;
+  update deck_inputs set user_id = p_user_id where deck_id = p_deck_id;
+  update user_files set user_id = p_user_id where user_id = claimed.guest_id;
+  return next claimed;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists claim_guest_deck(uuid, text, uuid);
+drop table if exists guest_decks;
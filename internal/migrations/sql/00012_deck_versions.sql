@@ -0,0 +1,29 @@
+-- Markdown of each render of a deck, from its generation and every regeneration or
+-- edit after it, to compare what changed. Versions are never changed once created.
+
+-- +goose Up
+create table if not exists deck_versions (
+  id uuid primary key default gen_random_uuid(),
+  deck_id uuid not null references pitch_decks(id) on delete cascade,
+  version integer not null,
+  markdown text not null,
+  created_at timestamptz not null default now(),
+  unique (deck_id, version)
+);
+alter table deck_versions enable row level security;
+
+-- Numbers the versions of each deck from 1, the unique constraint rejects the
+-- second of two concurrent versions
+-- +goose StatementBegin
+create or replace function create_deck_version(p_deck_id uuid, p_markdown text)
+returns setof deck_versions as $$
+  insert into deck_versions (deck_id, version, markdown)
+  select p_deck_id, coalesce(max(version), 0) + 1, p_markdown
+  from deck_versions where deck_id = p_deck_id
+  returning *;
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists create_deck_version(uuid, text);
+drop table if exists deck_versions;
@@ -0,0 +1,9 @@
+-- Revision of each deck, incremented by every change made by a user. Clients send
+-- back the revision they changed so that of two concurrent changes the second one
+-- is rejected instead of overwriting the first.
+
+-- +goose Up
+alter table pitch_decks add column if not exists revision integer not null default 1;
+
+-- +goose Down
+alter table pitch_decks drop column if exists revision;
@@ -0,0 +1,16 @@
+-- Times a deck is scheduled to become public (e.g. after a demo day embargo) and to
+-- become private again. The server applies them every minute and clears them once
+-- applied; until then the public pages of the deck compare them to the current time.
+
+-- +goose Up
+alter table pitch_decks add column if not exists publish_at timestamptz;
+alter table pitch_decks add column if not exists unpublish_at timestamptz;
+
+create index if not exists pitch_decks_publish_at_idx on pitch_decks (publish_at) where publish_at is not null;
+create index if not exists pitch_decks_unpublish_at_idx on pitch_decks (unpublish_at) where unpublish_at is not null;
+
+-- +goose Down
+drop index if exists pitch_decks_unpublish_at_idx;
+drop index if exists pitch_decks_publish_at_idx;
+alter table pitch_decks drop column if exists unpublish_at;
+alter table pitch_decks drop column if exists publish_at;
@@ -0,0 +1,10 @@
+-- Time after which a share link, or every public page of a deck, stops working and
+-- answers 410 Gone, for decks shared for a limited time such as a data room.
+
+-- +goose Up
+alter table share_links add column if not exists expires_at timestamptz;
+alter table pitch_decks add column if not exists expires_at timestamptz;
+
+-- +goose Down
+alter table pitch_decks drop column if exists expires_at;
+alter table share_links drop column if exists expires_at;
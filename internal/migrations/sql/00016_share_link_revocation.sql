@@ -0,0 +1,8 @@
+-- Share links are given to one recipient each; revoking a link stops it working while
+-- keeping its analytics.
+
+-- +goose Up
+alter table share_links add column if not exists revoked_at timestamptz;
+
+-- +goose Down
+alter table share_links drop column if exists revoked_at;
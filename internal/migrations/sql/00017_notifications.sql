@@ -0,0 +1,21 @@
+-- Notifications of the events on the decks of a user (generation finished or failed,
+-- deck opened through a share link, comment added), listed by the notification bell
+-- of the app until they are read.
+
+-- +goose Up
+create table if not exists notifications (
+  id uuid primary key,
+  user_id uuid not null,
+  type text not null,
+  deck_id uuid references pitch_decks(id) on delete cascade,
+  message text not null,
+  metadata jsonb,
+  read_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists notifications_user_id_idx on notifications (user_id, created_at desc);
+create index if not exists notifications_unread_idx on notifications (user_id) where read_at is null;
+alter table notifications enable row level security;
+
+-- +goose Down
+drop table if exists notifications;
@@ -0,0 +1,51 @@
+-- Email preferences of each user, with the address read from their token when they
+-- opted in, and when they were last sent the weekly digest of the activity on their
+-- decks.
+
+-- +goose Up
+create table if not exists email_preferences (
+  user_id uuid primary key,
+  email text not null default '',
+  weekly_digest boolean not null default false,
+  last_digest_at timestamptz,
+  updated_at timestamptz not null default now()
+);
+create index if not exists email_preferences_digest_idx on email_preferences (last_digest_at) where weekly_digest;
+alter table email_preferences enable row level security;
+
+-- +goose StatementBegin
+create or replace function set_email_preferences(p_user_id uuid, p_email text, p_weekly_digest boolean)
+returns setof email_preferences as $$
+  insert into email_preferences (user_id, email, weekly_digest)
+  values (p_user_id, p_email, p_weekly_digest)
+  on conflict (user_id) do update set
+    email = coalesce(nullif(excluded.email, ''), email_preferences.email),
+    weekly_digest = excluded.weekly_digest,
+    updated_at = now()
+  returning *;
+$$ language sql;
+-- +goose StatementEnd
+
+-- Hands the digests due since p_interval to an instance, marking them sent. Rows are
+-- locked so concurrent instances never send the same digest.
+-- +goose StatementBegin
+create or replace function claim_digests(p_interval interval, p_limit integer)
+returns setof email_preferences as $$
+  with due as (
+    select user_id from email_preferences
+    where weekly_digest and email <> ''
+      and (last_digest_at is null or last_digest_at < now() - p_interval)
+    limit p_limit
+    for update skip locked
+  )
+  update email_preferences p set last_digest_at = now()
+  from due
+  where p.user_id = due.user_id
+  returning p.*;
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists claim_digests(interval, integer);
+drop function if exists set_email_preferences(uuid, text, boolean);
+drop table if exists email_preferences;
@@ -0,0 +1,15 @@
+-- Data key of each user, encrypting the markdown and the answers of their decks when
+-- ENCRYPTION_KEY is set. Keys are stored wrapped by that master key, which never
+-- reaches the database. Deleting the key of a user makes their encrypted data
+-- unreadable, including in backups.
+
+-- +goose Up
+create table if not exists user_data_keys (
+  user_id uuid primary key,
+  wrapped_key text not null,
+  created_at timestamptz not null default now()
+);
+alter table user_data_keys enable row level security;
+
+-- +goose Down
+drop table if exists user_data_keys;
@@ -0,0 +1,54 @@
+-- Users signing in through an enterprise identity provider (OIDC), by issuer and
+-- subject, with the user they are mapped to.
+
+-- +goose Up
+create table if not exists sso_identities (
+  issuer text not null,
+  subject text not null,
+  user_id uuid not null,
+  email text not null default '',
+  created_at timestamptz not null default now(),
+  last_login_at timestamptz not null default now(),
+  primary key (issuer, subject)
+);
+create index if not exists sso_identities_user_id_idx on sso_identities (user_id);
+alter table sso_identities enable row level security;
+
+-- Returns the user of an identity, mapping it on first sign in to the account with
+-- the same email when p_link_email is set, or else to a new user. It reads the
+-- accounts of every user, only the service role may call it.
+-- +goose StatementBegin
+create or replace function resolve_sso_identity(p_issuer text, p_subject text, p_email text, p_link_email boolean)
+returns uuid as $$
+declare
+  v_user_id uuid;
+begin
+  update sso_identities set
+    email = coalesce(nullif(p_email, ''), email),
+    last_login_at = now()
+  where issuer = p_issuer and subject = p_subject
+  returning user_id into v_user_id;
+  if v_user_id is not null then
+    return v_user_id;
+  end if;
+
+  if p_link_email and p_email <> '' then
+    select id into v_user_id from auth.users
+    where lower(email) = lower(p_email)
+    order by created_at
+    limit 1;
+  end if;
+
+  insert into sso_identities (issuer, subject, user_id, email)
+  values (p_issuer, p_subject, coalesce(v_user_id, gen_random_uuid()), p_email)
+  on conflict (issuer, subject) do update set last_login_at = now()
+  returning user_id into v_user_id;
+  return v_user_id;
+end;
+$$ language plpgsql security definer set search_path = public;
+-- +goose StatementEnd
+revoke execute on function resolve_sso_identity(text, text, text, boolean) from public, anon, authenticated;
+
+-- +goose Down
+drop function if exists resolve_sso_identity(text, text, text, boolean);
+drop table if exists sso_identities;
@@ -0,0 +1,40 @@
+-- White-label customers served by one deployment in multi-tenant mode (MULTI_TENANT),
+-- recognized by the hostnames they are served on or by the tenant_id of the
+-- app_metadata of the tokens of their users. Users belong to the tenant they first
+-- signed in to, their decks and organizations to the tenant of their creator. The
+-- empty tenant is the deployment's own.
+
+-- +goose Up
+create table if not exists tenants (
+  id text primary key check (id ~ '^[a-z0-9-]+$'),
+  name text not null,
+  hostnames text[] not null default '{}',
+  -- Bucket of the files of the tenant, the shared bucket under tenants/<id>/ when null
+  bucket text,
+  -- Themes its users can generate decks with, every theme when empty
+  themes text[] not null default '{}',
+  -- Decks its users can create per month altogether, on top of their plans
+  decks_per_month integer,
+  branding jsonb not null default '{}',
+  created_at timestamptz not null default now()
+);
+alter table tenants enable row level security;
+
+create table if not exists tenant_users (
+  user_id uuid primary key,
+  tenant_id text not null,
+  created_at timestamptz not null default now()
+);
+create index if not exists tenant_users_tenant_id_idx on tenant_users (tenant_id);
+alter table tenant_users enable row level security;
+
+alter table pitch_decks add column if not exists tenant_id text not null default '';
+create index if not exists pitch_decks_tenant_created_idx on pitch_decks (tenant_id, created_at);
+alter table organizations add column if not exists tenant_id text not null default '';
+
+-- +goose Down
+alter table organizations drop column if exists tenant_id;
+drop index if exists pitch_decks_tenant_created_idx;
+alter table pitch_decks drop column if exists tenant_id;
+drop table if exists tenant_users;
+drop table if exists tenants;
@@ -0,0 +1,12 @@
+-- Branding of the public viewer of the decks shared with an organization, and the
+-- domain of the organization its viewer is served on.
+
+-- +goose Up
+alter table organizations add column if not exists viewer_branding jsonb;
+alter table organizations add column if not exists custom_domain text;
+create unique index if not exists organizations_custom_domain_idx on organizations (custom_domain);
+
+-- +goose Down
+drop index if exists organizations_custom_domain_idx;
+alter table organizations drop column if exists custom_domain;
+alter table organizations drop column if exists viewer_branding;
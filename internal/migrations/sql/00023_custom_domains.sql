@@ -0,0 +1,16 @@
+-- Domains of users serving their public decks, once verified with a TXT record
+-- holding the verification token.
+
+-- +goose Up
+create table if not exists custom_domains (
+  domain text primary key,
+  user_id uuid not null,
+  verification_token text not null,
+  verified_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists custom_domains_user_id_idx on custom_domains (user_id);
+alter table custom_domains enable row level security;
+
+-- +goose Down
+drop table if exists custom_domains;
@@ -0,0 +1,17 @@
+-- Uploads rejected because they contain malware, kept on the server that received
+-- them until the janitor removes them.
+
+-- +goose Up
+create table if not exists quarantined_files (
+  id uuid primary key,
+  user_id uuid not null,
+  original_name text not null default '',
+  signature text not null,
+  local_path text not null default '',
+  created_at timestamptz not null default now()
+);
+create index if not exists quarantined_files_user_id_idx on quarantined_files (user_id);
+alter table quarantined_files enable row level security;
+
+-- +goose Down
+drop table if exists quarantined_files;
@@ -0,0 +1,20 @@
+-- Intents of storing files and recording them, written before the upload and removed
+-- once the record is written. The outbox reconciler completes the entries left behind.
+
+-- +goose Up
+create table if not exists storage_outbox (
+  id uuid primary key,
+  user_id uuid not null,
+  kind text not null,
+  bucket text not null,
+  paths text[] not null default '{}',
+  record jsonb,
+  stored_at timestamptz,
+  created_at timestamptz not null default now()
+);
+create index if not exists storage_outbox_user_id_idx on storage_outbox (user_id);
+create index if not exists storage_outbox_created_at_idx on storage_outbox (created_at);
+alter table storage_outbox enable row level security;
+
+-- +goose Down
+drop table if exists storage_outbox;
@@ -0,0 +1,34 @@
+-- Storage objects of each deck, the files of every render. Rows outlive their deck so
+-- its files can still be found and deleted; deletions remove the rows with the files.
+
+-- +goose Up
+create table if not exists deck_assets (
+  id uuid primary key default gen_random_uuid(),
+  deck_id uuid not null,
+  user_id uuid not null,
+  kind text not null,
+  bucket text not null,
+  path text not null,
+  size bigint not null default 0,
+  created_at timestamptz not null default now(),
+  unique (bucket, path)
+);
+create index if not exists deck_assets_deck_id_idx on deck_assets (deck_id);
+create index if not exists deck_assets_user_id_idx on deck_assets (user_id);
+alter table deck_assets enable row level security;
+
+-- Files named after their content are stored once, rendering the same deck again
+-- records them again
+-- +goose StatementBegin
+create or replace function record_deck_assets(p_assets jsonb)
+returns void as $$
+  insert into deck_assets (deck_id, user_id, kind, bucket, path, size)
+  select deck_id, user_id, kind, bucket, path, coalesce(size, 0)
+  from jsonb_to_recordset(p_assets) as a(deck_id uuid, user_id uuid, kind text, bucket text, path text, size bigint)
+  on conflict (bucket, path) do nothing;
+$$ language sql;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists record_deck_assets(jsonb);
+drop table if exists deck_assets;
@@ -0,0 +1,71 @@
+-- Storage each plan includes for the files uploaded by its users, with the size of
+-- every upload. A null limit is unlimited.
+
+-- +goose Up
+alter table user_files add column if not exists size bigint not null default 0;
+
+alter table plans add column if not exists max_storage_bytes bigint;
+
+update plans set max_storage_bytes = 250 * 1024 * 1024 where id = 'free';
+update plans set max_storage_bytes = 20::bigint * 1024 * 1024 * 1024 where id = 'pro';
+
+-- The uploads made before sizes were recorded take theirs from the storage, on
+-- Supabase. Their names end with the path of the upload, under the folder of the
+-- environment.
+-- +goose StatementBegin
+do $$
+begin
+  if to_regclass('storage.objects') is not null then
+    update user_files f set size = coalesce((o.metadata->>'size')::bigint, 0)
+    from storage.objects o
+    where f.size = 0 and (o.name = f.storage_path or o.name like '%/' || f.storage_path);
+  end if;
+end
+$$;
+-- +goose StatementEnd
+
+-- The return type changes, the function cannot be replaced
+drop function if exists user_plan(uuid);
+
+-- +goose StatementBegin
+create function user_plan(p_user_id uuid)
+returns table (
+  id text, name text, decks_per_month integer, max_uploads integer, max_storage_bytes bigint,
+  watermark boolean, custom_themes boolean, models text[], max_output_tokens integer,
+  decks_this_month integer, uploads integer, storage_bytes bigint
+) as $$
+  select p.id, p.name, p.decks_per_month, p.max_uploads, p.max_storage_bytes,
+    p.watermark, p.custom_themes, p.models, p.max_output_tokens,
+    (select count(*)::integer from pitch_decks d
+      where d.user_id = p_user_id
+        and d.created_at >= date_trunc('month', now() at time zone 'utc') at time zone 'utc'),
+    (select count(*)::integer from user_files f where f.user_id = p_user_id),
+    (select coalesce(sum(f.size), 0)::bigint from user_files f where f.user_id = p_user_id)
+  from plans p
+  where p.id = coalesce((select up.plan_id from user_plans up where up.user_id = p_user_id), 'free');
+$$ language sql stable;
+-- +goose StatementEnd
+
+-- +goose Down
+drop function if exists user_plan(uuid);
+
+-- +goose StatementBegin
+create function user_plan(p_user_id uuid)
+returns table (
+  id text, name text, decks_per_month integer, max_uploads integer,
+  watermark boolean, custom_themes boolean, models text[], max_output_tokens integer,
+  decks_this_month integer, uploads integer
+) as $$
+  select p.id, p.name, p.decks_per_month, p.max_uploads, p.watermark, p.custom_themes,
+    p.models, p.max_output_tokens,
+    (select count(*)::integer from pitch_decks d
+      where d.user_id = p_user_id
+        and d.created_at >= date_trunc('month', now() at time zone 'utc') at time zone 'utc'),
+    (select count(*)::integer from user_files f where f.user_id = p_user_id)
+  from plans p
+  where p.id = coalesce((select up.plan_id from user_plans up where up.user_id = p_user_id), 'free');
+$$ language sql stable;
+-- +goose StatementEnd
+
+alter table plans drop column if exists max_storage_bytes;
+alter table user_files drop column if exists size;
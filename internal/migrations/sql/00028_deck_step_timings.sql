@@ -0,0 +1,8 @@
+-- Durations in milliseconds of the steps of the last generation of each deck, keyed
+-- by step (llm, render_pdf, render_html, upload, total)
+
+-- +goose Up
+alter table pitch_decks add column if not exists step_timings jsonb;
+
+-- +goose Down
+alter table pitch_decks drop column if exists step_timings;
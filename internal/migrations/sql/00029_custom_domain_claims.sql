@@ -0,0 +1,19 @@
+-- Several users may claim a domain while it is unverified, so that a claim never
+-- reserves a domain someone else owns. Only one claim of a domain can be verified.
+
+-- +goose Up
+alter table custom_domains drop constraint if exists custom_domains_pkey;
+alter table custom_domains add primary key (domain, user_id);
+create unique index if not exists custom_domains_verified_domain_idx
+  on custom_domains (domain) where verified_at is not null;
+
+-- +goose Down
+drop index if exists custom_domains_verified_domain_idx;
+delete from custom_domains c
+  where exists (
+    select 1 from custom_domains o
+    where o.domain = c.domain and o.user_id <> c.user_id
+      and (o.verified_at is not null or (c.verified_at is null and o.created_at < c.created_at))
+  );
+alter table custom_domains drop constraint if exists custom_domains_pkey;
+alter table custom_domains add primary key (domain);
@@ -0,0 +1,23 @@
+-- The custom domain of an organization is served once it is verified with a TXT
+-- record, like the domains of users. Several organizations may claim a domain while
+-- it is unverified, only one of them can verify it.
+
+-- +goose Up
+alter table organizations add column if not exists custom_domain_token text;
+alter table organizations add column if not exists custom_domain_verified_at timestamptz;
+drop index if exists organizations_custom_domain_idx;
+create unique index if not exists organizations_custom_domain_idx
+  on organizations (custom_domain) where custom_domain_verified_at is not null;
+
+-- +goose Down
+drop index if exists organizations_custom_domain_idx;
+update organizations o set custom_domain = null
+  where custom_domain is not null and exists (
+    select 1 from organizations other
+    where other.custom_domain = o.custom_domain and other.id <> o.id
+      and (other.custom_domain_verified_at is not null
+        or (o.custom_domain_verified_at is null and other.created_at < o.created_at))
+  );
+create unique index if not exists organizations_custom_domain_idx on organizations (custom_domain);
+alter table organizations drop column if exists custom_domain_verified_at;
+alter table organizations drop column if exists custom_domain_token;
@@ -1,16 +1,106 @@
 package model
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/telegram"
+)
+
+// Errors wrapped by services so handlers can answer with the matching status code
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrUnavailable  = errors.New("unavailable")
+	// The plan of the user does not allow the action
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// The deck or link is no longer shared: it expired or was revoked
+	ErrExpired = errors.New("expired")
+)
+
+// privateObjectPath is in the URLs of the objects of private buckets
+const privateObjectPath = "/storage/v1/object/authenticated/"
 
 type PitchDeckInfo struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Name      string    `json:"name"`
-	PdfURL    string    `json:"pdf_url"`
-	HtmlURL   string    `json:"html_url"`
-	IsPublic  bool      `json:"is_public"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string `json:"id"`
+	UserID      string `json:"user_id"`
+	Name        string `json:"name"`
+	Slug        string `json:"slug,omitempty"`
+	PdfURL      string `json:"pdf_url"`
+	HtmlURL     string `json:"html_url"`
+	MarkdownURL string `json:"markdown_url,omitempty"`
+	ProjectID   string `json:"project_id,omitempty"`
+	OrgID       string `json:"org_id,omitempty"`
+	IsPublic    bool   `json:"is_public"`
+	Status      string `json:"status"`
+	// Tenant of the user who created the deck, empty outside of multi-tenant mode
+	TenantID string `json:"tenant_id,omitempty"`
+	// Classification and message of the last generation failure
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	// Views of the public deck, maintained by the record_deck_view function
+	ViewCount    int        `json:"view_count,omitempty"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	// Set when an administrator takes down an abusive public deck
+	TakenDownAt    *time.Time `json:"taken_down_at,omitempty"`
+	TakedownReason string     `json:"takedown_reason,omitempty"`
+	// Times the deck is scheduled to become public and private again, cleared once
+	// the schedule applied them
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+	// Time after which the public pages and share links of the deck stop working
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Version of the generation prompt, empty for the template built into the server
+	PromptVersionID string `json:"prompt_version_id,omitempty"`
+	// Durations in milliseconds of the steps of the last generation, by step (llm,
+	// render_pdf, render_html, upload and total)
+	StepTimings map[string]int64 `json:"step_timings,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	// Set by the database on every write of the record
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Incremented by every change made by a user, changes are made from a revision
+	Revision int `json:"revision,omitempty"`
+}
+
+// WithFileProxy returns the deck with the URLs of its files replaced, when it is
+// private, by the API endpoint under baseURL serving them to the users allowed to see
+// them. The files of private decks are in a private bucket, their object URLs cannot
+// be opened; so are the files of a deck made public until they are moved.
+func (d PitchDeckInfo) WithFileProxy(baseURL string) PitchDeckInfo {
+	if d.IsPublic && !strings.Contains(d.PdfURL+d.HtmlURL, privateObjectPath) {
+		return d
+	}
+	files := strings.TrimSuffix(baseURL, "/") + "/api/pitch-decks/" + d.ID + "/file/"
+	if d.PdfURL != "" {
+		d.PdfURL = files + "pdf"
+	}
+	if d.HtmlURL != "" {
+		d.HtmlURL = files + "html"
+	}
+	if d.MarkdownURL != "" {
+		d.MarkdownURL = files + "md"
+	}
+	return d
+}
+
+// StaleError rejects a change made from an earlier revision of a deck than its
+// current one, which it holds
+type StaleError struct {
+	Current *PitchDeckInfo
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%v: the deck was changed since, it is now at revision %d", ErrConflict, e.Current.Revision)
+}
+
+func (e *StaleError) Unwrap() error {
+	return ErrConflict
 }
 
 type PitchDeckData struct {
@@ -30,12 +120,24 @@ type PitchDeckData struct {
 	DevelopmentPlan string `json:"developmentPlan"`
 	MarketSize      string `json:"marketSize"`
 
+	// Competitors compared on the competitive landscape slide, researched from their websites
+	Competitors []Competitor `json:"competitors"`
+
 	// Step 4: Fundraising & Investment Details
 	FundingAmount       string `json:"fundingAmount"`
 	FundingUse          string `json:"fundingUse"`
 	Valuation           string `json:"valuation"`
 	InvestmentStructure string `json:"investmentStructure"`
 
+	// Round the deck raises: "pre_seed", "seed" or "series_a", which sets what the
+	// deck emphasizes and which answers are required
+	FundingStage string `json:"fundingStage"`
+
+	// Business model and traction, expected from seed onwards
+	RevenueModel   string `json:"revenueModel"`
+	Traction       string `json:"traction"`
+	NextMilestones string `json:"nextMilestones"`
+
 	// Step 5: Market Opportunity
 	TAM          string `json:"tam"`
 	SAM          string `json:"sam"`
@@ -43,6 +145,8 @@ type PitchDeckData struct {
 	TargetNiche  string `json:"targetNiche"`
 	MarketTrends string `json:"marketTrends"`
 	Industry     string `json:"industry"`
+	// Geography of the market, for the estimates of the market sizes left empty
+	Geography string `json:"geography"`
 
 	// Step 6: Team & Experience
 	WhyYou            string       `json:"whyYou"`
@@ -55,15 +159,95 @@ type PitchDeckData struct {
 	CompanyLogo string `json:"companyLogo"`
 	TeamPhoto   string `json:"teamPhoto"`
 	Diagram     string `json:"diagram"`
+	// Website of the company, its logo is fetched from it when CompanyLogo is empty
+	Website string `json:"website"`
+	// Link to a YouTube or Loom video, or URL of an uploaded video, presented on a
+	// slide of its own: played in the HTML export, linked with a QR code in the PDF
+	ProductDemoVideo string `json:"productDemoVideo"`
 
 	// Theme Selection
-	Theme string `json:"theme"`
+	Theme     string `json:"theme"`
+	CodeTheme string `json:"codeTheme"`
+	// Layouts of some slides, for users who want control over their design
+	SlideLayouts []SlideLayout `json:"slideLayouts"`
+	// Slides added to the generated deck, for topics its structure does not cover
+	CustomSlides []CustomSlide `json:"customSlides"`
+
+	// Language the deck is written in (ISO code or English name), defaults to English
+	Language string `json:"language"`
+
+	// EmojiPolicy is "keep" (default), "strip" or "image" to replace emoji with images
+	EmojiPolicy string `json:"emojiPolicy"`
+
+	// AccessiblePDF produces a tagged PDF, with the structure and outline of the deck
+	// for screen readers
+	AccessiblePDF bool `json:"accessiblePdf"`
+
+	// ExportProfile is "standard" (default) or "pdfa" for a PDF/A file to archive
+	ExportProfile string `json:"exportProfile"`
+
+	// Project the deck belongs to, its settings fill the fields left empty
+	ProjectID string `json:"projectId"`
+
+	// AudiencePersona describes who the deck is pitched to (e.g. "seed-stage VCs")
+	AudiencePersona string `json:"audiencePersona"`
+
+	// DeckType is "fundraising" (default), "sales", "investor_update" or
+	// "grant_application", each generated with its own slide structure
+	DeckType string `json:"deckType"`
+
+	// Generation controls the LLM writing the deck, within the limits of the plan
+	Generation GenerationSettings `json:"generation"`
+
+	// Sealed holds the other fields encrypted when the answers are stored with
+	// encryption at rest, the other fields are then empty
+	Sealed string `json:"sealed,omitempty"`
+}
+
+// GenerationSettings are the parameters of the LLM generating a deck. Preset is
+// "balanced" (default), "precise" or "creative", the other fields override it.
+type GenerationSettings struct {
+	Preset      string   `json:"preset,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+}
+
+// Competitor is a competitor of the company, by name, website or both
+type Competitor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// SlideLayout asks for a layout on a slide of the deck: "two-column",
+// "full-bleed-image", "quote" or "statement"
+type SlideLayout struct {
+	// Slide the layout applies to, as named in the deck (e.g. "Team", "Problem")
+	Section string `json:"section"`
+	Layout  string `json:"layout"`
+}
+
+// CustomSlide is a slide written by the user, added to the deck after its
+// generation. It is either a title and its content, or raw Marp markdown.
+type CustomSlide struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Markdown string `json:"markdown"`
+	// Number of the slide in the deck (1 is first), appended at the end when 0 or
+	// beyond the last slide
+	Position int `json:"position"`
 }
 
 type TeamMember struct {
 	Name       string `json:"name"`
 	Role       string `json:"role"`
 	Experience string `json:"experience"`
+	// LinkedIn profile URL, read for a richer bio and a photo
+	LinkedIn string `json:"linkedin"`
+	// Profile text pasted by the user, used instead of reading LinkedIn
+	Profile string `json:"profile"`
+	// Photo URL, taken from the LinkedIn profile when empty
+	Photo string `json:"photo"`
 }
 
 type ContactInfo struct {
@@ -72,16 +256,811 @@ type ContactInfo struct {
 	Socials  string `json:"socials"`
 }
 
+// DeckListOptions filters, sorts and paginates the decks of a user
+type DeckListOptions struct {
+	// ProjectID restricts the list to a project, "none" to decks without project
+	ProjectID string
+	Status    string
+	// Visibility is "public" or "private"
+	Visibility    string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Sort is "created_at" (newest first by default) or "name"
+	Sort   string
+	Order  string
+	Limit  int
+	Offset int
+	// TenantID restricts the list to the decks of a tenant, set from the user
+	TenantID string
+}
+
+// DeckPage is a page of a deck list with the number of decks matching the filters
+type DeckPage struct {
+	Decks  []PitchDeckInfo `json:"decks"`
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+}
+
+// DeckSearchResult is a deck matching a search. Title and Snippet are HTML escaped,
+// with the matching words wrapped in <mark> tags.
+type DeckSearchResult struct {
+	Deck    PitchDeckInfo `json:"deck"`
+	Title   string        `json:"title"`
+	Snippet string        `json:"snippet"`
+}
+
+// GenerationEstimate is the expected size and cost of generating a deck, computed
+// before the generation without calling the LLM
+type GenerationEstimate struct {
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+	CostUSD      float64 `json:"costUsd"`
+	// Decks of the monthly allowance used, and left (nil when unlimited)
+	Credits          int      `json:"credits"`
+	RemainingCredits *int     `json:"remainingCredits"`
+	Warnings         []string `json:"warnings,omitempty"`
+}
+
+// Theme is a theme decks can be generated with
+type Theme struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Palette    ThemePalette `json:"palette"`
+	PreviewURL string       `json:"previewUrl"`
+}
+
+// ThemePalette holds the main colors of a theme
+type ThemePalette struct {
+	Background string `json:"background"`
+	Text       string `json:"text"`
+	Accent     string `json:"accent"`
+}
+
+// DeckVersion is the markdown of a deck as one of its renders left it
+type DeckVersion struct {
+	ID        string    `json:"id"`
+	DeckID    string    `json:"deck_id"`
+	Version   int       `json:"version"`
+	Markdown  string    `json:"markdown,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DeckAsset is a storage object of a deck, one of the files of a render
+type DeckAsset struct {
+	DeckID    string    `json:"deck_id"`
+	UserID    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Bucket    string    `json:"bucket"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// DeckDiff compares the slides of two versions of a deck
+type DeckDiff struct {
+	From      int         `json:"from"`
+	To        int         `json:"to"`
+	Added     int         `json:"added"`
+	Removed   int         `json:"removed"`
+	Changed   int         `json:"changed"`
+	Unchanged int         `json:"unchanged"`
+	Slides    []SlideDiff `json:"slides"`
+}
+
+// SlideDiff is how a slide changed between two versions: "added", "removed",
+// "changed" or "unchanged". The slide numbers are 0 in the version without it.
+type SlideDiff struct {
+	Status       string   `json:"status"`
+	FromSlide    int      `json:"fromSlide,omitempty"`
+	ToSlide      int      `json:"toSlide,omitempty"`
+	Title        string   `json:"title"`
+	AddedLines   []string `json:"addedLines,omitempty"`
+	RemovedLines []string `json:"removedLines,omitempty"`
+}
+
 type PitchDeckService interface {
-	Create(data PitchDeckData, userID string) (*PitchDeckInfo, error)
+	Create(ctx context.Context, data PitchDeckData, userID string) (*PitchDeckInfo, error)
 	Get(deckID string) (*PitchDeckInfo, error)
-	UpdateVisibility(deckID string, userID string, isPublic bool) error
-	ListUserDecks(userID string) ([]PitchDeckInfo, error)
+	UpdateVisibility(ctx context.Context, deckID string, userID string, isPublic bool, revision int) error
+	ListUserDecks(ctx context.Context, userID, projectID string) ([]PitchDeckInfo, error)
+	ListDecks(ctx context.Context, userID string, opts DeckListOptions) (*DeckPage, error)
+	Search(userID, query string, limit int) ([]DeckSearchResult, error)
+	RecordView(deckID, clientIP string) error
+	UpdateSlug(deckID, userID, slug string, revision int) (string, error)
+	SchedulePublishing(deckID, userID string, publishAt, unpublishAt *time.Time, revision int) error
+	SetExpiry(deckID, userID string, expiresAt *time.Time, revision int) error
+	GetForUser(deckID, userID string) (*PitchDeckInfo, error)
+	ExportDecks(userID, format string) ([]byte, error)
+	PublicHTML(deckID, host string) (string, error)
+	EmbedHTML(deckID, host string) (string, error)
+	OEmbed(deckURL, baseURL string, maxWidth, maxHeight int) (*OEmbed, error)
 	UpdateStatus(deckID string, status string) error
-	UploadImage(filePath string) (string, error)
+	UploadImage(filePath, originalName, userID string) (string, error)
+	Import(ctx context.Context, filePath, originalName, theme, userID string) (*PitchDeckInfo, error)
+	ExportContent(deckID, userID string) (*DeckContent, error)
+	StandaloneHTML(deckID, userID string) ([]byte, string, error)
+	DeckFile(deckID, userID, kind string) (*FileDownload, error)
+	ImportContent(ctx context.Context, content DeckContent, userID string) (*PitchDeckInfo, error)
+	ContentSchema() []byte
+	Retry(deckID, userID string) error
+	Estimate(data PitchDeckData, userID string) (*GenerationEstimate, error)
+	SubmitFeedback(deckID, userID string, rating int, comment string, slide *int) (*DeckFeedback, error)
+	IndustryTemplates() []IndustryTemplate
+	Demo(theme string) (*PitchDeckInfo, error)
+	Themes(baseURL, tenantID string) []Theme
+	ThemePreview(theme string) ([]byte, error)
+	PreviewTheme(theme, userID, deckID string, data *PitchDeckData) ([]byte, error)
+	ReorderSlides(deckID, userID string, order []int, revision int) error
+	DeleteSlide(deckID, userID string, number, revision int) error
+	InsertSlide(deckID, userID string, position int, template, title string, revision int) error
+	Versions(deckID, userID string) ([]DeckVersion, error)
+	DiffVersions(deckID, userID string, from, to int) (*DeckDiff, error)
+}
+
+// IndustryTemplate is a deck structure specialized for an industry, selected by
+// setting Industry to its ID or name
+type IndustryTemplate struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Slides []string `json:"slides"`
+}
+
+// DeckContent is the content of a deck in the open PitchTree content format,
+// described by the JSON schema served at /schema/pitch-deck-content/v1.json.
+// It is independent of PitchDeckData so the form can evolve without breaking it.
+type DeckContent struct {
+	Schema    string          `json:"$schema,omitempty"`
+	Version   string          `json:"version"`
+	Meta      ContentMeta     `json:"meta"`
+	Company   ContentCompany  `json:"company"`
+	Problem   ContentProblem  `json:"problem,omitzero"`
+	Solution  ContentSolution `json:"solution,omitzero"`
+	Market    ContentMarket   `json:"market,omitzero"`
+	Funding   ContentFunding  `json:"funding,omitzero"`
+	Team      ContentTeam     `json:"team,omitzero"`
+	Contact   ContactInfo     `json:"contact,omitzero"`
+	Takeaways string          `json:"takeaways,omitempty"`
+	// Slides hold the rendered content, an import with slides skips the generation
+	Slides []ContentSlide `json:"slides,omitempty"`
+}
+
+type ContentMeta struct {
+	Title     string     `json:"title,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	Theme     string     `json:"theme,omitempty"`
+	CodeTheme string     `json:"codeTheme,omitempty"`
+	Audience  string     `json:"audience,omitempty"`
+	Generator string     `json:"generator,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type ContentCompany struct {
+	Name     string `json:"name"`
+	Tagline  string `json:"tagline,omitempty"`
+	Industry string `json:"industry,omitempty"`
+	Logo     string `json:"logo,omitempty"`
+}
+
+type ContentProblem struct {
+	Statement         string `json:"statement,omitempty"`
+	Audience          string `json:"audience,omitempty"`
+	ExistingSolutions string `json:"existingSolutions,omitempty"`
+}
+
+type ContentSolution struct {
+	Description     string `json:"description,omitempty"`
+	Technology      string `json:"technology,omitempty"`
+	Differentiators string `json:"differentiators,omitempty"`
+	Roadmap         string `json:"roadmap,omitempty"`
+	Diagram         string `json:"diagram,omitempty"`
+}
+
+type ContentMarket struct {
+	Size   string `json:"size,omitempty"`
+	TAM    string `json:"tam,omitempty"`
+	SAM    string `json:"sam,omitempty"`
+	SOM    string `json:"som,omitempty"`
+	Niche  string `json:"niche,omitempty"`
+	Trends string `json:"trends,omitempty"`
+}
+
+type ContentFunding struct {
+	Amount     string `json:"amount,omitempty"`
+	Use        string `json:"use,omitempty"`
+	Valuation  string `json:"valuation,omitempty"`
+	Instrument string `json:"instrument,omitempty"`
+}
+
+type ContentTeam struct {
+	Members        []TeamMember `json:"members,omitempty"`
+	WhyUs          string       `json:"whyUs,omitempty"`
+	Qualifications string       `json:"qualifications,omitempty"`
+	Photo          string       `json:"photo,omitempty"`
+}
+
+type ContentSlide struct {
+	Title    string `json:"title,omitempty"`
+	Markdown string `json:"markdown"`
+}
+
+// OEmbed is the oEmbed response of a public deck (https://oembed.com)
+type OEmbed struct {
+	Type         string `json:"type"`
+	Version      string `json:"version"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 type StorageService interface {
 	UploadFile(filePath, bucketName, fileName string) (string, error)
+	// UploadImmutable stores a file under a name derived from fileName and its content
+	UploadImmutable(filePath, bucketName, fileName string) (string, error)
 	DownloadFile(url string, destPath string) error
+	ListFiles(bucketName, folder string) ([]StorageObject, error)
+	DeleteFiles(bucketName string, paths []string) error
+}
+
+// DeckRepository persists deck records and the answers they were generated from.
+// Get returns ErrNotFound for unknown decks. WithTx runs fn with a repository bound
+// to a transaction, committed when fn returns nil.
+type DeckRepository interface {
+	Get(ctx context.Context, idOrSlug string) (*PitchDeckInfo, error)
+	Save(ctx context.Context, deck *PitchDeckInfo) error
+	List(ctx context.Context, userID string, orgIDs []string, opts DeckListOptions) ([]PitchDeckInfo, int, error)
+	UpdateStatus(ctx context.Context, deckID, status string) error
+	UpdateVisibility(ctx context.Context, deckID string, isPublic bool) error
+	// Revise increments the revision of a deck from the given one, ErrConflict when
+	// the deck is at another revision
+	Revise(ctx context.Context, deckID string, revision int) error
+	// StartProcessing moves a deck to the processing status, ErrConflict when it
+	// already is
+	StartProcessing(ctx context.Context, deckID string) error
+	RecordFailure(ctx context.Context, deckID, code, message string) error
+	SaveInput(ctx context.Context, deckID, userID string, data PitchDeckData) error
+	Input(ctx context.Context, deckID string) (*PitchDeckData, error)
+	WithTx(ctx context.Context, fn func(repo DeckRepository) error) error
+}
+
+// StorageObject is a file stored in a bucket
+type StorageObject struct {
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// StorageGCReport is the result of a reconciliation of the storage with the database
+type StorageGCReport struct {
+	Scanned        int       `json:"scanned"`
+	Orphans        []string  `json:"orphans"`
+	Deleted        int       `json:"deleted"`
+	ReclaimedBytes int64     `json:"reclaimedBytes"`
+	DryRun         bool      `json:"dryRun"`
+	RanAt          time.Time `json:"ranAt"`
+}
+
+// NotionExportOptions controls how a deck is pushed to Notion
+type NotionExportOptions struct {
+	// ParentPageID is the Notion page the deck is created under, defaults to the
+	// first page shared with the integration
+	ParentPageID string `json:"parentPageId"`
+	// Mode is "pages" for one Notion page per slide or "toggles" for a single
+	// page with one toggle section per slide
+	Mode string `json:"mode"`
+}
+
+type IntegrationService interface {
+	NotionAuthorizeURL(state string) (string, error)
+	ConnectNotion(userID, code string) error
+	ExportToNotion(deckID, userID string, opts NotionExportOptions) (string, error)
+}
+
+// UserFile is an image uploaded by a user, stored in the user_files table
+type UserFile struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	OriginalName string    `json:"original_name"`
+	FileURL      string    `json:"file_url"`
+	StoragePath  string    `json:"storage_path"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Sizes of the files accepted as resumable uploads
+const (
+	MaxResumableImageSize = 32 << 20
+	MaxResumableVideoSize = 500 << 20
+)
+
+// ResumableUpload is an image or video uploaded in chunks with the tus protocol. It
+// is stored once all its bytes are received, FileURL is then set.
+type ResumableUpload struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Length      int64     `json:"length"`
+	Offset      int64     `json:"-"`
+	FileURL     string    `json:"file_url,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// AuditEvent is a lifecycle event of a deck, stored in the audit_log table
+type AuditEvent struct {
+	ID        string                 `json:"id"`
+	DeckID    string                 `json:"deck_id"`
+	ActorID   string                 `json:"actor_id"`
+	Action    string                 `json:"action"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// DeckFailure is a failed generation kept in the deck_failures dead-letter table
+type DeckFailure struct {
+	ID        string     `json:"id"`
+	DeckID    string     `json:"deck_id"`
+	Stage     string     `json:"stage"`
+	Code      string     `json:"code"`
+	Message   string     `json:"message"`
+	Error     string     `json:"error"`
+	Stderr    string     `json:"stderr,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetriedAt *time.Time `json:"retried_at,omitempty"`
+}
+
+// Plan is a subscription tier and its limits, nil limits are unlimited
+type Plan struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	DecksPerMonth *int   `json:"decksPerMonth"`
+	MaxUploads    *int   `json:"maxUploads"`
+	// Total size of the files the user can upload, in bytes
+	MaxStorageBytes *int64 `json:"maxStorageBytes"`
+	Watermark       bool   `json:"watermark"`
+	CustomThemes    bool   `json:"customThemes"`
+	// Models decks can be generated with, and the longest output they can ask for
+	Models          []string `json:"models"`
+	MaxOutputTokens int      `json:"maxOutputTokens"`
+}
+
+// PlanUsage is the plan of a user with their allowance left, nil when unlimited
+type PlanUsage struct {
+	Plan             Plan      `json:"plan"`
+	DecksThisMonth   int       `json:"decksThisMonth"`
+	Uploads          int       `json:"uploads"`
+	StorageBytes     int64     `json:"storageBytes"`
+	RemainingDecks   *int      `json:"remainingDecks"`
+	RemainingUploads *int      `json:"remainingUploads"`
+	RemainingStorage *int64    `json:"remainingStorageBytes"`
+	ResetsAt         time.Time `json:"resetsAt"`
+}
+
+// StorageUsage is the storage taken by the uploads of a user and what their plan
+// includes, in bytes. Limit and Available are nil when unlimited.
+type StorageUsage struct {
+	Plan      string `json:"plan"`
+	Files     int    `json:"files"`
+	Used      int64  `json:"usedBytes"`
+	Limit     *int64 `json:"limitBytes"`
+	Available *int64 `json:"availableBytes"`
+}
+
+// PromptVersion is a version of an LLM prompt template. Versions are not changed once
+// created, new generations use the version published last.
+type PromptVersion struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Version     int        `json:"version"`
+	Template    string     `json:"template"`
+	Notes       string     `json:"notes,omitempty"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// DeckFeedback is a rating of a deck, with the prompt version it was generated with
+type DeckFeedback struct {
+	ID              string    `json:"id"`
+	DeckID          string    `json:"deck_id"`
+	UserID          string    `json:"user_id"`
+	Slide           *int      `json:"slide,omitempty"`
+	Rating          int       `json:"rating"`
+	Comment         string    `json:"comment,omitempty"`
+	PromptVersionID *string   `json:"prompt_version_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PromptFeedbackStats are the ratings of the decks generated with a prompt version,
+// whose ID is nil for the template built into the server
+type PromptFeedbackStats struct {
+	PromptVersionID *string `json:"prompt_version_id"`
+	Name            *string `json:"name"`
+	Version         *int    `json:"version"`
+	Decks           int     `json:"decks"`
+	Ratings         int     `json:"ratings"`
+	AverageRating   float64 `json:"average_rating"`
+}
+
+type PromptService interface {
+	ListVersions(name string) ([]PromptVersion, *PromptVersion, error)
+	GetVersion(name string, version int) (*PromptVersion, error)
+	CreateVersion(name, template, notes, adminID string) (*PromptVersion, error)
+	PublishVersion(name string, version int, adminID string) (*PromptVersion, error)
+}
+
+type PlanService interface {
+	CurrentPlan(userID string) (*PlanUsage, error)
+	StorageUsage(userID string) (*StorageUsage, error)
+}
+
+type AuditService interface {
+	DeckAuditLog(deckID, userID string, limit int) ([]AuditEvent, error)
+}
+
+// Notification tells a user about an event on one of their decks
+type Notification struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	// deck_completed, deck_failed, deck_viewed or comment_added
+	Type      string                 `json:"type"`
+	DeckID    string                 `json:"deck_id,omitempty"`
+	Message   string                 `json:"message"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// NotificationFeed is a page of the notifications of a user, newest first, and the
+// number of notifications they have not read
+type NotificationFeed struct {
+	Notifications []Notification `json:"notifications"`
+	Unread        int            `json:"unread"`
+}
+
+// EmailPreferences are the emails a user agreed to receive, at the address of their
+// account
+type EmailPreferences struct {
+	UserID       string     `json:"user_id"`
+	Email        string     `json:"email"`
+	WeeklyDigest bool       `json:"weekly_digest"`
+	LastDigestAt *time.Time `json:"last_digest_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type AccountService interface {
+	Export(userID string) ([]byte, error)
+	Erase(userID string) error
+}
+
+type DigestService interface {
+	Preferences(userID string) (*EmailPreferences, error)
+	UpdatePreferences(userID, email string, weeklyDigest bool) (*EmailPreferences, error)
+}
+
+// Tenant is a white-label customer of a multi-tenant deployment
+type Tenant struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	Hostnames     []string       `json:"hostnames"`
+	Bucket        string         `json:"bucket,omitempty"`
+	Themes        []string       `json:"themes"`
+	DecksPerMonth *int           `json:"decks_per_month,omitempty"`
+	Branding      TenantBranding `json:"branding"`
+}
+
+// TenantBranding replaces the PitchTree brand in the apps and decks of a tenant
+type TenantBranding struct {
+	ProductName  string `json:"productName,omitempty"`
+	LogoURL      string `json:"logoUrl,omitempty"`
+	AccentColor  string `json:"accentColor,omitempty"`
+	SupportEmail string `json:"supportEmail,omitempty"`
+}
+
+// CustomDomain is a domain of a user serving their public decks, once they proved
+// they own it with a TXT record
+type CustomDomain struct {
+	Domain            string     `json:"domain"`
+	UserID            string     `json:"user_id"`
+	VerificationToken string     `json:"verification_token"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	// The TXT record to create, derived from the domain and token
+	RecordName  string `json:"record_name,omitempty"`
+	RecordValue string `json:"record_value,omitempty"`
+}
+
+type DomainService interface {
+	List(userID string) ([]CustomDomain, error)
+	Add(userID, domain string) (*CustomDomain, error)
+	Verify(userID, domain string) (*CustomDomain, error)
+	Remove(userID, domain string) error
+}
+
+type TenantService interface {
+	Branding(tenantID string) (*TenantBranding, error)
+}
+
+type NotificationService interface {
+	List(userID string, unreadOnly bool, limit int) (*NotificationFeed, error)
+	MarkRead(userID, notificationID string) error
+	MarkAllRead(userID string) error
+}
+
+type AdminService interface {
+	ListDecks(status string, limit, offset int) ([]PitchDeckInfo, error)
+	GetDeck(deckID string) (*PitchDeckInfo, []AuditEvent, error)
+	RetryDeck(deckID, adminID string) error
+	ListFailures(includeRetried bool, limit, offset int) ([]DeckFailure, error)
+	ListFeedback(limit, offset int) ([]DeckFeedback, []PromptFeedbackStats, error)
+	DeleteDeck(deckID, adminID, reason string) error
+	TakeDownDeck(deckID, adminID, reason string) error
+	RunStorageGC() (*StorageGCReport, error)
+	ReindexSearch() error
+}
+
+type UploadService interface {
+	ListUploads(userID string) ([]UserFile, error)
+	ReplaceUpload(fileID, userID, filePath string) (*UserFile, []string, error)
+	RerenderAffectedDecks(fileID, userID string) ([]string, error)
+	CreateResumableUpload(userID string, length int64, filename, contentType string) (*ResumableUpload, error)
+	GetResumableUpload(uploadID, userID string) (*ResumableUpload, error)
+	AppendResumableUpload(uploadID, userID string, offset int64, chunk io.Reader) (*ResumableUpload, error)
+	DeleteResumableUpload(uploadID, userID string) error
+}
+
+// ShareLink is a tracked link to a deck given to one recipient
+type ShareLink struct {
+	ID        string    `json:"id"`
+	DeckID    string    `json:"deck_id"`
+	UserID    string    `json:"user_id"`
+	Token     string    `json:"token"`
+	Label     string    `json:"label"`
+	CreatedAt time.Time `json:"created_at"`
+	// Time after which the link stops working, nil when it does not expire
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Set when the owner revoked the link
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ViewEvent is recorded by the instrumented viewer of a share link
+type ViewEvent struct {
+	SessionID  string `json:"sessionId"`
+	Type       string `json:"type"`
+	Slide      int    `json:"slide"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// DeckView is an opening of a deck through one of its share links
+type DeckView struct {
+	LinkID    string    `json:"linkId"`
+	SessionID string    `json:"sessionId"`
+	OpenedAt  time.Time `json:"openedAt"`
+}
+
+// SlideAnalytics aggregates the time spent on one slide
+type SlideAnalytics struct {
+	Slide        int     `json:"slide"`
+	TotalSeconds float64 `json:"totalSeconds"`
+	AvgSeconds   float64 `json:"avgSeconds"`
+	Views        int     `json:"views"`
+}
+
+// LinkAnalytics aggregates the activity of one share link
+type LinkAnalytics struct {
+	LinkID       string     `json:"linkId"`
+	Label        string     `json:"label"`
+	Opens        int        `json:"opens"`
+	Downloads    int        `json:"downloads"`
+	TotalSeconds float64    `json:"totalSeconds"`
+	LastOpenedAt *time.Time `json:"lastOpenedAt,omitempty"`
+	Revoked      bool       `json:"revoked,omitempty"`
+}
+
+// DeckAnalytics is the viewer activity of a deck across all its share links
+type DeckAnalytics struct {
+	DeckID        string           `json:"deckId"`
+	Opens         int              `json:"opens"`
+	UniqueViewers int              `json:"uniqueViewers"`
+	Downloads     int              `json:"downloads"`
+	TotalSeconds  float64          `json:"totalSeconds"`
+	Slides        []SlideAnalytics `json:"slides"`
+	Links         []LinkAnalytics  `json:"links"`
+	LastOpenedAt  *time.Time       `json:"lastOpenedAt,omitempty"`
+}
+
+// FileDownload is a stored file served under a readable name
+type FileDownload struct {
+	Filename string
+	Content  []byte
+	ModTime  time.Time
+}
+
+type AnalyticsService interface {
+	CreateShareLink(deckID, userID, label string, expiresAt *time.Time) (*ShareLink, error)
+	ListShareLinks(deckID, userID string) ([]ShareLink, error)
+	RevokeShareLink(deckID, linkID, userID string) error
+	ViewerHTML(token, host string) (string, error)
+	DownloadPDF(token, sessionID string, resumed bool) (*FileDownload, error)
+	RecordEvent(token string, event ViewEvent) error
+	GetAnalytics(deckID, userID string) (*DeckAnalytics, error)
+	RecentViews(deckID, userID string, limit int) ([]DeckView, error)
+}
+
+// BrandKit holds the visual settings shared by the decks of a project
+type BrandKit struct {
+	Theme     string `json:"theme,omitempty"`
+	CodeTheme string `json:"codeTheme,omitempty"`
+	Logo      string `json:"logo,omitempty"`
+}
+
+// Project groups the decks made for one purpose (seed round, grant application...)
+type Project struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	OrgID           string    `json:"org_id,omitempty"`
+	Name            string    `json:"name"`
+	BrandKit        BrandKit  `json:"brand_kit"`
+	AudiencePersona string    `json:"audience_persona"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ProjectService interface {
+	CreateProject(userID string, project Project) (*Project, error)
+	ListProjects(userID string) ([]Project, error)
+	GetProject(projectID, userID string) (*Project, error)
+	UpdateProject(projectID, userID string, project Project) (*Project, error)
+	DeleteProject(projectID, userID string) error
+	AssignDeck(deckID, projectID, userID string) error
+}
+
+// Roles of organization members, each one includes the permissions of the previous
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleOwner  = "owner"
+)
+
+// Organization is a workspace whose members share decks and projects
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// Tenant of the user who created the organization, only its users can join
+	TenantID string `json:"tenant_id,omitempty"`
+	// Look of the public viewer of the decks shared with the organization, served
+	// on CustomDomain too once it is verified
+	ViewerBranding         *ViewerBranding `json:"viewer_branding,omitempty"`
+	CustomDomain           string          `json:"custom_domain,omitempty"`
+	CustomDomainToken      string          `json:"custom_domain_token,omitempty"`
+	CustomDomainVerifiedAt *time.Time      `json:"custom_domain_verified_at,omitempty"`
+	// The TXT record verifying CustomDomain, derived from the domain and token
+	RecordName  string `json:"record_name,omitempty"`
+	RecordValue string `json:"record_value,omitempty"`
+	// Role of the requesting user, filled when listing organizations
+	Role string `json:"role,omitempty"`
+}
+
+// ViewerBranding replaces the PitchTree look of the public viewer of decks
+type ViewerBranding struct {
+	LogoURL         string `json:"logoUrl,omitempty"`
+	AccentColor     string `json:"accentColor,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	// HideProductBranding removes the PitchTree watermark and name from the viewer
+	HideProductBranding bool `json:"hideProductBranding,omitempty"`
+}
+
+type OrganizationMember struct {
+	OrgID     string    `json:"org_id"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OrganizationInvite is accepted by whoever opens the invite link carrying its token
+type OrganizationInvite struct {
+	ID         string     `json:"id"`
+	OrgID      string     `json:"org_id"`
+	Email      string     `json:"email"`
+	Role       string     `json:"role"`
+	Token      string     `json:"token"`
+	InvitedBy  string     `json:"invited_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+type OrganizationService interface {
+	CreateOrganization(userID, name string) (*Organization, error)
+	ListOrganizations(userID string) ([]Organization, error)
+	ListMembers(orgID, userID string) ([]OrganizationMember, error)
+	InviteMember(orgID, userID, email, role string) (*OrganizationInvite, error)
+	AcceptInvite(token, userID string) (*OrganizationMember, error)
+	UpdateMemberRole(orgID, userID, memberID, role string) error
+	RemoveMember(orgID, userID, memberID string) error
+	ShareDeck(deckID, orgID, userID string) error
+	ShareProject(projectID, orgID, userID string) error
+	UpdateViewerBranding(orgID, userID string, branding ViewerBranding, customDomain string) (*Organization, error)
+	VerifyCustomDomain(orgID, userID string) (*Organization, error)
+}
+
+// GuestDeck is a deck generated without an account. It is owned by a random guest
+// ID until the claim token attaches it to an account, and deleted when it expires.
+type GuestDeck struct {
+	DeckID     string `json:"deck_id"`
+	GuestID    string `json:"guest_id"`
+	ClaimToken string `json:"claim_token"`
+	// Hash of the client address the deck was generated from
+	IPHash    string     `json:"ip_hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	ClaimedBy string     `json:"claimed_by,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+}
+
+type GuestService interface {
+	Create(data PitchDeckData, clientIP string) (*GuestDeck, error)
+	GetDeck(deckID, claimToken string) (*PitchDeckInfo, error)
+	Claim(deckID, claimToken, userID string) (*PitchDeckInfo, error)
+}
+
+// Draft holds the answers of the deck form while it is being filled, so the wizard
+// can be resumed where it was left
+type Draft struct {
+	ID     string        `json:"id"`
+	UserID string        `json:"user_id"`
+	Data   PitchDeckData `json:"data"`
+	// Step of the wizard the user was on
+	Step      int       `json:"step"`
+	DeckID    string    `json:"deck_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type DraftService interface {
+	CreateDraft(userID string, data PitchDeckData, step int) (*Draft, error)
+	ListDrafts(userID string) ([]Draft, error)
+	GetDraft(draftID, userID string) (*Draft, error)
+	SaveDraft(draftID, userID string, data PitchDeckData, step int) (*Draft, error)
+	DeleteDraft(draftID, userID string) error
+	Generate(ctx context.Context, draftID, userID string) (*PitchDeckInfo, error)
+}
+
+// IntakeMessage is one message of a conversational intake, from the "assistant" or the "founder"
+type IntakeMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// IntakeSession collects the deck form through a conversation, one question at a time
+type IntakeSession struct {
+	ID       string          `json:"id"`
+	UserID   string          `json:"user_id"`
+	Data     PitchDeckData   `json:"data"`
+	Messages []IntakeMessage `json:"messages"`
+	// Index of the question being asked, equal to the number of questions when complete
+	Step      int       `json:"step"`
+	Complete  bool      `json:"complete"`
+	DeckID    string    `json:"deck_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type IntakeService interface {
+	StartSession(userID string) (*IntakeSession, error)
+	GetSession(sessionID, userID string) (*IntakeSession, error)
+	Reply(sessionID, userID, message string) (*IntakeSession, error)
+	AttachLogo(sessionID, userID, logoURL string) (*IntakeSession, error)
+	Generate(sessionID, userID string) (*PitchDeckInfo, error)
+}
+
+type TelegramService interface {
+	LinkURL(userID string) (string, error)
+	HandleUpdate(secret string, update telegram.Update) error
 }
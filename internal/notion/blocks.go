@@ -0,0 +1,168 @@
+package notion
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Notion limits the content of a single rich text object
+const maxTextLength = 2000
+
+var (
+	imageRegex    = regexp.MustCompile(`^!\[[^\]]*\]\((\S+?)\)`)
+	numberedRegex = regexp.MustCompile(`^\d+[.)]\s+`)
+	htmlTagRegex  = regexp.MustCompile(`<[^>]+>`)
+	emphasisRegex = regexp.MustCompile(`(\*\*|__|\*|_|~~)([^*_~]+)(\*\*|__|\*|_|~~)`)
+	linkRegex     = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
+	commentRegex  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	styleRegex    = regexp.MustCompile(`(?s)<style[^>]*>.*?</style>`)
+)
+
+func richText(text string) []map[string]interface{} {
+	if len(text) > maxTextLength {
+		text = text[:maxTextLength]
+	}
+	return []map[string]interface{}{
+		{"type": "text", "text": map[string]string{"content": text}},
+	}
+}
+
+func textBlock(kind, text string) Block {
+	return Block{
+		"object": "block",
+		"type":   kind,
+		kind:     map[string]interface{}{"rich_text": richText(text)},
+	}
+}
+
+// Heading returns a heading block of level 1 to 3
+func Heading(level int, text string) Block {
+	switch level {
+	case 1:
+		return textBlock("heading_1", text)
+	case 2:
+		return textBlock("heading_2", text)
+	default:
+		return textBlock("heading_3", text)
+	}
+}
+
+// Toggle returns a toggle block hiding the given children
+func Toggle(title string, children []Block) Block {
+	return Block{
+		"object": "block",
+		"type":   "toggle",
+		"toggle": map[string]interface{}{
+			"rich_text": richText(title),
+			"children":  children,
+		},
+	}
+}
+
+// Divider returns a horizontal divider block
+func Divider() Block {
+	return Block{"object": "block", "type": "divider", "divider": map[string]interface{}{}}
+}
+
+func imageBlock(url string) Block {
+	return Block{
+		"object": "block",
+		"type":   "image",
+		"image": map[string]interface{}{
+			"type":     "external",
+			"external": map[string]string{"url": url},
+		},
+	}
+}
+
+func codeBlock(language, code string) Block {
+	if language == "" {
+		language = "plain text"
+	}
+	return Block{
+		"object": "block",
+		"type":   "code",
+		"code": map[string]interface{}{
+			"rich_text": richText(code),
+			"language":  language,
+		},
+	}
+}
+
+// plainText strips inline markdown and HTML so the text reads well in Notion
+func plainText(text string) string {
+	text = linkRegex.ReplaceAllString(text, "$1")
+	text = emphasisRegex.ReplaceAllString(text, "$2")
+	text = htmlTagRegex.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "`", "")
+	return strings.TrimSpace(text)
+}
+
+// FromMarkdown converts the markdown of one slide into Notion blocks. Marp
+// directives, styles and raw HTML are dropped, remote images are kept.
+func FromMarkdown(slide string) []Block {
+	slide = commentRegex.ReplaceAllString(slide, "")
+	slide = styleRegex.ReplaceAllString(slide, "")
+
+	var blocks []Block
+	var code []string
+	codeLanguage := ""
+	inCode := false
+
+	for _, line := range strings.Split(slide, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				blocks = append(blocks, codeBlock(codeLanguage, strings.Join(code, "\n")))
+				code = nil
+			} else {
+				codeLanguage = strings.TrimPrefix(trimmed, "```")
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+
+		switch {
+		case trimmed == "":
+			continue
+		case strings.HasPrefix(trimmed, "|"):
+			// Tables are flattened to one paragraph per row
+			if strings.Trim(trimmed, "|-: ") == "" {
+				continue
+			}
+			cells := strings.Split(strings.Trim(trimmed, "|"), "|")
+			for i := range cells {
+				cells[i] = plainText(cells[i])
+			}
+			blocks = append(blocks, textBlock("paragraph", strings.Join(cells, " · ")))
+		case imageRegex.MatchString(trimmed):
+			url := imageRegex.FindStringSubmatch(trimmed)[1]
+			if strings.HasPrefix(url, "http") {
+				blocks = append(blocks, imageBlock(url))
+			}
+		case strings.HasPrefix(trimmed, "### "):
+			blocks = append(blocks, Heading(3, plainText(trimmed[4:])))
+		case strings.HasPrefix(trimmed, "## "):
+			blocks = append(blocks, Heading(2, plainText(trimmed[3:])))
+		case strings.HasPrefix(trimmed, "# "):
+			blocks = append(blocks, Heading(1, plainText(trimmed[2:])))
+		case strings.HasPrefix(trimmed, "- "), strings.HasPrefix(trimmed, "* "):
+			blocks = append(blocks, textBlock("bulleted_list_item", plainText(trimmed[2:])))
+		case numberedRegex.MatchString(trimmed):
+			blocks = append(blocks, textBlock("numbered_list_item", plainText(numberedRegex.ReplaceAllString(trimmed, ""))))
+		case strings.HasPrefix(trimmed, ">"):
+			blocks = append(blocks, textBlock("quote", plainText(strings.TrimPrefix(trimmed, ">"))))
+		default:
+			if text := plainText(trimmed); text != "" {
+				blocks = append(blocks, textBlock("paragraph", text))
+			}
+		}
+	}
+
+	return blocks
+}
@@ -0,0 +1,208 @@
+package notion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	apiURL     = "https://api.notion.com/v1"
+	apiVersion = "2022-06-28"
+
+	// Notion rejects requests with more than 100 children blocks
+	maxChildren = 100
+)
+
+// Client talks to the Notion public API on behalf of connected users
+type Client struct {
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	httpClient   *http.Client
+}
+
+// Connection is the result of a successful OAuth code exchange
+type Connection struct {
+	AccessToken   string `json:"access_token"`
+	WorkspaceID   string `json:"workspace_id"`
+	WorkspaceName string `json:"workspace_name"`
+	BotID         string `json:"bot_id"`
+}
+
+// Block is a Notion block object, built with the helpers in blocks.go
+type Block map[string]interface{}
+
+func NewClient() (*Client, error) {
+	clientID := os.Getenv("NOTION_CLIENT_ID")
+	clientSecret := os.Getenv("NOTION_CLIENT_SECRET")
+	redirectURI := os.Getenv("NOTION_REDIRECT_URI")
+
+	if clientID == "" || clientSecret == "" || redirectURI == "" {
+		return nil, fmt.Errorf("notion credentials not set")
+	}
+
+	return &Client{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURI:  redirectURI,
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// AuthorizeURL returns the URL the user is sent to in order to grant access to a workspace
+func (c *Client) AuthorizeURL(state string) string {
+	params := url.Values{}
+	params.Set("client_id", c.clientID)
+	params.Set("response_type", "code")
+	params.Set("owner", "user")
+	params.Set("redirect_uri", c.redirectURI)
+	if state != "" {
+		params.Set("state", state)
+	}
+	return apiURL + "/oauth/authorize?" + params.Encode()
+}
+
+// ExchangeCode trades an OAuth authorization code for an access token
+func (c *Client) ExchangeCode(code string) (*Connection, error) {
+	payload := map[string]string{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": c.redirectURI,
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal token request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL+"/oauth/token", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(c.clientID, c.clientSecret)
+	req.Header.Set("Content-Type", "application/json")
+
+	var conn Connection
+	if err := c.do(req, &conn); err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	return &conn, nil
+}
+
+// FirstSharedPage returns the ID of the first page the integration was given access to
+func (c *Client) FirstSharedPage(token string) (string, error) {
+	body := map[string]interface{}{
+		"filter":    map[string]string{"property": "object", "value": "page"},
+		"page_size": 1,
+	}
+
+	var result struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	if err := c.call(token, "POST", "/search", body, &result); err != nil {
+		return "", err
+	}
+	if len(result.Results) == 0 {
+		return "", fmt.Errorf("no page shared with the integration")
+	}
+	return result.Results[0].ID, nil
+}
+
+// CreatePage creates a page under the parent page and returns its ID and URL.
+// Children beyond the API limit are appended in subsequent requests.
+func (c *Client) CreatePage(token, parentPageID, title string, children []Block) (string, string, error) {
+	first := children
+	if len(first) > maxChildren {
+		first = children[:maxChildren]
+	}
+
+	body := map[string]interface{}{
+		"parent": map[string]string{"page_id": parentPageID},
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{
+				"title": richText(title),
+			},
+		},
+		"children": first,
+	}
+
+	var page struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	if err := c.call(token, "POST", "/pages", body, &page); err != nil {
+		return "", "", err
+	}
+
+	if err := c.AppendBlocks(token, page.ID, children[len(first):]); err != nil {
+		return page.ID, page.URL, err
+	}
+
+	return page.ID, page.URL, nil
+}
+
+// AppendBlocks appends children to an existing block or page, in batches
+func (c *Client) AppendBlocks(token, blockID string, children []Block) error {
+	for len(children) > 0 {
+		batch := children
+		if len(batch) > maxChildren {
+			batch = children[:maxChildren]
+		}
+		children = children[len(batch):]
+
+		body := map[string]interface{}{"children": batch}
+		if err := c.call(token, "PATCH", "/blocks/"+blockID+"/children", body, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) call(token, method, path string, body interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequest(method, apiURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Notion-Version", apiVersion)
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req, out)
+}
+
+func (c *Client) do(req *http.Request, out interface{}) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("notion API error, status: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return nil
+}
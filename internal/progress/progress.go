@@ -71,22 +71,55 @@ type ProgressUpdate struct {
 	Message     string `json:"message"`
 	DownloadUrl string `json:"downloadUrl,omitempty"`
 	ViewUrl     string `json:"viewUrl,omitempty"`
+
+	// Classification of a failure and the output of the renderer, if any
+	ErrorCode string `json:"errorCode,omitempty"`
+	Details   string `json:"details,omitempty"`
 }
 
 func (t *Tracker) SendUpdate(id string, update ProgressUpdate) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to marshal update: %w", err)
+	}
+
+	// Sent under the lock so the channel cannot be closed meanwhile, without blocking
+	// when nobody reads the stream
 	t.mu.RLock()
-	ch, exists := t.channels[id]
-	t.mu.RUnlock()
+	defer t.mu.RUnlock()
 
+	ch, exists := t.channels[id]
 	if !exists {
 		return fmt.Errorf("no progress channel found for ID: %s", id)
 	}
 
+	select {
+	case ch <- string(data):
+	default:
+		log.Printf("Progress channel of deck %s is full, dropping update", id)
+	}
+	return nil
+}
+
+// CloseAll sends a last update to every channel and closes them, the streams of the
+// running generations end when the server shuts down
+func (t *Tracker) CloseAll(update ProgressUpdate) {
 	data, err := json.Marshal(update)
 	if err != nil {
-		return fmt.Errorf("failed to marshal update: %w", err)
+		log.Printf("Failed to marshal update: %v", err)
+		return
 	}
 
-	ch <- string(data)
-	return nil
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, ch := range t.channels {
+		select {
+		case ch <- string(data):
+		default:
+		}
+		close(ch)
+		delete(t.channels, id)
+		delete(t.owners, id)
+	}
 }
@@ -0,0 +1,496 @@
+// Package qrcode encodes short texts, such as links, as QR codes drawn in SVG. Texts
+// are encoded as bytes with the medium error correction level, in versions 1 to 10,
+// which holds up to 213 bytes.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrTooLong is returned for texts that do not fit in a version 10 QR code
+var ErrTooLong = errors.New("text too long for a QR code")
+
+// Modules of light margin around the code, as scanners expect
+const quietZone = 4
+
+// blockLayout is the error correction layout of a version at the medium level
+type blockLayout struct {
+	ecPerBlock int
+	// Blocks of the first and second groups and their data codewords, the second
+	// group has one more codeword per block
+	blocks1, data1 int
+	blocks2        int
+}
+
+var layouts = [...]blockLayout{
+	1:  {10, 1, 16, 0},
+	2:  {16, 1, 28, 0},
+	3:  {26, 1, 44, 0},
+	4:  {18, 2, 32, 0},
+	5:  {24, 2, 43, 0},
+	6:  {16, 4, 27, 0},
+	7:  {18, 4, 31, 0},
+	8:  {22, 2, 38, 2},
+	9:  {22, 3, 36, 2},
+	10: {26, 4, 43, 1},
+}
+
+// Centers of the alignment patterns along each axis
+var alignmentPositions = [...][]int{
+	1:  nil,
+	2:  {6, 18},
+	3:  {6, 22},
+	4:  {6, 26},
+	5:  {6, 30},
+	6:  {6, 34},
+	7:  {6, 22, 38},
+	8:  {6, 24, 42},
+	9:  {6, 26, 46},
+	10: {6, 28, 50},
+}
+
+func (l blockLayout) dataCodewords() int {
+	return l.blocks1*l.data1 + l.blocks2*(l.data1+1)
+}
+
+// Code is the matrix of a QR code, true for dark modules
+type Code struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode returns the smallest QR code holding text
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v < len(layouts); v++ {
+		if capacity(v) >= len(data) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d", ErrTooLong, len(data), capacity(len(layouts)-1))
+	}
+
+	code := newCode(version)
+	codewords := addErrorCorrection(dataCodewords(data, version), layouts[version])
+	code.placeData(codewords)
+
+	mask, lowest := 0, -1
+	for m := 0; m < 8; m++ {
+		code.applyMask(m)
+		code.drawFormat(m)
+		if p := code.penalty(); lowest == -1 || p < lowest {
+			mask, lowest = m, p
+		}
+		// Masks are their own inverse
+		code.applyMask(m)
+	}
+	code.applyMask(mask)
+	code.drawFormat(mask)
+	return code, nil
+}
+
+// SVG returns text as a QR code in an SVG image, one unit per module
+func SVG(text string) (string, error) {
+	code, err := Encode(text)
+	if err != nil {
+		return "", err
+	}
+	return code.SVG(), nil
+}
+
+// SVG draws the code, its dark modules as one path
+func (c *Code) SVG() string {
+	var path strings.Builder
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+quietZone, y+quietZone)
+			}
+		}
+	}
+	size := c.size + 2*quietZone
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, size, size, path.String())
+}
+
+// capacity is the number of bytes a version holds
+func capacity(version int) int {
+	return (layouts[version].dataCodewords()*8 - 4 - countBits(version)) / 8
+}
+
+// countBits is the length of the byte count of a version
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// dataCodewords encodes data in byte mode and pads it to the capacity of the version
+func dataCodewords(data []byte, version int) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	total := layouts[version].dataCodewords() * 8
+	bits.append(0, min(4, total-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < total; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes()
+}
+
+// addErrorCorrection splits the data in blocks, computes their error correction
+// codewords and interleaves them
+func addErrorCorrection(data []byte, layout blockLayout) []byte {
+	generator := rsGenerator(layout.ecPerBlock)
+	var blocks, ecBlocks [][]byte
+	for i := 0; i < layout.blocks1+layout.blocks2; i++ {
+		n := layout.data1
+		if i >= layout.blocks1 {
+			n++
+		}
+		blocks = append(blocks, data[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(data[:n], generator))
+		data = data[n:]
+	}
+
+	var result []byte
+	for i := 0; i <= layout.data1; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+func newCode(version int) *Code {
+	size := 17 + 4*version
+	c := &Code{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	positions := alignmentPositions[version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Alignment patterns do not overlap the finders
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// Reserved until the mask is chosen
+	c.drawFormat(0)
+	c.drawVersion(version)
+	return c
+}
+
+// set draws a function module at column x of row y
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFinder draws a finder pattern centered on x, y with its light separator
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws the error correction level and the mask, twice
+func (c *Code) drawFormat(mask int) {
+	// The medium level is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(bits, i))
+	}
+	c.set(8, 7, bit(bits, 6))
+	c.set(8, 8, bit(bits, 7))
+	c.set(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.size-15+i, bit(bits, i))
+	}
+	c.set(8, c.size-8, true)
+}
+
+// drawVersion draws the version of codes from version 7, twice
+func (c *Code) drawVersion(version int) {
+	if version < 7 {
+		return
+	}
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := version<<12 | rem
+	for i := 0; i < 18; i++ {
+		a, b := c.size-11+i%3, i/3
+		c.set(a, b, bit(bits, i))
+		c.set(b, a, bit(bits, i))
+	}
+}
+
+// placeData fills the modules that are not part of a pattern, in pairs of columns
+// going up and down from the bottom right corner
+func (c *Code) placeData(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+				if c.function[y][x] {
+					continue
+				}
+				// Modules left after the codewords stay light
+				if i < len(codewords)*8 {
+					c.modules[y][x] = codewords[i>>3]>>(7-i&7)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			c.modules[y][x] = c.modules[y][x] != invert
+		}
+	}
+}
+
+// Runs that look like a finder pattern, penalized as they confuse scanners
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// penalty scores how hard the code is to scan, lower is better
+func (c *Code) penalty() int {
+	score := 0
+	dark := 0
+	for i := 0; i < c.size; i++ {
+		row := c.modules[i]
+		column := make([]bool, c.size)
+		for j := range column {
+			column[j] = c.modules[j][i]
+		}
+		for _, line := range [][]bool{row, column} {
+			score += runPenalty(line) + finderPenalty(line)
+		}
+		for _, d := range row {
+			if d {
+				dark++
+			}
+		}
+	}
+
+	for y := 0; y < c.size-1; y++ {
+		for x := 0; x < c.size-1; x++ {
+			m := c.modules[y][x]
+			if m == c.modules[y][x+1] && m == c.modules[y+1][x] && m == c.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	total := c.size * c.size
+	percent := dark * 100 / total
+	score += 10 * (abs(percent-50) / 5)
+	return score
+}
+
+// runPenalty penalizes runs of five modules or more of the same color
+func runPenalty(line []bool) int {
+	score, run := 0, 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		run = 1
+	}
+	return score
+}
+
+func finderPenalty(line []bool) int {
+	score := 0
+	for i := 0; i+len(finderLike[0]) <= len(line); i++ {
+		for _, pattern := range finderLike {
+			matched := true
+			for j, d := range pattern {
+				if line[i+j] != d {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				score += 40
+			}
+		}
+	}
+	return score
+}
+
+// bitBuffer is a sequence of bits, most significant first
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, set := range b {
+		if set {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
+
+// rsGenerator returns the Reed-Solomon generator polynomial of a degree, without
+// its leading coefficient
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of a block
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range generator {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func bit(x, i int) bool {
+	return x>>i&1 == 1
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
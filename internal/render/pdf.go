@@ -0,0 +1,115 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// ErrBrowserNotFound is returned when no Chrome or Chromium executable is installed
+var ErrBrowserNotFound = errors.New("chrome or chromium not found")
+
+// Executables looked up in the PATH when no browser is configured
+var browserNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "headless-shell"}
+
+// FindBrowser returns the browser set by CHROME_PATH or PUPPETEER_EXECUTABLE_PATH,
+// or the first one found in the PATH
+func FindBrowser() (string, error) {
+	for _, env := range []string{"CHROME_PATH", "PUPPETEER_EXECUTABLE_PATH"} {
+		if path := os.Getenv(env); path != "" {
+			if _, err := os.Stat(path); err == nil {
+				return path, nil
+			}
+		}
+	}
+	for _, name := range browserNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrBrowserNotFound
+}
+
+// PDF prints an HTML document to a PDF with a headless browser, one page per slide.
+// The document is opened from disk so the local images of the deck are loaded. A
+// tagged PDF carries the structure of the document and an outline of its headings,
+// for screen readers and accessibility checkers.
+func PDF(ctx context.Context, htmlPath, pdfPath string, tagged bool) error {
+	absPath, err := filepath.Abs(htmlPath)
+	if err != nil {
+		return err
+	}
+	browserCtx, cancel, err := newBrowser(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var pdf []byte
+	err = chromedp.Run(browserCtx,
+		chromedp.Navigate("file://"+filepath.ToSlash(absPath)),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdf, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				WithPreferCSSPageSize(true).
+				WithGenerateTaggedPDF(tagged).
+				WithGenerateDocumentOutline(tagged).
+				Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to print PDF: %w", err)
+	}
+
+	if err := os.WriteFile(pdfPath, pdf, 0644); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// Warm starts the browser once and opens a blank page, so a browser that cannot run
+// is found before the first conversion, and its files are loaded from disk
+func Warm(ctx context.Context) error {
+	browserCtx, cancel, err := newBrowser(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	if err := chromedp.Run(browserCtx, chromedp.Navigate("about:blank")); err != nil {
+		return fmt.Errorf("failed to start the browser: %w", err)
+	}
+	return nil
+}
+
+// newBrowser starts a headless browser, stopped by the returned function
+func newBrowser(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	browser, err := FindBrowser()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(browser),
+		chromedp.Flag("allow-file-access-from-files", true),
+	)
+	// Required to run as root in containers, as for marp-cli
+	if os.Getenv("CHROME_NO_SANDBOX") != "" {
+		opts = append(opts, chromedp.NoSandbox)
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	return browserCtx, func() {
+		cancelBrowser()
+		cancelAlloc()
+	}, nil
+}
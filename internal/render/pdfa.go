@@ -0,0 +1,107 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrGhostscriptNotFound is returned when the gs executable is not installed
+var ErrGhostscriptNotFound = errors.New("ghostscript not found")
+
+// Locations of the sRGB profile installed with Ghostscript, the profile built in its
+// executable is used when none is found
+var iccProfilePaths = []string{
+	"/usr/share/ghostscript/iccprofiles/srgb.icc",
+	"/usr/share/ghostscript/*/iccprofiles/srgb.icc",
+	"/usr/local/share/ghostscript/*/iccprofiles/srgb.icc",
+}
+
+// pdfaDefinition declares the output intent PDF/A requires, the sRGB color space the
+// pages are converted to
+const pdfaDefinition = `%%!
+/ICCProfile (%s) def
+[/_objdef {icc_PDFA} /type /stream /OBJ pdfmark
+[{icc_PDFA} << /N 3 >> /PUT pdfmark
+[{icc_PDFA} ICCProfile (r) file /PUT pdfmark
+[/_objdef {OutputIntent_PDFA} /type /dict /OBJ pdfmark
+[{OutputIntent_PDFA} <<
+  /Type /OutputIntent
+  /S /GTS_PDFA1
+  /DestOutputProfile {icc_PDFA}
+  /OutputConditionIdentifier (sRGB)
+>> /PUT pdfmark
+[{Catalog} << /OutputIntents [ {OutputIntent_PDFA} ] >> /PUT pdfmark
+`
+
+// PDFA converts a PDF to PDF/A-2b with Ghostscript, for archiving in document
+// management systems. Fonts are embedded and the document information is kept, the
+// structure of tagged PDFs is not.
+func PDFA(ctx context.Context, pdfPath, pdfaPath string) error {
+	gs, err := exec.LookPath("gs")
+	if err != nil {
+		return ErrGhostscriptNotFound
+	}
+
+	profile := iccProfile()
+	definition, err := os.CreateTemp("", "pdfa-*.ps")
+	if err != nil {
+		return fmt.Errorf("failed to write PDF/A definition: %w", err)
+	}
+	defer os.Remove(definition.Name())
+	_, err = fmt.Fprintf(definition, pdfaDefinition, escapePostScript(profile))
+	if closeErr := definition.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write PDF/A definition: %w", err)
+	}
+
+	args := []string{
+		"-dPDFA=2",
+		"-dPDFACompatibilityPolicy=1",
+		"-dBATCH",
+		"-dNOPAUSE",
+		"-dNOOUTERSAVE",
+		"-dQUIET",
+		"-sDEVICE=pdfwrite",
+		"-sColorConversionStrategy=RGB",
+		"-sOutputFile=" + pdfaPath,
+	}
+	if !strings.HasPrefix(profile, "%rom%") {
+		args = append(args, "--permit-file-read="+profile)
+	}
+	args = append(args, definition.Name(), pdfPath)
+
+	cmd := exec.CommandContext(ctx, gs, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ghostscript failed: %w: %s", err, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// iccProfile returns the sRGB profile set by GS_ICC_PROFILE, or the one installed
+// with Ghostscript
+func iccProfile() string {
+	if path := os.Getenv("GS_ICC_PROFILE"); path != "" {
+		return path
+	}
+	for _, pattern := range iccProfilePaths {
+		if matches, _ := filepath.Glob(pattern); len(matches) > 0 {
+			return matches[len(matches)-1]
+		}
+	}
+	return "%rom%iccprofiles/srgb.icc"
+}
+
+func escapePostScript(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s)
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// PDFInfo is the document information of a PDF, shown by readers as its properties
+// and indexed by document management systems
+type PDFInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+}
+
+var (
+	startXrefRegex   = regexp.MustCompile(`startxref\s+(\d+)`)
+	rootRegex        = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	trailerSizeRegex = regexp.MustCompile(`/Size\s+(\d+)`)
+	idRegex          = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+)
+
+// SetPDFInfo replaces the document information of a PDF with an incremental update:
+// a new information dictionary and a cross-reference section pointing to it are
+// appended, the original bytes are kept as they are.
+func SetPDFInfo(pdfPath string, info PDFInfo) error {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	// The last trailer (or cross-reference stream) holds the current values
+	prev := lastSubmatch(startXrefRegex, data)
+	root := lastSubmatch(rootRegex, data)
+	size := lastSubmatch(trailerSizeRegex, data)
+	if prev == "" || root == "" || size == "" {
+		return fmt.Errorf("failed to update PDF information: trailer not found")
+	}
+	// Cross-reference streams cannot be extended with a table, Chrome writes tables
+	offset, err := strconv.Atoi(prev)
+	if err != nil || offset >= len(data) || !bytes.HasPrefix(data[offset:], []byte("xref")) {
+		return fmt.Errorf("failed to update PDF information: no cross-reference table")
+	}
+	objectNumber, err := strconv.Atoi(size)
+	if err != nil {
+		return fmt.Errorf("failed to update PDF information: invalid size %q", size)
+	}
+
+	var update bytes.Buffer
+	update.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		update.WriteByte('\n')
+	}
+
+	infoOffset := update.Len()
+	fmt.Fprintf(&update, "%d 0 obj\n<<", objectNumber)
+	for _, entry := range []struct{ key, value string }{
+		{"Title", info.Title},
+		{"Author", info.Author},
+		{"Subject", info.Subject},
+		{"Keywords", info.Keywords},
+		{"Creator", info.Creator},
+	} {
+		if entry.value != "" {
+			fmt.Fprintf(&update, " /%s %s", entry.key, pdfText(entry.value))
+		}
+	}
+	fmt.Fprintf(&update, " /ModDate (D:%s) >>\nendobj\n", time.Now().UTC().Format("20060102150405Z"))
+
+	xrefOffset := update.Len()
+	// Entries are exactly 20 bytes long, their end of line included
+	fmt.Fprintf(&update, "xref\n%d 1\n%010d 00000 n \n", objectNumber, infoOffset)
+	fmt.Fprintf(&update, "trailer\n<< /Size %d /Root %s /Info %d 0 R /Prev %s", objectNumber+1, root, objectNumber, prev)
+	if id := idRegex.FindAll(data, -1); len(id) > 0 {
+		fmt.Fprintf(&update, " %s", id[len(id)-1])
+	}
+	fmt.Fprintf(&update, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+
+	if err := os.WriteFile(pdfPath, update.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+func lastSubmatch(re *regexp.Regexp, data []byte) string {
+	matches := re.FindAllSubmatch(data, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return string(matches[len(matches)-1][1])
+}
+
+// pdfText encodes a text string of any script as UTF-16BE in a hex string
+func pdfText(s string) string {
+	units := utf16.Encode([]rune(s))
+	b := make([]byte, 2, 2+2*len(units))
+	b[0], b[1] = 0xFE, 0xFF
+	for _, u := range units {
+		b = append(b, byte(u>>8), byte(u))
+	}
+	return "<" + hex.EncodeToString(b) + ">"
+}
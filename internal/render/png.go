@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/chromedp/chromedp"
+)
+
+// PNG captures the slides of an HTML document to one PNG image, stacked vertically
+func PNG(ctx context.Context, htmlPath, pngPath string) error {
+	absPath, err := filepath.Abs(htmlPath)
+	if err != nil {
+		return err
+	}
+	browserCtx, cancel, err := newBrowser(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	var count int
+	err = chromedp.Run(browserCtx,
+		chromedp.Navigate("file://"+filepath.ToSlash(absPath)),
+		chromedp.Evaluate(`document.querySelectorAll("body > section").length`, &count),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open slides: %w", err)
+	}
+
+	captures := make([][]byte, count)
+	for i := range captures {
+		selector := fmt.Sprintf("body > section:nth-of-type(%d)", i+1)
+		if err := chromedp.Run(browserCtx, chromedp.Screenshot(selector, &captures[i], chromedp.ByQuery)); err != nil {
+			return fmt.Errorf("failed to capture slide %d: %w", i+1, err)
+		}
+	}
+
+	stacked, err := StackPNG(captures)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pngPath, stacked, 0644); err != nil {
+		return fmt.Errorf("failed to write PNG: %w", err)
+	}
+	return nil
+}
+
+// StackPNG places PNG images one below the other, aligned left, in a new PNG image
+func StackPNG(images [][]byte) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, errors.New("no image to stack")
+	}
+	if len(images) == 1 {
+		return images[0], nil
+	}
+
+	decoded := make([]image.Image, len(images))
+	var width, height int
+	for i, data := range images {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image %d: %w", i+1, err)
+		}
+		decoded[i] = img
+		width = max(width, img.Bounds().Dx())
+		height += img.Bounds().Dy()
+	}
+
+	stacked := image.NewRGBA(image.Rect(0, 0, width, height))
+	y := 0
+	for _, img := range decoded {
+		bounds := img.Bounds()
+		draw.Draw(stacked, image.Rect(0, y, bounds.Dx(), y+bounds.Dy()), img, bounds.Min, draw.Src)
+		y += bounds.Dy()
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, stacked); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
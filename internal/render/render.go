@@ -0,0 +1,248 @@
+// Package render renders Marp decks without marp-cli, for hosts without Node.js. It
+// supports the subset of Marp used by the generated decks: global and local
+// directives, the bundled themes, pagination, headers and footers, image sizes and
+// backgrounds. Code blocks are not highlighted.
+package render
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pitch-deck-generator/internal/sanitize"
+	"pitch-deck-generator/internal/slides"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+//go:embed themes/*.css
+var themes embed.FS
+
+// DefaultTheme is used for decks with an unknown theme
+const DefaultTheme = "default"
+
+// Directives in HTML comments, e.g. <!-- _class: lead -->
+var directiveRegex = regexp.MustCompile(`<!--\s*(_?[a-zA-Z]+)\s*:\s*(.*?)\s*-->`)
+
+// Image sizes in the alt text, e.g. ![w:400](image.png)
+var sizeRegex = regexp.MustCompile(`^(w|h|width|height):(\d+)(px)?$`)
+
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	// Decks embed their own styles and HTML blocks, as Marp allows
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// directives holds the directives of a slide, see
+// https://marpit.marp.app/directives
+type directives map[string]string
+
+// HTML renders a deck to a standalone HTML document, with a theme used when its
+// front matter does not set one
+func HTML(deck, theme string) ([]byte, error) {
+	frontMatter, deckSlides := slides.Split(deck)
+
+	global := directives{"theme": theme}
+	for _, line := range strings.Split(frontMatter, "\n") {
+		if key, value, found := strings.Cut(line, ":"); found {
+			global[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+
+	css, err := themeCSS(global["theme"])
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for i, slide := range deckSlides {
+		// Directives without an underscore also apply to the following slides
+		local := directives{}
+		for _, match := range directiveRegex.FindAllStringSubmatch(slide, -1) {
+			if key, ok := strings.CutPrefix(match[1], "_"); ok {
+				local[key] = match[2]
+			} else {
+				global[key] = match[2]
+			}
+		}
+		for key, value := range global {
+			if _, ok := local[key]; !ok {
+				local[key] = value
+			}
+		}
+
+		if err := renderSlide(&body, slide, local, i+1); err != nil {
+			return nil, fmt.Errorf("failed to render slide %d: %w", i+1, err)
+		}
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">\n")
+	doc.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	fmt.Fprintf(&doc, "<title>%s</title>\n", html.EscapeString(global["title"]))
+	fmt.Fprintf(&doc, "<style>\n%s\n</style>\n</head><body>\n", css)
+	doc.Write(body.Bytes())
+	doc.WriteString("</body></html>\n")
+	return doc.Bytes(), nil
+}
+
+// themeCSS returns the base styles followed by those of a bundled theme
+func themeCSS(theme string) (string, error) {
+	base, err := themes.ReadFile("themes/base.css")
+	if err != nil {
+		return "", err
+	}
+	css, err := themes.ReadFile("themes/" + theme + ".css")
+	if err != nil || theme == "base" {
+		css, err = themes.ReadFile("themes/" + DefaultTheme + ".css")
+		if err != nil {
+			return "", err
+		}
+	}
+	return string(base) + "\n" + string(css), nil
+}
+
+// renderSlide writes a slide as a section with its directives applied
+func renderSlide(w *bytes.Buffer, slide string, dirs directives, number int) error {
+	source := []byte(slide)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+	backgrounds := extractImages(doc, source)
+
+	var content bytes.Buffer
+	if err := markdown.Renderer().Render(&content, source, doc); err != nil {
+		return err
+	}
+
+	var style []string
+	if color := dirs["backgroundColor"]; color != "" {
+		style = append(style, "background-color:"+color)
+	}
+	if color := dirs["color"]; color != "" {
+		style = append(style, "color:"+color)
+	}
+
+	class := dirs["class"]
+	var split string
+	for _, bg := range backgrounds {
+		if bg.side != "" {
+			split = bg.side
+			class += " split-" + bg.side
+		}
+	}
+
+	fmt.Fprintf(w, "<section id=\"%d\" class=\"%s\" style=\"%s\"", number, html.EscapeString(strings.TrimSpace(class)), html.EscapeString(strings.Join(style, ";")))
+	if dirs["paginate"] == "true" {
+		fmt.Fprintf(w, " data-pagination=\"%d\"", number)
+	}
+	w.WriteString(">\n")
+
+	for _, bg := range backgrounds {
+		fmt.Fprintf(w, "<div class=\"background %s\" style=\"background-image:url('%s');background-size:%s\"></div>\n",
+			bg.side, html.EscapeString(bg.src), bg.size)
+	}
+	if header := dirs["header"]; header != "" {
+		fmt.Fprintf(w, "<header>%s</header>\n", inline(header))
+	}
+
+	// Raw HTML and link URLs are rendered as written, javascript: links included
+	slideHTML := sanitize.HTML(content.String())
+	if split != "" {
+		fmt.Fprintf(w, "<div class=\"content\">\n%s</div>\n", slideHTML)
+	} else {
+		w.WriteString(slideHTML)
+	}
+
+	if footer := dirs["footer"]; footer != "" {
+		fmt.Fprintf(w, "<footer>%s</footer>\n", inline(footer))
+	}
+	w.WriteString("</section>\n")
+	return nil
+}
+
+// background is an image of a slide with the bg keyword
+type background struct {
+	src  string
+	side string
+	size string
+}
+
+// extractImages removes the background images from a slide and applies the sizes
+// set in the alt text of the others
+func extractImages(doc ast.Node, source []byte) []background {
+	var images []*ast.Image
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if image, ok := n.(*ast.Image); ok && entering {
+			images = append(images, image)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var backgrounds []background
+	for _, image := range images {
+		keywords := strings.Fields(altText(image, source))
+		if len(keywords) > 0 && keywords[0] == "bg" {
+			bg := background{src: string(image.Destination), size: "cover"}
+			for _, keyword := range keywords[1:] {
+				switch keyword {
+				case "left", "right":
+					bg.side = keyword
+				case "contain", "fit":
+					bg.size = "contain"
+				}
+			}
+			backgrounds = append(backgrounds, bg)
+			image.Parent().RemoveChild(image.Parent(), image)
+			continue
+		}
+
+		var style []string
+		for _, keyword := range keywords {
+			if match := sizeRegex.FindStringSubmatch(keyword); match != nil {
+				size, _ := strconv.Atoi(match[2])
+				property := "width"
+				if match[1] == "h" || match[1] == "height" {
+					property = "height"
+				}
+				style = append(style, fmt.Sprintf("%s:%dpx", property, size))
+			}
+		}
+		if len(style) > 0 {
+			image.SetAttributeString("style", []byte(strings.Join(style, ";")))
+		}
+	}
+	return backgrounds
+}
+
+// altText returns the alt text of an image, where Marp puts its keywords
+func altText(image *ast.Image, source []byte) string {
+	var sb strings.Builder
+	for child := image.FirstChild(); child != nil; child = child.NextSibling() {
+		if t, ok := child.(*ast.Text); ok {
+			sb.Write(t.Segment.Value(source))
+		}
+	}
+	return sb.String()
+}
+
+// inline renders the markdown of a header or footer without its paragraph
+func inline(value string) string {
+	source := []byte(value)
+	doc := markdown.Parser().Parse(text.NewReader(source))
+	extractImages(doc, source)
+
+	var buf bytes.Buffer
+	if err := markdown.Renderer().Render(&buf, source, doc); err != nil {
+		return html.EscapeString(value)
+	}
+	out := strings.TrimSpace(buf.String())
+	out = strings.TrimPrefix(out, "<p>")
+	return sanitize.HTML(strings.TrimSuffix(out, "</p>"))
+}
@@ -0,0 +1,20 @@
+package repository
+
+import "context"
+
+type accessTokenKey struct{}
+
+// WithAccessToken attaches the JWT of the user making the request, so the records
+// read and written for them are subject to row level security when enabled
+func WithAccessToken(ctx context.Context, token string) context.Context {
+	if token == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, accessTokenKey{}, token)
+}
+
+// AccessToken returns the JWT attached to the context, empty for background work
+func AccessToken(ctx context.Context) string {
+	token, _ := ctx.Value(accessTokenKey{}).(string)
+	return token
+}
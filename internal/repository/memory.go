@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Memory keeps the records in memory, to test services without a database. WithTx
+// restores the records when fn fails but does not isolate concurrent writes.
+type Memory struct {
+	mu     sync.Mutex
+	decks  map[string]model.PitchDeckInfo
+	inputs map[string]model.PitchDeckData
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		decks:  make(map[string]model.PitchDeckInfo),
+		inputs: make(map[string]model.PitchDeckData),
+	}
+}
+
+func (m *Memory) WithTx(ctx context.Context, fn func(repo model.DeckRepository) error) error {
+	m.mu.Lock()
+	decks, inputs := maps.Clone(m.decks), maps.Clone(m.inputs)
+	m.mu.Unlock()
+
+	if err := fn(m); err != nil {
+		m.mu.Lock()
+		m.decks, m.inputs = decks, inputs
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, idOrSlug string) (*model.PitchDeckInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if deck, ok := m.decks[idOrSlug]; ok {
+		return &deck, nil
+	}
+	for _, deck := range m.decks {
+		if deck.Slug != "" && deck.Slug == idOrSlug {
+			return &deck, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+}
+
+// Save creates the deck or updates it, keeping the slug, markdown and project when not set
+func (m *Memory) Save(ctx context.Context, deck *model.PitchDeckInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := *deck
+	saved.Revision = 1
+	if existing, ok := m.decks[deck.ID]; ok {
+		saved = existing
+		saved.Name = deck.Name
+		saved.PdfURL = deck.PdfURL
+		saved.HtmlURL = deck.HtmlURL
+		saved.IsPublic = deck.IsPublic
+		saved.Status = deck.Status
+		if deck.Slug != "" {
+			saved.Slug = deck.Slug
+		}
+		if deck.MarkdownURL != "" {
+			saved.MarkdownURL = deck.MarkdownURL
+		}
+		if deck.ProjectID != "" {
+			saved.ProjectID = deck.ProjectID
+		}
+	}
+	m.decks[deck.ID] = saved
+	return nil
+}
+
+func (m *Memory) List(ctx context.Context, userID string, orgIDs []string, opts model.DeckListOptions) ([]model.PitchDeckInfo, int, error) {
+	if !deckSortColumns[opts.Sort] || (opts.Order != "asc" && opts.Order != "desc") {
+		return nil, 0, fmt.Errorf("%w: unsupported order %s %s", model.ErrInvalidInput, opts.Sort, opts.Order)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var decks []model.PitchDeckInfo
+	for _, deck := range m.decks {
+		switch {
+		case deck.UserID != userID && (deck.OrgID == "" || !slices.Contains(orgIDs, deck.OrgID)):
+		case deck.TenantID != opts.TenantID:
+		case opts.ProjectID == "none" && deck.ProjectID != "":
+		case opts.ProjectID != "" && opts.ProjectID != "none" && deck.ProjectID != opts.ProjectID:
+		case opts.Status != "" && deck.Status != opts.Status:
+		case opts.Visibility != "" && deck.IsPublic != (opts.Visibility == "public"):
+		case !opts.CreatedAfter.IsZero() && deck.CreatedAt.Before(opts.CreatedAfter):
+		case !opts.CreatedBefore.IsZero() && !deck.CreatedAt.Before(opts.CreatedBefore):
+		default:
+			decks = append(decks, deck)
+		}
+	}
+
+	slices.SortFunc(decks, func(a, b model.PitchDeckInfo) int {
+		c := a.CreatedAt.Compare(b.CreatedAt)
+		if opts.Sort == "name" {
+			c = strings.Compare(a.Name, b.Name)
+		}
+		if opts.Order == "desc" {
+			c = -c
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		return c
+	})
+
+	total := len(decks)
+	if opts.Limit > 0 {
+		start := min(opts.Offset, total)
+		decks = decks[start:min(start+opts.Limit, total)]
+	}
+	return decks, total, nil
+}
+
+// update applies fn to a deck, unknown decks are ignored like in the database
+func (m *Memory) update(deckID string, fn func(deck *model.PitchDeckInfo)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if deck, ok := m.decks[deckID]; ok {
+		fn(&deck)
+		m.decks[deckID] = deck
+	}
+}
+
+func (m *Memory) UpdateStatus(ctx context.Context, deckID, status string) error {
+	m.update(deckID, func(deck *model.PitchDeckInfo) {
+		deck.Status = status
+	})
+	return nil
+}
+
+func (m *Memory) StartProcessing(ctx context.Context, deckID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deck, ok := m.decks[deckID]
+	if !ok || deck.Status == "processing" {
+		return fmt.Errorf("%w: the deck is already processing", model.ErrConflict)
+	}
+	deck.Status = "processing"
+	m.decks[deckID] = deck
+	return nil
+}
+
+func (m *Memory) UpdateVisibility(ctx context.Context, deckID string, isPublic bool) error {
+	m.update(deckID, func(deck *model.PitchDeckInfo) {
+		deck.IsPublic = isPublic
+	})
+	return nil
+}
+
+func (m *Memory) Revise(ctx context.Context, deckID string, revision int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deck, ok := m.decks[deckID]
+	if !ok || deck.Revision != revision {
+		return fmt.Errorf("%w: the deck is not at revision %d", model.ErrConflict, revision)
+	}
+	deck.Revision++
+	m.decks[deckID] = deck
+	return nil
+}
+
+func (m *Memory) RecordFailure(ctx context.Context, deckID, code, message string) error {
+	m.update(deckID, func(deck *model.PitchDeckInfo) {
+		deck.Status = "failed"
+		deck.ErrorCode = code
+		deck.ErrorMessage = message
+	})
+	return nil
+}
+
+func (m *Memory) SaveInput(ctx context.Context, deckID, userID string, data model.PitchDeckData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs[deckID] = data
+	return nil
+}
+
+func (m *Memory) Input(ctx context.Context, deckID string) (*model.PitchDeckData, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.inputs[deckID]
+	if !ok {
+		return nil, nil
+	}
+	return &data, nil
+}
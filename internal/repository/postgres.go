@@ -0,0 +1,267 @@
+// Package repository persists the records of the application. Services were written
+// against the Supabase REST API and move to the repositories one table at a time.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// deckSortColumns are the columns a deck list can be ordered by
+var deckSortColumns = map[string]bool{
+	"created_at": true,
+	"name":       true,
+}
+
+// querier is implemented by the connection pool and by transactions
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Postgres connects to the database directly through a connection pool. pgx prepares
+// and caches the statements on each connection, so DATABASE_URL must point to a direct
+// or session pooler connection; add default_query_exec_mode=exec to go through the
+// transaction pooler. The pool is sized with the pool_max_conns parameter.
+type Postgres struct {
+	pool *pgxpool.Pool
+	db   querier
+}
+
+func NewPostgres(ctx context.Context, databaseURL string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &Postgres{
+		pool: pool,
+		db:   pool,
+	}, nil
+}
+
+// Close waits for the queries in progress and closes the connections
+func (p *Postgres) Close() {
+	p.pool.Close()
+}
+
+// WithTx runs fn in a transaction, or in the current one when already in a transaction
+func (p *Postgres) WithTx(ctx context.Context, fn func(repo model.DeckRepository) error) error {
+	if _, ok := p.db.(pgx.Tx); ok {
+		return fn(p)
+	}
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		return fn(&Postgres{pool: p.pool, db: tx})
+	})
+}
+
+// Optional columns are read as empty strings, like the omitted fields of the REST API
+const deckColumns = `id::text, user_id::text, name, coalesce(slug, ''), coalesce(pdf_url, ''),
+	coalesce(html_url, ''), coalesce(markdown_url, ''), coalesce(project_id::text, ''),
+	coalesce(org_id::text, ''), is_public, status, coalesce(error_code, ''),
+	coalesce(error_message, ''), view_count, last_viewed_at, taken_down_at,
+	coalesce(takedown_reason, ''), publish_at, unpublish_at, expires_at,
+	coalesce(prompt_version_id::text, ''), step_timings, tenant_id, created_at, updated_at,
+	revision`
+
+func scanDeck(row pgx.Row) (model.PitchDeckInfo, error) {
+	var deck model.PitchDeckInfo
+	err := row.Scan(
+		&deck.ID, &deck.UserID, &deck.Name, &deck.Slug, &deck.PdfURL,
+		&deck.HtmlURL, &deck.MarkdownURL, &deck.ProjectID,
+		&deck.OrgID, &deck.IsPublic, &deck.Status, &deck.ErrorCode,
+		&deck.ErrorMessage, &deck.ViewCount, &deck.LastViewedAt, &deck.TakenDownAt,
+		&deck.TakedownReason, &deck.PublishAt, &deck.UnpublishAt, &deck.ExpiresAt,
+		&deck.PromptVersionID, &deck.StepTimings, &deck.TenantID, &deck.CreatedAt, &deck.UpdatedAt,
+		&deck.Revision,
+	)
+	return deck, err
+}
+
+// Get returns a deck by ID, or by slug for shared URLs
+func (p *Postgres) Get(ctx context.Context, idOrSlug string) (*model.PitchDeckInfo, error) {
+	query := "select " + deckColumns + " from pitch_decks where slug = $1"
+	if _, err := uuid.Parse(idOrSlug); err == nil {
+		query = "select " + deckColumns + " from pitch_decks where id = $1"
+	}
+
+	deck, err := scanDeck(p.db.QueryRow(ctx, query, idOrSlug))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deck: %w", err)
+	}
+	return &deck, nil
+}
+
+// Save creates the deck or updates it. The slug, markdown and project are kept when
+// not set, as the record is saved again when the generation completes.
+func (p *Postgres) Save(ctx context.Context, deck *model.PitchDeckInfo) error {
+	_, err := p.db.Exec(ctx, `
+		insert into pitch_decks (id, user_id, name, slug, pdf_url, html_url, markdown_url,
+			project_id, is_public, status, created_at, tenant_id)
+		values ($1, $2, $3, nullif($4, ''), $5, $6, nullif($7, ''), nullif($8, '')::uuid, $9, $10, $11, $12)
+		on conflict (id) do update set
+			name = excluded.name,
+			slug = coalesce(excluded.slug, pitch_decks.slug),
+			pdf_url = excluded.pdf_url,
+			html_url = excluded.html_url,
+			markdown_url = coalesce(excluded.markdown_url, pitch_decks.markdown_url),
+			project_id = coalesce(excluded.project_id, pitch_decks.project_id),
+			is_public = excluded.is_public,
+			status = excluded.status`,
+		deck.ID, deck.UserID, deck.Name, deck.Slug, deck.PdfURL, deck.HtmlURL, deck.MarkdownURL,
+		deck.ProjectID, deck.IsPublic, deck.Status, deck.CreatedAt, deck.TenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save deck: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the decks of a user and of their organizations, and the
+// number of decks matching the filters
+func (p *Postgres) List(ctx context.Context, userID string, orgIDs []string, opts model.DeckListOptions) ([]model.PitchDeckInfo, int, error) {
+	if !deckSortColumns[opts.Sort] || (opts.Order != "asc" && opts.Order != "desc") {
+		return nil, 0, fmt.Errorf("%w: unsupported order %s %s", model.ErrInvalidInput, opts.Sort, opts.Order)
+	}
+
+	args := []any{userID, orgIDs}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"(user_id = $1 or org_id = any($2))", "tenant_id = " + arg(opts.TenantID)}
+	switch opts.ProjectID {
+	case "":
+	case "none":
+		conditions = append(conditions, "project_id is null")
+	default:
+		conditions = append(conditions, "project_id = "+arg(opts.ProjectID))
+	}
+	if opts.Status != "" {
+		conditions = append(conditions, "status = "+arg(opts.Status))
+	}
+	if opts.Visibility != "" {
+		conditions = append(conditions, "is_public = "+arg(opts.Visibility == "public"))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		conditions = append(conditions, "created_at >= "+arg(opts.CreatedAfter))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		conditions = append(conditions, "created_at < "+arg(opts.CreatedBefore))
+	}
+	where := " where " + strings.Join(conditions, " and ")
+
+	var total int
+	if err := p.db.QueryRow(ctx, "select count(*) from pitch_decks"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count decks: %w", err)
+	}
+
+	// The ID breaks ties so pages do not overlap
+	query := fmt.Sprintf("select %s from pitch_decks%s order by %s %s, id", deckColumns, where, opts.Sort, opts.Order)
+	if opts.Limit > 0 {
+		query += " limit " + arg(opts.Limit) + " offset " + arg(opts.Offset)
+	}
+
+	rows, err := p.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list decks: %w", err)
+	}
+	decks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.PitchDeckInfo, error) {
+		return scanDeck(row)
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list decks: %w", err)
+	}
+	return decks, total, nil
+}
+
+func (p *Postgres) UpdateStatus(ctx context.Context, deckID, status string) error {
+	if _, err := p.db.Exec(ctx, "update pitch_decks set status = $2 where id = $1", deckID, status); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) StartProcessing(ctx context.Context, deckID string) error {
+	tag, err := p.db.Exec(ctx,
+		"update pitch_decks set status = 'processing' where id = $1 and status <> 'processing'", deckID)
+	if err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: the deck is already processing", model.ErrConflict)
+	}
+	return nil
+}
+
+func (p *Postgres) UpdateVisibility(ctx context.Context, deckID string, isPublic bool) error {
+	if _, err := p.db.Exec(ctx, "update pitch_decks set is_public = $2 where id = $1", deckID, isPublic); err != nil {
+		return fmt.Errorf("failed to update visibility: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Revise(ctx context.Context, deckID string, revision int) error {
+	tag, err := p.db.Exec(ctx,
+		"update pitch_decks set revision = revision + 1 where id = $1 and revision = $2", deckID, revision)
+	if err != nil {
+		return fmt.Errorf("failed to update revision: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: the deck is not at revision %d", model.ErrConflict, revision)
+	}
+	return nil
+}
+
+// RecordFailure marks the deck as failed with the classification of the error
+func (p *Postgres) RecordFailure(ctx context.Context, deckID, code, message string) error {
+	_, err := p.db.Exec(ctx,
+		"update pitch_decks set status = 'failed', error_code = $2, error_message = $3 where id = $1",
+		deckID, code, message)
+	if err != nil {
+		return fmt.Errorf("failed to record failure: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) SaveInput(ctx context.Context, deckID, userID string, data model.PitchDeckData) error {
+	_, err := p.db.Exec(ctx,
+		"insert into deck_inputs (deck_id, user_id, data, created_at) values ($1, $2, $3, now())",
+		deckID, userID, data)
+	if err != nil {
+		return fmt.Errorf("failed to save deck answers: %w", err)
+	}
+	return nil
+}
+
+// Input returns the answers of a deck, nil for decks imported from a file
+func (p *Postgres) Input(ctx context.Context, deckID string) (*model.PitchDeckData, error) {
+	var data model.PitchDeckData
+	err := p.db.QueryRow(ctx,
+		"select data from deck_inputs where deck_id = $1 order by created_at desc limit 1", deckID).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deck answers: %w", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/internal/tracing"
+
+	"github.com/google/uuid"
+)
+
+// postgrestClient sends the requests, as client spans of the traces of their context
+var postgrestClient = &http.Client{Transport: tracing.Transport(nil)}
+
+// PostgREST goes through the Supabase REST API with the service key. The API has no
+// transactions: WithTx runs fn directly and its writes are not rolled back on error.
+//
+// With SUPABASE_RLS set to true, requests made with the token of a user (see
+// WithAccessToken) send it with the anon key instead, so PostgREST applies the row
+// level security policies. Background work keeps the service key.
+type PostgREST struct {
+	baseURL string
+	key     string
+	anonKey string
+	rls     bool
+}
+
+func NewPostgREST() (*PostgREST, error) {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+
+	if supabaseURL == "" || supabaseKey == "" {
+		return nil, fmt.Errorf("supabase credentials not set")
+	}
+
+	rls := os.Getenv("SUPABASE_RLS") == "true"
+	anonKey := os.Getenv("SUPABASE_KEY")
+	if rls && anonKey == "" {
+		return nil, fmt.Errorf("SUPABASE_KEY is required when SUPABASE_RLS is enabled")
+	}
+
+	return &PostgREST{
+		baseURL: strings.TrimSuffix(supabaseURL, "/") + "/rest/v1/",
+		key:     supabaseKey,
+		anonKey: anonKey,
+		rls:     rls,
+	}, nil
+}
+
+// request sends a request to the REST API and decodes the response into out when set
+func (r *PostgREST) request(ctx context.Context, method, path, prefer string, body, out any) (http.Header, error) {
+	var jsonData []byte
+	if body != nil {
+		var err error
+		if jsonData, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to marshal body: %w", err)
+		}
+	}
+
+	apiKey, bearer := r.key, r.key
+	if token := AccessToken(ctx); r.rls && token != "" {
+		apiKey, bearer = r.anonKey, token
+	}
+
+	// Inserts and RPCs may not be idempotent, they are not retried
+	dependency := resilience.Supabase
+	if method == "POST" {
+		dependency = dependency.Once()
+	}
+
+	type response struct {
+		header http.Header
+		body   []byte
+	}
+	res, err := resilience.Call(dependency, func() (response, error) {
+		var reader io.Reader
+		if jsonData != nil {
+			reader = bytes.NewReader(jsonData)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, reader)
+		if err != nil {
+			return response{}, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("apikey", apiKey)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+
+		resp, err := postgrestClient.Do(req)
+		if err != nil {
+			return response{}, fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return response{}, fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return response{}, &resilience.StatusError{
+				StatusCode: resp.StatusCode,
+				Message:    fmt.Sprintf("supabase request failed with status %d: %s", resp.StatusCode, string(respBody)),
+			}
+		}
+		return response{header: resp.Header, body: respBody}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if out != nil && len(res.body) > 0 {
+		if err := json.Unmarshal(res.body, out); err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+	return res.header, nil
+}
+
+// WithTx runs fn without a transaction, see PostgREST
+func (r *PostgREST) WithTx(ctx context.Context, fn func(repo model.DeckRepository) error) error {
+	return fn(r)
+}
+
+// Get returns a deck by ID, or by slug for shared URLs
+func (r *PostgREST) Get(ctx context.Context, idOrSlug string) (*model.PitchDeckInfo, error) {
+	path := "pitch_decks?slug=eq." + url.QueryEscape(idOrSlug)
+	if _, err := uuid.Parse(idOrSlug); err == nil {
+		path = "pitch_decks?id=eq." + idOrSlug
+	}
+
+	var decks []model.PitchDeckInfo
+	if _, err := r.request(ctx, "GET", path, "", nil, &decks); err != nil {
+		return nil, fmt.Errorf("failed to load deck: %w", err)
+	}
+	if len(decks) == 0 {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	return &decks[0], nil
+}
+
+// Save creates the deck or updates it. The slug, markdown and project are omitted
+// when not set, so they are kept when the record is saved again.
+func (r *PostgREST) Save(ctx context.Context, deck *model.PitchDeckInfo) error {
+	record := model.PitchDeckInfo{
+		ID:          deck.ID,
+		UserID:      deck.UserID,
+		Name:        deck.Name,
+		Slug:        deck.Slug,
+		PdfURL:      deck.PdfURL,
+		HtmlURL:     deck.HtmlURL,
+		MarkdownURL: deck.MarkdownURL,
+		ProjectID:   deck.ProjectID,
+		IsPublic:    deck.IsPublic,
+		Status:      deck.Status,
+		TenantID:    deck.TenantID,
+		CreatedAt:   deck.CreatedAt,
+	}
+	if _, err := r.request(ctx, "POST", "pitch_decks", "resolution=merge-duplicates,return=minimal", record, nil); err != nil {
+		return fmt.Errorf("failed to save deck: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of the decks of a user and of their organizations, and the
+// number of decks matching the filters
+func (r *PostgREST) List(ctx context.Context, userID string, orgIDs []string, opts model.DeckListOptions) ([]model.PitchDeckInfo, int, error) {
+	if !deckSortColumns[opts.Sort] || (opts.Order != "asc" && opts.Order != "desc") {
+		return nil, 0, fmt.Errorf("%w: unsupported order %s %s", model.ErrInvalidInput, opts.Sort, opts.Order)
+	}
+
+	path := "pitch_decks?user_id=eq." + url.QueryEscape(userID)
+	if len(orgIDs) > 0 {
+		path = fmt.Sprintf("pitch_decks?or=(user_id.eq.%s,org_id.in.(%s))", url.QueryEscape(userID), strings.Join(orgIDs, ","))
+	}
+	// The ID breaks ties so pages do not overlap
+	path += fmt.Sprintf("&order=%s.%s,id.asc", opts.Sort, opts.Order)
+
+	switch opts.ProjectID {
+	case "":
+	case "none":
+		path += "&project_id=is.null"
+	default:
+		path += "&project_id=eq." + url.QueryEscape(opts.ProjectID)
+	}
+	path += "&tenant_id=eq." + url.QueryEscape(opts.TenantID)
+	if opts.Status != "" {
+		path += "&status=eq." + url.QueryEscape(opts.Status)
+	}
+	if opts.Visibility != "" {
+		path += "&is_public=is." + strconv.FormatBool(opts.Visibility == "public")
+	}
+	if !opts.CreatedAfter.IsZero() {
+		path += "&created_at=gte." + url.QueryEscape(opts.CreatedAfter.Format(time.RFC3339))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		path += "&created_at=lt." + url.QueryEscape(opts.CreatedBefore.Format(time.RFC3339))
+	}
+	if opts.Limit > 0 {
+		path += fmt.Sprintf("&limit=%d&offset=%d", opts.Limit, opts.Offset)
+	}
+
+	// The total is returned in the Content-Range header, e.g. 0-49/123
+	var decks []model.PitchDeckInfo
+	header, err := r.request(ctx, "GET", path, "count=exact", nil, &decks)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list decks: %w", err)
+	}
+
+	total := len(decks)
+	if _, count, found := strings.Cut(header.Get("Content-Range"), "/"); found {
+		if n, err := strconv.Atoi(count); err == nil {
+			total = n
+		}
+	}
+	return decks, total, nil
+}
+
+// update patches the columns of a deck
+func (r *PostgREST) update(ctx context.Context, deckID string, fields map[string]any) error {
+	_, err := r.request(ctx, "PATCH", "pitch_decks?id=eq."+url.QueryEscape(deckID), "return=minimal", fields, nil)
+	return err
+}
+
+func (r *PostgREST) UpdateStatus(ctx context.Context, deckID, status string) error {
+	if err := r.update(ctx, deckID, map[string]any{"status": status}); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	return nil
+}
+
+func (r *PostgREST) StartProcessing(ctx context.Context, deckID string) error {
+	var started []model.PitchDeckInfo
+	path := "pitch_decks?id=eq." + url.QueryEscape(deckID) + "&status=neq.processing&select=id"
+	if _, err := r.request(ctx, "PATCH", path, "return=representation", map[string]any{"status": "processing"}, &started); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+	if len(started) == 0 {
+		return fmt.Errorf("%w: the deck is already processing", model.ErrConflict)
+	}
+	return nil
+}
+
+func (r *PostgREST) UpdateVisibility(ctx context.Context, deckID string, isPublic bool) error {
+	if err := r.update(ctx, deckID, map[string]any{"is_public": isPublic}); err != nil {
+		return fmt.Errorf("failed to update visibility: %w", err)
+	}
+	return nil
+}
+
+// Revise sets the next revision only on the deck still at the given one, the API
+// has no expression to increment it
+func (r *PostgREST) Revise(ctx context.Context, deckID string, revision int) error {
+	var revised []model.PitchDeckInfo
+	path := fmt.Sprintf("pitch_decks?id=eq.%s&revision=eq.%d&select=id", url.QueryEscape(deckID), revision)
+	if _, err := r.request(ctx, "PATCH", path, "return=representation", map[string]any{"revision": revision + 1}, &revised); err != nil {
+		return fmt.Errorf("failed to update revision: %w", err)
+	}
+	if len(revised) == 0 {
+		return fmt.Errorf("%w: the deck is not at revision %d", model.ErrConflict, revision)
+	}
+	return nil
+}
+
+// RecordFailure marks the deck as failed with the classification of the error
+func (r *PostgREST) RecordFailure(ctx context.Context, deckID, code, message string) error {
+	failure := map[string]any{
+		"status":        "failed",
+		"error_code":    code,
+		"error_message": message,
+	}
+	if err := r.update(ctx, deckID, failure); err != nil {
+		return fmt.Errorf("failed to record failure: %w", err)
+	}
+	return nil
+}
+
+// deckInput is a row of the deck_inputs table
+type deckInput struct {
+	DeckID    string              `json:"deck_id"`
+	UserID    string              `json:"user_id"`
+	Data      model.PitchDeckData `json:"data"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+func (r *PostgREST) SaveInput(ctx context.Context, deckID, userID string, data model.PitchDeckData) error {
+	input := deckInput{DeckID: deckID, UserID: userID, Data: data, CreatedAt: time.Now()}
+	if _, err := r.request(ctx, "POST", "deck_inputs", "return=minimal", input, nil); err != nil {
+		return fmt.Errorf("failed to save deck answers: %w", err)
+	}
+	return nil
+}
+
+// Input returns the answers of a deck, nil for decks imported from a file
+func (r *PostgREST) Input(ctx context.Context, deckID string) (*model.PitchDeckData, error) {
+	var inputs []deckInput
+	path := "deck_inputs?deck_id=eq." + url.QueryEscape(deckID) + "&order=created_at.desc&limit=1"
+	if _, err := r.request(ctx, "GET", path, "", nil, &inputs); err != nil {
+		return nil, fmt.Errorf("failed to load deck answers: %w", err)
+	}
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	return &inputs[0].Data, nil
+}
@@ -0,0 +1,168 @@
+// Package resilience protects the calls to external dependencies (Supabase, the
+// storage and the LLM) with circuit breakers and retries. A dependency failing
+// repeatedly is not called for a while, so requests fail fast with
+// model.ErrUnavailable instead of each waiting for it to time out.
+package resilience
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/sony/gobreaker/v2"
+)
+
+const (
+	// Consecutive failures opening the breaker of a dependency
+	tripAfter = 5
+	// How long an open breaker rejects calls before letting one through
+	openTimeout = 30 * time.Second
+)
+
+// Breaker states and trips of each dependency, published on the admin metrics endpoint
+var breakerMetrics = expvar.NewMap("breakers")
+
+// StatusError is an HTTP error status answered by a dependency
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// Policy sets how the calls to a dependency are retried
+type Policy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Dependency is an external service called through a circuit breaker
+type Dependency struct {
+	name    string
+	breaker *gobreaker.CircuitBreaker[any]
+	policy  Policy
+}
+
+// Dependencies of the server, sharing their breaker across packages
+var (
+	Supabase = New("supabase", Policy{Attempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second})
+	Storage  = New("storage", Policy{Attempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second})
+	LLM      = New("llm", Policy{Attempts: 3, BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+)
+
+// New creates a dependency with its own breaker
+func New(name string, policy Policy) *Dependency {
+	breakerMetrics.Set(name, stateVar(gobreaker.StateClosed))
+	return &Dependency{
+		name:   name,
+		policy: policy,
+		breaker: gobreaker.NewCircuitBreaker[any](gobreaker.Settings{
+			Name:        name,
+			MaxRequests: 1,
+			Timeout:     openTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= tripAfter
+			},
+			// Requests rejected by the dependency do not tell it is down
+			IsSuccessful: func(err error) bool {
+				return err == nil || permanent(err)
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				log.Printf("Circuit breaker of %s changed from %s to %s", name, from, to)
+				breakerMetrics.Set(name, stateVar(to))
+				if to == gobreaker.StateOpen {
+					breakerMetrics.Add(name+"_trips", 1)
+				}
+			},
+		}),
+	}
+}
+
+// Once returns the dependency without retries, for calls that are not idempotent.
+// It shares the breaker of d.
+func (d *Dependency) Once() *Dependency {
+	once := *d
+	once.policy.Attempts = 1
+	return &once
+}
+
+// Available reports whether the breaker of the dependency lets calls through
+func (d *Dependency) Available() bool {
+	return d.breaker.State() != gobreaker.StateOpen
+}
+
+// Call runs fn through the breaker of a dependency. Failures are retried with
+// exponential backoff and jitter, except those caused by the request itself. While
+// the breaker is open fn is not called and an error wrapping model.ErrUnavailable
+// is returned.
+func Call[T any](d *Dependency, fn func() (T, error)) (T, error) {
+	var zero T
+	var err error
+	for attempt := 0; attempt < max(d.policy.Attempts, 1); attempt++ {
+		if attempt > 0 {
+			time.Sleep(d.backoff(attempt))
+		}
+
+		var result any
+		result, err = d.breaker.Execute(func() (any, error) {
+			return fn()
+		})
+		if err == nil {
+			value, _ := result.(T)
+			return value, nil
+		}
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			breakerMetrics.Add(d.name+"_rejected", 1)
+			return zero, fmt.Errorf("%w: %s is unavailable, try again in a moment", model.ErrUnavailable, d.name)
+		}
+		if permanent(err) {
+			return zero, err
+		}
+	}
+	return zero, err
+}
+
+// Do is Call for functions without a result
+func Do(d *Dependency, fn func() error) error {
+	_, err := Call(d, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+	return err
+}
+
+// backoff returns the delay before a retry: it doubles with each attempt up to
+// MaxDelay, and half of it is random so the instances do not retry in step
+func (d *Dependency) backoff(attempt int) time.Duration {
+	delay := min(d.policy.BaseDelay<<(attempt-1), d.policy.MaxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Transient reports whether a failed call may succeed when made again later
+func Transient(err error) bool {
+	return err != nil && !permanent(err)
+}
+
+// permanent reports whether an error is caused by the request rather than the
+// dependency, so retrying it would fail again
+func permanent(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	code := statusErr.StatusCode
+	return code >= 400 && code < 500 && code != 408 && code != 429
+}
+
+func stateVar(state gobreaker.State) *expvar.String {
+	v := new(expvar.String)
+	v.Set(state.String())
+	return v
+}
@@ -0,0 +1,253 @@
+// Package sanitize removes active content (scripts, event handlers, javascript: URLs,
+// frames, forms) from the HTML of generated and user-edited decks, keeping the
+// markup and styles Marp decks are laid out with. Comments, which hold the Marp
+// directives, are kept with their content sanitized.
+package sanitize
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	nethtml "golang.org/x/net/html"
+)
+
+// Elements kept, without the attributes not listed below
+var allowedElements = map[string]bool{
+	"a": true, "abbr": true, "article": true, "aside": true, "b": true, "blockquote": true,
+	"br": true, "caption": true, "center": true, "cite": true, "code": true, "col": true,
+	"colgroup": true, "dd": true, "del": true, "details": true, "div": true, "dl": true,
+	"dt": true, "em": true, "figcaption": true, "figure": true, "footer": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true,
+	"i": true, "img": true, "ins": true, "kbd": true, "li": true, "main": true, "mark": true,
+	"ol": true, "p": true, "picture": true, "pre": true, "q": true, "s": true, "section": true,
+	"small": true, "source": true, "span": true, "strong": true, "style": true, "sub": true,
+	"summary": true, "sup": true, "table": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "tr": true, "u": true, "ul": true,
+}
+
+// Elements removed with their content, the others are removed without it
+var droppedElements = map[string]bool{
+	"applet": true, "embed": true, "frame": true, "frameset": true, "iframe": true,
+	"math": true, "noembed": true, "noframes": true, "noscript": true, "object": true,
+	"plaintext": true, "script": true, "select": true, "svg": true, "template": true,
+	"textarea": true, "title": true, "xmp": true,
+}
+
+var allowedAttributes = map[string]bool{
+	"align": true, "alt": true, "class": true, "colspan": true, "dir": true, "height": true,
+	"href": true, "id": true, "lang": true, "rel": true, "rowspan": true, "src": true,
+	"srcset": true, "style": true, "target": true, "title": true, "type": true, "width": true,
+}
+
+// CSS able to run scripts in some browsers
+var unsafeCSS = regexp.MustCompile(`(?i)expression\s*\(|javascript:|vbscript:|-moz-binding|behavior\s*:`)
+
+// Characters browsers ignore in URL schemes, e.g. "java\tscript:"
+var ignoredURLChars = regexp.MustCompile(`[\x00-\x20]`)
+
+// HTML sanitizes an HTML fragment
+func HTML(fragment string) string {
+	var b strings.Builder
+	z := nethtml.NewTokenizer(strings.NewReader(fragment))
+
+	// Element whose content is being removed, and how deep it is nested
+	skipping, depth := "", 0
+	inStyle := false
+
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			return b.String()
+		}
+		raw := string(z.Raw())
+
+		if skipping != "" {
+			name, _ := z.TagName()
+			switch {
+			case tt == nethtml.StartTagToken && string(name) == skipping:
+				depth++
+			case tt == nethtml.EndTagToken && string(name) == skipping:
+				if depth--; depth == 0 {
+					skipping = ""
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case nethtml.TextToken:
+			// Text is kept as written, escaping it would change markdown and CSS
+			if inStyle && unsafeCSS.MatchString(raw) {
+				continue
+			}
+			b.WriteString(raw)
+
+		case nethtml.CommentToken:
+			b.WriteString(comment(raw))
+
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			token := z.Token()
+			if droppedElements[token.Data] {
+				if tt == nethtml.StartTagToken {
+					skipping, depth = token.Data, 1
+				}
+				continue
+			}
+			if !allowedElements[token.Data] {
+				continue
+			}
+			inStyle = token.Data == "style" && tt == nethtml.StartTagToken
+			b.WriteString(startTag(token, tt == nethtml.SelfClosingTagToken))
+
+		case nethtml.EndTagToken:
+			token := z.Token()
+			if token.Data == "style" {
+				inStyle = false
+			}
+			if allowedElements[token.Data] {
+				b.WriteString("</" + token.Data + ">")
+			}
+		}
+	}
+}
+
+// comment sanitizes the content of a comment, as Marp renders the values of
+// directives such as header and footer
+func comment(raw string) string {
+	inner, ok := strings.CutPrefix(raw, "<!--")
+	if !ok {
+		return ""
+	}
+	inner, ok = strings.CutSuffix(inner, "-->")
+	if !ok || strings.Contains(inner, "-->") {
+		return ""
+	}
+	if !strings.Contains(inner, "<") {
+		return raw
+	}
+	return "<!--" + strings.ReplaceAll(HTML(inner), "-->", "") + "-->"
+}
+
+// startTag writes a start tag with its allowed attributes
+func startTag(token nethtml.Token, selfClosing bool) string {
+	var b strings.Builder
+	b.WriteString("<" + token.Data)
+
+	blankTarget := false
+	for _, attr := range token.Attr {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" || !(allowedAttributes[key] || strings.HasPrefix(key, "data-")) {
+			continue
+		}
+		switch key {
+		case "href", "src":
+			if !safeURL(attr.Val, token.Data == "img" || token.Data == "source") {
+				continue
+			}
+		case "srcset":
+			if !safeSrcset(attr.Val) {
+				continue
+			}
+		case "style":
+			if unsafeCSS.MatchString(attr.Val) {
+				continue
+			}
+		case "target":
+			blankTarget = attr.Val == "_blank"
+		case "rel":
+			// Set below for links opened in a new tab
+			continue
+		}
+		b.WriteString(" " + key + `="` + html.EscapeString(attr.Val) + `"`)
+	}
+	if blankTarget {
+		b.WriteString(` rel="noopener noreferrer"`)
+	}
+
+	if selfClosing {
+		b.WriteString(" />")
+	} else {
+		b.WriteString(">")
+	}
+	return b.String()
+}
+
+// safeURL accepts relative URLs and web, mail and phone links, and inline images
+func safeURL(value string, image bool) bool {
+	normalized := strings.ToLower(ignoredURLChars.ReplaceAllString(html.UnescapeString(value), ""))
+	scheme, _, found := strings.Cut(normalized, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return true
+	}
+	switch scheme {
+	case "http", "https", "mailto", "tel":
+		return true
+	case "data":
+		return image && strings.HasPrefix(normalized, "data:image/")
+	}
+	return false
+}
+
+func safeSrcset(value string) bool {
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 && !safeURL(fields[0], true) {
+			return false
+		}
+	}
+	return true
+}
+
+// Markdown sanitizes the raw HTML of a markdown document, its blocks and inline
+// tags. The rest of the markdown is kept as written.
+func Markdown(markdown string) string {
+	source := []byte(markdown)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(source))
+
+	var segments []text.Segment
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := n.(type) {
+		case *ast.HTMLBlock:
+			lines := node.Lines()
+			if lines.Len() == 0 {
+				break
+			}
+			segment := text.NewSegment(lines.At(0).Start, lines.At(lines.Len()-1).Stop)
+			if node.HasClosure() {
+				segment.Stop = node.ClosureLine.Stop
+			}
+			segments = append(segments, segment)
+		case *ast.RawHTML:
+			for i := 0; i < node.Segments.Len(); i++ {
+				segments = append(segments, node.Segments.At(i))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(segments) == 0 {
+		return markdown
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+
+	var out bytes.Buffer
+	last := 0
+	for _, segment := range segments {
+		if segment.Start < last {
+			continue
+		}
+		out.Write(source[last:segment.Start])
+		out.WriteString(HTML(string(segment.Value(source))))
+		last = segment.Stop
+	}
+	out.Write(source[last:])
+	return out.String()
+}
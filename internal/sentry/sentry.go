@@ -0,0 +1,111 @@
+// Package sentry reports errors and panics to Sentry, or a compatible service such
+// as GlitchTip, with the sentry-go SDK. The errors of requests are reported with
+// the hub the gin middleware of sentry-go keeps on their context.
+//
+// Events are sent in the background, and only once Configure found a DSN.
+// Otherwise capturing does nothing.
+package sentry
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// Payloads attached to an event, such as the output of the renderer, are cut to this
+// many bytes
+const maxExtraBytes = 8 << 10
+
+var enabled atomic.Bool
+
+// Context is what an event is about. Tags are indexed by Sentry and can be searched,
+// extra data such as the response of an API is only shown on the event.
+type Context struct {
+	UserID string
+	Tags   map[string]string
+	Extra  map[string]string
+	// Request the error happened in, if any
+	Request *http.Request
+}
+
+// Configure enables error tracking when SENTRY_DSN is set, e.g.
+// "https://<key>@o1.ingest.sentry.io/42". SENTRY_ENVIRONMENT and SENTRY_RELEASE are
+// sent with the events. A DSN that cannot be parsed is an error.
+func Configure() (bool, error) {
+	if os.Getenv("SENTRY_DSN") == "" {
+		return false, nil
+	}
+	// The DSN, environment and release are read from the environment by the SDK
+	if err := sentry.Init(sentry.ClientOptions{}); err != nil {
+		return false, err
+	}
+	enabled.Store(true)
+	return true, nil
+}
+
+// Enabled reports whether errors are reported
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// CaptureError reports an error with the stack of its caller
+func CaptureError(err error, c Context) {
+	if !Enabled() || err == nil {
+		return
+	}
+	withScope(c, func(hub *sentry.Hub) { hub.CaptureException(err) })
+}
+
+// CapturePanic reports a recovered panic. Called from the deferred function that
+// recovered it, the stack trace ends where the panic happened.
+func CapturePanic(recovered interface{}, c Context) {
+	if !Enabled() {
+		return
+	}
+	withScope(c, func(hub *sentry.Hub) { hub.Recover(recovered) })
+}
+
+// SetUser records the authenticated user of a request on the events it reports
+func SetUser(ctx context.Context, userID string) {
+	if hub := sentry.GetHubFromContext(ctx); hub != nil {
+		hub.Scope().SetUser(sentry.User{ID: userID})
+	}
+}
+
+// Flush sends the events still queued, waiting until ctx is done at most
+func Flush(ctx context.Context) {
+	if Enabled() {
+		sentry.FlushWithContext(ctx)
+	}
+}
+
+// withScope reports an event with the hub of the request of c, or the global one,
+// in a scope holding what c says of the event
+func withScope(c Context, report func(hub *sentry.Hub)) {
+	hub := sentry.CurrentHub()
+	if c.Request != nil {
+		if requestHub := sentry.GetHubFromContext(c.Request.Context()); requestHub != nil {
+			hub = requestHub
+		}
+	}
+
+	hub.WithScope(func(scope *sentry.Scope) {
+		if c.UserID != "" {
+			scope.SetUser(sentry.User{ID: c.UserID})
+		}
+		if c.Request != nil {
+			scope.SetRequest(c.Request)
+		}
+		scope.SetTags(c.Tags)
+		for key, payload := range c.Extra {
+			if len(payload) > maxExtraBytes {
+				payload = payload[len(payload)-maxExtraBytes:]
+			}
+			scope.SetExtra(key, payload)
+		}
+		report(hub)
+	})
+}
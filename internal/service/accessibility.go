@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/render"
+	"pitch-deck-generator/internal/slides"
+)
+
+// Characters of the big idea kept as the subject of a PDF
+const maxPDFSubjectLength = 200
+
+var (
+	imageAltRegex = regexp.MustCompile(`!\[([^\]]*)\]\(`)
+	// Keywords of Marp image syntax, removed from the alternative text of images
+	marpImageKeywordRegex = regexp.MustCompile(`^(bg|contain|cover|fit|auto|left|right|vertical|` +
+		`(blur|brightness|contrast|drop-shadow|grayscale|hue-rotate|invert|opacity|saturate|sepia)(:.*)?|` +
+		`(w|h|width|height):.+|\d+(\.\d+)?%)$`)
+)
+
+// describeImages gives the images of a deck without alternative text one, after the
+// title of their slide, so that screen readers and tagged PDFs do not skip them
+func describeImages(markdown string) string {
+	frontMatter, deckSlides := slides.Split(markdown)
+	described := false
+	for i, slide := range deckSlides {
+		description := slides.Title(slide)
+		if description == "" {
+			description = fmt.Sprintf("Slide %d image", i+1)
+		}
+		description = strings.NewReplacer("[", "", "]", "").Replace(description)
+
+		deckSlides[i] = imageAltRegex.ReplaceAllStringFunc(slide, func(match string) string {
+			alt := imageAltRegex.FindStringSubmatch(match)[1]
+			if hasAltText(alt) {
+				return match
+			}
+			described = true
+			return "![" + strings.TrimSpace(description+" "+strings.TrimSpace(alt)) + "]("
+		})
+	}
+	if !described {
+		return markdown
+	}
+	return slides.Join(frontMatter, deckSlides)
+}
+
+// hasAltText reports whether the alt of a markdown image holds text other than the
+// keywords of Marp
+func hasAltText(alt string) bool {
+	for _, word := range strings.Fields(alt) {
+		if !marpImageKeywordRegex.MatchString(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// pdfMetadata describes a deck in the document information of its PDF
+func pdfMetadata(data model.PitchDeckData) render.PDFInfo {
+	var authors []string
+	for _, member := range data.TeamMembers {
+		if name := strings.TrimSpace(member.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	var keywords []string
+	for _, keyword := range []string{data.Industry, data.TargetNiche, data.FundingStage, data.DeckType} {
+		if keyword = strings.TrimSpace(strings.ReplaceAll(keyword, "_", " ")); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return render.PDFInfo{
+		Title:    strings.TrimSpace(data.ProjectName),
+		Author:   strings.Join(authors, ", "),
+		Subject:  truncateRunes(strings.TrimSpace(data.BigIdea), maxPDFSubjectLength),
+		Keywords: strings.Join(keywords, ", "),
+	}
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/storage"
+)
+
+// Replaces the user in the records kept after their account is erased, such as the
+// audit log of the decks of an organization
+const erasedUserID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+
+// userTables are the tables holding the records of a user, with the column naming
+// them and the columns exported. Deleting the decks of a user deletes the records of
+// the decks (answers, versions, share links, view events...) with them.
+var userTables = []struct {
+	Name   string
+	Column string
+	Select string
+}{
+	{"pitch_decks", "user_id", "*"},
+	{"deck_inputs", "user_id", "*"},
+	{"projects", "user_id", "*"},
+	{"user_files", "user_id", "*"},
+	{"share_links", "user_id", "*"},
+	{"deck_feedback", "user_id", "*"},
+	{"deck_drafts", "user_id", "*"},
+	{"intake_sessions", "user_id", "*"},
+	{"organization_members", "user_id", "*"},
+	{"notifications", "user_id", "*"},
+	{"email_preferences", "user_id", "*"},
+	{"user_plans", "user_id", "*"},
+	{"telegram_links", "user_id", "user_id,created_at"},
+	{"telegram_chats", "user_id", "*"},
+	// The access token stays out of the export
+	{"notion_connections", "user_id", "workspace_id,workspace_name,created_at"},
+	// Deleting the data key leaves anything it encrypted unreadable, backups included
+	{"user_data_keys", "user_id", "user_id,created_at"},
+	{"sso_identities", "user_id", "issuer,email,created_at,last_login_at"},
+	{"tenant_users", "user_id", "tenant_id,created_at"},
+	{"custom_domains", "user_id", "domain,verified_at,created_at"},
+	{"quarantined_files", "user_id", "original_name,signature,created_at"},
+	{"storage_outbox", "user_id", "kind,paths,created_at"},
+	{"deck_assets", "user_id", "deck_id,kind,path,size,created_at"},
+}
+
+// Fields of the records stored encrypted with encryption at rest, decrypted in exports
+var sealedFields = map[string]string{
+	"deck_inputs":   "data",
+	"deck_versions": "markdown",
+}
+
+// Records of other users that name the user, kept with the user replaced by erasedUserID
+var userReferences = []struct {
+	Table  string
+	Column string
+}{
+	{"audit_log", "actor_id"},
+	{"organizations", "created_by"},
+	{"organization_invites", "invited_by"},
+}
+
+// AccountService exports and erases all the data of a user, as required by the GDPR
+type AccountService struct {
+	decks *PitchDeckService
+}
+
+func NewAccountService(decks *PitchDeckService) *AccountService {
+	return &AccountService{
+		decks: decks,
+	}
+}
+
+// Export returns a ZIP of the records of a user as JSON, with the PDF and markdown of
+// their decks and their uploaded images. Files that cannot be downloaded are listed
+// in missing-files.txt instead of failing the export.
+func (s *AccountService) Export(userID string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, content []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", name, err)
+		}
+		if _, err := w.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		return nil
+	}
+
+	for _, table := range userTables {
+		var records json.RawMessage
+		path := fmt.Sprintf("%s?select=%s&%s=eq.%s", table.Name, table.Select, table.Column, url.QueryEscape(userID))
+		if err := supabaseREST("GET", path, nil, &records); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table.Name, err)
+		}
+		records, err := unsealRecords(records, sealedFields[table.Name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table.Name, err)
+		}
+		if err := add("records/"+table.Name+".json", records); err != nil {
+			return nil, err
+		}
+	}
+
+	decks, err := userDecks(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(decks))
+	for i, deck := range decks {
+		ids[i] = deck.ID
+	}
+	// Versions and analytics of the decks, the other deck records are exported above
+	for _, table := range []string{"deck_versions", "deck_view_events"} {
+		var records []json.RawMessage
+		err := forEachBatch(ids, func(batch []string) error {
+			var page []json.RawMessage
+			if err := supabaseREST("GET", table+"?deck_id=in.("+strings.Join(batch, ",")+")", nil, &page); err != nil {
+				return err
+			}
+			records = append(records, page...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		content, err := json.Marshal(records)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		if content, err = unsealRecords(content, sealedFields[table]); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		if err := add("records/"+table+".json", content); err != nil {
+			return nil, err
+		}
+	}
+
+	var missing []string
+	download := func(name, fileURL string) error {
+		if fileURL == "" {
+			return nil
+		}
+		content, err := fetchBytes(fileURL)
+		if err != nil {
+			log.Printf("Failed to export %s of user %s: %v", name, userID, err)
+			missing = append(missing, name)
+			return nil
+		}
+		return add(name, content)
+	}
+	for _, deck := range decks {
+		if err := download("decks/"+deck.ID+".pdf", deck.PdfURL); err != nil {
+			return nil, err
+		}
+		if deck.MarkdownURL == "" {
+			continue
+		}
+		// Decrypted, the stored file is sealed with encryption at rest
+		name := "decks/" + deck.ID + ".md"
+		markdown, err := s.decks.loadMarkdown(&deck)
+		if err != nil {
+			log.Printf("Failed to export %s of user %s: %v", name, userID, err)
+			missing = append(missing, name)
+			continue
+		}
+		if err := add(name, []byte(markdown)); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []model.UserFile
+	if err := supabaseREST("GET", "user_files?user_id=eq."+url.QueryEscape(userID), nil, &files); err != nil {
+		return nil, fmt.Errorf("failed to export uploads: %w", err)
+	}
+	for _, file := range files {
+		if err := download("uploads/"+file.ID+"-"+path.Base(file.OriginalName), file.FileURL); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(missing) > 0 {
+		if err := add("missing-files.txt", []byte(strings.Join(missing, "\n")+"\n")); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Erase deletes the decks, uploads, analytics and every other record of a user, their
+// stored files and their account. The records of other users that name them, such as
+// the audit log of a shared deck, are kept without their ID.
+func (s *AccountService) Erase(userID string) error {
+	decks, err := userDecks(userID)
+	if err != nil {
+		return err
+	}
+	// The job of a deck being processed would save it again when it completes
+	for _, deck := range decks {
+		if err := checkUnlocked(&deck); err != nil {
+			return err
+		}
+	}
+
+	var files []model.UserFile
+	if err := supabaseREST("GET", "user_files?select=storage_path&user_id=eq."+url.QueryEscape(userID), nil, &files); err != nil {
+		return fmt.Errorf("failed to load uploads: %w", err)
+	}
+
+	// Files go first, the records still list them if their deletion fails. Decks
+	// created before the user joined a tenant are stored outside of it.
+	ids := make([]string, len(decks))
+	paths := make(map[string][]string)
+	for i, deck := range decks {
+		ids[i] = deck.ID
+		bucket, folder, err := tenantStorage(deck.TenantID)
+		if err != nil {
+			return err
+		}
+		// Decks rendered before the files were named after their content use fixed names
+		paths[bucket] = append(paths[bucket], folder+deck.ID+".pdf", folder+deck.ID+".html", folder+deck.ID+".md")
+		for _, fileURL := range []string{deck.PdfURL, deck.HtmlURL, deck.MarkdownURL} {
+			for _, filesBucket := range []string{bucket, storage.PrivateBucket(bucket)} {
+				if p, err := storedPath(fileURL, filesBucket); err == nil && !strings.HasPrefix(p, folder+deck.ID+".") {
+					paths[filesBucket] = append(paths[filesBucket], p)
+				}
+			}
+		}
+		purgeCDN(deck.PdfURL, deck.HtmlURL, deck.MarkdownURL)
+	}
+	// The manifest lists the files of every render, previous ones included
+	assets, err := deckAssets(ids)
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		paths[asset.Bucket] = append(paths[asset.Bucket], asset.Path)
+	}
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return err
+	}
+	uploadBucket, _, err := tenantStorage(tenantID)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		paths[uploadBucket] = append(paths[uploadBucket], file.StoragePath)
+	}
+	if s.decks.storage != nil {
+		for bucket, bucketPaths := range paths {
+			err := forEachBatch(bucketPaths, func(batch []string) error {
+				return s.decks.storage.DeleteFiles(bucket, batch)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete stored files: %w", err)
+			}
+		}
+	}
+
+	// The audit log is not tied to the decks
+	err = forEachBatch(ids, func(batch []string) error {
+		return supabaseREST("DELETE", "audit_log?deck_id=in.("+strings.Join(batch, ",")+")", nil, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete audit log: %w", err)
+	}
+	for _, table := range userTables {
+		path := fmt.Sprintf("%s?%s=eq.%s", table.Name, table.Column, url.QueryEscape(userID))
+		if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", table.Name, err)
+		}
+	}
+	deckCache.invalidate()
+	dataKeys.Delete(userID)
+	tenantUsers.Delete(userID)
+
+	for _, ref := range userReferences {
+		path := fmt.Sprintf("%s?%s=eq.%s", ref.Table, ref.Column, url.QueryEscape(userID))
+		if err := supabaseREST("PATCH", path, map[string]string{ref.Column: erasedUserID}, nil); err != nil {
+			return fmt.Errorf("failed to anonymize %s: %w", ref.Table, err)
+		}
+	}
+
+	if err := deleteAuthUser(userID); err != nil {
+		return err
+	}
+	log.Printf("Erased user %s: %d decks, %d uploads", userID, len(decks), len(files))
+	return nil
+}
+
+// unsealRecords decrypts a field of JSON records stored with encryption at rest, the
+// markdown of versions or the answers of decks. Records are returned as is without
+// a field to decrypt.
+func unsealRecords(records json.RawMessage, field string) (json.RawMessage, error) {
+	if field == "" || !encryptionEnabled() {
+		return records, nil
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(records, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		var markdown string
+		if json.Unmarshal(row[field], &markdown) == nil {
+			content, err := unseal(markdown)
+			if err != nil {
+				return nil, err
+			}
+			if row[field], err = json.Marshal(content); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var data model.PitchDeckData
+		if err := json.Unmarshal(row[field], &data); err != nil || data.Sealed == "" {
+			continue
+		}
+		input, err := unsealInput(&data)
+		if err != nil {
+			return nil, err
+		}
+		if row[field], err = json.Marshal(input); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(rows)
+}
+
+// userDecks returns the decks created by a user
+func userDecks(userID string) ([]model.PitchDeckInfo, error) {
+	var decks []model.PitchDeckInfo
+	path := "pitch_decks?select=id,user_id,name,status,pdf_url,html_url,markdown_url,tenant_id&user_id=eq." + url.QueryEscape(userID)
+	if err := supabaseREST("GET", path, nil, &decks); err != nil {
+		return nil, fmt.Errorf("failed to load decks: %w", err)
+	}
+	return decks, nil
+}
+
+// forEachBatch calls fn with the items by batches of recordBatchSize, until it fails
+func forEachBatch(items []string, fn func(batch []string) error) error {
+	for start := 0; start < len(items); start += recordBatchSize {
+		if err := fn(items[start:min(start+recordBatchSize, len(items))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAuthUser deletes the Supabase account of a user, signing them out everywhere
+func deleteAuthUser(userID string) error {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+	if supabaseURL == "" || supabaseKey == "" {
+		return fmt.Errorf("supabase credentials not set")
+	}
+
+	apiURL := strings.TrimSuffix(supabaseURL, "/") + "/auth/v1/admin/users/" + url.PathEscape(userID)
+	req, err := http.NewRequest("DELETE", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("apikey", supabaseKey)
+	req.Header.Set("Authorization", "Bearer "+supabaseKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// The account may be gone already when an earlier erasure failed after deleting it
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to delete account, status: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+)
+
+// AdminService gives administrators access to every deck for operations and moderation.
+// Callers are trusted, the routes are guarded by the AdminOnly middleware.
+type AdminService struct {
+	decks     *PitchDeckService
+	storageGC *StorageGC
+
+	// Held while the search index is rebuilt
+	reindexing sync.Mutex
+}
+
+func NewAdminService(decks *PitchDeckService, storageGC *StorageGC) *AdminService {
+	return &AdminService{
+		decks:     decks,
+		storageGC: storageGC,
+	}
+}
+
+// ListDecks lists the decks of all users, newest first, optionally restricted to a
+// status, e.g. failed to inspect the generations that did not complete
+func (s *AdminService) ListDecks(status string, limit, offset int) ([]model.PitchDeckInfo, error) {
+	if limit <= 0 {
+		limit = defaultAdminPageSize
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	path := fmt.Sprintf("pitch_decks?order=created_at.desc&limit=%d&offset=%d", limit, max(offset, 0))
+	if status != "" {
+		path += "&status=eq." + url.QueryEscape(status)
+	}
+
+	var decks []model.PitchDeckInfo
+	if err := supabaseREST("GET", path, nil, &decks); err != nil {
+		return nil, fmt.Errorf("failed to list decks: %w", err)
+	}
+	return decks, nil
+}
+
+// GetDeck returns a deck with its error details and its audit log
+func (s *AdminService) GetDeck(deckID string) (*model.PitchDeckInfo, []model.AuditEvent, error) {
+	deck, err := s.decks.Get(deckID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+
+	var events []model.AuditEvent
+	path := fmt.Sprintf("audit_log?deck_id=eq.%s&order=created_at.desc&limit=%d", url.QueryEscape(deck.ID), maxAuditLimit)
+	if err := supabaseREST("GET", path, nil, &events); err != nil {
+		return nil, nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+	return deck, events, nil
+}
+
+// RetryDeck generates a deck again, e.g. after a renderer or LLM outage. Its stored
+// markdown is rendered again when there is one, see regenerate.
+func (s *AdminService) RetryDeck(deckID, adminID string) error {
+	deck, err := s.decks.Get(deckID)
+	if err != nil {
+		return fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+
+	if err := s.decks.regenerate(deck, false); err != nil {
+		return err
+	}
+
+	markRetried(deck.ID)
+	recordAudit(deck.ID, adminID, AuditDeckRegenerated, map[string]interface{}{
+		"reason": "admin_retry",
+	})
+	return nil
+}
+
+// ListFailures lists the failed generations of the dead-letter queue, newest first.
+// Failures already retried are left out unless includeRetried is set.
+func (s *AdminService) ListFailures(includeRetried bool, limit, offset int) ([]model.DeckFailure, error) {
+	if limit <= 0 {
+		limit = defaultAdminPageSize
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	path := fmt.Sprintf("deck_failures?order=created_at.desc&limit=%d&offset=%d", limit, max(offset, 0))
+	if !includeRetried {
+		path += "&retried_at=is.null"
+	}
+
+	var failures []model.DeckFailure
+	if err := supabaseREST("GET", path, nil, &failures); err != nil {
+		return nil, fmt.Errorf("failed to list failures: %w", err)
+	}
+	return failures, nil
+}
+
+// ListFeedback lists the ratings of the decks, newest first, with the average rating
+// of the decks generated with each prompt version
+func (s *AdminService) ListFeedback(limit, offset int) ([]model.DeckFeedback, []model.PromptFeedbackStats, error) {
+	if limit <= 0 {
+		limit = defaultAdminPageSize
+	}
+	limit = min(limit, maxAdminPageSize)
+
+	var feedback []model.DeckFeedback
+	path := fmt.Sprintf("deck_feedback?order=created_at.desc&limit=%d&offset=%d", limit, max(offset, 0))
+	if err := supabaseREST("GET", path, nil, &feedback); err != nil {
+		return nil, nil, fmt.Errorf("failed to list feedback: %w", err)
+	}
+
+	var stats []model.PromptFeedbackStats
+	if err := supabaseREST("POST", "rpc/prompt_feedback_stats", map[string]string{}, &stats); err != nil {
+		return nil, nil, fmt.Errorf("failed to load feedback stats: %w", err)
+	}
+	return feedback, stats, nil
+}
+
+// DeleteDeck removes the deck record. Its audit log is kept, and stored files are
+// left to the storage cleanup but purged from the CDN.
+func (s *AdminService) DeleteDeck(deckID, adminID, reason string) error {
+	deck, err := s.decks.Get(deckID)
+	if err != nil {
+		return fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	// The job would save the record again when it completes
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+
+	// Files go first, the manifest still lists them if their deletion fails
+	assets, err := deckAssets([]string{deck.ID})
+	if err != nil {
+		return err
+	}
+	if err := s.decks.deleteAssets(assets); err != nil {
+		return err
+	}
+
+	if err := supabaseREST("DELETE", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete deck: %w", err)
+	}
+	purgeCDN(deck.PdfURL, deck.HtmlURL, deck.MarkdownURL)
+
+	recordAudit(deck.ID, adminID, AuditDeckDeleted, map[string]interface{}{
+		"name":    deck.Name,
+		"user_id": deck.UserID,
+		"reason":  strings.TrimSpace(reason),
+		"by":      "admin",
+	})
+	return nil
+}
+
+// TakeDownDeck unpublishes an abusive deck. Its share links and embeds stop working
+// and the owner can no longer make it public.
+func (s *AdminService) TakeDownDeck(deckID, adminID, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return fmt.Errorf("%w: a reason is required", model.ErrInvalidInput)
+	}
+
+	deck, err := s.decks.Get(deckID)
+	if err != nil {
+		return fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+
+	update := map[string]interface{}{
+		"is_public":       false,
+		"taken_down_at":   time.Now(),
+		"takedown_reason": reason,
+		"publish_at":      nil,
+	}
+	if err := supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil); err != nil {
+		return fmt.Errorf("failed to take down deck: %w", err)
+	}
+	if err := s.decks.moveDeckFiles(deck, false); err != nil {
+		log.Printf("Failed to move the files of taken down deck %s to private storage: %v", deck.ID, err)
+	}
+
+	recordAudit(deck.ID, adminID, AuditDeckTakenDown, map[string]interface{}{
+		"reason":     reason,
+		"was_public": deck.IsPublic,
+	})
+	return nil
+}
+
+// RunStorageGC reconciles the storage with the database now instead of waiting for the schedule
+func (s *AdminService) RunStorageGC() (*model.StorageGCReport, error) {
+	return s.storageGC.Run()
+}
+
+// ReindexSearch indexes the content of every rendered deck in the background, e.g. the
+// decks rendered before search was introduced
+func (s *AdminService) ReindexSearch() error {
+	if !s.reindexing.TryLock() {
+		return fmt.Errorf("%w: a reindex is already running", model.ErrConflict)
+	}
+
+	go func() {
+		defer s.reindexing.Unlock()
+
+		var indexed, failed int
+		for offset := 0; ; offset += recordBatchSize {
+			var decks []model.PitchDeckInfo
+			path := fmt.Sprintf("pitch_decks?select=id,markdown_url&status=eq.completed&markdown_url=not.is.null&order=id&limit=%d&offset=%d",
+				recordBatchSize, offset)
+			if err := supabaseREST("GET", path, nil, &decks); err != nil {
+				log.Printf("Search reindex stopped: %v", err)
+				return
+			}
+
+			for i := range decks {
+				markdown, err := s.decks.loadMarkdown(&decks[i])
+				if err != nil {
+					log.Printf("Search reindex: failed to load deck %s: %v", decks[i].ID, err)
+					failed++
+					continue
+				}
+				indexDeckContent(decks[i].ID, markdown)
+				indexed++
+			}
+
+			if len(decks) < recordBatchSize {
+				break
+			}
+		}
+		log.Printf("Search reindex completed: %d decks indexed, %d failed", indexed, failed)
+	}()
+	return nil
+}
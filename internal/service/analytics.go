@@ -0,0 +1,394 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Longest slide duration accepted from the viewer, to ignore tabs left open
+	maxSlideDuration = 30 * time.Minute
+	// Length of the label naming the recipient of a share link
+	maxLinkLabel = 100
+)
+
+// AnalyticsService manages tracked share links and the events of their viewer
+type AnalyticsService struct {
+	decks *PitchDeckService
+}
+
+// viewEventRecord is a row of the deck_view_events table
+type viewEventRecord struct {
+	ID         string    `json:"id"`
+	LinkID     string    `json:"link_id"`
+	DeckID     string    `json:"deck_id"`
+	SessionID  string    `json:"session_id"`
+	EventType  string    `json:"event_type"`
+	Slide      int       `json:"slide"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func NewAnalyticsService(decks *PitchDeckService) *AnalyticsService {
+	return &AnalyticsService{
+		decks: decks,
+	}
+}
+
+// Share tokens are 16 random bytes, base64url encoded
+var shareTokenRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+// IsShareToken reports whether a token has the form of the tokens of share links and
+// organization invites
+func IsShareToken(token string) bool {
+	return shareTokenRegex.MatchString(token)
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateShareLink creates a tracked link to a deck, working until expiresAt unless nil
+func (s *AnalyticsService) CreateShareLink(deckID, userID, label string, expiresAt *time.Time) (*model.ShareLink, error) {
+	label = strings.TrimSpace(label)
+	if utf8.RuneCountInString(label) > maxLinkLabel {
+		return nil, fmt.Errorf("%w: the label must be at most %d characters", model.ErrInvalidInput, maxLinkLabel)
+	}
+	if err := checkExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+	if _, err := s.decks.authorizedDeck(deckID, userID, model.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link := model.ShareLink{
+		ID:        uuid.New().String(),
+		DeckID:    deckID,
+		UserID:    userID,
+		Token:     token,
+		Label:     label,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	if err := supabaseREST("POST", "share_links", link, nil); err != nil {
+		return nil, fmt.Errorf("failed to save share link: %w", err)
+	}
+
+	recordAudit(deckID, userID, AuditDeckShared, map[string]interface{}{
+		"via":        "share_link",
+		"link_id":    link.ID,
+		"label":      link.Label,
+		"expires_at": link.ExpiresAt,
+	})
+	return &link, nil
+}
+
+func (s *AnalyticsService) ListShareLinks(deckID, userID string) ([]model.ShareLink, error) {
+	if _, err := s.decks.authorizedDeck(deckID, userID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	var links []model.ShareLink
+	path := fmt.Sprintf("share_links?deck_id=eq.%s&order=created_at.desc", url.QueryEscape(deckID))
+	if err := supabaseREST("GET", path, nil, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// RevokeShareLink stops a share link working. Its events are kept in the analytics
+// of the deck.
+func (s *AnalyticsService) RevokeShareLink(deckID, linkID, userID string) error {
+	deck, err := s.decks.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+
+	var links []model.ShareLink
+	path := fmt.Sprintf("share_links?id=eq.%s&deck_id=eq.%s&revoked_at=is.null", url.QueryEscape(linkID), url.QueryEscape(deck.ID))
+	update := map[string]interface{}{"revoked_at": time.Now()}
+	if err := supabaseREST("PATCH", path, update, &links); err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
+	if len(links) == 0 {
+		return fmt.Errorf("%w: share link not found", model.ErrNotFound)
+	}
+
+	recordAudit(deck.ID, userID, AuditLinkRevoked, map[string]interface{}{
+		"link_id": links[0].ID,
+		"label":   links[0].Label,
+	})
+	return nil
+}
+
+// linkByToken resolves a share link and the deck it points to, ErrExpired once the
+// link was revoked or the link or the deck expired
+func (s *AnalyticsService) linkByToken(token string) (*model.ShareLink, *model.PitchDeckInfo, error) {
+	var links []model.ShareLink
+	if err := supabaseREST("GET", "share_links?token=eq."+url.QueryEscape(token), nil, &links); err != nil {
+		return nil, nil, err
+	}
+	if len(links) == 0 {
+		return nil, nil, fmt.Errorf("%w: share link not found", model.ErrNotFound)
+	}
+
+	deck, err := s.decks.Get(links[0].DeckID)
+	if err != nil || deck.Status != "completed" || deck.TakenDownAt != nil {
+		return nil, nil, fmt.Errorf("%w: deck not available", model.ErrNotFound)
+	}
+	if links[0].RevokedAt != nil {
+		return nil, nil, fmt.Errorf("%w: this link was revoked", model.ErrExpired)
+	}
+	if now := time.Now(); expired(links[0].ExpiresAt, now) || expired(deck.ExpiresAt, now) {
+		return nil, nil, fmt.Errorf("%w: this link is no longer shared", model.ErrExpired)
+	}
+	return &links[0], deck, nil
+}
+
+// ViewerHTML returns the deck HTML instrumented to report opens and time per slide,
+// with the viewer branding of its organization, as served on host
+func (s *AnalyticsService) ViewerHTML(token, host string) (string, error) {
+	_, deck, err := s.linkByToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	html, err := fetchText(deck.HtmlURL)
+	if err != nil {
+		return "", err
+	}
+	if html, err = brandViewer(deck, host, html); err != nil {
+		return "", err
+	}
+
+	return injectBeforeBodyEnd(html, viewerTrackingScript), nil
+}
+
+// DownloadPDF records a download through the share link and returns the PDF of the
+// deck, named after it. Resumed downloads were recorded when they started.
+func (s *AnalyticsService) DownloadPDF(token, sessionID string, resumed bool) (*model.FileDownload, error) {
+	link, deck, err := s.linkByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if deck.PdfURL == "" {
+		return nil, fmt.Errorf("%w: the PDF of this deck is not available", model.ErrNotFound)
+	}
+
+	content, err := fetchBytes(deck.PdfURL)
+	if err != nil {
+		return nil, err
+	}
+	if !resumed {
+		if err := s.saveEvent(link, model.ViewEvent{SessionID: sessionID, Type: "download"}); err != nil {
+			return nil, err
+		}
+	}
+
+	download := &model.FileDownload{
+		Filename: slugify(deck.Name) + ".pdf",
+		Content:  content,
+		ModTime:  deck.CreatedAt,
+	}
+	if deck.UpdatedAt != nil {
+		download.ModTime = *deck.UpdatedAt
+	}
+	return download, nil
+}
+
+// RecordEvent stores an event sent by the instrumented viewer
+func (s *AnalyticsService) RecordEvent(token string, event model.ViewEvent) error {
+	if event.Type != "open" && event.Type != "slide" {
+		return fmt.Errorf("%w: unknown event type %q", model.ErrInvalidInput, event.Type)
+	}
+	event.DurationMs = min(max(event.DurationMs, 0), maxSlideDuration.Milliseconds())
+
+	link, deck, err := s.linkByToken(token)
+	if err != nil {
+		return err
+	}
+	if err := s.saveEvent(link, event); err != nil {
+		return err
+	}
+
+	if event.Type == "open" {
+		message := fmt.Sprintf("%s was opened through a share link", deck.Name)
+		if link.Label != "" {
+			message = fmt.Sprintf("%s was opened through the link for %s", deck.Name, link.Label)
+		}
+		notify(deck.UserID, NotificationDeckViewed, deck.ID, message, map[string]interface{}{
+			"link_id": link.ID,
+			"label":   link.Label,
+		})
+	}
+	return nil
+}
+
+func (s *AnalyticsService) saveEvent(link *model.ShareLink, event model.ViewEvent) error {
+	record := viewEventRecord{
+		ID:         uuid.New().String(),
+		LinkID:     link.ID,
+		DeckID:     link.DeckID,
+		SessionID:  event.SessionID,
+		EventType:  event.Type,
+		Slide:      event.Slide,
+		DurationMs: event.DurationMs,
+		CreatedAt:  time.Now(),
+	}
+	if err := supabaseWrite("POST", "deck_view_events", record); err != nil {
+		return fmt.Errorf("failed to save view event: %w", err)
+	}
+	return nil
+}
+
+// GetAnalytics aggregates the events of every share link of the deck
+func (s *AnalyticsService) GetAnalytics(deckID, userID string) (*model.DeckAnalytics, error) {
+	links, err := s.ListShareLinks(deckID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []viewEventRecord
+	path := fmt.Sprintf("deck_view_events?deck_id=eq.%s&order=created_at.asc", url.QueryEscape(deckID))
+	if err := supabaseREST("GET", path, nil, &events); err != nil {
+		return nil, err
+	}
+
+	analytics := &model.DeckAnalytics{DeckID: deckID}
+	sessions := make(map[string]bool)
+	slides := make(map[int]*model.SlideAnalytics)
+	byLink := make(map[string]*model.LinkAnalytics)
+	for _, link := range links {
+		byLink[link.ID] = &model.LinkAnalytics{LinkID: link.ID, Label: link.Label, Revoked: link.RevokedAt != nil}
+	}
+
+	for _, event := range events {
+		link := byLink[event.LinkID]
+		if link == nil {
+			link = &model.LinkAnalytics{LinkID: event.LinkID}
+			byLink[event.LinkID] = link
+		}
+		createdAt := event.CreatedAt
+
+		switch event.EventType {
+		case "open":
+			analytics.Opens++
+			link.Opens++
+			analytics.LastOpenedAt = &createdAt
+			link.LastOpenedAt = &createdAt
+			if event.SessionID != "" {
+				sessions[event.SessionID] = true
+			}
+		case "download":
+			analytics.Downloads++
+			link.Downloads++
+		case "slide":
+			seconds := float64(event.DurationMs) / 1000
+			analytics.TotalSeconds += seconds
+			link.TotalSeconds += seconds
+			slide := slides[event.Slide]
+			if slide == nil {
+				slide = &model.SlideAnalytics{Slide: event.Slide}
+				slides[event.Slide] = slide
+			}
+			slide.TotalSeconds += seconds
+			slide.Views++
+		}
+	}
+	analytics.UniqueViewers = len(sessions)
+
+	for _, slide := range slides {
+		slide.AvgSeconds = slide.TotalSeconds / float64(slide.Views)
+		analytics.Slides = append(analytics.Slides, *slide)
+	}
+	sort.Slice(analytics.Slides, func(i, j int) bool { return analytics.Slides[i].Slide < analytics.Slides[j].Slide })
+
+	for _, link := range links {
+		analytics.Links = append(analytics.Links, *byLink[link.ID])
+	}
+
+	return analytics, nil
+}
+
+// RecentViews returns the latest openings of the deck through its share links
+func (s *AnalyticsService) RecentViews(deckID, userID string, limit int) ([]model.DeckView, error) {
+	if _, err := s.decks.authorizedDeck(deckID, userID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	var events []viewEventRecord
+	path := fmt.Sprintf("deck_view_events?deck_id=eq.%s&event_type=eq.open&order=created_at.desc&limit=%d",
+		url.QueryEscape(deckID), limit)
+	if err := supabaseREST("GET", path, nil, &events); err != nil {
+		return nil, err
+	}
+
+	views := make([]model.DeckView, 0, len(events))
+	for _, event := range events {
+		views = append(views, model.DeckView{LinkID: event.LinkID, SessionID: event.SessionID, OpenedAt: event.CreatedAt})
+	}
+	return views, nil
+}
+
+// viewerTrackingScript follows the slide shown by the Marp viewer (location hash)
+// and reports opens and time spent per slide with sendBeacon. It is the same for
+// every link, the viewer is served at /s/<token>, so its hash is allowed by the CSP.
+const viewerTrackingScript = `<script>
+(function () {
+  var endpoint = location.pathname.replace(/\/+$/, "") + "/events";
+  var sid = sessionStorage.getItem("pt_sid");
+  if (!sid) {
+    sid = Math.random().toString(36).slice(2) + Date.now().toString(36);
+    sessionStorage.setItem("pt_sid", sid);
+  }
+  function send(event) {
+    event.sessionId = sid;
+    var body = JSON.stringify(event);
+    if (navigator.sendBeacon) {
+      navigator.sendBeacon(endpoint, new Blob([body], { type: "application/json" }));
+    } else {
+      fetch(endpoint, { method: "POST", body: body, headers: { "Content-Type": "application/json" }, keepalive: true });
+    }
+  }
+  function currentSlide() {
+    var n = parseInt((location.hash || "").replace(/[^0-9]/g, ""), 10);
+    return isNaN(n) ? 1 : n;
+  }
+  var slide = currentSlide();
+  var since = Date.now();
+  function flush() {
+    var duration = Date.now() - since;
+    if (duration > 500) {
+      send({ type: "slide", slide: slide, durationMs: duration });
+    }
+    since = Date.now();
+  }
+  send({ type: "open", slide: slide });
+  window.addEventListener("hashchange", function () { flush(); slide = currentSlide(); });
+  document.addEventListener("visibilitychange", function () {
+    if (document.visibilityState === "hidden") { flush(); } else { since = Date.now(); }
+  });
+  window.addEventListener("pagehide", flush);
+})();
+</script>
+`
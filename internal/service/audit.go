@@ -0,0 +1,79 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditDeckCreated         = "deck.created"
+	AuditDeckRegenerated     = "deck.regenerated"
+	AuditVisibilityChanged   = "deck.visibility_changed"
+	AuditDeckShared          = "deck.shared"
+	AuditDeckDeleted         = "deck.deleted"
+	AuditDeckTakenDown       = "deck.taken_down"
+	AuditDeckClaimed         = "deck.claimed"
+	AuditPublishingScheduled = "deck.publishing_scheduled"
+	AuditExpiryChanged       = "deck.expiry_changed"
+	AuditLinkRevoked         = "deck.link_revoked"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 500
+)
+
+// recordAudit appends an event to the audit_log table. A failure is logged but
+// does not fail the action that was audited.
+func recordAudit(deckID, actorID, action string, metadata map[string]interface{}) {
+	event := model.AuditEvent{
+		ID:        uuid.New().String(),
+		DeckID:    deckID,
+		ActorID:   actorID,
+		Action:    action,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if err := supabaseWrite("POST", "audit_log", event); err != nil {
+		log.Printf("Failed to record audit event %s of deck %s: %v", action, deckID, err)
+	}
+}
+
+// AuditService exposes the lifecycle events of decks to the people accountable for them
+type AuditService struct {
+	decks *PitchDeckService
+}
+
+func NewAuditService(decks *PitchDeckService) *AuditService {
+	return &AuditService{
+		decks: decks,
+	}
+}
+
+// DeckAuditLog returns the events of a deck, newest first. It is restricted to the
+// creator of the deck and the owners of the organization it is shared with.
+func (s *AuditService) DeckAuditLog(deckID, userID string, limit int) ([]model.AuditEvent, error) {
+	deck, err := s.decks.authorizedDeck(deckID, userID, model.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	limit = min(limit, maxAuditLimit)
+
+	var events []model.AuditEvent
+	path := fmt.Sprintf("audit_log?deck_id=eq.%s&order=created_at.desc&limit=%d", url.QueryEscape(deck.ID), limit)
+	if err := supabaseREST("GET", path, nil, &events); err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+	return events, nil
+}
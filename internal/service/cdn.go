@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/storage"
+)
+
+var purgeClient = &http.Client{Timeout: 10 * time.Second}
+
+// purgeCDN asks the CDN in front of the storage to drop its copies of objects that
+// were replaced or removed, by POSTing {"urls": [...]} to CDN_PURGE_URL with the
+// CDN_PURGE_TOKEN bearer token. It runs in the background, a failure is logged and
+// the copies expire on their own.
+func purgeCDN(urls ...string) {
+	hook := os.Getenv("CDN_PURGE_URL")
+	var purged []string
+	for _, u := range urls {
+		if u != "" {
+			purged = append(purged, u)
+		}
+	}
+	if hook == "" || len(purged) == 0 {
+		return
+	}
+
+	go func() {
+		body, _ := json.Marshal(map[string][]string{"urls": purged})
+		req, err := http.NewRequest("POST", hook, bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Failed to purge CDN: %v", err)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token := os.Getenv("CDN_PURGE_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := purgeClient.Do(req)
+		if err != nil {
+			log.Printf("Failed to purge %d URLs from the CDN: %v", len(purged), err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Failed to purge %d URLs from the CDN, status: %d", len(purged), resp.StatusCode)
+		}
+	}()
+}
+
+// replacedURLs returns the URLs of before that after no longer uses
+func replacedURLs(before, after []string) []string {
+	var replaced []string
+	for i, u := range before {
+		if u != "" && i < len(after) && after[i] != u {
+			replaced = append(replaced, u)
+		}
+	}
+	return replaced
+}
+
+// storedPath returns the path in bucket of an object given its public URL, which is
+// <base>/<bucket>/<path> whether the storage or a CDN serves it
+func storedPath(fileURL, bucket string) (string, error) {
+	marker := "/" + bucket + "/"
+	i := strings.Index(fileURL, marker)
+	if i == -1 {
+		return "", fmt.Errorf("%s is not stored in bucket %s", fileURL, bucket)
+	}
+	// Paths are relative to the folder of the environment
+	return strings.TrimPrefix(fileURL[i+len(marker):], storage.Prefix()), nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Syntax highlighting styles available for code blocks, with the highlight.js
+// stylesheet each is rendered with
+var codeThemes = map[string]string{
+	"github":          "github",
+	"github-dark":     "github-dark",
+	"monokai":         "monokai",
+	"dracula":         "base16/dracula",
+	"nord":            "nord",
+	"solarized-light": "base16/solarized-light",
+}
+
+// codeThemeDirective is the global directive the Marp engine of the renderer reads
+// the highlight.js stylesheet of a deck from, see marp/engine.cjs
+const codeThemeDirective = "codeTheme"
+
+// codeThemeMarker is set by the Marp engine along the stylesheet, so the rendered HTML
+// can be checked for it
+const codeThemeMarker = "--pitchtree-code-theme"
+
+var fencedCodeRegex = regexp.MustCompile("(?m)^```")
+
+// CodeThemes returns the names of the supported syntax highlighting styles
+func CodeThemes() []string {
+	names := make([]string, 0, len(codeThemes))
+	for name := range codeThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// injectCodeTheme sets the codeTheme directive of a deck to the highlight.js
+// stylesheet of the style, which the Marp engine applies to its code blocks
+func injectCodeTheme(markdown, name string) (string, error) {
+	if name == "" {
+		return markdown, nil
+	}
+
+	stylesheet, ok := codeThemes[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown code theme: %s", name)
+	}
+
+	return setDirective(markdown, codeThemeDirective, stylesheet), nil
+}
+
+// setDirective sets a global directive in the front-matter of a Marp document,
+// replacing its current value, and adds a front-matter to a document without one
+func setDirective(markdown, key, value string) string {
+	line := key + ": " + value
+	lines := strings.Split(markdown, "\n")
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				return strings.Join(slices.Insert(lines, i, line), "\n")
+			}
+			if name, _, found := strings.Cut(lines[i], ":"); found && strings.TrimSpace(name) == key {
+				lines[i] = line
+				return strings.Join(lines, "\n")
+			}
+		}
+	}
+
+	return "---\n" + line + "\n---\n\n" + markdown
+}
+
+// insertAfterFrontMatter places a block of content after the front-matter of a Marp
+// document, or at the very top when the document has none. A block the document
+// already has, as when a stored deck is rendered again, is not added twice.
+func insertAfterFrontMatter(markdown, block string) string {
+	if strings.Contains(markdown, block) {
+		return markdown
+	}
+	if strings.HasPrefix(markdown, "---\n") || strings.HasPrefix(markdown, "---\r\n") {
+		rest := markdown[strings.Index(markdown, "\n")+1:]
+		if end := strings.Index(rest, "\n---"); end != -1 {
+			closing := end + len("\n---")
+			if nl := strings.Index(rest[closing:], "\n"); nl != -1 {
+				closing += nl + 1
+			} else {
+				closing = len(rest)
+			}
+			head := markdown[:len(markdown)-len(rest)+closing]
+			return strings.TrimRight(head, "\r\n") + "\n\n" + block + "\n\n" + rest[closing:]
+		}
+	}
+
+	return block + "\n\n" + markdown
+}
+
+// verifyCodeHighlighting checks that code blocks in the markdown were rendered with
+// syntax highlighting in the HTML export and that the PDF export was produced
+func verifyCodeHighlighting(markdown, htmlPath, pdfPath, codeTheme string) error {
+	if !fencedCodeRegex.MatchString(markdown) {
+		return nil
+	}
+
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HTML export: %w", err)
+	}
+	if !strings.Contains(string(html), "hljs") {
+		return fmt.Errorf("code blocks were not highlighted in HTML export")
+	}
+	if codeTheme != "" && !strings.Contains(string(html), codeThemeMarker) {
+		return fmt.Errorf("code theme %s missing from HTML export", codeTheme)
+	}
+
+	info, err := os.Stat(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat PDF export: %w", err)
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("PDF export is empty")
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+const (
+	maxCompetitors           = 6
+	maxCompetitorPositioning = 600
+)
+
+// checkCompetitors validates the competitors of a deck before it is generated
+func checkCompetitors(competitors []model.Competitor) error {
+	if len(competitors) > maxCompetitors {
+		return fmt.Errorf("%w: at most %d competitors can be compared", model.ErrInvalidInput, maxCompetitors)
+	}
+	for _, competitor := range competitors {
+		if strings.TrimSpace(competitor.Name) == "" && strings.TrimSpace(competitor.URL) == "" {
+			return fmt.Errorf("%w: each competitor needs a name or a URL", model.ErrInvalidInput)
+		}
+		if competitor.URL != "" {
+			if _, err := competitorURL(competitor.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// competitorURL parses the website of a competitor, https is assumed without scheme
+func competitorURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: invalid competitor URL %q", model.ErrInvalidInput, raw)
+	}
+	return u, nil
+}
+
+// researchCompetitors reads the public positioning of the competitors from their
+// websites. Competitors without a website, or whose website cannot be read, are
+// compared from their name and the answers of the form only.
+func researchCompetitors(competitors []model.Competitor) []prompts.CompetitorProfile {
+	profiles := competitorProfiles(competitors)
+	var wg sync.WaitGroup
+	for i := range profiles {
+		if profiles[i].URL == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(profile *prompts.CompetitorProfile) {
+			defer wg.Done()
+			title, positioning, err := fetchPositioning(profile.URL)
+			if err != nil {
+				log.Printf("Failed to research competitor %s: %v", profile.URL, err)
+				return
+			}
+			if profile.Name == "" {
+				profile.Name = title
+			}
+			profile.Positioning = positioning
+		}(&profiles[i])
+	}
+	wg.Wait()
+
+	for i := range profiles {
+		if profiles[i].Name == "" {
+			profiles[i].Name = profiles[i].URL
+		}
+	}
+	return profiles
+}
+
+// competitorProfiles returns the profiles of the competitors before their research
+func competitorProfiles(competitors []model.Competitor) []prompts.CompetitorProfile {
+	profiles := make([]prompts.CompetitorProfile, len(competitors))
+	for i, competitor := range competitors {
+		profiles[i] = prompts.CompetitorProfile{
+			Name: strings.TrimSpace(competitor.Name),
+			URL:  strings.TrimSpace(competitor.URL),
+		}
+	}
+	return profiles
+}
+
+// fetchPositioning returns the title of a website and how it presents itself: its
+// description and first headings
+func fetchPositioning(rawURL string) (string, string, error) {
+	u, err := competitorURL(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	page, err := fetchPage(u)
+	if err != nil {
+		return "", "", err
+	}
+
+	var parts []string
+	seen := make(map[string]bool)
+	candidates := append([]string{page.Title, page.Meta["description"], page.Meta["og:title"], page.Meta["og:description"]}, page.Headings...)
+	for _, part := range candidates {
+		if part != "" && !seen[part] {
+			seen[part] = true
+			parts = append(parts, part)
+		}
+	}
+	return page.Title, truncateRunes(strings.Join(parts, " | "), maxCompetitorPositioning), nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+)
+
+const (
+	// Slides a user can add to a deck
+	maxCustomSlides = 10
+	// Length of the markdown of a custom slide
+	maxCustomSlide = 5000
+)
+
+// checkCustomSlides validates the slides added by the user before the deck is generated
+func checkCustomSlides(custom []model.CustomSlide) error {
+	if len(custom) > maxCustomSlides {
+		return fmt.Errorf("%w: at most %d custom slides can be added", model.ErrInvalidInput, maxCustomSlides)
+	}
+	for i, slide := range custom {
+		if slide.Position < 0 {
+			return fmt.Errorf("%w: custom slide %d has a negative position", model.ErrInvalidInput, i+1)
+		}
+		if strings.TrimSpace(slide.Markdown) != "" && (slide.Title != "" || slide.Content != "") {
+			return fmt.Errorf("%w: custom slide %d has both markdown and a title or content", model.ErrInvalidInput, i+1)
+		}
+
+		markdown := customSlideMarkdown(slide)
+		if markdown == "" {
+			return fmt.Errorf("%w: custom slide %d needs a title or markdown", model.ErrInvalidInput, i+1)
+		}
+		if utf8.RuneCountInString(markdown) > maxCustomSlide {
+			return fmt.Errorf("%w: custom slide %d exceeds %d characters", model.ErrInvalidInput, i+1, maxCustomSlide)
+		}
+		// A separator would turn the slide into several, front-matter would set the
+		// directives of the whole deck
+		if frontMatter, parts := slides.Split(markdown); frontMatter != "" || len(parts) != 1 {
+			return fmt.Errorf("%w: custom slide %d must be a single slide, without --- separators", model.ErrInvalidInput, i+1)
+		}
+	}
+	return nil
+}
+
+// customSlideMarkdown returns the markdown of a custom slide, its title as a heading
+// followed by its content unless it is raw markdown
+func customSlideMarkdown(slide model.CustomSlide) string {
+	if markdown := strings.TrimSpace(slide.Markdown); markdown != "" {
+		return markdown
+	}
+	title := strings.TrimSpace(slide.Title)
+	if title == "" {
+		return ""
+	}
+	markdown := "## " + title
+	if content := strings.TrimSpace(slide.Content); content != "" {
+		markdown += "\n\n" + content
+	}
+	return markdown
+}
+
+// insertCustomSlides adds the custom slides to a generated deck. They are placed in
+// order of position, so each position is the number of the slide in the final deck.
+func insertCustomSlides(markdown string, custom []model.CustomSlide) string {
+	if len(custom) == 0 {
+		return markdown
+	}
+
+	ordered := slices.Clone(custom)
+	slices.SortStableFunc(ordered, func(a, b model.CustomSlide) int {
+		// Appended slides go last, in the order they were given
+		if a.Position == 0 || b.Position == 0 {
+			return min(b.Position, 1) - min(a.Position, 1)
+		}
+		return a.Position - b.Position
+	})
+
+	frontMatter, deckSlides := slides.Split(markdown)
+	for _, slide := range ordered {
+		content := customSlideMarkdown(slide)
+		if slide.Position == 0 || slide.Position > len(deckSlides) {
+			deckSlides = append(deckSlides, content)
+		} else {
+			deckSlides = slices.Insert(deckSlides, slide.Position-1, content)
+		}
+	}
+	return slides.Join(frontMatter, deckSlides)
+}
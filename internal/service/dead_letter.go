@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Stages of a generation, recorded with its failures
+const (
+	stageExtract  = "extract"
+	stageGenerate = "generate"
+	stageRender   = "render"
+	stageUpload   = "upload"
+	stageResume   = "resume"
+	// Where a generation that panicked stopped is not known
+	stageUnknown = "unknown"
+)
+
+// deadLetter keeps a failed generation in the deck_failures table, with the output of
+// marp-cli for rendering failures
+func deadLetter(deckID, stage, code, message string, err error) {
+	failure := model.DeckFailure{
+		ID:        uuid.New().String(),
+		DeckID:    deckID,
+		Stage:     stage,
+		Code:      code,
+		Message:   message,
+		Error:     err.Error(),
+		CreatedAt: time.Now(),
+	}
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		failure.Stderr = renderErr.Stderr
+	}
+
+	if err := supabaseWrite("POST", "deck_failures", failure); err != nil {
+		log.Printf("Failed to record failure of deck %s in the dead-letter queue: %v", deckID, err)
+	}
+}
+
+// markRetried marks the failures of a deck as retried, so they leave the queue
+func markRetried(deckID string) {
+	update := map[string]interface{}{"retried_at": time.Now()}
+	path := "deck_failures?deck_id=eq." + url.QueryEscape(deckID) + "&retried_at=is.null"
+	if err := supabaseWrite("PATCH", path, update); err != nil {
+		log.Printf("Failed to mark failures of deck %s as retried: %v", deckID, err)
+	}
+}
+
+// Retry runs a failed generation again, without the user entering the form again
+func (s *PitchDeckService) Retry(deckID, userID string) error {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if deck.Status != "failed" {
+		return fmt.Errorf("%w: only failed decks can be retried, the deck is %s", model.ErrConflict, deck.Status)
+	}
+
+	if err := s.regenerate(deck, false); err != nil {
+		return err
+	}
+
+	markRetried(deck.ID)
+	recordAudit(deck.ID, userID, AuditDeckRegenerated, map[string]interface{}{
+		"reason": "retry",
+	})
+	return nil
+}
+
+// regenerate runs the generation of a deck again. Its stored markdown is rendered
+// again when there is one, keeping its content, otherwise the deck is generated
+// again from its answers. A deck whose job was claimed from a lost instance is still
+// processing, the claim takes over its lock.
+func (s *PitchDeckService) regenerate(deck *model.PitchDeckInfo, claimed bool) error {
+	if deck.MarkdownURL != "" && !claimed {
+		return s.rerenderDeck(deck, nil)
+	}
+	if deck.MarkdownURL != "" {
+		if err := s.acceptingJobs(); err != nil {
+			return err
+		}
+		markdown, err := s.loadMarkdown(deck)
+		if err != nil {
+			return err
+		}
+		return s.startRerender(deck, markdown)
+	}
+
+	data, err := s.loadInput(deck.ID)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return fmt.Errorf("%w: the deck has no content or answers to generate it from, import it again", model.ErrConflict)
+	}
+	if err := s.acceptingJobs(); err != nil {
+		return err
+	}
+
+	if !claimed {
+		if err := s.lockDeck(deck.ID); err != nil {
+			return err
+		}
+	}
+	progressChan := s.progress.CreateChannel(deck.ID, deck.UserID)
+	s.startJob(deck, func(ctx context.Context) { s.processDeck(ctx, *data, deck, progressChan) })
+	return nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Kinds of deck assets. Images are uploads of their user, tracked in user_files.
+const (
+	assetPDF      = "pdf"
+	assetHTML     = "html"
+	assetMarkdown = "markdown"
+)
+
+// renderAsset is a file of a render, stored at URL from the local path
+type renderAsset struct {
+	Kind      string
+	URL       string
+	LocalPath string
+}
+
+// recordRenderAssets adds the stored files of a render to the assets of its deck. A
+// lost record leaves the files to the storage GC, which also finds them by name.
+func recordRenderAssets(deck *model.PitchDeckInfo, bucket string, files []renderAsset) {
+	var assets []model.DeckAsset
+	for _, file := range files {
+		if file.URL == "" {
+			continue
+		}
+		objectPath, err := storedPath(file.URL, bucket)
+		if err != nil {
+			log.Printf("Failed to record asset of deck %s: %v", deck.ID, err)
+			continue
+		}
+		asset := model.DeckAsset{
+			DeckID: deck.ID,
+			UserID: deck.UserID,
+			Kind:   file.Kind,
+			Bucket: bucket,
+			Path:   objectPath,
+		}
+		if info, err := os.Stat(file.LocalPath); err == nil {
+			asset.Size = info.Size()
+		}
+		assets = append(assets, asset)
+	}
+	if len(assets) == 0 {
+		return
+	}
+	if err := supabaseWrite("POST", "rpc/record_deck_assets", map[string]interface{}{"p_assets": assets}); err != nil {
+		log.Printf("Failed to record assets of deck %s: %v", deck.ID, err)
+	}
+}
+
+// deckAssets lists the assets of decks
+func deckAssets(deckIDs []string) ([]model.DeckAsset, error) {
+	var assets []model.DeckAsset
+	err := forEachBatch(deckIDs, func(batch []string) error {
+		var page []model.DeckAsset
+		path := "deck_assets?select=deck_id,user_id,kind,bucket,path,size,created_at&deck_id=in.(" + strings.Join(batch, ",") + ")"
+		if err := supabaseREST("GET", path, nil, &page); err != nil {
+			return err
+		}
+		assets = append(assets, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deck assets: %w", err)
+	}
+	return assets, nil
+}
+
+// assetsByPath looks up the assets stored at paths of a bucket
+func assetsByPath(bucket string, paths []string) (map[string]model.DeckAsset, error) {
+	assets := make(map[string]model.DeckAsset)
+	err := forEachBatch(paths, func(batch []string) error {
+		var page []model.DeckAsset
+		path := fmt.Sprintf("deck_assets?select=deck_id,user_id,kind,bucket,path,size&bucket=eq.%s&path=in.(%s)",
+			url.QueryEscape(bucket), quotedList(batch))
+		if err := supabaseREST("GET", path, nil, &page); err != nil {
+			return err
+		}
+		for _, asset := range page {
+			assets[asset.Path] = asset
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load deck assets: %w", err)
+	}
+	return assets, nil
+}
+
+// deleteAssets deletes the stored files of assets, then their records. Records are
+// kept for the files that could not be deleted.
+func (s *PitchDeckService) deleteAssets(assets []model.DeckAsset) error {
+	byBucket := make(map[string][]string)
+	for _, asset := range assets {
+		byBucket[asset.Bucket] = append(byBucket[asset.Bucket], asset.Path)
+	}
+	for bucket, paths := range byBucket {
+		if s.storage != nil {
+			err := forEachBatch(paths, func(batch []string) error {
+				return s.storage.DeleteFiles(bucket, batch)
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete stored files: %w", err)
+			}
+		}
+		if err := forgetAssets(bucket, paths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetAssets removes the records of assets whose files were deleted
+func forgetAssets(bucket string, paths []string) error {
+	err := forEachBatch(paths, func(batch []string) error {
+		path := fmt.Sprintf("deck_assets?bucket=eq.%s&path=in.(%s)", url.QueryEscape(bucket), quotedList(batch))
+		return supabaseREST("DELETE", path, nil, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete deck assets: %w", err)
+	}
+	return nil
+}
+
+// quotedList formats values for an in.() filter, quoted as they may contain commas
+func quotedList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = url.QueryEscape(`"` + strings.ReplaceAll(value, `"`, `\"`) + `"`)
+	}
+	return strings.Join(quoted, ",")
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+
+	"github.com/google/uuid"
+)
+
+// contentVersion is the version of the open content format written by exports.
+// Imports accept any 1.x document, minor versions only add optional fields.
+const contentVersion = "1.0"
+
+//go:embed schema/pitch-deck-content.v1.json
+var contentSchema []byte
+
+// ContentSchema returns the JSON schema of the open content format
+func (s *PitchDeckService) ContentSchema() []byte {
+	return contentSchema
+}
+
+func contentFromData(data model.PitchDeckData) model.DeckContent {
+	return model.DeckContent{
+		Version: contentVersion,
+		Meta: model.ContentMeta{
+			Title:     data.ProjectName,
+			Language:  data.Language,
+			Theme:     data.Theme,
+			CodeTheme: data.CodeTheme,
+			Audience:  data.AudiencePersona,
+		},
+		Company: model.ContentCompany{
+			Name:     data.ProjectName,
+			Tagline:  data.BigIdea,
+			Industry: data.Industry,
+			Logo:     data.CompanyLogo,
+		},
+		Problem: model.ContentProblem{
+			Statement:         data.Problem,
+			Audience:          data.TargetAudience,
+			ExistingSolutions: data.ExistingSolutions,
+		},
+		Solution: model.ContentSolution{
+			Description:     data.Solution,
+			Technology:      data.Technology,
+			Differentiators: data.Differentiators,
+			Roadmap:         data.DevelopmentPlan,
+			Diagram:         data.Diagram,
+		},
+		Market: model.ContentMarket{
+			Size:   data.MarketSize,
+			TAM:    data.TAM,
+			SAM:    data.SAM,
+			SOM:    data.SOM,
+			Niche:  data.TargetNiche,
+			Trends: data.MarketTrends,
+		},
+		Funding: model.ContentFunding{
+			Amount:     data.FundingAmount,
+			Use:        data.FundingUse,
+			Valuation:  data.Valuation,
+			Instrument: data.InvestmentStructure,
+		},
+		Team: model.ContentTeam{
+			Members:        data.TeamMembers,
+			WhyUs:          data.WhyYou,
+			Qualifications: data.TeamQualification,
+			Photo:          data.TeamPhoto,
+		},
+		Contact:   data.ContactInfo,
+		Takeaways: data.KeyTakeaways,
+	}
+}
+
+func dataFromContent(content model.DeckContent) model.PitchDeckData {
+	return model.PitchDeckData{
+		ProjectName:         strings.TrimSpace(content.Company.Name),
+		BigIdea:             content.Company.Tagline,
+		Problem:             content.Problem.Statement,
+		TargetAudience:      content.Problem.Audience,
+		ExistingSolutions:   content.Problem.ExistingSolutions,
+		Solution:            content.Solution.Description,
+		Technology:          content.Solution.Technology,
+		Differentiators:     content.Solution.Differentiators,
+		DevelopmentPlan:     content.Solution.Roadmap,
+		MarketSize:          content.Market.Size,
+		FundingAmount:       content.Funding.Amount,
+		FundingUse:          content.Funding.Use,
+		Valuation:           content.Funding.Valuation,
+		InvestmentStructure: content.Funding.Instrument,
+		TAM:                 content.Market.TAM,
+		SAM:                 content.Market.SAM,
+		SOM:                 content.Market.SOM,
+		TargetNiche:         content.Market.Niche,
+		MarketTrends:        content.Market.Trends,
+		Industry:            content.Company.Industry,
+		WhyYou:              content.Team.WhyUs,
+		TeamMembers:         content.Team.Members,
+		TeamQualification:   content.Team.Qualifications,
+		ContactInfo:         content.Contact,
+		KeyTakeaways:        content.Takeaways,
+		CompanyLogo:         content.Company.Logo,
+		TeamPhoto:           content.Team.Photo,
+		Diagram:             content.Solution.Diagram,
+		Theme:               content.Meta.Theme,
+		CodeTheme:           content.Meta.CodeTheme,
+		Language:            content.Meta.Language,
+		AudiencePersona:     content.Meta.Audience,
+	}
+}
+
+// ExportContent returns the content of a deck in the open format: the answers it was
+// generated from when they were kept, and its slides once rendered
+func (s *PitchDeckService) ExportContent(deckID, userID string) (*model.DeckContent, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	content := model.DeckContent{Version: contentVersion}
+	data, err := s.loadInput(deck.ID)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		content = contentFromData(*data)
+	}
+
+	content.Meta.Title = deck.Name
+	content.Meta.Generator = "PitchTree"
+	content.Meta.CreatedAt = &deck.CreatedAt
+	if content.Company.Name == "" {
+		content.Company.Name = deck.Name
+	}
+
+	if deck.MarkdownURL != "" {
+		markdown, err := s.loadMarkdown(deck)
+		if err != nil {
+			return nil, err
+		}
+		frontMatter, deckSlides := slides.Split(markdown)
+		if content.Meta.Theme == "" {
+			content.Meta.Theme = slides.FrontMatterValue(frontMatter, "theme")
+		}
+		for _, slide := range deckSlides {
+			content.Slides = append(content.Slides, model.ContentSlide{Title: slides.Title(slide), Markdown: slide})
+		}
+	}
+
+	return &content, nil
+}
+
+// ImportContent creates a deck from a document in the open format. Its slides are
+// rendered as they are when present, otherwise the deck is generated from its content.
+func (s *PitchDeckService) ImportContent(ctx context.Context, content model.DeckContent, userID string) (*model.PitchDeckInfo, error) {
+	if content.Version != "1" && !strings.HasPrefix(content.Version, "1.") {
+		return nil, fmt.Errorf("%w: unsupported content version %q, expected 1.x", model.ErrInvalidInput, content.Version)
+	}
+	if err := s.acceptingJobs(); err != nil {
+		return nil, err
+	}
+
+	data := dataFromContent(content)
+	if data.ProjectName == "" {
+		return nil, fmt.Errorf("%w: company.name is required", model.ErrInvalidInput)
+	}
+
+	if len(content.Slides) == 0 {
+		return s.Create(ctx, data, userID)
+	}
+
+	var deckSlides []string
+	for i, slide := range content.Slides {
+		if strings.TrimSpace(slide.Markdown) == "" {
+			return nil, fmt.Errorf("%w: slide %d has no markdown", model.ErrInvalidInput, i+1)
+		}
+		deckSlides = append(deckSlides, slide.Markdown)
+	}
+
+	theme := data.Theme
+	if theme == "" {
+		theme = "default"
+	}
+	if err := checkNewDeck(userID, theme); err != nil {
+		return nil, err
+	}
+	markdown := slides.Join(fmt.Sprintf("marp: true\ntheme: %s\npaginate: true", theme), deckSlides)
+
+	deckID := uuid.New().String()
+	s.progress.CreateChannel(deckID, userID)
+
+	deckInfo := &model.PitchDeckInfo{
+		ID:        deckID,
+		UserID:    userID,
+		Name:      data.ProjectName,
+		Status:    "processing",
+		CreatedAt: time.Now(),
+	}
+	assignSlug(deckInfo)
+
+	if err := s.createRecord(ctx, deckInfo, &data); err != nil {
+		log.Printf("Error creating pitch deck record: %v", err)
+	}
+	recordAudit(deckID, userID, AuditDeckCreated, map[string]interface{}{
+		"name":   deckInfo.Name,
+		"source": "content",
+	})
+
+	deckDir := filepath.Join("temp", deckID)
+	os.MkdirAll(deckDir, os.ModePerm)
+
+	opts := renderOptionsFor(data)
+	opts.Theme = theme
+	s.startJob(deckInfo, func(ctx context.Context) { s.renderDeck(ctx, deckInfo, markdown, opts, deckDir) })
+
+	return deckInfo, nil
+}
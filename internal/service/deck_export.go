@@ -0,0 +1,149 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+var inventoryHeader = []string{"Name", "Status", "Created", "Views", "Last viewed", "Public URL"}
+
+// publicDeckURL returns the link to share a public deck, on the frontend when
+// APP_URL is set and on the rendered HTML otherwise
+func publicDeckURL(deck model.PitchDeckInfo) string {
+	if now := time.Now(); !isPublished(&deck, now) || expired(deck.ExpiresAt, now) {
+		return ""
+	}
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		id := deck.ID
+		if deck.Slug != "" {
+			id = deck.Slug
+		}
+		return strings.TrimSuffix(appURL, "/") + "/view/" + id
+	}
+	return deck.HtmlURL
+}
+
+// spreadsheetSafe keeps user provided text from being evaluated as a formula
+func spreadsheetSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func inventoryRows(decks []model.PitchDeckInfo) [][]string {
+	rows := [][]string{inventoryHeader}
+	for _, deck := range decks {
+		lastViewed := ""
+		if deck.LastViewedAt != nil {
+			lastViewed = deck.LastViewedAt.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{
+			spreadsheetSafe(deck.Name),
+			deck.Status,
+			deck.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(deck.ViewCount),
+			lastViewed,
+			publicDeckURL(deck),
+		})
+	}
+	return rows
+}
+
+// ExportDecks returns the deck inventory of the user as "csv" or "xlsx"
+func (s *PitchDeckService) ExportDecks(userID, format string) ([]byte, error) {
+	decks, err := s.ListUserDecks(context.Background(), userID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list decks: %w", err)
+	}
+	rows := inventoryRows(decks)
+
+	switch format {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		if err := w.WriteAll(rows); err != nil {
+			return nil, fmt.Errorf("failed to write csv: %w", err)
+		}
+		return buf.Bytes(), nil
+	case "xlsx":
+		return writeXLSX("Decks", rows)
+	default:
+		return nil, fmt.Errorf("%w: unknown export format %q, expected csv or xlsx", model.ErrInvalidInput, format)
+	}
+}
+
+// writeXLSX builds a minimal single sheet workbook with inline string cells
+func writeXLSX(sheetName string, rows [][]string) ([]byte, error) {
+	var sheet strings.Builder
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, i+1)
+		for j, value := range row {
+			sheet.WriteString(`<c r="` + xlsxColumn(j) + strconv.Itoa(i+1) + `" t="inlineStr"><is><t>`)
+			if err := xml.EscapeText(&sheet, []byte(value)); err != nil {
+				return nil, fmt.Errorf("failed to escape cell: %w", err)
+			}
+			sheet.WriteString(`</t></is></c>`)
+		}
+		sheet.WriteString(`</row>`)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	files := []struct {
+		Name    string
+		Content string
+	}{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + sheetName + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", file.Name, err)
+		}
+		if _, err := w.Write([]byte(file.Content)); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close workbook: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// xlsxColumn returns the column letters of a zero based index (0 is A, 26 is AA)
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/storage"
+)
+
+// IsDeckFileKind reports whether a kind is a stored file of decks, "pdf", "html" or "md"
+func IsDeckFileKind(kind string) bool {
+	return kind == "pdf" || kind == "html" || kind == "md"
+}
+
+// DeckFile returns a stored file of a deck, "pdf", "html" or "md", to a user it is
+// shared with. The files of private decks are stored in a private bucket and served
+// through it, the markdown source decrypted.
+func (s *PitchDeckService) DeckFile(deckID, userID, kind string) (*model.FileDownload, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileURL string
+	switch kind {
+	case "pdf":
+		fileURL = deck.PdfURL
+	case "html":
+		fileURL = deck.HtmlURL
+	case "md":
+		fileURL = deck.MarkdownURL
+	default:
+		return nil, fmt.Errorf("%w: unknown file %q, expected pdf, html or md", model.ErrNotFound, kind)
+	}
+	if fileURL == "" {
+		return nil, fmt.Errorf("%w: the %s file of this deck is not available", model.ErrNotFound, kind)
+	}
+
+	var content []byte
+	if kind == "md" {
+		markdown, err := s.loadMarkdown(deck)
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(markdown)
+	} else if content, err = fetchBytes(fileURL); err != nil {
+		return nil, err
+	}
+
+	download := &model.FileDownload{
+		Filename: slugify(deck.Name) + "." + kind,
+		Content:  content,
+		ModTime:  deck.CreatedAt,
+	}
+	if deck.UpdatedAt != nil {
+		download.ModTime = *deck.UpdatedAt
+	}
+	return download, nil
+}
+
+// fileProxyBaseURL returns the public URL of the API, API_URL, under which the files
+// of private decks are served in the messages sent outside of a request. Without it
+// their URLs are relative to the API.
+func fileProxyBaseURL() string {
+	return os.Getenv("API_URL")
+}
+
+// deckFilesBucket returns the bucket the rendered files of a deck are stored in: the
+// bucket of its tenant when it is public, its private bucket otherwise
+func deckFilesBucket(bucket string, public bool) string {
+	if public {
+		return bucket
+	}
+	return storage.PrivateBucket(bucket)
+}
+
+// deckFileColumns are the columns of the stored files of a deck
+var deckFileColumns = []struct {
+	column string
+	kind   string
+	url    func(deck *model.PitchDeckInfo) *string
+}{
+	{"pdf_url", assetPDF, func(deck *model.PitchDeckInfo) *string { return &deck.PdfURL }},
+	{"html_url", assetHTML, func(deck *model.PitchDeckInfo) *string { return &deck.HtmlURL }},
+	{"markdown_url", assetMarkdown, func(deck *model.PitchDeckInfo) *string { return &deck.MarkdownURL }},
+}
+
+// moveDeckFiles moves the rendered files of a deck to the bucket of a visibility,
+// copied under the same names, then deletes them from the other bucket. Files already
+// in place are left as they are. The URLs of the deck are updated.
+func (s *PitchDeckService) moveDeckFiles(deck *model.PitchDeckInfo, public bool) error {
+	if s.storage == nil {
+		return nil
+	}
+	bucket, folder, err := tenantStorage(deck.TenantID)
+	if err != nil {
+		return err
+	}
+	from, to := deckFilesBucket(bucket, !public), deckFilesBucket(bucket, public)
+
+	dir, err := os.MkdirTemp("", "deck-files")
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	update := make(map[string]string)
+	var moved, replaced []string
+	var assets []renderAsset
+	for _, file := range deckFileColumns {
+		fileURL := file.url(deck)
+		objectPath, err := storedPath(*fileURL, from)
+		if *fileURL == "" || err != nil {
+			continue
+		}
+		content, err := fetchBytes(*fileURL)
+		if err != nil {
+			return err
+		}
+		localPath := filepath.Join(dir, path.Base(objectPath))
+		if err := os.WriteFile(localPath, content, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", localPath, err)
+		}
+		// Named after their content, the copies keep the names of the files
+		copyURL, err := s.storage.UploadImmutable(localPath, to, folder+deck.ID+path.Ext(objectPath))
+		if err != nil {
+			return err
+		}
+
+		update[file.column] = copyURL
+		moved = append(moved, objectPath)
+		replaced = append(replaced, *fileURL)
+		assets = append(assets, renderAsset{Kind: file.kind, URL: copyURL, LocalPath: localPath})
+	}
+	if len(update) == 0 {
+		return nil
+	}
+
+	if err := supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil); err != nil {
+		return fmt.Errorf("failed to update files of deck: %w", err)
+	}
+	for _, file := range deckFileColumns {
+		if copyURL, ok := update[file.column]; ok {
+			*file.url(deck) = copyURL
+		}
+	}
+	recordRenderAssets(deck, to, assets)
+
+	// The copies are in use, the originals are left to the storage GC if they cannot
+	// be deleted now
+	if err := s.storage.DeleteFiles(from, moved); err != nil {
+		log.Printf("Failed to delete the moved files of deck %s from %s: %v", deck.ID, from, err)
+	} else if err := forgetAssets(from, moved); err != nil {
+		log.Printf("Failed to forget the moved files of deck %s: %v", deck.ID, err)
+	}
+	purgeCDN(replaced...)
+	return nil
+}
+
+// Decks whose files are moved to the private bucket per batch
+const privateFilesBatchSize = 50
+
+// MovePrivateFiles moves the files of the private decks rendered before the private
+// buckets existed out of the public bucket, in the background
+func (s *PitchDeckService) MovePrivateFiles() {
+	go func() {
+		moved := 0
+		for {
+			var decks []model.PitchDeckInfo
+			path := fmt.Sprintf("pitch_decks?select=id,user_id,tenant_id,pdf_url,html_url,markdown_url"+
+				"&is_public=eq.false&status=eq.completed&pdf_url=neq.&pdf_url=not.like.%s&order=created_at&limit=%d",
+				url.QueryEscape("*/object/authenticated/*"), privateFilesBatchSize)
+			if err := supabaseREST("GET", path, nil, &decks); err != nil {
+				log.Printf("Failed to list the private decks with public files: %v", err)
+				return
+			}
+
+			failed := 0
+			for i := range decks {
+				if err := s.moveDeckFiles(&decks[i], false); err != nil {
+					log.Printf("Failed to move the files of private deck %s: %v", decks[i].ID, err)
+					failed++
+				} else if !storage.IsPrivateURL(decks[i].PdfURL) {
+					// Stored outside of the bucket of its tenant, left where it is
+					failed++
+				}
+			}
+			moved += len(decks) - failed
+			// Decks left in place would be listed again
+			if len(decks) < privateFilesBatchSize || failed > 0 {
+				break
+			}
+		}
+		if moved > 0 {
+			deckCache.invalidate()
+			log.Printf("Moved the files of %d private decks to the private buckets", moved)
+		}
+	}()
+}
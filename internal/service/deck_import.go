@@ -0,0 +1,300 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/progress"
+	"pitch-deck-generator/prompts"
+
+	"github.com/google/uuid"
+)
+
+// Deck formats accepted by the import endpoint
+var importFormats = map[string]bool{
+	".pdf":  true,
+	".pptx": true,
+}
+
+var pptxSlideRegex = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// Import starts the generation of a polished deck from an uploaded PDF or PPTX file.
+// The uploaded file is removed once its text has been extracted.
+func (s *PitchDeckService) Import(ctx context.Context, filePath, originalName, theme, userID string) (*model.PitchDeckInfo, error) {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if !importFormats[ext] {
+		os.Remove(filePath)
+		return nil, fmt.Errorf("%w: unsupported deck format %q, expected .pdf or .pptx", model.ErrInvalidInput, ext)
+	}
+	if err := s.acceptingJobs(); err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+	if err := checkNewDeck(userID, theme); err != nil {
+		os.Remove(filePath)
+		return nil, err
+	}
+
+	deckID := uuid.New().String()
+	s.progress.CreateChannel(deckID, userID)
+
+	deckInfo := &model.PitchDeckInfo{
+		ID:        deckID,
+		UserID:    userID,
+		Name:      strings.TrimSuffix(filepath.Base(originalName), filepath.Ext(originalName)),
+		Status:    "processing",
+		CreatedAt: time.Now(),
+	}
+	assignSlug(deckInfo)
+
+	if err := s.createRecord(ctx, deckInfo, nil); err != nil {
+		log.Printf("Error creating pitch deck record: %v", err)
+	}
+	recordAudit(deckID, userID, AuditDeckCreated, map[string]interface{}{
+		"name":   deckInfo.Name,
+		"source": "import",
+		"file":   originalName,
+	})
+
+	s.startJob(deckInfo, func(ctx context.Context) { s.processImport(ctx, filePath, theme, deckInfo) })
+
+	return deckInfo, nil
+}
+
+func (s *PitchDeckService) processImport(ctx context.Context, filePath, theme string, deckInfo *model.PitchDeckInfo) {
+	deckDir := filepath.Join("temp", deckInfo.ID)
+	os.MkdirAll(deckDir, os.ModePerm)
+
+	s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
+		Status:      "processing",
+		CurrentStep: 1,
+		Message:     "Extracting slides...",
+	})
+
+	slides, err := extractSlides(filePath)
+	os.Remove(filePath)
+	if err != nil {
+		s.handleError(deckInfo.ID, stageExtract, "Failed to extract slides", err)
+		return
+	}
+
+	s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
+		Status:      "processing",
+		CurrentStep: 2,
+		Message:     "Improving content...",
+	})
+
+	prompt, err := prompts.GenerateDeckImprovementPrompt(prompts.ImportedDeckData{
+		Slides: slides,
+		Theme:  theme,
+	})
+	if err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to generate content", err)
+		return
+	}
+
+	markdown, err := s.generateFromPrompt(ctx, prompt, defaultGeneration)
+	if err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to generate content", err)
+		return
+	}
+	if markdown, err = s.postLLMHooks(ctx, deckInfo, markdown); err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to check content", err)
+		return
+	}
+
+	s.renderDeck(ctx, deckInfo, markdown, renderOptions{Theme: theme}, deckDir)
+}
+
+// extractSlides returns the text of each slide of a PDF or PPTX deck, in presentation order
+func extractSlides(filePath string) ([]prompts.ImportedSlide, error) {
+	var texts []string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".pdf":
+		texts, err = extractPDFText(filePath)
+	case ".pptx":
+		texts, err = extractPPTXText(filePath)
+	default:
+		return nil, fmt.Errorf("unsupported deck format: %s", filepath.Ext(filePath))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var slides []prompts.ImportedSlide
+	for i, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		slides = append(slides, prompts.ImportedSlide{Number: i + 1, Text: text})
+	}
+
+	if len(slides) == 0 {
+		return nil, fmt.Errorf("no text found in deck")
+	}
+
+	return slides, nil
+}
+
+// extractPDFText uses pdftotext, which separates pages with a form feed
+func extractPDFText(filePath string) ([]string, error) {
+	out, err := exec.Command("pdftotext", "-layout", "-enc", "UTF-8", filePath, "-").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run pdftotext: %w", err)
+	}
+
+	pages := strings.Split(string(out), "\f")
+	// pdftotext terminates the last page with a form feed as well
+	if len(pages) > 0 && strings.TrimSpace(pages[len(pages)-1]) == "" {
+		pages = pages[:len(pages)-1]
+	}
+
+	return pages, nil
+}
+
+// extractPPTXText reads the slide XML parts of a PowerPoint file
+func extractPPTXText(filePath string) ([]string, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pptx: %w", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	order := pptxSlideOrder(files)
+
+	texts := make([]string, 0, len(order))
+	for _, name := range order {
+		text, err := readSlideText(files[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		texts = append(texts, text)
+	}
+
+	return texts, nil
+}
+
+// pptxSlideOrder resolves the presentation order of slides from presentation.xml,
+// falling back to the slide file numbering when the manifest cannot be read
+func pptxSlideOrder(files map[string]*zip.File) []string {
+	var presentation struct {
+		SlideIDs []struct {
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	if decodeZipXML(files["ppt/presentation.xml"], &presentation) == nil &&
+		decodeZipXML(files["ppt/_rels/presentation.xml.rels"], &rels) == nil {
+		targets := make(map[string]string)
+		for _, r := range rels.Relationships {
+			targets[r.ID] = path.Join("ppt", r.Target)
+		}
+
+		var order []string
+		for _, id := range presentation.SlideIDs {
+			if name, ok := targets[id.RelID]; ok && files[name] != nil {
+				order = append(order, name)
+			}
+		}
+		if len(order) > 0 {
+			return order
+		}
+	}
+
+	var order []string
+	for name := range files {
+		if pptxSlideRegex.MatchString(name) {
+			order = append(order, name)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, _ := strconv.Atoi(pptxSlideRegex.FindStringSubmatch(order[i])[1])
+		b, _ := strconv.Atoi(pptxSlideRegex.FindStringSubmatch(order[j])[1])
+		return a < b
+	})
+	return order
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("missing zip entry")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// readSlideText collects the text runs (<a:t>) of a slide, one line per paragraph (<a:p>)
+func readSlideText(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	var line strings.Builder
+	inText := false
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			inText = t.Name.Local == "t"
+		case xml.EndElement:
+			if t.Name.Local == "t" {
+				inText = false
+			}
+			if t.Name.Local == "p" && line.Len() > 0 {
+				sb.WriteString(strings.TrimSpace(line.String()))
+				sb.WriteString("\n")
+				line.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				line.Write(t)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// A deck is locked while it is processing: its generation or render saves the whole
+// record when it completes, which would undo the changes made in the meantime. Decks
+// go from processing to completed or failed when the job ends, and back to processing
+// only through lockDeck, so two jobs never run on the same deck.
+var errDeckLocked = fmt.Errorf("%w: the deck is being generated, try again when it is done", model.ErrConflict)
+
+// checkUnlocked rejects changes to a deck while it is processing
+func checkUnlocked(deck *model.PitchDeckInfo) error {
+	if deck.Status == "processing" {
+		return errDeckLocked
+	}
+	return nil
+}
+
+// lockDeck moves a deck to processing before a job runs on it, unless another job
+// already does
+func (s *PitchDeckService) lockDeck(deckID string) error {
+	err := s.repo.StartProcessing(context.Background(), deckID)
+	deckCache.invalidate()
+	if errors.Is(err, model.ErrConflict) {
+		return errDeckLocked
+	}
+	return err
+}
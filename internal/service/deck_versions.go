@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+)
+
+// recordDeckVersion keeps the markdown a deck was rendered from as its next version,
+// encrypted with the data key of its owner when encryption at rest is on
+func recordDeckVersion(deckID, userID, markdown string) {
+	markdown, err := seal(userID, markdown)
+	if err != nil {
+		log.Printf("Failed to record version of deck %s: %v", deckID, err)
+		return
+	}
+	params := map[string]string{"p_deck_id": deckID, "p_markdown": markdown}
+	if err := supabaseWrite("POST", "rpc/create_deck_version", params); err != nil {
+		log.Printf("Failed to record version of deck %s: %v", deckID, err)
+	}
+}
+
+// Versions lists the versions of a deck, the last first, without their markdown
+func (s *PitchDeckService) Versions(deckID, userID string) ([]model.DeckVersion, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []model.DeckVersion
+	path := fmt.Sprintf("deck_versions?select=id,deck_id,version,created_at&deck_id=eq.%s&order=version.desc", url.QueryEscape(deck.ID))
+	if err := supabaseREST("GET", path, nil, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func deckVersion(deckID string, version int) (*model.DeckVersion, error) {
+	var versions []model.DeckVersion
+	path := fmt.Sprintf("deck_versions?deck_id=eq.%s&version=eq.%d", url.QueryEscape(deckID), version)
+	if err := supabaseREST("GET", path, nil, &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: the deck has no version %d", model.ErrNotFound, version)
+	}
+	markdown, err := unseal(versions[0].Markdown)
+	if err != nil {
+		return nil, err
+	}
+	versions[0].Markdown = markdown
+	return &versions[0], nil
+}
+
+// DiffVersions compares the slides of two versions of a deck
+func (s *PitchDeckService) DiffVersions(deckID, userID string, from, to int) (*model.DeckDiff, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	fromVersion, err := deckVersion(deck.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := deckVersion(deck.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffSlides(fromVersion.Markdown, toVersion.Markdown)
+	diff.From = from
+	diff.To = to
+	return diff, nil
+}
+
+// diffSlides pairs the identical slides of two decks in order, then the slides left
+// between two pairs as changed, and the slides left over as added or removed
+func diffSlides(fromMarkdown, toMarkdown string) *model.DeckDiff {
+	_, fromSlides := slides.Split(fromMarkdown)
+	_, toSlides := slides.Split(toMarkdown)
+
+	diff := &model.DeckDiff{}
+	i, j := 0, 0
+	// A final pair past the last slides flushes the slides left after the last match
+	pairs := append(commonSequence(fromSlides, toSlides), [2]int{len(fromSlides), len(toSlides)})
+	for _, pair := range pairs {
+		for ; i < pair[0] && j < pair[1]; i, j = i+1, j+1 {
+			added, removed := diffLines(fromSlides[i], toSlides[j])
+			diff.Slides = append(diff.Slides, model.SlideDiff{
+				Status: "changed", FromSlide: i + 1, ToSlide: j + 1, Title: slides.Title(toSlides[j]),
+				AddedLines: added, RemovedLines: removed,
+			})
+			diff.Changed++
+		}
+		for ; i < pair[0]; i++ {
+			diff.Slides = append(diff.Slides, model.SlideDiff{
+				Status: "removed", FromSlide: i + 1, Title: slides.Title(fromSlides[i]),
+				RemovedLines: contentLines(fromSlides[i]),
+			})
+			diff.Removed++
+		}
+		for ; j < pair[1]; j++ {
+			diff.Slides = append(diff.Slides, model.SlideDiff{
+				Status: "added", ToSlide: j + 1, Title: slides.Title(toSlides[j]),
+				AddedLines: contentLines(toSlides[j]),
+			})
+			diff.Added++
+		}
+		if pair[0] < len(fromSlides) {
+			diff.Slides = append(diff.Slides, model.SlideDiff{
+				Status: "unchanged", FromSlide: pair[0] + 1, ToSlide: pair[1] + 1, Title: slides.Title(toSlides[pair[1]]),
+			})
+			diff.Unchanged++
+			i, j = pair[0]+1, pair[1]+1
+		}
+	}
+	return diff
+}
+
+// diffLines returns the lines of text added to and removed from a slide
+func diffLines(from, to string) (added, removed []string) {
+	fromLines, toLines := contentLines(from), contentLines(to)
+	i, j := 0, 0
+	for _, pair := range append(commonSequence(fromLines, toLines), [2]int{len(fromLines), len(toLines)}) {
+		removed = append(removed, fromLines[i:pair[0]]...)
+		added = append(added, toLines[j:pair[1]]...)
+		i, j = pair[0]+1, pair[1]+1
+	}
+	return added, removed
+}
+
+// contentLines returns the non-empty lines of a slide, trimmed
+func contentLines(slide string) []string {
+	var lines []string
+	for _, line := range strings.Split(slide, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// commonSequence returns the indexes of the elements of the longest common
+// subsequence of a and b, in order
+func commonSequence(a, b []string) [][2]int {
+	// lengths[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// demoMarkdown is a sample deck of a fictional company, rendered in each theme
+// without calling the LLM
+//
+//go:embed demo/pitch_deck.md
+var demoMarkdown string
+
+// Themes the sample deck is rendered in
+var demoThemes = []string{"default", "gaia", "uncover", "rose-pine"}
+
+// Owner of the sample decks, on the free plan so they show its watermark
+const demoUserID = "00000000-0000-0000-0000-000000000000"
+
+// Keeps an instance from starting two renders of a sample deck
+var demoRenders sync.Mutex
+
+// demoDeckID is the fixed ID of the sample deck in a theme, shared by the instances
+func demoDeckID(theme string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("pitchtree:demo:"+theme)).String()
+}
+
+// Demo returns the sample deck in a theme. It is rendered on the first request for
+// the theme, the following ones get the stored deck. A render that failed is started
+// again.
+func (s *PitchDeckService) Demo(theme string) (*model.PitchDeckInfo, error) {
+	theme = strings.ToLower(strings.TrimSpace(theme))
+	if theme == "" {
+		theme = demoThemes[0]
+	}
+	if !slices.Contains(demoThemes, theme) {
+		return nil, fmt.Errorf("%w: no sample deck for theme %q, expected one of %s",
+			model.ErrInvalidInput, theme, strings.Join(demoThemes, ", "))
+	}
+
+	demoRenders.Lock()
+	defer demoRenders.Unlock()
+
+	deckID := demoDeckID(theme)
+	deck, err := s.repo.Get(context.Background(), deckID)
+	switch {
+	case err == nil && deck.Status != "failed":
+		return deck, nil
+	case err != nil && !errors.Is(err, model.ErrNotFound):
+		return nil, err
+	}
+
+	if err := s.acceptingJobs(); err != nil {
+		return nil, err
+	}
+	if deck == nil {
+		deck = &model.PitchDeckInfo{
+			ID:        deckID,
+			UserID:    demoUserID,
+			Name:      "Orbital Greens (sample)",
+			Slug:      "sample-deck-" + theme,
+			IsPublic:  true,
+			Status:    "processing",
+			CreatedAt: time.Now(),
+		}
+		if err := s.createRecord(context.Background(), deck, nil); err != nil {
+			return nil, fmt.Errorf("failed to create sample deck: %w", err)
+		}
+	} else if err := s.lockDeck(deckID); errors.Is(err, errDeckLocked) {
+		// Another instance started rendering it again
+		return s.repo.Get(context.Background(), deckID)
+	} else if err != nil {
+		return nil, err
+	}
+	deck.Status = "processing"
+	s.progress.CreateChannel(deckID, demoUserID)
+
+	markdown := strings.ReplaceAll(demoMarkdown, "{{THEME}}", theme)
+	s.startJob(deck, func(ctx context.Context) {
+		deckDir := filepath.Join("temp", deckID)
+		os.MkdirAll(deckDir, os.ModePerm)
+		log.Printf("Rendering sample deck in theme %s", theme)
+		s.renderDeck(ctx, deck, markdown, renderOptions{Theme: theme}, deckDir)
+	})
+	return deck, nil
+}
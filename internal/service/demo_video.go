@@ -0,0 +1,178 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/qrcode"
+	"pitch-deck-generator/internal/slides"
+)
+
+// Where a product demo video is hosted
+const (
+	videoYouTube = "youtube"
+	videoLoom    = "loom"
+	videoFile    = "file"
+)
+
+// Alternative text of the thumbnail linking to the demo video, which the HTML
+// export replaces with a player
+const demoThumbnailAlt = "Product demo video"
+
+var (
+	youTubeIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	loomIDRegex    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	demoLinkRegex  = regexp.MustCompile(`(?is)<a\b[^>]*\bhref="([^"]+)"[^>]*>\s*<img\b[^>]*\balt="` + demoThumbnailAlt + `"[^>]*>\s*</a>`)
+)
+
+var errInvalidDemoVideo = fmt.Errorf("%w: the product demo video must be a link to a YouTube or Loom video, or an uploaded MP4 or WebM video", model.ErrInvalidInput)
+
+// Thumbnail of videos uploaded by users, a play button
+const videoPlaceholderSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 160 90">` +
+	`<rect width="160" height="90" fill="#1f2937"/><circle cx="80" cy="45" r="22" fill="#fff" fill-opacity=".9"/>` +
+	`<path d="M73 33v24l20-12z" fill="#1f2937"/></svg>`
+
+// demoVideo is a product demo on YouTube, on Loom or uploaded as a video file
+type demoVideo struct {
+	Kind string
+	// ID of the video on YouTube and Loom, the URL of the file otherwise
+	ID string
+}
+
+// parseDemoVideo accepts the links to YouTube and Loom videos and the https URLs of
+// MP4 and WebM files, such as the videos uploaded by users
+func parseDemoVideo(raw string) (*demoVideo, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errInvalidDemoVideo
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtube.com", "m.youtube.com", "youtube-nocookie.com":
+		id := u.Query().Get("v")
+		if len(segments) == 2 && (segments[0] == "embed" || segments[0] == "shorts" || segments[0] == "live") {
+			id = segments[1]
+		}
+		if youTubeIDRegex.MatchString(id) {
+			return &demoVideo{Kind: videoYouTube, ID: id}, nil
+		}
+	case "youtu.be":
+		if len(segments) == 1 && youTubeIDRegex.MatchString(segments[0]) {
+			return &demoVideo{Kind: videoYouTube, ID: segments[0]}, nil
+		}
+	case "loom.com":
+		if len(segments) == 2 && (segments[0] == "share" || segments[0] == "embed") && loomIDRegex.MatchString(segments[1]) {
+			return &demoVideo{Kind: videoLoom, ID: segments[1]}, nil
+		}
+	default:
+		ext := strings.ToLower(path.Ext(u.Path))
+		if u.Scheme == "https" && (ext == ".mp4" || ext == ".webm") {
+			return &demoVideo{Kind: videoFile, ID: u.String()}, nil
+		}
+	}
+	return nil, errInvalidDemoVideo
+}
+
+// WatchURL is the page the PDF links to
+func (v *demoVideo) WatchURL() string {
+	switch v.Kind {
+	case videoYouTube:
+		return "https://www.youtube.com/watch?v=" + v.ID
+	case videoLoom:
+		return "https://www.loom.com/share/" + v.ID
+	}
+	return v.ID
+}
+
+// ThumbnailURL is the image shown in place of the player in the PDF
+func (v *demoVideo) ThumbnailURL() string {
+	switch v.Kind {
+	case videoYouTube:
+		return "https://img.youtube.com/vi/" + v.ID + "/hqdefault.jpg"
+	case videoLoom:
+		return "https://cdn.loom.com/sessions/thumbnails/" + v.ID + "-with-play.gif"
+	}
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(videoPlaceholderSVG))
+}
+
+// playerHTML is the element playing the video in the HTML export
+func (v *demoVideo) playerHTML() string {
+	const frame = `<iframe src="%s" title="` + demoThumbnailAlt + `" width="640" height="360" style="border: 0; max-width: 100%%"` +
+		` allow="autoplay; encrypted-media; fullscreen; picture-in-picture" allowfullscreen loading="lazy"></iframe>`
+	switch v.Kind {
+	case videoYouTube:
+		return fmt.Sprintf(frame, "https://www.youtube-nocookie.com/embed/"+v.ID)
+	case videoLoom:
+		return fmt.Sprintf(frame, "https://www.loom.com/embed/"+v.ID)
+	}
+	return fmt.Sprintf(`<video src="%s" title="`+demoThumbnailAlt+`" width="640" controls preload="metadata" style="max-width: 100%%"></video>`,
+		html.EscapeString(v.ID))
+}
+
+// demoVideoSlide is the slide presenting the video: its thumbnail linking to it and
+// a QR code for the readers of the printed deck
+func demoVideoSlide(video *demoVideo) (string, error) {
+	qr, err := qrcode.SVG(video.WatchURL())
+	if err != nil {
+		return "", err
+	}
+	qrURI := "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(qr))
+	return fmt.Sprintf("## Product Demo\n\n[![%s w:560](%s)](%s)\n\n![Scan to watch the demo w:140](%s) [Watch the demo](%s)",
+		demoThumbnailAlt, video.ThumbnailURL(), video.WatchURL(), qrURI, video.WatchURL()), nil
+}
+
+// insertDemoVideoSlide adds the slide of the demo video after the slide presenting
+// the solution, or before the last slide when there is none
+func insertDemoVideoSlide(markdown string, video *demoVideo) (string, error) {
+	slide, err := demoVideoSlide(video)
+	if err != nil {
+		return "", err
+	}
+
+	frontMatter, deckSlides := slides.Split(markdown)
+	position := max(len(deckSlides)-1, 0)
+	for i, s := range deckSlides {
+		if strings.Contains(strings.ToLower(slides.Title(s)), "solution") {
+			position = i + 1
+			break
+		}
+	}
+	deckSlides = slices.Insert(deckSlides, position, slide)
+	return slides.Join(frontMatter, deckSlides), nil
+}
+
+// embedDemoPlayers replaces the thumbnails of demo videos in an HTML export with
+// players. Links the players cannot play are left as they are.
+func embedDemoPlayers(htmlPath string) error {
+	content, err := os.ReadFile(htmlPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HTML export: %w", err)
+	}
+
+	document := demoLinkRegex.ReplaceAllStringFunc(string(content), func(link string) string {
+		href := demoLinkRegex.FindStringSubmatch(link)[1]
+		video, err := parseDemoVideo(html.UnescapeString(href))
+		if err != nil {
+			return link
+		}
+		return video.playerHTML()
+	})
+	if document == string(content) {
+		return nil
+	}
+
+	if err := os.WriteFile(htmlPath, []byte(document), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/mail"
+	"pitch-deck-generator/internal/model"
+)
+
+const (
+	// Period summarized by a digest, users are sent one digest per period
+	digestPeriod = 7 * 24 * time.Hour
+	// How often the due digests are looked up
+	digestInterval = time.Hour
+)
+
+// DigestService emails the users who opted in a weekly summary of the views,
+// downloads and comments on their decks
+type DigestService struct {
+	mail *mail.Client
+}
+
+// deckActivity is the activity on one deck over the period of a digest
+type deckActivity struct {
+	Name      string
+	Views     int
+	Downloads int
+	Comments  int
+}
+
+func NewDigestService(mailClient *mail.Client) *DigestService {
+	return &DigestService{
+		mail: mailClient,
+	}
+}
+
+// Preferences returns the email preferences of a user, the defaults when they never
+// changed them
+func (s *DigestService) Preferences(userID string) (*model.EmailPreferences, error) {
+	var prefs []model.EmailPreferences
+	if err := supabaseREST("GET", "email_preferences?user_id=eq."+url.QueryEscape(userID), nil, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to load email preferences: %w", err)
+	}
+	if len(prefs) == 0 {
+		return &model.EmailPreferences{UserID: userID}, nil
+	}
+	return &prefs[0], nil
+}
+
+// UpdatePreferences opts a user in or out of the weekly digest, sent to email
+func (s *DigestService) UpdatePreferences(userID, email string, weeklyDigest bool) (*model.EmailPreferences, error) {
+	email = strings.TrimSpace(email)
+	if weeklyDigest && !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("%w: your account has no email address to send the digest to", model.ErrInvalidInput)
+	}
+
+	var prefs []model.EmailPreferences
+	params := map[string]interface{}{
+		"p_user_id":       userID,
+		"p_email":         email,
+		"p_weekly_digest": weeklyDigest,
+	}
+	if err := supabaseREST("POST", "rpc/set_email_preferences", params, &prefs); err != nil {
+		return nil, fmt.Errorf("failed to save email preferences: %w", err)
+	}
+	if len(prefs) == 0 {
+		return nil, fmt.Errorf("failed to save email preferences")
+	}
+	return &prefs[0], nil
+}
+
+// Start sends the due digests now and then at every interval, in the background.
+// Nothing is sent when no mail server is configured.
+func (s *DigestService) Start() {
+	if s.mail == nil {
+		return
+	}
+	go func() {
+		for {
+			s.SendDue()
+			time.Sleep(digestInterval)
+		}
+	}()
+}
+
+// SendDue sends their digest to the users who were not sent one for a period. Users
+// without activity on their decks over the period are skipped until the next one.
+func (s *DigestService) SendDue() {
+	params := map[string]interface{}{
+		"p_interval": fmt.Sprintf("%d seconds", int(digestPeriod.Seconds())),
+		"p_limit":    recordBatchSize,
+	}
+	var due []model.EmailPreferences
+	if err := supabaseREST("POST", "rpc/claim_digests", params, &due); err != nil {
+		log.Printf("Failed to claim the due digests: %v", err)
+		return
+	}
+
+	since := time.Now().Add(-digestPeriod)
+	sent := 0
+	for _, prefs := range due {
+		activity, err := weeklyActivity(prefs.UserID, since)
+		if err == nil && len(activity) > 0 {
+			if err = s.mail.Send(digestMessage(prefs.Email, activity)); err == nil {
+				sent++
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to send the digest of user %s: %v", prefs.UserID, err)
+			// Sent again at the next run
+			reset := map[string]interface{}{"last_digest_at": nil}
+			if err := supabaseREST("PATCH", "email_preferences?user_id=eq."+url.QueryEscape(prefs.UserID), reset, nil); err != nil {
+				log.Printf("Failed to reschedule the digest of user %s: %v", prefs.UserID, err)
+			}
+		}
+	}
+	if sent > 0 {
+		log.Printf("Sent %d weekly digests", sent)
+	}
+}
+
+// weeklyActivity returns the decks of a user that were viewed, downloaded or
+// commented on since a time, the most viewed first
+func weeklyActivity(userID string, since time.Time) ([]deckActivity, error) {
+	var decks []model.PitchDeckInfo
+	if err := supabaseREST("GET", "pitch_decks?select=id,name&status=eq.completed&user_id=eq."+url.QueryEscape(userID), nil, &decks); err != nil {
+		return nil, err
+	}
+	if len(decks) == 0 {
+		return nil, nil
+	}
+
+	byDeck := make(map[string]*deckActivity, len(decks))
+	ids := make([]string, len(decks))
+	for i, deck := range decks {
+		ids[i] = deck.ID
+		byDeck[deck.ID] = &deckActivity{Name: deck.Name}
+	}
+	inDecks := "deck_id=in.(" + strings.Join(ids, ",") + ")"
+	sinceParam := url.QueryEscape(since.UTC().Format(time.RFC3339))
+
+	var events []viewEventRecord
+	path := fmt.Sprintf("deck_view_events?select=deck_id,event_type&%s&event_type=in.(open,download)&created_at=gte.%s", inDecks, sinceParam)
+	if err := supabaseREST("GET", path, nil, &events); err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.EventType == "open" {
+			byDeck[event.DeckID].Views++
+		} else {
+			byDeck[event.DeckID].Downloads++
+		}
+	}
+
+	// Comments left by the people the decks are shared with
+	var comments []model.DeckFeedback
+	path = fmt.Sprintf("deck_feedback?select=deck_id&%s&comment=neq.&user_id=neq.%s&updated_at=gte.%s", inDecks, url.QueryEscape(userID), sinceParam)
+	if err := supabaseREST("GET", path, nil, &comments); err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		byDeck[comment.DeckID].Comments++
+	}
+
+	var activity []deckActivity
+	for _, deck := range byDeck {
+		if deck.Views+deck.Downloads+deck.Comments > 0 {
+			activity = append(activity, *deck)
+		}
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].Views != activity[j].Views {
+			return activity[i].Views > activity[j].Views
+		}
+		return activity[i].Name < activity[j].Name
+	})
+	return activity, nil
+}
+
+// digestMessage writes the digest email of the activity on the decks of a user
+func digestMessage(to string, activity []deckActivity) mail.Message {
+	var views, downloads, comments int
+	for _, deck := range activity {
+		views += deck.Views
+		downloads += deck.Downloads
+		comments += deck.Comments
+	}
+	summary := fmt.Sprintf("This week your decks were viewed %d times, downloaded %d times and received %d comments.",
+		views, downloads, comments)
+	footer := "You receive this email because you turned on the weekly digest in your PitchTree settings."
+	if appURL := os.Getenv("APP_URL"); appURL != "" {
+		footer += " Turn it off at any time from " + strings.TrimSuffix(appURL, "/") + "."
+	}
+
+	var text, body strings.Builder
+	fmt.Fprintf(&text, "%s\n\n", summary)
+	fmt.Fprintf(&body, "<p>%s</p>\n<table cellpadding=\"6\" style=\"border-collapse: collapse\">\n", html.EscapeString(summary))
+	body.WriteString("<tr><th align=\"left\">Deck</th><th>Views</th><th>Downloads</th><th>Comments</th></tr>\n")
+	for _, deck := range activity {
+		fmt.Fprintf(&text, "- %s: %d views, %d downloads, %d comments\n", deck.Name, deck.Views, deck.Downloads, deck.Comments)
+		fmt.Fprintf(&body, "<tr><td>%s</td><td align=\"center\">%d</td><td align=\"center\">%d</td><td align=\"center\">%d</td></tr>\n",
+			html.EscapeString(deck.Name), deck.Views, deck.Downloads, deck.Comments)
+	}
+	fmt.Fprintf(&text, "\n%s\n", footer)
+	fmt.Fprintf(&body, "</table>\n<p style=\"color: #888; font-size: 12px\">%s</p>\n", html.EscapeString(footer))
+
+	return mail.Message{
+		To:      to,
+		Subject: "Your weekly PitchTree digest",
+		Text:    text.String(),
+		HTML:    body.String(),
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+const (
+	defaultMinFreeDiskMB    = 1024
+	defaultDiskMonitorEvery = time.Minute
+)
+
+// Disk usage of the working directories, published on the admin metrics endpoint
+var diskMetrics = expvar.NewMap("disk")
+
+// lowDiskSpace is set while the free space is below the threshold, new generations
+// are rejected until it is freed
+var lowDiskSpace atomic.Bool
+
+var errLowDiskSpace = fmt.Errorf("%w: the server is running low on disk space, new decks cannot be generated for a few minutes", model.ErrUnavailable)
+
+// DiskMonitor watches the free space of the disk holding temp/ and outputs/. Below
+// the threshold new generations are rejected and the janitor frees what it can.
+type DiskMonitor struct {
+	janitor  *Janitor
+	minFree  uint64
+	interval time.Duration
+}
+
+// NewDiskMonitor configures the monitor from DISK_MIN_FREE_MB and DISK_CHECK_INTERVAL
+func NewDiskMonitor(janitor *Janitor) *DiskMonitor {
+	minFree, err := strconv.ParseUint(os.Getenv("DISK_MIN_FREE_MB"), 10, 64)
+	if err != nil || minFree == 0 {
+		minFree = defaultMinFreeDiskMB
+	}
+	interval, err := time.ParseDuration(os.Getenv("DISK_CHECK_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultDiskMonitorEvery
+	}
+
+	return &DiskMonitor{
+		janitor:  janitor,
+		minFree:  minFree << 20,
+		interval: interval,
+	}
+}
+
+// Start checks the disk now and then at every interval, in the background
+func (m *DiskMonitor) Start() {
+	go func() {
+		for {
+			m.Check()
+			time.Sleep(m.interval)
+		}
+	}()
+}
+
+// Check records the disk usage and updates whether generations are accepted. When
+// the free space is low, an emergency sweep runs before generations are rejected.
+func (m *DiskMonitor) Check() {
+	free, err := m.measure()
+	if err != nil {
+		log.Printf("Disk monitor: %v", err)
+		diskMetrics.Add("errors", 1)
+		return
+	}
+
+	if free < m.minFree {
+		log.Printf("Disk monitor: %d MB free, below %d MB, removing old files", free>>20, m.minFree>>20)
+		m.janitor.EmergencySweep()
+		if free, err = m.measure(); err != nil {
+			log.Printf("Disk monitor: %v", err)
+			diskMetrics.Add("errors", 1)
+			return
+		}
+	}
+
+	low := free < m.minFree
+	if lowDiskSpace.Swap(low) != low {
+		if low {
+			log.Printf("Disk monitor: %d MB free after cleanup, rejecting new generations", free>>20)
+		} else {
+			log.Printf("Disk monitor: %d MB free, accepting new generations again", free>>20)
+		}
+	}
+	lowFlag := new(expvar.Int)
+	if low {
+		lowFlag.Set(1)
+	}
+	diskMetrics.Set("low_space", lowFlag)
+}
+
+// measure publishes the free space of the disk and the size of the working
+// directories, and returns the free space
+func (m *DiskMonitor) measure() (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(".", &stat); err != nil {
+		return 0, fmt.Errorf("failed to read disk usage: %w", err)
+	}
+	free := stat.Bavail * uint64(stat.Bsize)
+
+	setDiskMetric("free_bytes", int64(free))
+	setDiskMetric("total_bytes", int64(stat.Blocks*uint64(stat.Bsize)))
+	setDiskMetric("temp_bytes", dirSize("temp"))
+	setDiskMetric("outputs_bytes", dirSize("outputs"))
+	return free, nil
+}
+
+func setDiskMetric(name string, value int64) {
+	metric := new(expvar.Int)
+	metric.Set(value)
+	diskMetrics.Set(name, metric)
+}
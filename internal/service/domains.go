@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+)
+
+const (
+	// Domains a user may add, verified or not
+	maxDomainsPerUser = 5
+	// Unverified claims of a domain are dropped after this long, and when another
+	// user verifies it
+	pendingDomainTTL = 7 * 24 * time.Hour
+	// How long the verified domains are cached by the viewers
+	domainCacheTTL = time.Minute
+	dnsTimeout     = 5 * time.Second
+	// The TXT record proving a domain is owned is created on this subdomain of it
+	verificationPrefix = "_pitchtree-verification."
+	// Plan of the users without a subscription
+	freePlanID = "free"
+)
+
+var (
+	verifiedDomainsMu       sync.Mutex
+	verifiedDomains         map[string]string
+	verifiedDomainsLoadedAt time.Time
+)
+
+// loadVerifiedDomains returns the owner of each verified domain, reloaded when stale
+func loadVerifiedDomains() map[string]string {
+	verifiedDomainsMu.Lock()
+	defer verifiedDomainsMu.Unlock()
+	if verifiedDomains != nil && time.Since(verifiedDomainsLoadedAt) < domainCacheTTL {
+		return verifiedDomains
+	}
+
+	var domains []model.CustomDomain
+	if err := supabaseREST("GET", "custom_domains?select=domain,user_id&verified_at=not.is.null", nil, &domains); err != nil {
+		// The last known domains are kept while Supabase is unavailable, and retried
+		// after the TTL rather than on every request
+		log.Printf("Failed to load custom domains: %v", err)
+		if verifiedDomains == nil {
+			verifiedDomains = map[string]string{}
+		}
+		verifiedDomainsLoadedAt = time.Now()
+		return verifiedDomains
+	}
+	owners := make(map[string]string, len(domains))
+	for _, domain := range domains {
+		owners[domain.Domain] = domain.UserID
+	}
+	verifiedDomains, verifiedDomainsLoadedAt = owners, time.Now()
+	return owners
+}
+
+func invalidateVerifiedDomains() {
+	verifiedDomainsMu.Lock()
+	verifiedDomains = nil
+	verifiedDomainsMu.Unlock()
+}
+
+// domainOwner returns the user whose verified domain host is, or an empty string
+func domainOwner(host string) string {
+	return loadVerifiedDomains()[hostname(host)]
+}
+
+// HostPolicy allows autocert to request certificates for the verified custom domains
+// of users and organizations only, looked up in the database as certificates are
+// requested rarely
+func HostPolicy(_ context.Context, host string) error {
+	domain := url.QueryEscape(hostname(host))
+	var domains []model.CustomDomain
+	if err := supabaseREST("GET", "custom_domains?select=domain&verified_at=not.is.null&domain=eq."+domain, nil, &domains); err != nil {
+		return fmt.Errorf("failed to check domain %s: %w", host, err)
+	}
+	if len(domains) > 0 {
+		return nil
+	}
+	var orgs []model.Organization
+	if err := supabaseREST("GET", "organizations?select=id&custom_domain_verified_at=not.is.null&custom_domain=eq."+domain, nil, &orgs); err != nil {
+		return fmt.Errorf("failed to check domain %s: %w", host, err)
+	}
+	if len(orgs) == 0 {
+		return fmt.Errorf("%s is not a verified custom domain", host)
+	}
+	return nil
+}
+
+// DomainService lets paying users serve their public decks on their own domains
+type DomainService struct{}
+
+func NewDomainService() *DomainService {
+	return &DomainService{}
+}
+
+// verificationRecord returns the name and value of the TXT record proving a domain
+// is owned by whoever was given token
+func verificationRecord(domain, token string) (string, string) {
+	return verificationPrefix + domain, "pitchtree-verification=" + token
+}
+
+// checkVerificationRecord looks up the TXT record of a domain, which must hold the value
+// of verificationRecord
+func checkVerificationRecord(domain, token string) error {
+	name, value := verificationRecord(domain, token)
+	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
+	defer cancel()
+	values, err := net.DefaultResolver.LookupTXT(ctx, name)
+	for _, v := range values {
+		if strings.TrimSpace(v) == value {
+			return nil
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to look up the verification record of %s: %v", domain, err)
+	}
+	return fmt.Errorf("%w: no TXT record %s with value %s was found, DNS changes can take a while to propagate",
+		model.ErrInvalidInput, name, value)
+}
+
+// withRecord fills the TXT record a domain is verified with
+func withRecord(domain model.CustomDomain) model.CustomDomain {
+	domain.RecordName, domain.RecordValue = verificationRecord(domain.Domain, domain.VerificationToken)
+	return domain
+}
+
+// List returns the domains of a user with the record verifying each
+func (s *DomainService) List(userID string) ([]model.CustomDomain, error) {
+	var domains []model.CustomDomain
+	if err := supabaseREST("GET", "custom_domains?order=created_at&user_id=eq."+url.QueryEscape(userID), nil, &domains); err != nil {
+		return nil, fmt.Errorf("failed to load custom domains: %w", err)
+	}
+	for i := range domains {
+		domains[i] = withRecord(domains[i])
+	}
+	return domains, nil
+}
+
+// Add registers a claim of a domain for a user, who then creates the TXT record
+// returned and verifies the domain. Several users may claim a domain until one of
+// them verifies it, an unverified claim does not keep its owner from claiming it.
+func (s *DomainService) Add(userID, domain string) (*model.CustomDomain, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return nil, fmt.Errorf("%w: domain is required", model.ErrInvalidInput)
+	}
+	if err := validateCustomDomain(domain); err != nil {
+		return nil, err
+	}
+
+	usage, err := planUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	if usage.Plan.ID == freePlanID {
+		return nil, fmt.Errorf("%w: custom domains need a paid plan", model.ErrQuotaExceeded)
+	}
+
+	existing, err := s.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxDomainsPerUser {
+		return nil, fmt.Errorf("%w: at most %d custom domains can be added", model.ErrQuotaExceeded, maxDomainsPerUser)
+	}
+	// The verified domains of organizations serve their viewers
+	if _, byDomain := loadBrandedOrgs(); byDomain[domain].ID != "" {
+		return nil, fmt.Errorf("%w: %s is already used", model.ErrConflict, domain)
+	}
+	if owner := domainOwner(domain); owner != "" && owner != userID {
+		return nil, fmt.Errorf("%w: %s is already used", model.ErrConflict, domain)
+	}
+	expirePendingClaims(domain)
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	record := model.CustomDomain{
+		Domain:            domain,
+		UserID:            userID,
+		VerificationToken: token,
+		CreatedAt:         time.Now(),
+	}
+	if err := supabaseREST("POST", "custom_domains", record, nil); err != nil {
+		var statusErr *resilience.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: %s was already added", model.ErrConflict, domain)
+		}
+		return nil, fmt.Errorf("failed to add custom domain: %w", err)
+	}
+
+	record = withRecord(record)
+	return &record, nil
+}
+
+// Verify looks up the TXT record of a domain of a user and marks the domain
+// verified when it holds the token. Its decks are served on it from then on, and
+// the claims of other users are dropped.
+func (s *DomainService) Verify(userID, domain string) (*model.CustomDomain, error) {
+	record, err := userDomain(userID, domain)
+	if err != nil {
+		return nil, err
+	}
+	if record.VerifiedAt != nil {
+		return record, nil
+	}
+
+	// The verified domain of an organization serves its viewers
+	if _, byDomain := loadBrandedOrgs(); byDomain[record.Domain].ID != "" {
+		return nil, fmt.Errorf("%w: %s is already used", model.ErrConflict, record.Domain)
+	}
+	if err := checkVerificationRecord(record.Domain, record.VerificationToken); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	path := fmt.Sprintf("custom_domains?domain=eq.%s&user_id=eq.%s", url.QueryEscape(record.Domain), url.QueryEscape(userID))
+	if err := supabaseREST("PATCH", path, map[string]interface{}{"verified_at": now}, nil); err != nil {
+		// Only one claim of a domain can be verified
+		var statusErr *resilience.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: %s was verified by another account", model.ErrConflict, record.Domain)
+		}
+		return nil, fmt.Errorf("failed to verify custom domain: %w", err)
+	}
+	invalidateVerifiedDomains()
+
+	path = fmt.Sprintf("custom_domains?domain=eq.%s&user_id=neq.%s&verified_at=is.null", url.QueryEscape(record.Domain), url.QueryEscape(userID))
+	if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+		log.Printf("Failed to drop the other claims of %s: %v", record.Domain, err)
+	}
+
+	record.VerifiedAt = &now
+	return record, nil
+}
+
+// Remove deletes a domain of a user, its decks are no longer served on it
+func (s *DomainService) Remove(userID, domain string) error {
+	record, err := userDomain(userID, domain)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("custom_domains?domain=eq.%s&user_id=eq.%s", url.QueryEscape(record.Domain), url.QueryEscape(userID))
+	if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove custom domain: %w", err)
+	}
+	invalidateVerifiedDomains()
+	return nil
+}
+
+// expirePendingClaims drops the unverified claims of a domain older than
+// pendingDomainTTL
+func expirePendingClaims(domain string) {
+	before := time.Now().Add(-pendingDomainTTL).UTC().Format(time.RFC3339)
+	path := fmt.Sprintf("custom_domains?domain=eq.%s&verified_at=is.null&created_at=lt.%s", url.QueryEscape(domain), url.QueryEscape(before))
+	if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+		log.Printf("Failed to expire the claims of %s: %v", domain, err)
+	}
+}
+
+// userDomain returns a domain added by a user, other domains are reported as not found
+func userDomain(userID, domain string) (*model.CustomDomain, error) {
+	var domains []model.CustomDomain
+	path := fmt.Sprintf("custom_domains?domain=eq.%s&user_id=eq.%s", url.QueryEscape(strings.ToLower(domain)), url.QueryEscape(userID))
+	if err := supabaseREST("GET", path, nil, &domains); err != nil {
+		return nil, fmt.Errorf("failed to load custom domain: %w", err)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("%w: domain not found", model.ErrNotFound)
+	}
+	record := withRecord(domains[0])
+	return &record, nil
+}
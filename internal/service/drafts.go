@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Steps of the form wizard
+	draftSteps = 10
+	// Drafts kept per user, older ones must be deleted or generated first
+	maxDrafts = 20
+)
+
+// DraftService saves the deck form while it is being filled
+type DraftService struct {
+	decks *PitchDeckService
+}
+
+func NewDraftService(decks *PitchDeckService) *DraftService {
+	return &DraftService{
+		decks: decks,
+	}
+}
+
+func checkDraftStep(step int) error {
+	if step < 0 || step > draftSteps {
+		return fmt.Errorf("%w: step must be between 0 and %d", model.ErrInvalidInput, draftSteps)
+	}
+	return nil
+}
+
+func (s *DraftService) CreateDraft(userID string, data model.PitchDeckData, step int) (*model.Draft, error) {
+	if err := checkDraftStep(step); err != nil {
+		return nil, err
+	}
+
+	var existing []model.Draft
+	path := fmt.Sprintf("deck_drafts?select=id&user_id=eq.%s&deck_id=is.null&limit=%d", url.QueryEscape(userID), maxDrafts)
+	if err := supabaseREST("GET", path, nil, &existing); err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxDrafts {
+		return nil, fmt.Errorf("%w: at most %d drafts can be kept, delete one first", model.ErrConflict, maxDrafts)
+	}
+
+	now := time.Now()
+	draft := model.Draft{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Data:      data,
+		Step:      step,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := supabaseREST("POST", "deck_drafts", draft, nil); err != nil {
+		return nil, fmt.Errorf("failed to save draft: %w", err)
+	}
+	return &draft, nil
+}
+
+// ListDrafts lists the drafts of a user, the last saved first
+func (s *DraftService) ListDrafts(userID string) ([]model.Draft, error) {
+	var drafts []model.Draft
+	path := fmt.Sprintf("deck_drafts?user_id=eq.%s&order=updated_at.desc", url.QueryEscape(userID))
+	if err := supabaseREST("GET", path, nil, &drafts); err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+func (s *DraftService) GetDraft(draftID, userID string) (*model.Draft, error) {
+	var drafts []model.Draft
+	if err := supabaseREST("GET", "deck_drafts?id=eq."+url.QueryEscape(draftID), nil, &drafts); err != nil {
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, fmt.Errorf("%w: draft not found", model.ErrNotFound)
+	}
+	if drafts[0].UserID != userID {
+		return nil, fmt.Errorf("%w: draft belongs to another user", model.ErrForbidden)
+	}
+	return &drafts[0], nil
+}
+
+// SaveDraft replaces the answers of a draft, as the wizard autosaves them
+func (s *DraftService) SaveDraft(draftID, userID string, data model.PitchDeckData, step int) (*model.Draft, error) {
+	draft, err := s.GetDraft(draftID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.DeckID != "" {
+		return nil, fmt.Errorf("%w: a deck was already generated from this draft", model.ErrConflict)
+	}
+	if err := checkDraftStep(step); err != nil {
+		return nil, err
+	}
+
+	draft.Data = data
+	draft.Step = step
+	draft.UpdatedAt = time.Now()
+	update := map[string]interface{}{
+		"data":       draft.Data,
+		"step":       draft.Step,
+		"updated_at": draft.UpdatedAt,
+	}
+	if err := supabaseREST("PATCH", "deck_drafts?id=eq."+url.QueryEscape(draftID), update, nil); err != nil {
+		return nil, fmt.Errorf("failed to save draft: %w", err)
+	}
+	return draft, nil
+}
+
+func (s *DraftService) DeleteDraft(draftID, userID string) error {
+	if _, err := s.GetDraft(draftID, userID); err != nil {
+		return err
+	}
+	if err := supabaseREST("DELETE", "deck_drafts?id=eq."+url.QueryEscape(draftID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete draft: %w", err)
+	}
+	return nil
+}
+
+// Generate starts the generation of a deck from the answers of a draft. The draft
+// is kept, linked to the deck.
+func (s *DraftService) Generate(ctx context.Context, draftID, userID string) (*model.PitchDeckInfo, error) {
+	draft, err := s.GetDraft(draftID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if draft.DeckID != "" {
+		return nil, fmt.Errorf("%w: a deck was already generated from this draft", model.ErrConflict)
+	}
+
+	deck, err := s.decks.Create(ctx, draft.Data, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	update := map[string]interface{}{"deck_id": deck.ID, "updated_at": time.Now()}
+	if err := supabaseREST("PATCH", "deck_drafts?id=eq."+url.QueryEscape(draft.ID), update, nil); err != nil {
+		log.Printf("Failed to link deck %s to draft %s: %v", deck.ID, draft.ID, err)
+	}
+	return deck, nil
+}
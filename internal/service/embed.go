@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Default size of embedded decks, slides are 16:9
+const (
+	embedWidth  = 960
+	embedHeight = 540
+)
+
+// embedCSS hides the on-screen controller of the Marp viewer so only the slides
+// are shown inside the iframe
+const embedCSS = `<style>
+  html, body { margin: 0; background: transparent; }
+  .bespoke-marp-osc { display: none !important; }
+</style>
+`
+
+// embedScript keeps keyboard navigation working inside the iframe: the viewer
+// only receives key events once the frame has the focus
+const embedScript = `<script>
+(function () {
+  function focus() { window.focus(); document.body.focus(); }
+  document.body.setAttribute("tabindex", "-1");
+  document.addEventListener("click", focus);
+  window.addEventListener("load", focus);
+})();
+</script>
+`
+
+// injectBeforeBodyEnd inserts a snippet at the end of the body of an HTML document
+func injectBeforeBodyEnd(document, snippet string) string {
+	if i := strings.LastIndex(document, "</body>"); i != -1 {
+		return document[:i] + snippet + document[i:]
+	}
+	return document + snippet
+}
+
+// publicDeck returns a completed public deck, other decks are reported as not found
+// and expired decks as expired
+func (s *PitchDeckService) publicDeck(deckID string) (*model.PitchDeckInfo, error) {
+	deck, err := s.Get(deckID)
+	if err != nil || !isPublished(deck, time.Now()) || deck.Status != "completed" {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if expired(deck.ExpiresAt, time.Now()) {
+		return nil, fmt.Errorf("%w: this deck is no longer shared", model.ErrExpired)
+	}
+	return deck, nil
+}
+
+// PublicHTML returns the HTML viewer of a public deck, by ID or slug, with the viewer
+// branding of its organization, as served on host
+func (s *PitchDeckService) PublicHTML(deckID, host string) (string, error) {
+	deck, err := s.publicDeck(deckID)
+	if err != nil {
+		return "", err
+	}
+
+	document, err := fetchText(deck.HtmlURL)
+	if err != nil {
+		return "", err
+	}
+	return brandViewer(deck, host, document)
+}
+
+// EmbedHTML returns the HTML viewer of a public deck, stripped of its controls and
+// with the viewer branding of its organization, as served on host
+func (s *PitchDeckService) EmbedHTML(deckID, host string) (string, error) {
+	deck, err := s.publicDeck(deckID)
+	if err != nil {
+		return "", err
+	}
+
+	document, err := fetchText(deck.HtmlURL)
+	if err != nil {
+		return "", err
+	}
+	if document, err = brandViewer(deck, host, document); err != nil {
+		return "", err
+	}
+
+	if i := strings.Index(document, "</head>"); i != -1 {
+		document = document[:i] + embedCSS + document[i:]
+	}
+	return injectBeforeBodyEnd(document, embedScript), nil
+}
+
+// OEmbed describes the embed of a public deck, given the URL of its viewer page or
+// embed. The iframe points to VIEWER_URL, or baseURL, the address the API is served from.
+func (s *PitchDeckService) OEmbed(deckURL, baseURL string, maxWidth, maxHeight int) (*model.OEmbed, error) {
+	parsed, err := url.Parse(deckURL)
+	if err != nil || parsed.Path == "" {
+		return nil, fmt.Errorf("%w: invalid deck URL", model.ErrInvalidInput)
+	}
+
+	ref := path.Base(parsed.Path)
+	if !IsDeckRef(ref) {
+		return nil, fmt.Errorf("%w: invalid deck URL", model.ErrInvalidInput)
+	}
+	deck, err := s.publicDeck(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fit the 16:9 frame in the requested bounds
+	width, height := embedWidth, embedHeight
+	if maxWidth > 0 && width > maxWidth {
+		width, height = maxWidth, maxWidth*9/16
+	}
+	if maxHeight > 0 && height > maxHeight {
+		width, height = maxHeight*16/9, maxHeight
+	}
+
+	viewerURL := os.Getenv("VIEWER_URL")
+	if viewerURL == "" {
+		viewerURL = baseURL
+	}
+	src := strings.TrimSuffix(viewerURL, "/") + "/embed/" + url.PathEscape(deck.ID)
+	providerURL := os.Getenv("APP_URL")
+	if providerURL == "" {
+		providerURL = baseURL
+	}
+
+	return &model.OEmbed{
+		Type:         "rich",
+		Version:      "1.0",
+		Title:        deck.Name,
+		ProviderName: viewerProviderName(deck),
+		ProviderURL:  providerURL,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0" allow="fullscreen" allowfullscreen title="%s"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(deck.Name)),
+		Width:  width,
+		Height: height,
+	}, nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+
+	"pitch-deck-generator/internal/envelope"
+	"pitch-deck-generator/internal/model"
+)
+
+// Key ID of the master key in the wrapped data keys
+const masterKeyID = "master"
+
+var (
+	// masterKey wraps the data keys of the users, encryption at rest is off without it
+	masterKey []byte
+	// dataKeys caches the unwrapped data keys by user ID
+	dataKeys sync.Map
+)
+
+// ConfigureEncryption reads the master key from ENCRYPTION_KEY (32 bytes, base64).
+// Without it the markdown and answers of new decks are stored in clear, and decks
+// stored encrypted can no longer be read.
+func ConfigureEncryption() (bool, error) {
+	encoded := os.Getenv("ENCRYPTION_KEY")
+	if encoded == "" {
+		return false, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false, fmt.Errorf("invalid ENCRYPTION_KEY: %w", err)
+	}
+	if len(key) != envelope.KeySize {
+		return false, fmt.Errorf("invalid ENCRYPTION_KEY: must be %d bytes, got %d", envelope.KeySize, len(key))
+	}
+	masterKey = key
+	return true, nil
+}
+
+func encryptionEnabled() bool {
+	return masterKey != nil
+}
+
+// userDataKey returns the data key of a user, created on first use when create is set
+func userDataKey(userID string, create bool) ([]byte, error) {
+	if key, ok := dataKeys.Load(userID); ok {
+		return key.([]byte), nil
+	}
+	if !encryptionEnabled() {
+		return nil, fmt.Errorf("%w: ENCRYPTION_KEY is not set, encrypted content cannot be read", model.ErrUnavailable)
+	}
+
+	key, err := loadDataKey(userID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		// Deleted with the account of the user, what it encrypted is lost
+		if !create {
+			return nil, fmt.Errorf("%w: the key of this encrypted content was deleted", model.ErrNotFound)
+		}
+		if key, err = createDataKey(userID); err != nil {
+			return nil, err
+		}
+	}
+	dataKeys.Store(userID, key)
+	return key, nil
+}
+
+// loadDataKey returns the stored data key of a user, nil when they have none
+func loadDataKey(userID string) ([]byte, error) {
+	var keys []struct {
+		WrappedKey string `json:"wrapped_key"`
+	}
+	if err := supabaseREST("GET", "user_data_keys?select=wrapped_key&user_id=eq."+url.QueryEscape(userID), nil, &keys); err != nil {
+		return nil, fmt.Errorf("failed to load data key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	key, err := envelope.Open(masterKey, keys[0].WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key of user %s: %w", userID, err)
+	}
+	return key, nil
+}
+
+func createDataKey(userID string) ([]byte, error) {
+	key, err := envelope.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := envelope.Seal(masterKeyID, masterKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	record := map[string]string{"user_id": userID, "wrapped_key": wrapped}
+	if err := supabaseREST("POST", "user_data_keys", record, nil); err != nil {
+		// Another request may have created the key first
+		if key, loadErr := loadDataKey(userID); loadErr == nil && key != nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("failed to save data key: %w", err)
+	}
+	return key, nil
+}
+
+// seal encrypts content with the data key of a user, it is returned as is when
+// encryption at rest is off
+func seal(userID, content string) (string, error) {
+	if !encryptionEnabled() {
+		return content, nil
+	}
+	key, err := userDataKey(userID, true)
+	if err != nil {
+		return "", err
+	}
+	return envelope.Seal(userID, key, []byte(content))
+}
+
+// unseal decrypts content sealed by seal, content stored in clear is returned as is.
+// The data key is the one it was sealed with, which stays with the deck when it is
+// transferred to another user.
+func unseal(content string) (string, error) {
+	if !envelope.IsSealed(content) {
+		return content, nil
+	}
+	keyID, err := envelope.KeyID(content)
+	if err != nil {
+		return "", err
+	}
+	key, err := userDataKey(keyID, false)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := envelope.Open(key, content)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealInput returns the answers of a deck to store, encrypted in Sealed when
+// encryption at rest is on
+func sealInput(userID string, data model.PitchDeckData) (model.PitchDeckData, error) {
+	// Sealed is only ever set here, never from a request
+	data.Sealed = ""
+	if !encryptionEnabled() {
+		return data, nil
+	}
+	content, err := json.Marshal(data)
+	if err != nil {
+		return data, fmt.Errorf("failed to encode answers: %w", err)
+	}
+	sealed, err := seal(userID, string(content))
+	if err != nil {
+		return data, fmt.Errorf("failed to encrypt answers: %w", err)
+	}
+	return model.PitchDeckData{Sealed: sealed}, nil
+}
+
+// unsealInput decrypts answers stored by sealInput
+func unsealInput(data *model.PitchDeckData) (*model.PitchDeckData, error) {
+	if data == nil || !envelope.IsSealed(data.Sealed) {
+		return data, nil
+	}
+	content, err := unseal(data.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt answers: %w", err)
+	}
+	var input model.PitchDeckData
+	if err := json.Unmarshal([]byte(content), &input); err != nil {
+		return nil, fmt.Errorf("failed to decode answers: %w", err)
+	}
+	return &input, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/internal/sentry"
+	"pitch-deck-generator/internal/tracing"
+)
+
+// failureContext describes a failed generation to the error tracker: its deck and
+// owner, where it failed and its trace
+func (s *PitchDeckService) failureContext(deckID, stage, code string) sentry.Context {
+	c := sentry.Context{
+		Tags:  map[string]string{"deck.id": deckID, "stage": stage, "code": code},
+		Extra: make(map[string]string),
+	}
+	if deck := s.runningDeck(deckID); deck != nil {
+		c.UserID = deck.UserID
+		if deck.TenantID != "" {
+			c.Tags["tenant.id"] = deck.TenantID
+		}
+	}
+	if traceID := tracing.TraceID(s.jobSpan(deckID)); traceID != "" {
+		c.Tags["trace_id"] = traceID
+	}
+	return c
+}
+
+// reportFailure reports a failed generation with what the LLM or the renderer
+// answered, the message of the error alone rarely tells why
+func (s *PitchDeckService) reportFailure(deckID, stage, code string, err error) {
+	if !sentry.Enabled() {
+		return
+	}
+	c := s.failureContext(deckID, stage, code)
+
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		c.Extra["renderer_output"] = renderErr.Stderr
+	}
+	var statusErr *resilience.StatusError
+	if errors.As(err, &statusErr) {
+		c.Tags["status_code"] = strconv.Itoa(statusErr.StatusCode)
+		c.Extra["response"] = statusErr.Message
+	}
+	sentry.CaptureError(err, c)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+)
+
+const (
+	// Characters per token of the generation prompts, a common approximation for
+	// English text with the Gemini tokenizer
+	charsPerToken = 4
+	// Tokens of a generated deck of 10 to 13 slides, measured on generated decks
+	estimatedOutputTokens = 3000
+	// Prompts above this size are slower and more likely to be cut or rejected
+	longPromptTokens = 4000
+	// Answers above this length are reported, the slides cannot show them anyway
+	longAnswerChars = 1500
+)
+
+// modelPrice is the price of a model in US dollars per million tokens
+type modelPrice struct {
+	input  float64
+	output float64
+}
+
+// Models decks can be generated with, and their price
+var modelPrices = map[string]modelPrice{
+	"gemini-1.5-flash-latest": {input: 0.075, output: 0.30},
+	"gemini-1.5-pro-latest":   {input: 1.25, output: 5.00},
+}
+
+// Estimate computes the size and cost of generating a deck from the answers, from
+// the prompt the generation would send, without calling the LLM
+func (s *PitchDeckService) Estimate(data model.PitchDeckData, userID string) (*model.GenerationEstimate, error) {
+	if data.ProjectID != "" {
+		project, err := getProject(data.ProjectID, userID, model.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+		applyProjectDefaults(&data, project)
+	}
+	params, err := generationParamsFor(data.Generation)
+	if err != nil {
+		return nil, err
+	}
+	promptName, err := deckPrompt(data.DeckType)
+	if err != nil {
+		return nil, err
+	}
+
+	// The images are downloaded during the generation, their URLs stand in for the paths
+	prompt, err := buildPrompt(data, map[string]string{
+		"logo":    data.CompanyLogo,
+		"team":    data.TeamPhoto,
+		"diagram": data.Diagram,
+	}, deckResearch{Competitors: competitorProfiles(data.Competitors)}, activePrompt(promptName).Template)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &model.GenerationEstimate{
+		Model: params.Model,
+		// The positioning of the competitors is researched during the generation
+		InputTokens:  estimateTokens(prompt) + len(data.Competitors)*maxCompetitorPositioning/charsPerToken,
+		OutputTokens: min(estimatedOutputTokens, params.MaxTokens),
+		// A generation uses one deck of the monthly allowance
+		Credits: 1,
+	}
+	price := modelPrices[params.Model]
+	cost := (float64(estimate.InputTokens)*price.input + float64(estimate.OutputTokens)*price.output) / 1e6
+	estimate.CostUSD = math.Round(cost*1e6) / 1e6
+
+	if estimate.InputTokens > longPromptTokens {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"The answers make a prompt of about %d tokens, shorter answers generate faster and more focused slides",
+			estimate.InputTokens))
+	}
+	long := longAnswers(data)
+	for _, field := range slices.Sorted(maps.Keys(long)) {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"%s is %d characters long, only its key points fit on a slide", field, long[field]))
+	}
+
+	usage, err := planUsage(userID)
+	if err != nil {
+		log.Printf("Failed to load plan of user %s for the estimate: %v", userID, err)
+		return estimate, nil
+	}
+	if err := allowedByPlan(usage.Plan, params); err != nil {
+		return nil, err
+	}
+	estimate.RemainingCredits = usage.RemainingDecks
+
+	return estimate, nil
+}
+
+// estimateTokens approximates the tokens of a text from its length
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// longAnswers returns the length of the answers over longAnswerChars, by field name
+func longAnswers(data model.PitchDeckData) map[string]int {
+	answers := map[string]string{
+		"bigIdea":           data.BigIdea,
+		"problem":           data.Problem,
+		"targetAudience":    data.TargetAudience,
+		"existingSolutions": data.ExistingSolutions,
+		"solution":          data.Solution,
+		"technology":        data.Technology,
+		"differentiators":   data.Differentiators,
+		"developmentPlan":   data.DevelopmentPlan,
+		"fundingUse":        data.FundingUse,
+		"revenueModel":      data.RevenueModel,
+		"traction":          data.Traction,
+		"nextMilestones":    data.NextMilestones,
+		"marketTrends":      data.MarketTrends,
+		"whyYou":            data.WhyYou,
+		"teamQualification": data.TeamQualification,
+		"keyTakeaways":      data.KeyTakeaways,
+	}
+
+	long := make(map[string]int)
+	for field, answer := range answers {
+		if n := utf8.RuneCountInString(answer); n > longAnswerChars {
+			long[field] = n
+		}
+	}
+	return long
+}
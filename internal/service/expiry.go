@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// expired tells whether an expiry time has passed, nil never expires
+func expired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !now.Before(*expiresAt)
+}
+
+// checkExpiry validates an expiry time set by a user
+func checkExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: the expiry time must be in the future", model.ErrInvalidInput)
+	}
+	return nil
+}
+
+// SetExpiry sets the time after which the public pages and share links of a deck
+// answer that it is gone. A nil time removes the expiry.
+func (s *PitchDeckService) SetExpiry(deckID, userID string, expiresAt *time.Time, revision int) error {
+	if err := checkExpiry(expiresAt); err != nil {
+		return err
+	}
+
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+	if err := s.reviseDeck(context.Background(), deck.ID, revision); err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{
+		"expires_at": expiresAt,
+	}
+	err = supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil)
+	deckCache.invalidate()
+	if err != nil {
+		return fmt.Errorf("failed to set deck expiry: %w", err)
+	}
+
+	recordAudit(deck.ID, userID, AuditExpiryChanged, map[string]interface{}{
+		"expires_at": expiresAt,
+	})
+	return nil
+}
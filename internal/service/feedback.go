@@ -0,0 +1,58 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+)
+
+const maxFeedbackComment = 2000
+
+// SubmitFeedback records the rating of a generated deck, or of one of its slides, by
+// a user it is shared with. Rating it again replaces the previous rating.
+func (s *PitchDeckService) SubmitFeedback(deckID, userID string, rating int, comment string, slide *int) (*model.DeckFeedback, error) {
+	if rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("%w: rating must be between 1 and 5", model.ErrInvalidInput)
+	}
+	if slide != nil && *slide < 1 {
+		return nil, fmt.Errorf("%w: slides are numbered from 1", model.ErrInvalidInput)
+	}
+	comment = strings.TrimSpace(comment)
+	if utf8.RuneCountInString(comment) > maxFeedbackComment {
+		return nil, fmt.Errorf("%w: comment must be at most %d characters", model.ErrInvalidInput, maxFeedbackComment)
+	}
+
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if deck.Status != "completed" {
+		return nil, fmt.Errorf("%w: only generated decks can be rated, the deck is %s", model.ErrConflict, deck.Status)
+	}
+
+	var saved []model.DeckFeedback
+	params := map[string]interface{}{
+		"p_deck_id": deck.ID,
+		"p_user_id": userID,
+		"p_slide":   slide,
+		"p_rating":  rating,
+		"p_comment": comment,
+	}
+	if err := supabaseREST("POST", "rpc/submit_deck_feedback", params, &saved); err != nil {
+		return nil, fmt.Errorf("failed to save feedback: %w", err)
+	}
+	if len(saved) == 0 {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+
+	if comment != "" && userID != deck.UserID {
+		notify(deck.UserID, NotificationCommentAdded, deck.ID, fmt.Sprintf("New comment on %s", deck.Name), map[string]interface{}{
+			"author_id": userID,
+			"rating":    rating,
+			"slide":     slide,
+		})
+	}
+	return &saved[0], nil
+}
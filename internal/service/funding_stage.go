@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+// stageField is an answer of the form a funding stage requires
+type stageField struct {
+	name  string
+	value func(data model.PitchDeckData) string
+}
+
+var (
+	bigIdeaField        = stageField{"bigIdea", func(d model.PitchDeckData) string { return d.BigIdea }}
+	problemField        = stageField{"problem", func(d model.PitchDeckData) string { return d.Problem }}
+	solutionField       = stageField{"solution", func(d model.PitchDeckData) string { return d.Solution }}
+	whyYouField         = stageField{"whyYou", func(d model.PitchDeckData) string { return d.WhyYou }}
+	targetAudienceField = stageField{"targetAudience", func(d model.PitchDeckData) string { return d.TargetAudience }}
+	fundingAmountField  = stageField{"fundingAmount", func(d model.PitchDeckData) string { return d.FundingAmount }}
+	fundingUseField     = stageField{"fundingUse", func(d model.PitchDeckData) string { return d.FundingUse }}
+	tractionField       = stageField{"traction", func(d model.PitchDeckData) string { return d.Traction }}
+	revenueModelField   = stageField{"revenueModel", func(d model.PitchDeckData) string { return d.RevenueModel }}
+	tamField            = stageField{"tam", func(d model.PitchDeckData) string { return d.TAM }}
+	milestonesField     = stageField{"nextMilestones", func(d model.PitchDeckData) string { return d.NextMilestones }}
+)
+
+// Answers required by each stage: the vision and the team from pre-seed, evidence of
+// product-market fit from seed and metrics at Series A
+var stageRequirements = map[string][]stageField{
+	"pre_seed": {bigIdeaField, problemField, solutionField, whyYouField},
+	"seed": {bigIdeaField, problemField, solutionField, whyYouField, targetAudienceField,
+		fundingAmountField, fundingUseField, tractionField},
+	"series_a": {problemField, solutionField, targetAudienceField, fundingAmountField, fundingUseField,
+		tractionField, revenueModelField, tamField, milestonesField},
+}
+
+// checkFundingStage rejects an unknown funding stage, and decks missing the answers
+// their stage requires
+func checkFundingStage(data model.PitchDeckData) error {
+	if data.FundingStage == "" {
+		return nil
+	}
+	stage, ok := prompts.FundingStages[data.FundingStage]
+	if !ok {
+		return fmt.Errorf("%w: unknown funding stage %q, expected pre_seed, seed or series_a",
+			model.ErrInvalidInput, data.FundingStage)
+	}
+
+	var missing []string
+	for _, field := range stageRequirements[stage.ID] {
+		if strings.TrimSpace(field.value(data)) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s decks require %s", model.ErrInvalidInput, stage.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+const (
+	// Temperatures accepted by the Gemini models
+	maxTemperature = 2.0
+	// Output shorter than this cuts the deck before its last slides
+	minOutputTokens = 1000
+)
+
+// generationParams are the resolved parameters of an LLM call
+type generationParams struct {
+	Model       string
+	Temperature float64
+	MaxTokens   int
+	// Grounds the answer in a Google search, for calls that need current facts
+	Search bool
+}
+
+// Parameters of the generations without settings, and of the imports and the intake
+var defaultGeneration = generationParams{Model: geminiModel, Temperature: 0.7, MaxTokens: 4000}
+
+// Named sets of parameters, to pick a style without tuning the temperature
+var generationPresets = map[string]generationParams{
+	"balanced": defaultGeneration,
+	"precise":  {Model: geminiModel, Temperature: 0.2, MaxTokens: 4000},
+	"creative": {Model: geminiModel, Temperature: 1.0, MaxTokens: 4000},
+}
+
+// generationParamsFor resolves the settings of a deck: the preset, then the fields
+// given. The limits of the plan are checked by checkGeneration.
+func generationParamsFor(settings model.GenerationSettings) (generationParams, error) {
+	params := defaultGeneration
+	if settings.Preset != "" {
+		preset, ok := generationPresets[strings.ToLower(settings.Preset)]
+		if !ok {
+			return params, fmt.Errorf("%w: unknown generation preset %q, expected balanced, precise or creative",
+				model.ErrInvalidInput, settings.Preset)
+		}
+		params = preset
+	}
+
+	if settings.Model != "" {
+		if _, ok := modelPrices[settings.Model]; !ok {
+			return params, fmt.Errorf("%w: unknown model %q, expected one of %s",
+				model.ErrInvalidInput, settings.Model, strings.Join(generationModels(), ", "))
+		}
+		params.Model = settings.Model
+	}
+	if settings.Temperature != nil {
+		if *settings.Temperature < 0 || *settings.Temperature > maxTemperature {
+			return params, fmt.Errorf("%w: temperature must be between 0 and %g",
+				model.ErrInvalidInput, maxTemperature)
+		}
+		params.Temperature = *settings.Temperature
+	}
+	if settings.MaxTokens != 0 {
+		if settings.MaxTokens < minOutputTokens {
+			return params, fmt.Errorf("%w: maxTokens must be at least %d to fit a deck",
+				model.ErrInvalidInput, minOutputTokens)
+		}
+		params.MaxTokens = settings.MaxTokens
+	}
+	return params, nil
+}
+
+// checkGeneration rejects the settings of a deck that are invalid or that the plan
+// of the user does not allow
+func checkGeneration(userID string, settings model.GenerationSettings) error {
+	params, err := generationParamsFor(settings)
+	if err != nil {
+		return err
+	}
+	usage, err := planUsage(userID)
+	if err != nil {
+		return err
+	}
+	return allowedByPlan(usage.Plan, params)
+}
+
+func allowedByPlan(plan model.Plan, params generationParams) error {
+	if !slices.Contains(plan.Models, params.Model) {
+		return fmt.Errorf("%w: the %s plan does not include %s, use %s",
+			model.ErrQuotaExceeded, plan.Name, params.Model, strings.Join(plan.Models, " or "))
+	}
+	if params.MaxTokens > plan.MaxOutputTokens {
+		return fmt.Errorf("%w: the %s plan allows up to %d output tokens",
+			model.ErrQuotaExceeded, plan.Name, plan.MaxOutputTokens)
+	}
+	return nil
+}
+
+// deckPrompt returns the name of the prompt generating a type of deck
+func deckPrompt(deckType string) (string, error) {
+	name, ok := prompts.DeckTypes[deckType]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown deck type %q, expected fundraising, sales, investor_update or grant_application",
+			model.ErrInvalidInput, deckType)
+	}
+	return name, nil
+}
+
+// generationModels returns the names of the models decks can be generated with
+func generationModels() []string {
+	models := make([]string, 0, len(modelPrices))
+	for name := range modelPrices {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+	return models
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Decks generated without an account from one client address per guestWindow
+	guestDecksPerAddress = 1
+	guestWindow          = 24 * time.Hour
+	// How long a guest deck is kept before it is deleted, unless it is claimed
+	guestRetention = 48 * time.Hour
+	// How often the expired guest decks are deleted
+	guestSweepInterval = time.Hour
+)
+
+// GuestService generates decks for visitors without an account. Guest decks are
+// watermarked as on the free plan, limited per client address and short lived: the
+// claim token returned with a deck attaches it to the account of the visitor once
+// they sign up.
+type GuestService struct {
+	decks *PitchDeckService
+}
+
+func NewGuestService(decks *PitchDeckService) *GuestService {
+	return &GuestService{
+		decks: decks,
+	}
+}
+
+// Start deletes the expired guest decks now and then at every interval, in the background
+func (s *GuestService) Start() {
+	go func() {
+		for {
+			s.Sweep()
+			time.Sleep(guestSweepInterval)
+		}
+	}()
+}
+
+// hashAddress keeps client addresses out of the database
+func hashAddress(clientIP string) string {
+	sum := sha256.Sum256([]byte("guest:" + clientIP))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create starts the generation of a deck owned by a new guest ID
+func (s *GuestService) Create(data model.PitchDeckData, clientIP string) (*model.GuestDeck, error) {
+	ipHash := hashAddress(clientIP)
+	since := time.Now().Add(-guestWindow).UTC().Format(time.RFC3339)
+
+	var recent []model.GuestDeck
+	path := fmt.Sprintf("guest_decks?select=deck_id&ip_hash=eq.%s&created_at=gt.%s", ipHash, url.QueryEscape(since))
+	if err := supabaseREST("GET", path, nil, &recent); err != nil {
+		return nil, err
+	}
+	if len(recent) >= guestDecksPerAddress {
+		return nil, fmt.Errorf("%w: sign up to create more decks", model.ErrQuotaExceeded)
+	}
+
+	// Projects belong to accounts
+	data.ProjectID = ""
+
+	guestID := uuid.New().String()
+	deck, err := s.decks.Create(context.Background(), data, guestID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	guest := model.GuestDeck{
+		DeckID:     deck.ID,
+		GuestID:    guestID,
+		ClaimToken: token,
+		IPHash:     ipHash,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(guestRetention),
+	}
+	if err := supabaseREST("POST", "guest_decks", guest, nil); err != nil {
+		return nil, fmt.Errorf("failed to save guest deck: %w", err)
+	}
+	return &guest, nil
+}
+
+// guestDeck returns the guest deck the claim token was issued for, while it is unclaimed
+func guestDeck(deckID, claimToken string) (*model.GuestDeck, error) {
+	var guests []model.GuestDeck
+	path := fmt.Sprintf("guest_decks?deck_id=eq.%s&claim_token=eq.%s", url.QueryEscape(deckID), url.QueryEscape(claimToken))
+	if err := supabaseREST("GET", path, nil, &guests); err != nil {
+		return nil, err
+	}
+	if len(guests) == 0 || guests[0].ClaimedBy != "" || time.Now().After(guests[0].ExpiresAt) {
+		return nil, fmt.Errorf("%w: guest deck not found, expired or already claimed", model.ErrNotFound)
+	}
+	return &guests[0], nil
+}
+
+// GetDeck returns a guest deck to the holder of its claim token
+func (s *GuestService) GetDeck(deckID, claimToken string) (*model.PitchDeckInfo, error) {
+	if _, err := guestDeck(deckID, claimToken); err != nil {
+		return nil, err
+	}
+	return s.decks.Get(deckID)
+}
+
+// Claim attaches a guest deck, its answers and its uploads to the account of the user
+func (s *GuestService) Claim(deckID, claimToken, userID string) (*model.PitchDeckInfo, error) {
+	var claimed []model.GuestDeck
+	params := map[string]string{"p_deck_id": deckID, "p_claim_token": claimToken, "p_user_id": userID}
+	if err := supabaseREST("POST", "rpc/claim_guest_deck", params, &claimed); err != nil {
+		return nil, fmt.Errorf("failed to claim deck: %w", err)
+	}
+	if len(claimed) == 0 {
+		return nil, fmt.Errorf("%w: guest deck not found, expired or already claimed", model.ErrNotFound)
+	}
+	// Guests belong to no tenant, the deck joins the tenant of the user
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		update := map[string]string{"tenant_id": tenantID}
+		if err := supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deckID), update, nil); err != nil {
+			return nil, fmt.Errorf("failed to move deck to tenant: %w", err)
+		}
+	}
+	deckCache.invalidate()
+
+	recordAudit(deckID, userID, AuditDeckClaimed, map[string]interface{}{
+		"guest_id": claimed[0].GuestID,
+	})
+	return s.decks.Get(deckID)
+}
+
+// Sweep deletes the guest decks that expired unclaimed. Their stored files are left
+// to the storage cleanup.
+func (s *GuestService) Sweep() {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var expired []model.GuestDeck
+	path := fmt.Sprintf("guest_decks?select=deck_id,guest_id&claimed_by=is.null&expires_at=lt.%s&limit=%d", url.QueryEscape(now), recordBatchSize)
+	if err := supabaseREST("GET", path, nil, &expired); err != nil {
+		log.Printf("Failed to list expired guest decks: %v", err)
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	ids := make([]string, len(expired))
+	guestIDs := make([]string, len(expired))
+	for i, guest := range expired {
+		ids[i] = guest.DeckID
+		guestIDs[i] = guest.GuestID
+	}
+	if err := supabaseREST("DELETE", "pitch_decks?id=in.("+strings.Join(ids, ",")+")", nil, nil); err != nil {
+		log.Printf("Failed to delete expired guest decks: %v", err)
+		return
+	}
+	if err := supabaseREST("DELETE", "user_files?user_id=in.("+strings.Join(guestIDs, ",")+")", nil, nil); err != nil {
+		log.Printf("Failed to delete uploads of expired guest decks: %v", err)
+	}
+	deckCache.invalidate()
+
+	for _, guest := range expired {
+		recordAudit(guest.DeckID, guest.GuestID, AuditDeckDeleted, map[string]interface{}{
+			"by": "retention",
+		})
+	}
+	log.Printf("Deleted %d expired guest decks", len(expired))
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	imageDownloadTimeout = 20 * time.Second
+	maxImageDownload     = 10 << 20
+	maxImageRedirects    = 3
+)
+
+// Extensions of the images of the answers downloaded for a deck
+var imageExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// storageImageClient fetches the images of the deck storage, which may be on the
+// internal network of self-hosted deployments
+var storageImageClient = &http.Client{
+	Timeout:       imageDownloadTimeout,
+	CheckRedirect: imageRedirectPolicy(true),
+}
+
+// allowedImageClient fetches the images of the other allowed hosts, from public
+// addresses only
+var allowedImageClient = &http.Client{
+	Timeout: imageDownloadTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: publicAddressOnly,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+	CheckRedirect: imageRedirectPolicy(false),
+}
+
+// downloadImage saves an image of the answers of a deck in its directory and returns
+// its URL, or an empty string when it cannot be used. Images are only downloaded from
+// the deck storage, where uploads go, and the hosts listed in IMAGE_ALLOWED_HOSTS.
+func (s *PitchDeckService) downloadImage(imageURL, deckDir, prefix string) string {
+	destPath, err := fetchImage(imageURL, deckDir, prefix)
+	if err != nil {
+		log.Printf("Failed to download image %s: %v", redactURL(imageURL), err)
+		return ""
+	}
+	log.Printf("Downloaded image %s to %s", redactURL(imageURL), destPath)
+	return imageURL
+}
+
+func fetchImage(imageURL, deckDir, prefix string) (string, error) {
+	u, err := url.Parse(imageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("not an http URL")
+	}
+	trusted, allowed := imageHostAllowed(u.Hostname())
+	if !allowed {
+		return "", fmt.Errorf("host %s is not allowed", u.Hostname())
+	}
+	client := allowedImageClient
+	if trusted {
+		client = storageImageClient
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxImageDownload {
+		return "", fmt.Errorf("larger than %d bytes", maxImageDownload)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("not an image: %q", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageDownload+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxImageDownload {
+		return "", fmt.Errorf("larger than %d bytes", maxImageDownload)
+	}
+	// The content must be the image it claims to be, SVG is text and not sniffed
+	if sniffed := http.DetectContentType(data); contentType != "image/svg+xml" && sniffed != contentType {
+		return "", fmt.Errorf("content is %s, not %s", sniffed, contentType)
+	}
+
+	destPath := filepath.Join(deckDir, prefix+ext)
+	if err := os.WriteFile(destPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %w", err)
+	}
+	return destPath, nil
+}
+
+// imageHostAllowed reports whether images are downloaded from a host, and whether it
+// is the deck storage. IMAGE_ALLOWED_HOSTS lists hosts (comma separated) allowed with
+// their subdomains.
+func imageHostAllowed(host string) (trusted, allowed bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, env := range []string{"SUPABASE_URL", "CDN_URL"} {
+		if storage, err := url.Parse(os.Getenv(env)); err == nil && storage.Host != "" && strings.EqualFold(storage.Hostname(), host) {
+			return true, true
+		}
+	}
+	for _, entry := range strings.Split(os.Getenv("IMAGE_ALLOWED_HOSTS"), ",") {
+		entry = strings.ToLower(strings.Trim(strings.TrimSpace(entry), "."))
+		if entry != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// imageRedirectPolicy follows redirects to allowed hosts only. The storage client
+// stays on the storage, the others would bypass the check of public addresses.
+func imageRedirectPolicy(storage bool) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxImageRedirects {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+		}
+		trusted, allowed := imageHostAllowed(req.URL.Hostname())
+		if !allowed || trusted != storage {
+			return fmt.Errorf("redirect to %s is not allowed", req.URL.Hostname())
+		}
+		return nil
+	}
+}
+
+// redactURL drops the query of a URL for logs, it may hold tokens
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
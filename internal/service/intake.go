@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+
+	"github.com/google/uuid"
+)
+
+// Number of past messages given to the LLM at each turn
+const intakeHistoryLength = 12
+
+// intakeStep is one question of the intake and the form fields it fills. The
+// question is answered once any of its fields is, or its required field when set.
+type intakeStep struct {
+	Question string
+	Fields   []string
+	Required string
+}
+
+// intakeSteps follow the order of the deck form
+var intakeSteps = []intakeStep{
+	{
+		Question: "What is the name of your project, and what is the big idea behind it in one or two sentences?",
+		Fields:   []string{"projectName", "bigIdea"},
+		Required: "projectName",
+	},
+	{
+		Question: "What problem are you solving, and who experiences it the most?",
+		Fields:   []string{"problem", "targetAudience"},
+	},
+	{
+		Question: "How do people deal with this problem today? Which existing solutions or competitors are there?",
+		Fields:   []string{"existingSolutions"},
+	},
+	{
+		Question: "What is your solution, and what technology does it rely on?",
+		Fields:   []string{"solution", "technology"},
+	},
+	{
+		Question: "What makes you different from the alternatives, and what does your development plan look like?",
+		Fields:   []string{"differentiators", "developmentPlan"},
+	},
+	{
+		Question: "How big is your market? Share your TAM, SAM and SOM if you have them, your industry and the trends you ride.",
+		Fields:   []string{"marketSize", "tam", "sam", "som", "industry", "marketTrends", "targetNiche"},
+	},
+	{
+		Question: "How much are you raising and what will the money be used for? Mention the valuation and the instrument if you know them.",
+		Fields:   []string{"fundingAmount", "fundingUse", "valuation", "investmentStructure"},
+	},
+	{
+		Question: "Who is on the team? Give names, roles and relevant experience, and tell me why you are the right people for this.",
+		Fields:   []string{"teamMembers", "whyYou", "teamQualification"},
+	},
+	{
+		Question: "How can investors reach you? Email, LinkedIn or other socials.",
+		Fields:   []string{"contactInfo"},
+	},
+	{
+		Question: "Last one: what are the key takeaways you want investors to remember?",
+		Fields:   []string{"keyTakeaways"},
+	},
+}
+
+const (
+	intakeGreeting   = "Hi! I'll ask you a few questions to build your pitch deck, one at a time. "
+	intakeCompletion = "Thanks, I have everything I need. You can generate your deck whenever you are ready."
+)
+
+// intakeFields lists the form fields the intake may fill
+var intakeFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	for _, step := range intakeSteps {
+		for _, field := range step.Fields {
+			fields[field] = true
+		}
+	}
+	return fields
+}()
+
+// IntakeService runs conversational intakes and generates decks from their answers
+type IntakeService struct {
+	decks *PitchDeckService
+}
+
+func NewIntakeService(decks *PitchDeckService) *IntakeService {
+	return &IntakeService{
+		decks: decks,
+	}
+}
+
+// fieldFilled reports whether a form field holds an answer
+func fieldFilled(form map[string]interface{}, field string) bool {
+	switch value := form[field].(type) {
+	case string:
+		return strings.TrimSpace(value) != ""
+	case []interface{}:
+		return len(value) > 0
+	case map[string]interface{}:
+		for key := range value {
+			if fieldFilled(value, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextIntakeStep returns the first question without any answer, or the number of
+// questions when everything was answered
+func nextIntakeStep(data model.PitchDeckData) int {
+	var form map[string]interface{}
+	jsonData, _ := json.Marshal(data)
+	json.Unmarshal(jsonData, &form)
+
+	for i, step := range intakeSteps {
+		answered := false
+		for _, field := range step.Fields {
+			answered = answered || fieldFilled(form, field)
+		}
+		if step.Required != "" {
+			answered = fieldFilled(form, step.Required)
+		}
+		if !answered {
+			return i
+		}
+	}
+	return len(intakeSteps)
+}
+
+// applyIntakeUpdates merges the fields extracted by the LLM into the form. Fields
+// are applied one by one so a malformed value does not discard the others.
+func applyIntakeUpdates(data *model.PitchDeckData, updates map[string]json.RawMessage) {
+	for field, value := range updates {
+		if !intakeFields[field] {
+			continue
+		}
+		patch, _ := json.Marshal(map[string]json.RawMessage{field: value})
+		if err := json.Unmarshal(patch, data); err != nil {
+			// Figures are sometimes returned as numbers for text fields
+			text, _ := json.Marshal(strings.Trim(string(value), `"`))
+			patch, _ = json.Marshal(map[string]json.RawMessage{field: text})
+			json.Unmarshal(patch, data)
+		}
+	}
+}
+
+func intakeQuestion(step int) string {
+	if step >= len(intakeSteps) {
+		return intakeCompletion
+	}
+	return intakeSteps[step].Question
+}
+
+func (s *IntakeService) StartSession(userID string) (*model.IntakeSession, error) {
+	now := time.Now()
+	session := model.IntakeSession{
+		ID:     uuid.New().String(),
+		UserID: userID,
+		Messages: []model.IntakeMessage{
+			{Role: "assistant", Content: intakeGreeting + intakeQuestion(0)},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := supabaseREST("POST", "intake_sessions", session, nil); err != nil {
+		return nil, fmt.Errorf("failed to save intake session: %w", err)
+	}
+	return &session, nil
+}
+
+func (s *IntakeService) GetSession(sessionID, userID string) (*model.IntakeSession, error) {
+	var sessions []model.IntakeSession
+	if err := supabaseREST("GET", "intake_sessions?id=eq."+url.QueryEscape(sessionID), nil, &sessions); err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, fmt.Errorf("%w: intake session not found", model.ErrNotFound)
+	}
+	if sessions[0].UserID != userID {
+		return nil, fmt.Errorf("%w: intake session belongs to another user", model.ErrForbidden)
+	}
+	return &sessions[0], nil
+}
+
+// Reply records an answer of the founder, extracts it into the form and asks the next question
+func (s *IntakeService) Reply(sessionID, userID, message string) (*model.IntakeSession, error) {
+	session, err := s.GetSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.DeckID != "" {
+		return nil, fmt.Errorf("%w: a deck was already generated from this session", model.ErrConflict)
+	}
+
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, fmt.Errorf("%w: message is required", model.ErrInvalidInput)
+	}
+
+	// Answers given after completion may still refine the last question
+	current := min(session.Step, len(intakeSteps)-1)
+
+	reply, err := s.runTurn(session, current, message)
+	if err != nil {
+		log.Printf("Intake turn of session %s failed, keeping the raw answer: %v", session.ID, err)
+		for _, field := range intakeSteps[current].Fields {
+			text, _ := json.Marshal(message)
+			patch, _ := json.Marshal(map[string]json.RawMessage{field: text})
+			if json.Unmarshal(patch, &session.Data) == nil {
+				break
+			}
+		}
+	}
+
+	session.Messages = append(session.Messages, model.IntakeMessage{Role: "founder", Content: message})
+	session.Step = nextIntakeStep(session.Data)
+	session.Complete = session.Step == len(intakeSteps)
+	if reply == "" {
+		reply = intakeQuestion(session.Step)
+	}
+	session.Messages = append(session.Messages, model.IntakeMessage{Role: "assistant", Content: reply})
+	session.UpdatedAt = time.Now()
+
+	update := map[string]interface{}{
+		"data":       session.Data,
+		"messages":   session.Messages,
+		"step":       session.Step,
+		"complete":   session.Complete,
+		"updated_at": session.UpdatedAt,
+	}
+	if err := supabaseREST("PATCH", "intake_sessions?id=eq."+url.QueryEscape(session.ID), update, nil); err != nil {
+		return nil, fmt.Errorf("failed to save intake session: %w", err)
+	}
+	return session, nil
+}
+
+// AttachLogo sets the company logo of the deck, e.g. an image sent during the conversation
+func (s *IntakeService) AttachLogo(sessionID, userID, logoURL string) (*model.IntakeSession, error) {
+	session, err := s.GetSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.DeckID != "" {
+		return nil, fmt.Errorf("%w: a deck was already generated from this session", model.ErrConflict)
+	}
+	if !strings.HasPrefix(logoURL, "http") {
+		return nil, fmt.Errorf("%w: logo must be an uploaded image URL", model.ErrInvalidInput)
+	}
+
+	session.Data.CompanyLogo = logoURL
+	session.UpdatedAt = time.Now()
+
+	update := map[string]interface{}{
+		"data":       session.Data,
+		"updated_at": session.UpdatedAt,
+	}
+	if err := supabaseREST("PATCH", "intake_sessions?id=eq."+url.QueryEscape(session.ID), update, nil); err != nil {
+		return nil, fmt.Errorf("failed to save intake session: %w", err)
+	}
+	return session, nil
+}
+
+// runTurn asks the LLM to extract the answer into the form and to phrase the next question
+func (s *IntakeService) runTurn(session *model.IntakeSession, current int, answer string) (string, error) {
+	collected, err := json.Marshal(session.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal answers: %w", err)
+	}
+
+	var remaining []string
+	for _, step := range intakeSteps[current+1:] {
+		remaining = append(remaining, step.Question)
+	}
+
+	var history []prompts.IntakeMessage
+	for _, msg := range session.Messages[max(0, len(session.Messages)-intakeHistoryLength):] {
+		history = append(history, prompts.IntakeMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	prompt, err := prompts.GenerateIntakePrompt(prompts.IntakeTurnData{
+		CollectedJSON:      string(collected),
+		CurrentQuestion:    intakeSteps[current].Question,
+		CurrentFields:      intakeSteps[current].Fields,
+		RemainingQuestions: remaining,
+		History:            history,
+		Answer:             answer,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	text, err := callLLM(context.Background(), prompt, defaultGeneration)
+	if err != nil {
+		return "", err
+	}
+
+	var turn struct {
+		Updates map[string]json.RawMessage `json:"updates"`
+		Reply   string                     `json:"reply"`
+	}
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```")
+	text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	if err := json.Unmarshal([]byte(text), &turn); err != nil {
+		return "", fmt.Errorf("failed to parse intake turn: %w", err)
+	}
+	if len(turn.Updates) == 0 {
+		return "", fmt.Errorf("no answer extracted")
+	}
+
+	applyIntakeUpdates(&session.Data, turn.Updates)
+	return strings.TrimSpace(turn.Reply), nil
+}
+
+// Generate starts the generation of the deck once every question was answered
+func (s *IntakeService) Generate(sessionID, userID string) (*model.PitchDeckInfo, error) {
+	session, err := s.GetSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if session.DeckID != "" {
+		return nil, fmt.Errorf("%w: a deck was already generated from this session", model.ErrConflict)
+	}
+	if !session.Complete || strings.TrimSpace(session.Data.ProjectName) == "" {
+		return nil, fmt.Errorf("%w: the intake is not complete, next question: %s",
+			model.ErrInvalidInput, intakeQuestion(nextIntakeStep(session.Data)))
+	}
+
+	deck, err := s.decks.Create(context.Background(), session.Data, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	update := map[string]string{"deck_id": deck.ID}
+	if err := supabaseREST("PATCH", "intake_sessions?id=eq."+url.QueryEscape(session.ID), update, nil); err != nil {
+		log.Printf("Failed to link deck %s to intake session %s: %v", deck.ID, session.ID, err)
+	}
+	return deck, nil
+}
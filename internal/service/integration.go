@@ -0,0 +1,156 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/notion"
+	"pitch-deck-generator/internal/slides"
+)
+
+// IntegrationService pushes generated decks to third-party workspaces
+type IntegrationService struct {
+	decks  *PitchDeckService
+	notion *notion.Client
+}
+
+// errNotionUnavailable is returned when the Notion integration is not configured
+var errNotionUnavailable = fmt.Errorf("%w: the notion integration is not configured", model.ErrUnavailable)
+
+// notionConnection is a row of the notion_connections table. The access token is
+// sealed with the data key of the user when encryption at rest is on.
+type notionConnection struct {
+	UserID        string    `json:"user_id"`
+	AccessToken   string    `json:"access_token"`
+	WorkspaceID   string    `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	BotID         string    `json:"bot_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// NewIntegrationService creates the service, the Notion client may be nil when
+// the integration is not configured
+func NewIntegrationService(decks *PitchDeckService, notionClient *notion.Client) *IntegrationService {
+	return &IntegrationService{
+		decks:  decks,
+		notion: notionClient,
+	}
+}
+
+func (s *IntegrationService) NotionAuthorizeURL(state string) (string, error) {
+	if s.notion == nil {
+		return "", errNotionUnavailable
+	}
+	return s.notion.AuthorizeURL(state), nil
+}
+
+// ConnectNotion exchanges the OAuth code and stores the workspace token for the user
+func (s *IntegrationService) ConnectNotion(userID, code string) error {
+	if s.notion == nil {
+		return errNotionUnavailable
+	}
+	if code == "" {
+		return fmt.Errorf("%w: missing authorization code", model.ErrInvalidInput)
+	}
+
+	conn, err := s.notion.ExchangeCode(code)
+	if err != nil {
+		return err
+	}
+	token, err := seal(userID, conn.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt notion token: %w", err)
+	}
+
+	record := notionConnection{
+		UserID:        userID,
+		AccessToken:   token,
+		WorkspaceID:   conn.WorkspaceID,
+		WorkspaceName: conn.WorkspaceName,
+		BotID:         conn.BotID,
+		CreatedAt:     time.Now(),
+	}
+
+	// Replace any previous connection of the user
+	if err := supabaseREST("DELETE", "notion_connections?user_id=eq."+url.QueryEscape(userID), nil, nil); err != nil {
+		return fmt.Errorf("failed to remove previous notion connection: %w", err)
+	}
+	if err := supabaseREST("POST", "notion_connections", record, nil); err != nil {
+		return fmt.Errorf("failed to save notion connection: %w", err)
+	}
+
+	return nil
+}
+
+// ExportToNotion creates the deck in the user's Notion workspace and returns the page URL
+func (s *IntegrationService) ExportToNotion(deckID, userID string, opts model.NotionExportOptions) (string, error) {
+	if s.notion == nil {
+		return "", errNotionUnavailable
+	}
+	if opts.Mode == "" {
+		opts.Mode = "pages"
+	}
+	if opts.Mode != "pages" && opts.Mode != "toggles" {
+		return "", fmt.Errorf("%w: mode must be pages or toggles", model.ErrInvalidInput)
+	}
+
+	deck, err := s.decks.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return "", err
+	}
+
+	var conns []notionConnection
+	if err := supabaseREST("GET", "notion_connections?user_id=eq."+url.QueryEscape(userID), nil, &conns); err != nil {
+		return "", fmt.Errorf("failed to load notion connection: %w", err)
+	}
+	if len(conns) == 0 {
+		return "", fmt.Errorf("%w: notion workspace not connected", model.ErrInvalidInput)
+	}
+	token, err := unseal(conns[0].AccessToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt notion token: %w", err)
+	}
+
+	markdown, err := s.decks.loadMarkdown(deck)
+	if err != nil {
+		return "", err
+	}
+	_, deckSlides := slides.Split(markdown)
+
+	parentID := opts.ParentPageID
+	if parentID == "" {
+		if parentID, err = s.notion.FirstSharedPage(token); err != nil {
+			return "", fmt.Errorf("%w: %v", model.ErrInvalidInput, err)
+		}
+	}
+
+	if opts.Mode == "toggles" {
+		var children []notion.Block
+		for i, slide := range deckSlides {
+			children = append(children, notion.Toggle(slideLabel(i, slide), notion.FromMarkdown(slide)))
+		}
+		_, pageURL, err := s.notion.CreatePage(token, parentID, deck.Name, children)
+		return pageURL, err
+	}
+
+	deckPageID, pageURL, err := s.notion.CreatePage(token, parentID, deck.Name, nil)
+	if err != nil {
+		return "", err
+	}
+	for i, slide := range deckSlides {
+		if _, _, err := s.notion.CreatePage(token, deckPageID, slideLabel(i, slide), notion.FromMarkdown(slide)); err != nil {
+			return pageURL, fmt.Errorf("failed to export slide %d: %w", i+1, err)
+		}
+	}
+
+	return pageURL, nil
+}
+
+func slideLabel(index int, slide string) string {
+	if title := slides.Title(slide); title != "" {
+		return fmt.Sprintf("%d. %s", index+1, title)
+	}
+	return fmt.Sprintf("Slide %d", index+1)
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRetentionDays   = 7
+	defaultJanitorInterval = time.Hour
+
+	// Working directories of decks that are no longer processing are kept a little
+	// while, so a failed generation can still be inspected on the server
+	tempGracePeriod = time.Hour
+
+	// Records looked up per request to the database
+	recordBatchSize = 100
+)
+
+// Space reclaimed by the janitor, published on the admin metrics endpoint
+var janitorMetrics = expvar.NewMap("janitor")
+
+// Janitor removes the local files of decks once they are no longer needed: working
+// directories after the generation ended, and rendered outputs older than the
+// retention period that have a copy in storage
+type Janitor struct {
+	decks     *PitchDeckService
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewJanitor configures the janitor from RETENTION_DAYS and JANITOR_INTERVAL
+func NewJanitor(decks *PitchDeckService) *Janitor {
+	days, err := strconv.Atoi(os.Getenv("RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = defaultRetentionDays
+	}
+	interval, err := time.ParseDuration(os.Getenv("JANITOR_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+
+	return &Janitor{
+		decks:     decks,
+		retention: time.Duration(days) * 24 * time.Hour,
+		interval:  interval,
+	}
+}
+
+// Start runs a sweep now and then at every interval, in the background
+func (j *Janitor) Start() {
+	go func() {
+		for {
+			j.Sweep()
+			time.Sleep(j.interval)
+		}
+	}()
+}
+
+// localEntry is a file or directory of a deck found on disk
+type localEntry struct {
+	path   string
+	deckID string
+	ext    string
+	size   int64
+	age    time.Duration
+	isDir  bool
+}
+
+// Sweep removes the files that are no longer needed and records the space reclaimed
+func (j *Janitor) Sweep() {
+	j.sweep(j.retention, tempGracePeriod)
+}
+
+// EmergencySweep frees disk space when it runs low: rendered outputs are removed
+// as soon as they have a copy in storage, and working directories as soon as their
+// generation ended
+func (j *Janitor) EmergencySweep() {
+	janitorMetrics.Add("emergency_sweeps", 1)
+	j.sweep(0, 0)
+}
+
+// sweep removes the outputs older than retention and the working directories of
+// generations that ended more than grace ago
+func (j *Janitor) sweep(retention, grace time.Duration) {
+	if removed, reclaimed := removeExpiredUploads(); removed > 0 {
+		janitorMetrics.Add("removed_entries", int64(removed))
+		janitorMetrics.Add("reclaimed_bytes", reclaimed)
+		log.Printf("Janitor: removed %d expired uploads, %d bytes reclaimed", removed, reclaimed)
+	}
+	if removed, reclaimed := removeQuarantined(j.retention); removed > 0 {
+		janitorMetrics.Add("removed_entries", int64(removed))
+		janitorMetrics.Add("reclaimed_bytes", reclaimed)
+		log.Printf("Janitor: removed %d quarantined files, %d bytes reclaimed", removed, reclaimed)
+	}
+
+	entries := append(scanDeckEntries("temp"), scanDeckEntries("outputs")...)
+	if len(entries) == 0 {
+		return
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if !seen[entry.deckID] {
+			seen[entry.deckID] = true
+			ids = append(ids, entry.deckID)
+		}
+	}
+
+	decks, err := deckStates(ids)
+	if err != nil {
+		log.Printf("Janitor: failed to load deck states, skipping sweep: %v", err)
+		janitorMetrics.Add("errors", 1)
+		return
+	}
+
+	var removed int
+	var reclaimed int64
+	for _, entry := range entries {
+		deck, found := decks[entry.deckID]
+		if !j.removable(entry, deck, found, retention, grace) {
+			continue
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			log.Printf("Janitor: failed to remove %s: %v", entry.path, err)
+			janitorMetrics.Add("errors", 1)
+			continue
+		}
+		removed++
+		reclaimed += entry.size
+	}
+
+	janitorMetrics.Add("removed_entries", int64(removed))
+	janitorMetrics.Add("reclaimed_bytes", reclaimed)
+	lastSweep := new(expvar.String)
+	lastSweep.Set(time.Now().UTC().Format(time.RFC3339))
+	janitorMetrics.Set("last_sweep", lastSweep)
+
+	if removed > 0 {
+		log.Printf("Janitor: removed %d files and directories, %d bytes reclaimed", removed, reclaimed)
+	}
+}
+
+func (j *Janitor) removable(entry localEntry, deck model.PitchDeckInfo, found bool, retention, grace time.Duration) bool {
+	if entry.isDir {
+		// A generation interrupted by a restart stays processing, its directory
+		// is removed once it is older than the retention period
+		if entry.age > j.retention {
+			return true
+		}
+		return found && deck.Status != "processing" && entry.age > grace
+	}
+
+	if !found || entry.age <= retention {
+		return false
+	}
+	switch entry.ext {
+	case ".pdf":
+		return deck.PdfURL != ""
+	case ".html":
+		return deck.HtmlURL != ""
+	}
+	return false
+}
+
+// scanDeckEntries lists the entries of dir named after a deck ID, e.g. temp/<id> or outputs/<id>.pdf
+func scanDeckEntries(dir string) []localEntry {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Janitor: failed to read %s: %v", dir, err)
+		}
+		return nil
+	}
+
+	var entries []localEntry
+	for _, dirEntry := range dirEntries {
+		ext := strings.ToLower(filepath.Ext(dirEntry.Name()))
+		deckID := strings.TrimSuffix(dirEntry.Name(), filepath.Ext(dirEntry.Name()))
+		if _, err := uuid.Parse(deckID); err != nil {
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, dirEntry.Name())
+		size := info.Size()
+		if info.IsDir() {
+			size = dirSize(path)
+		}
+
+		entries = append(entries, localEntry{
+			path:   path,
+			deckID: deckID,
+			ext:    ext,
+			size:   size,
+			age:    time.Since(info.ModTime()),
+			isDir:  info.IsDir(),
+		})
+	}
+	return entries
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// deckStates loads the status and storage URLs of the decks, in batches
+func deckStates(ids []string) (map[string]model.PitchDeckInfo, error) {
+	states := make(map[string]model.PitchDeckInfo)
+	for start := 0; start < len(ids); start += recordBatchSize {
+		batch := ids[start:min(start+recordBatchSize, len(ids))]
+
+		var decks []model.PitchDeckInfo
+		path := fmt.Sprintf("pitch_decks?select=id,status,pdf_url,html_url,markdown_url&id=in.(%s)", strings.Join(batch, ","))
+		if err := supabaseREST("GET", path, nil, &decks); err != nil {
+			return nil, err
+		}
+		for _, deck := range decks {
+			states[deck.ID] = deck
+		}
+	}
+	return states, nil
+}
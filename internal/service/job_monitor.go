@@ -0,0 +1,55 @@
+package service
+
+import (
+	"expvar"
+	"os"
+	"time"
+)
+
+const (
+	defaultHeartbeatInterval = 30 * time.Second
+	defaultJobStaleAfter     = 2 * time.Minute
+)
+
+// Jobs recovered from other instances, published on the admin metrics endpoint
+var jobMetrics = expvar.NewMap("jobs")
+
+// JobMonitor sends the heartbeats of the jobs of this instance and recovers the jobs
+// of instances that stopped, or crashed, before finishing them
+type JobMonitor struct {
+	decks      *PitchDeckService
+	interval   time.Duration
+	staleAfter time.Duration
+}
+
+// NewJobMonitor configures the monitor from JOB_HEARTBEAT_INTERVAL and JOB_STALE_AFTER
+func NewJobMonitor(decks *PitchDeckService) *JobMonitor {
+	interval, err := time.ParseDuration(os.Getenv("JOB_HEARTBEAT_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	staleAfter, err := time.ParseDuration(os.Getenv("JOB_STALE_AFTER"))
+	if err != nil || staleAfter <= 0 {
+		staleAfter = defaultJobStaleAfter
+	}
+	// A few heartbeats may be missed before a job is considered lost
+	staleAfter = max(staleAfter, 3*interval)
+
+	return &JobMonitor{
+		decks:      decks,
+		interval:   interval,
+		staleAfter: staleAfter,
+	}
+}
+
+// Start recovers the jobs left by the last shutdown now, then sends heartbeats and
+// looks for stuck jobs at every interval, in the background
+func (m *JobMonitor) Start() {
+	go func() {
+		for {
+			m.decks.RecoverJobs(m.staleAfter)
+			time.Sleep(m.interval)
+			m.decks.heartbeat()
+		}
+	}()
+}
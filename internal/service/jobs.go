@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/progress"
+	"pitch-deck-generator/internal/sentry"
+	"pitch-deck-generator/internal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Generations that crashed their instance this many times are not resumed again
+const maxJobAttempts = 3
+
+// errInterrupted is recorded on decks whose generation could not be resumed
+var errInterrupted = errors.New("generation interrupted")
+
+// errPanicked is recorded on decks whose generation panicked
+var errPanicked = errors.New("generation crashed")
+
+// instanceID identifies the jobs run by this process in the deck_jobs table
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return host + "-" + uuid.New().String()[:8]
+}()
+
+// claimedJob is a job handed to this instance by claim_deck_jobs
+type claimedJob struct {
+	DeckID   string `json:"deck_id"`
+	State    string `json:"state"`
+	Attempts int    `json:"attempts"`
+}
+
+// acceptingJobs rejects new generations once the server is shutting down, or while
+// it is low on disk space
+func (s *PitchDeckService) acceptingJobs() error {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	if s.draining {
+		return fmt.Errorf("%w: the server is restarting, try again in a moment", model.ErrUnavailable)
+	}
+	if lowDiskSpace.Load() {
+		diskMetrics.Add("rejected_generations", 1)
+		return errLowDiskSpace
+	}
+	return nil
+}
+
+// startJob runs the generation of a deck in the background, in a trace of its own
+// given to the job, and records the durations of its steps on the deck. Its progress
+// channel is closed once the job is over, and a job that panics fails its deck. A
+// deck accepted just before the shutdown started is queued instead, to be resumed at
+// the next start.
+func (s *PitchDeckService) startJob(deck *model.PitchDeckInfo, job func(ctx context.Context)) {
+	s.jobsMu.Lock()
+	if s.draining {
+		s.jobsMu.Unlock()
+		s.queue(deck.ID)
+		return
+	}
+	ctx, span := tracing.Start(context.Background(), "deck.generate",
+		attribute.String("deck.id", deck.ID), attribute.String("user.id", deck.UserID))
+	ctx, timings := withStepTimings(ctx)
+	s.jobs.Add(1)
+	s.running[deck.ID] = deck
+	if span != nil {
+		s.spans[deck.ID] = span
+	}
+	s.jobsMu.Unlock()
+
+	go func() {
+		started := time.Now()
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				s.failPanickedJob(deck.ID, recovered)
+			}
+			recordStepTimings(deck.ID, timings, time.Since(started))
+			recordJobState(deck.ID, "finished")
+			s.progress.CloseChannel(deck.ID)
+
+			s.jobsMu.Lock()
+			delete(s.running, deck.ID)
+			delete(s.spans, deck.ID)
+			s.jobsMu.Unlock()
+			span.End()
+			s.jobs.Done()
+		}()
+
+		recordJobState(deck.ID, "running")
+		job(ctx)
+	}()
+}
+
+// failPanickedJob fails the deck of a job that panicked, rather than leaving it
+// processing with its progress stream open. The job is not resumed, it would panic
+// again.
+func (s *PitchDeckService) failPanickedJob(deckID string, recovered interface{}) {
+	log.Printf("Generation of deck %s panicked: %v\n%s", deckID, recovered, debug.Stack())
+	jobMetrics.Add("panics", 1)
+	s.jobSpan(deckID).SetAttributes(attribute.Bool("panic", true))
+	sentry.CapturePanic(recovered, s.failureContext(deckID, stageUnknown, "internal_error"))
+
+	// The failure is recorded like the others, any panic of it is not recovered
+	s.handleError(deckID, stageUnknown, "Generation failed", fmt.Errorf("%w: %v", errPanicked, recovered))
+}
+
+// jobSpan returns the span of the running generation of a deck, a span recording
+// nothing when none
+func (s *PitchDeckService) jobSpan(deckID string) trace.Span {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if span, ok := s.spans[deckID]; ok {
+		return span
+	}
+	return trace.SpanFromContext(context.Background())
+}
+
+// runningDeck returns the deck of a running generation, nil when none
+func (s *PitchDeckService) runningDeck(deckID string) *model.PitchDeckInfo {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	return s.running[deckID]
+}
+
+// recordJobState persists a state transition of the job of a deck
+func recordJobState(deckID, state string) {
+	params := map[string]string{
+		"p_deck_id":  deckID,
+		"p_instance": instanceID,
+		"p_state":    state,
+	}
+	if err := supabaseWrite("POST", "rpc/transition_deck_job", params); err != nil {
+		log.Printf("Failed to record job of deck %s as %s: %v", deckID, state, err)
+	}
+}
+
+// queue marks a deck whose generation did not complete as queued
+func (s *PitchDeckService) queue(deckID string) {
+	recordJobState(deckID, "queued")
+	if err := s.UpdateStatus(deckID, "queued"); err != nil {
+		log.Printf("Failed to queue deck %s: %v", deckID, err)
+	}
+}
+
+// heartbeat tells the other instances the jobs of this one are still running
+func (s *PitchDeckService) heartbeat() {
+	s.jobsMu.Lock()
+	deckIDs := make([]string, 0, len(s.running))
+	for deckID := range s.running {
+		deckIDs = append(deckIDs, deckID)
+	}
+	s.jobsMu.Unlock()
+
+	if len(deckIDs) == 0 {
+		return
+	}
+	params := map[string]interface{}{"p_deck_ids": deckIDs}
+	if err := supabaseREST("POST", "rpc/heartbeat_deck_jobs", params, nil); err != nil {
+		log.Printf("Failed to send job heartbeat: %v", err)
+	}
+}
+
+// Shutdown stops accepting generations and waits for the running ones until ctx is
+// done. Decks still generating then are queued, and the progress streams are told
+// the server is restarting.
+func (s *PitchDeckService) Shutdown(ctx context.Context) {
+	s.jobsMu.Lock()
+	s.draining = true
+	s.jobsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("All generations completed")
+	case <-ctx.Done():
+		s.jobsMu.Lock()
+		interrupted := make([]string, 0, len(s.running))
+		for deckID := range s.running {
+			interrupted = append(interrupted, deckID)
+		}
+		s.jobsMu.Unlock()
+
+		log.Printf("Queuing %d generations still running", len(interrupted))
+		for _, deckID := range interrupted {
+			s.queue(deckID)
+		}
+	}
+
+	s.progress.CloseAll(progress.ProgressUpdate{
+		Status:  "restarting",
+		Message: "The server is restarting, the generation resumes shortly",
+	})
+}
+
+// RecoverJobs claims the jobs queued by a shutdown and the running jobs without a
+// heartbeat for staleAfter, lost with the instance running them, and resumes them.
+// Jobs that crashed their instance maxJobAttempts times are marked as failed.
+func (s *PitchDeckService) RecoverJobs(staleAfter time.Duration) {
+	params := map[string]interface{}{
+		"p_instance":    instanceID,
+		"p_stale_after": fmt.Sprintf("%d seconds", int(staleAfter.Seconds())),
+	}
+	var jobs []claimedJob
+	if err := supabaseREST("POST", "rpc/claim_deck_jobs", params, &jobs); err != nil {
+		log.Printf("Failed to claim jobs: %v", err)
+		jobMetrics.Add("errors", 1)
+		return
+	}
+
+	for _, job := range jobs {
+		deck, err := s.repo.Get(context.Background(), job.DeckID)
+		if err != nil {
+			log.Printf("Failed to load deck %s of claimed job: %v", job.DeckID, err)
+			continue
+		}
+
+		// The instance stopped after the generation ended but before the job did
+		if deck.Status == "completed" || deck.Status == "failed" {
+			recordJobState(deck.ID, "finished")
+			continue
+		}
+
+		if job.State == "running" && job.Attempts >= maxJobAttempts {
+			log.Printf("Giving up on deck %s after %d attempts", deck.ID, job.Attempts)
+			jobMetrics.Add("abandoned", 1)
+			s.handleError(deck.ID, stageResume, "Generation failed", fmt.Errorf("%w %d times", errInterrupted, job.Attempts))
+			recordJobState(deck.ID, "finished")
+			continue
+		}
+
+		log.Printf("Resuming %s generation of deck %s", job.State, deck.ID)
+		jobMetrics.Add("recovered", 1)
+		s.resume(deck)
+	}
+}
+
+// resume restarts an interrupted generation, decks with nothing to generate them
+// from are marked as failed. The deck of a running job lost with its instance is
+// left processing, the claimed job already owns it.
+func (s *PitchDeckService) resume(deck *model.PitchDeckInfo) {
+	err := s.regenerate(deck, deck.Status == "processing")
+	switch {
+	case err == nil:
+	case errors.Is(err, model.ErrConflict):
+		s.handleError(deck.ID, stageResume, "Generation failed", fmt.Errorf("%w before the deck was generated", errInterrupted))
+		recordJobState(deck.ID, "finished")
+	default:
+		s.handleError(deck.ID, stageResume, "Failed to resume generation", err)
+		recordJobState(deck.ID, "finished")
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Right-to-left languages, keyed by ISO 639-1 code and English name
+var rtlLanguages = map[string]bool{
+	"ar": true, "arabic": true,
+	"he": true, "hebrew": true,
+	"fa": true, "persian": true, "farsi": true,
+	"ur": true, "urdu": true,
+	"yi": true, "yiddish": true,
+}
+
+const rtlCSS = `<style>
+section { direction: rtl; text-align: right; }
+section ul, section ol { padding-right: 1.2em; padding-left: 0; }
+section table { direction: rtl; }
+section pre, section code { direction: ltr; text-align: left; }
+header, footer { direction: rtl; }
+</style>`
+
+// Marp keyword splitting a background image to one side of the slide, "left" or
+// "right" with an optional size ("left:40%")
+var sideKeywordRegex = regexp.MustCompile(`(^|\s)(left|right)(:\S+)?(\s|$)`)
+
+// isRTLLanguage reports whether the deck language is written right-to-left.
+// Both language codes (ar, he-IL) and English names (Arabic) are accepted.
+func isRTLLanguage(language string) bool {
+	lang := strings.ToLower(strings.TrimSpace(language))
+	if rtlLanguages[lang] {
+		return true
+	}
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		return rtlLanguages[lang[:i]]
+	}
+	return false
+}
+
+// applyRTLLayout injects right-to-left styles and mirrors the split backgrounds
+// (![bg left](...)) so that visuals sit on the opposite side of the reading
+// direction. The prompt asks for the usual layout, it is only mirrored here.
+// Markdown already laid out, as rerenders of a stored deck are, is left as is.
+func applyRTLLayout(markdown string) string {
+	if strings.Contains(markdown, rtlCSS) {
+		return markdown
+	}
+	markdown = imageAltRegex.ReplaceAllStringFunc(markdown, func(image string) string {
+		alt := imageAltRegex.FindStringSubmatch(image)[1]
+		if !slices.Contains(strings.Fields(alt), "bg") {
+			return image
+		}
+		alt = sideKeywordRegex.ReplaceAllStringFunc(alt, func(keyword string) string {
+			if strings.Contains(keyword, "left") {
+				return strings.Replace(keyword, "left", "right", 1)
+			}
+			return strings.Replace(keyword, "right", "left", 1)
+		})
+		return "![" + alt + "]("
+	})
+
+	return insertAfterFrontMatter(markdown, rtlCSS)
+}
+
+// setHTMLDirection marks the root element of an HTML export as right-to-left
+func setHTMLDirection(htmlPath string) error {
+	content, err := os.ReadFile(htmlPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HTML export: %w", err)
+	}
+
+	html := strings.Replace(string(content), "<html", `<html dir="rtl"`, 1)
+	if err := os.WriteFile(htmlPath, []byte(html), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML export: %w", err)
+	}
+	return nil
+}
+
+// cjkFont describes the font used to render a CJK language
+type cjkFont struct {
+	// FontconfigLang is the fontconfig language tag used to check glyph coverage
+	FontconfigLang string
+	Family         string
+}
+
+var cjkFonts = map[string]cjkFont{
+	"ja":       {FontconfigLang: "ja", Family: "Noto Sans CJK JP"},
+	"japanese": {FontconfigLang: "ja", Family: "Noto Sans CJK JP"},
+	"zh":       {FontconfigLang: "zh-cn", Family: "Noto Sans CJK SC"},
+	"zh-cn":    {FontconfigLang: "zh-cn", Family: "Noto Sans CJK SC"},
+	"zh-hans":  {FontconfigLang: "zh-cn", Family: "Noto Sans CJK SC"},
+	"chinese":  {FontconfigLang: "zh-cn", Family: "Noto Sans CJK SC"},
+	"zh-tw":    {FontconfigLang: "zh-tw", Family: "Noto Sans CJK TC"},
+	"zh-hk":    {FontconfigLang: "zh-tw", Family: "Noto Sans CJK TC"},
+	"zh-hant":  {FontconfigLang: "zh-tw", Family: "Noto Sans CJK TC"},
+	"ko":       {FontconfigLang: "ko", Family: "Noto Sans CJK KR"},
+	"korean":   {FontconfigLang: "ko", Family: "Noto Sans CJK KR"},
+}
+
+// cjkFontFor returns the font to use when the deck language is Chinese, Japanese or Korean
+func cjkFontFor(language string) (cjkFont, bool) {
+	lang := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(language)), "_", "-")
+	if font, ok := cjkFonts[lang]; ok {
+		return font, true
+	}
+	if i := strings.Index(lang, "-"); i != -1 {
+		font, ok := cjkFonts[lang[:i]]
+		return font, ok
+	}
+	return cjkFont{}, false
+}
+
+// cjkFontCSS puts the CJK font first in the font stack of slides, headers and footers
+func cjkFontCSS(font cjkFont) string {
+	return fmt.Sprintf(`<style>
+section, header, footer, section h1, section h2, section h3, section table { font-family: "%s", "Noto Sans CJK", "Noto Sans", sans-serif; }
+</style>`, font.Family)
+}
+
+// verifyFontCoverage checks with fontconfig that an installed font covers the language,
+// so a missing font fails the render instead of producing tofu boxes in the PDF
+func verifyFontCoverage(font cjkFont) error {
+	out, err := exec.Command("fc-list", ":lang="+font.FontconfigLang, "family").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list fonts: %w", err)
+	}
+	if strings.TrimSpace(string(out)) == "" {
+		return fmt.Errorf("no installed font covers language %s, install the Noto CJK fonts (%s)", font.FontconfigLang, font.Family)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+const (
+	// Slides of a deck a layout can be chosen for
+	maxSlideLayouts = 15
+	// Length of the slide names, quoted in the prompt
+	maxLayoutSection = 60
+)
+
+// Styles of the layout classes that are not part of the Marp themes, the statement
+// layout uses their lead class
+const layoutCSS = `<style>
+section.two-column { display: grid; grid-template-columns: 1fr 1fr; grid-template-rows: auto 1fr; column-gap: 48px; align-content: start; }
+section.two-column > h1, section.two-column > h2, section.two-column > h3 { grid-column: 1 / -1; }
+section.two-column img { max-width: 100%; }
+section.full-bleed { justify-content: flex-end; color: #fff; text-shadow: 0 2px 8px rgba(0, 0, 0, 0.6); }
+section.full-bleed h1, section.full-bleed h2 { color: #fff; }
+section.quote { justify-content: center; text-align: center; }
+section.quote blockquote { max-width: 80%; margin: 0 auto; border: none; font-size: 1.5em; font-style: italic; }
+section.quote blockquote + p { font-size: 0.9em; opacity: 0.8; }
+</style>`
+
+// Class directives of the slides using a layout styled by layoutCSS
+var layoutClassRegex = regexp.MustCompile(`(?m)\bclass:[^\n]*\b(two-column|full-bleed|quote)\b`)
+
+// LayoutIDs returns the IDs of the layouts slides can be given
+func LayoutIDs() []string {
+	ids := make([]string, 0, len(prompts.Layouts))
+	for id := range prompts.Layouts {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// checkSlideLayouts validates the layouts chosen for the slides before the deck is
+// generated
+func checkSlideLayouts(layouts []model.SlideLayout) error {
+	if len(layouts) > maxSlideLayouts {
+		return fmt.Errorf("%w: layouts can be chosen for at most %d slides", model.ErrInvalidInput, maxSlideLayouts)
+	}
+	for _, layout := range layouts {
+		section := strings.TrimSpace(layout.Section)
+		if section == "" {
+			return fmt.Errorf("%w: the slide of a layout is missing", model.ErrInvalidInput)
+		}
+		if utf8.RuneCountInString(section) > maxLayoutSection || strings.ContainsAny(section, "\"\r\n") {
+			return fmt.Errorf("%w: invalid slide name %q", model.ErrInvalidInput, section)
+		}
+		if _, ok := prompts.Layouts[layout.Layout]; !ok {
+			return fmt.Errorf("%w: unknown layout %q for the %s slide, expected one of %s",
+				model.ErrInvalidInput, layout.Layout, section, strings.Join(LayoutIDs(), ", "))
+		}
+	}
+	return nil
+}
+
+// slideLayouts converts the layouts chosen for the slides for the prompt
+func slideLayouts(layouts []model.SlideLayout) []prompts.SlideLayout {
+	var result []prompts.SlideLayout
+	for _, layout := range layouts {
+		if l, ok := prompts.Layouts[layout.Layout]; ok {
+			result = append(result, prompts.SlideLayout{Section: strings.TrimSpace(layout.Section), Layout: l})
+		}
+	}
+	return result
+}
+
+// injectLayoutCSS adds the styles of the layouts when a slide uses one of them
+func injectLayoutCSS(markdown string) string {
+	if !layoutClassRegex.MatchString(markdown) {
+		return markdown
+	}
+	return insertAfterFrontMatter(markdown, layoutCSS)
+}
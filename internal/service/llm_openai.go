@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Time a self-hosted model is given to answer, generating a deck on a local GPU
+// takes minutes
+const defaultCustomLLMTimeout = 10 * time.Minute
+
+// openAIEndpoint is an LLM served with the chat completions API of OpenAI, such as
+// Ollama or vLLM
+type openAIEndpoint struct {
+	baseURL string
+	model   string
+	// Header authenticating the requests, e.g. "Authorization: Bearer <key>", none
+	// when empty
+	authName  string
+	authValue string
+	timeout   time.Duration
+}
+
+// customLLM replaces Gemini when set
+var customLLM *openAIEndpoint
+
+// ConfigureCustomLLM sends the generations to the OpenAI-compatible API at
+// LLM_BASE_URL (e.g. "http://localhost:11434/v1" for Ollama) instead of Gemini, so
+// the answers and decks never leave a self-hosted deployment. LLM_MODEL names the
+// model every call is made with, whatever the settings of the deck or the plan ask
+// for, LLM_AUTH_HEADER is a "Name: value" header sent with the requests and
+// LLM_TIMEOUT bounds a call. Grounding in a Google search is not available, market
+// sizes are estimated from what the model knows.
+func ConfigureCustomLLM() (bool, error) {
+	baseURL := strings.TrimSuffix(os.Getenv("LLM_BASE_URL"), "/")
+	if baseURL == "" {
+		return false, nil
+	}
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return false, fmt.Errorf("invalid LLM_BASE_URL: %q must be an http(s) URL", baseURL)
+	}
+	endpoint := &openAIEndpoint{
+		baseURL: baseURL,
+		model:   os.Getenv("LLM_MODEL"),
+		timeout: defaultCustomLLMTimeout,
+	}
+	if endpoint.model == "" {
+		return false, fmt.Errorf("LLM_MODEL is required with LLM_BASE_URL")
+	}
+	if header := os.Getenv("LLM_AUTH_HEADER"); header != "" {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return false, fmt.Errorf("invalid LLM_AUTH_HEADER: expected \"Name: value\"")
+		}
+		endpoint.authName, endpoint.authValue = strings.TrimSpace(name), strings.TrimSpace(value)
+	}
+	if raw := os.Getenv("LLM_TIMEOUT"); raw != "" {
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return false, fmt.Errorf("invalid LLM_TIMEOUT: %q", raw)
+		}
+		endpoint.timeout = timeout
+	}
+	customLLM = endpoint
+	return true, nil
+}
+
+func (e *openAIEndpoint) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.authName != "" {
+		req.Header.Set(e.authName, e.authValue)
+	}
+	return req, nil
+}
+
+// request asks the model for a chat completion of the prompt
+func (e *openAIEndpoint) request(ctx context.Context, prompt string, params generationParams) (string, error) {
+	type message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	payload := map[string]interface{}{
+		"model":       e.model,
+		"messages":    []message{{Role: "user", Content: prompt}},
+		"temperature": params.Temperature,
+		"max_tokens":  params.MaxTokens,
+		"stream":      false,
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	req, err := e.newRequest(ctx, "POST", "/chat/completions", bytes.NewReader(jsonData))
+	if err != nil {
+		return "", err
+	}
+	resp, err := llmClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &resilience.StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("API request failed with status: %d, body: %s", resp.StatusCode, string(body)),
+		}
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w, body: %s", err, string(body))
+	}
+	if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no generated text found in response: %s", string(body))
+	}
+	return completion.Choices[0].Message.Content, nil
+}
+
+// check lists the models of the endpoint at startup, with the client of the
+// completions. Rejected credentials stop the server, an unreachable endpoint is only
+// logged.
+func (e *openAIEndpoint) check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "llm.check", attribute.String("gen_ai.system", "openai"))
+	defer span.End()
+	req, err := e.newRequest(ctx, "GET", "/models", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := llmClient.Do(req)
+	if err != nil {
+		tracing.RecordError(span, err)
+		log.Printf("LLM: %s is unreachable, generations will fail until it is: %v", e.baseURL, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("LLM: %s rejected the requests (status %d), check LLM_AUTH_HEADER", e.baseURL, resp.StatusCode)
+	default:
+		log.Printf("LLM: %s answered with status %d, generations may fail", e.baseURL, resp.StatusCode)
+		return nil
+	}
+
+	var models struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&models); err != nil {
+		return nil
+	}
+	for _, m := range models.Data {
+		if m.ID == e.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("LLM: the model %s is not served by %s, check LLM_MODEL (Ollama serves the models pulled)", e.model, e.baseURL)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const maxLogoSize = 2 << 20
+
+// Extensions of the images accepted as logos
+var logoExtensions = map[string]string{
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/svg+xml":            ".svg",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// websiteURL parses the website of the company, given as a domain or a URL
+func websiteURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return nil, fmt.Errorf("%w: invalid website %q", model.ErrInvalidInput, raw)
+	}
+	return u, nil
+}
+
+// logoFromWebsite finds the logo of a company on its website and uploads it for
+// the user, as if they had uploaded it. The touch icons are tried first, then the
+// icons, the Open Graph image and /favicon.ico.
+func (s *PitchDeckService) logoFromWebsite(website, userID string) (string, error) {
+	site, err := websiteURL(website)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	if page, err := fetchPage(site); err != nil {
+		log.Printf("Failed to read website %s: %v", site, err)
+	} else {
+		candidates = append(candidates, page.Icons...)
+		candidates = append(candidates, page.Meta["og:image"])
+	}
+	candidates = append(candidates, "/favicon.ico")
+
+	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
+		return "", err
+	}
+	for _, candidate := range candidates {
+		logoURL, err := site.Parse(candidate)
+		if candidate == "" || err != nil || (logoURL.Scheme != "http" && logoURL.Scheme != "https") {
+			continue
+		}
+		filePath, err := downloadLogo(logoURL)
+		if err != nil {
+			log.Printf("Failed to download logo %s: %v", logoURL, err)
+			continue
+		}
+		defer os.Remove(filePath)
+		return s.UploadImage(filePath, site.Hostname()+"-logo"+filepath.Ext(filePath), userID)
+	}
+	return "", fmt.Errorf("no logo found on %s", site.Hostname())
+}
+
+// downloadLogo saves an image of a public website in the uploads directory
+func downloadLogo(u *url.URL) (string, error) {
+	resp, err := publicClient.Get(u.String())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	ext, ok := logoExtensions[strings.TrimSpace(contentType)]
+	if !ok {
+		return "", fmt.Errorf("not an image: %s", contentType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLogoSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxLogoSize {
+		return "", errors.New("image too large")
+	}
+	if len(data) == 0 {
+		return "", errors.New("empty image")
+	}
+
+	filePath := filepath.Join("uploads", uuid.New().String()+ext)
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
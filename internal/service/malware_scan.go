@@ -0,0 +1,118 @@
+package service
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pitch-deck-generator/internal/clamav"
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Directory infected uploads are moved to, never served
+const quarantineDir = "quarantine"
+
+// Scanner of the files uploaded by users, nil when scanning is disabled
+var malwareScanner *clamav.Client
+
+// Uploads scanned, published on the admin metrics endpoint
+var malwareScanMetrics = expvar.NewMap("malware_scan")
+
+// quarantinedFile is an upload rejected as infected, stored in the quarantined_files table
+type quarantinedFile struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	OriginalName string    `json:"original_name"`
+	Signature    string    `json:"signature"`
+	LocalPath    string    `json:"local_path"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ConfigureMalwareScanning scans the files uploaded by users with the clamd daemon at
+// CLAMAV_ADDR before they are stored in the public bucket. Without it uploads are
+// stored without being scanned.
+func ConfigureMalwareScanning() bool {
+	client, err := clamav.NewClient()
+	if err != nil {
+		return false
+	}
+	malwareScanner = client
+	return true
+}
+
+// scanUpload rejects a file uploaded by a user that contains malware, moving it to
+// quarantine. Files that cannot be scanned are rejected as well, the bucket serves
+// them to anyone with their URL.
+func scanUpload(filePath, originalName, userID string) error {
+	if malwareScanner == nil {
+		return nil
+	}
+
+	result, err := malwareScanner.ScanFile(filePath)
+	if err != nil {
+		malwareScanMetrics.Add("failures", 1)
+		log.Printf("Failed to scan upload %s of user %s: %v", originalName, userID, err)
+		return fmt.Errorf("%w: uploads cannot be checked for malware right now, try again later", model.ErrUnavailable)
+	}
+	malwareScanMetrics.Add("scanned", 1)
+	if !result.Infected {
+		return nil
+	}
+
+	malwareScanMetrics.Add("infected", 1)
+	log.Printf("Rejected upload %s of user %s: %s found", originalName, userID, result.Signature)
+	quarantine(filePath, originalName, userID, result.Signature)
+	return fmt.Errorf("%w: the file was rejected because it contains malware (%s)", model.ErrInvalidInput, result.Signature)
+}
+
+// quarantine moves an infected file out of the upload directories and records it,
+// for administrators to investigate. The janitor removes it after the retention period.
+func quarantine(filePath, originalName, userID, signature string) {
+	record := quarantinedFile{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		OriginalName: originalName,
+		Signature:    signature,
+		CreatedAt:    time.Now(),
+	}
+	record.LocalPath = filepath.Join(quarantineDir, record.ID+filepath.Ext(filePath))
+
+	if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+		log.Printf("Failed to create quarantine directory: %v", err)
+	}
+	if err := os.Rename(filePath, record.LocalPath); err != nil {
+		log.Printf("Failed to quarantine %s, removing it: %v", filePath, err)
+		os.Remove(filePath)
+		record.LocalPath = ""
+	}
+	if err := supabaseREST("POST", "quarantined_files", record, nil); err != nil {
+		log.Printf("Failed to record quarantined file %s: %v", record.ID, err)
+	}
+}
+
+// removeQuarantined removes the quarantined files older than the retention period
+func removeQuarantined(retention time.Duration) (removed int, reclaimed int64) {
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Janitor: failed to read %s: %v", quarantineDir, err)
+		}
+		return 0, 0
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) <= retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(quarantineDir, entry.Name())); err == nil {
+			removed++
+			reclaimed += info.Size()
+		}
+	}
+	return removed, reclaimed
+}
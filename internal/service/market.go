@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+// Parameters of the market research: factual, and grounded in a web search so the
+// estimates cite real sources
+var marketResearchGeneration = generationParams{Model: geminiModel, Temperature: 0.2, MaxTokens: 2000, Search: true}
+
+// missingMarketSizes returns the market sizes the founder left empty
+func missingMarketSizes(data model.PitchDeckData) []string {
+	var missing []string
+	for _, size := range []struct{ metric, value string }{
+		{"TAM", data.TAM}, {"SAM", data.SAM}, {"SOM", data.SOM},
+	} {
+		if strings.TrimSpace(size.value) == "" {
+			missing = append(missing, size.metric)
+		}
+	}
+	return missing
+}
+
+// estimateMarket researches the market sizes left empty, from the industry and the
+// geography of the deck. Estimates without a source are left out.
+func estimateMarket(ctx context.Context, data model.PitchDeckData, metrics []string) ([]prompts.MarketEstimate, error) {
+	prompt, err := prompts.GenerateMarketEstimatePrompt(prompts.MarketEstimateData{
+		ProjectName:    data.ProjectName,
+		BigIdea:        data.BigIdea,
+		TargetAudience: data.TargetAudience,
+		TargetNiche:    data.TargetNiche,
+		Industry:       data.Industry,
+		Geography:      data.Geography,
+		Metrics:        metrics,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := callLLM(ctx, prompt, marketResearchGeneration)
+	if err != nil {
+		return nil, err
+	}
+
+	var research struct {
+		Estimates []prompts.MarketEstimate `json:"estimates"`
+	}
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```")
+	text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	if err := json.Unmarshal([]byte(text), &research); err != nil {
+		return nil, fmt.Errorf("failed to parse market estimates: %w", err)
+	}
+
+	requested := make(map[string]bool)
+	for _, metric := range metrics {
+		requested[metric] = true
+	}
+
+	var estimates []prompts.MarketEstimate
+	for _, estimate := range research.Estimates {
+		estimate.Metric = strings.ToUpper(strings.TrimSpace(estimate.Metric))
+		if !requested[estimate.Metric] || strings.TrimSpace(estimate.Value) == "" {
+			continue
+		}
+		var sources []prompts.MarketSource
+		for _, source := range estimate.Sources {
+			if u, err := url.Parse(source.URL); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+				sources = append(sources, source)
+			}
+		}
+		if len(sources) == 0 {
+			continue
+		}
+		estimate.Sources = sources
+		requested[estimate.Metric] = false
+		estimates = append(estimates, estimate)
+	}
+	return estimates, nil
+}
@@ -0,0 +1,43 @@
+// Marp engine of the renderer, given to marp-cli with --engine. It styles the code
+// blocks of a deck with the highlight.js stylesheet named by its codeTheme
+// directive (e.g. "codeTheme: base16/dracula"), scoped to the slides like the
+// styles of the deck itself. highlight.js is resolved through NODE_PATH.
+const fs = require('fs')
+
+// Custom property the server looks for in the HTML to check the style was applied
+const marker = '--pitchtree-code-theme'
+
+const stylesheet = (name) => {
+  if (!/^[a-z0-9-]+(\/[a-z0-9-]+)?$/.test(name)) {
+    throw new Error(`invalid code theme: ${name}`)
+  }
+  const path = require.resolve(`highlight.js/styles/${name}.css`)
+  return `${fs.readFileSync(path, 'utf8')}\nsection pre { ${marker}: ${name}; }`
+}
+
+module.exports = ({ marp }) => {
+  let codeTheme
+
+  marp.customDirectives.global.codeTheme = (value) => {
+    codeTheme = value
+    return {}
+  }
+
+  const render = marp.render.bind(marp)
+  marp.render = (markdown, env) => {
+    codeTheme = undefined
+    return render(markdown, env)
+  }
+
+  // The stylesheet goes before the styles of the deck, which can still override it
+  const packOptions = marp.themeSetPackOptions.bind(marp)
+  marp.themeSetPackOptions = () => {
+    const options = packOptions()
+    if (codeTheme) {
+      options.after = [stylesheet(codeTheme), options.after].filter(Boolean).join('\n')
+    }
+    return options
+  }
+
+  return marp
+}
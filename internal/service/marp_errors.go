@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"pitch-deck-generator/internal/chaos"
+)
+
+// Classification codes of Marp rendering failures
+const (
+	RenderErrMarpMissing     = "marp_cli_missing"
+	RenderErrChromiumMissing = "chromium_missing"
+	RenderErrImageNotFound   = "image_not_found"
+	RenderErrCSS             = "css_error"
+	RenderErrThemeNotFound   = "theme_not_found"
+	RenderErrOutOfMemory     = "out_of_memory"
+	RenderErrUnknown         = "render_failed"
+)
+
+const chromiumMissingMessage = "The PDF renderer could not start a browser. Chromium is missing on the server, please try again later or contact support."
+
+// RenderError is returned when marp-cli fails, with a classification of the
+// failure and a message the user can act upon
+type RenderError struct {
+	Code    string
+	Message string
+	Stderr  string
+	Err     error
+}
+
+func (e *RenderError) Error() string {
+	return e.Message
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// renderErrorPatterns maps stderr fragments to a classification, checked in order
+var renderErrorPatterns = []struct {
+	Code      string
+	Fragments []string
+	Message   string
+}{
+	{
+		Code:      RenderErrChromiumMissing,
+		Fragments: []string{"no suitable browser", "could not find chrome", "could not find chromium", "failed to launch the browser", "browser was not found"},
+		Message:   chromiumMissingMessage,
+	},
+	{
+		Code:      RenderErrOutOfMemory,
+		Fragments: []string{"heap out of memory", "enomem", "out of memory", "target closed", "page crashed"},
+		Message:   "The renderer ran out of memory. Try reducing the number or size of images in your deck.",
+	},
+	{
+		Code:      RenderErrImageNotFound,
+		Fragments: []string{"err_file_not_found", "not allowed to load local resource", "failed to load resource", "err_name_not_resolved", "404 (not found)"},
+		Message:   "An image referenced by the deck could not be loaded. Check that your uploaded images are still available and re-upload them if needed.",
+	},
+	{
+		Code:      RenderErrThemeNotFound,
+		Fragments: []string{"theme not found", "could not find theme", "failed to load theme", "is not recognized"},
+		Message:   "The theme of the deck could not be found. Pick another theme and generate the deck again.",
+	},
+	{
+		Code:      RenderErrCSS,
+		Fragments: []string{"csssyntaxerror", "unknown word", "unclosed block", "unclosed bracket"},
+		Message:   "The deck contains invalid styling. Regenerate the deck or remove custom styles from your content.",
+	},
+}
+
+// runMarp runs marp-cli and classifies its output when it fails
+func runMarp(args ...string) error {
+	if chaos.Inject(chaos.RenderCrash) {
+		return classifyMarpError(chaos.ErrInjected, "[ ERROR ] Page crashed!")
+	}
+
+	return renderer.run(args...)
+}
+
+// classifyMarpError maps a marp-cli failure to a RenderError
+func classifyMarpError(err error, output string) *RenderError {
+	if errors.Is(err, exec.ErrNotFound) {
+		return &RenderError{
+			Code:    RenderErrMarpMissing,
+			Message: "The slide renderer (Node.js/npx) is not installed on the server, please contact support.",
+			Stderr:  output,
+			Err:     err,
+		}
+	}
+
+	lower := strings.ToLower(output)
+
+	// A process killed by the OOM killer exits without any output
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == -1 && strings.Contains(err.Error(), "killed") {
+		lower += " out of memory"
+	}
+
+	for _, pattern := range renderErrorPatterns {
+		for _, fragment := range pattern.Fragments {
+			if strings.Contains(lower, fragment) {
+				return &RenderError{Code: pattern.Code, Message: pattern.Message, Stderr: output, Err: err}
+			}
+		}
+	}
+
+	return &RenderError{
+		Code:    RenderErrUnknown,
+		Message: fmt.Sprintf("The slide renderer failed unexpectedly (%v). Please try again.", err),
+		Stderr:  output,
+		Err:     err,
+	}
+}
+
+// Lines of the renderer output shown to the user along a failure
+const outputTailLines = 10
+
+// outputTail returns the last lines of the renderer output, where marp-cli reports
+// the error, without blank lines
+func outputTail(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines[max(len(lines)-outputTailLines, 0):], "\n")
+}
+
+// logRenderError writes a structured log entry for a classified rendering failure
+func logRenderError(deckID, format string, err error) {
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) {
+		slog.Error("marp conversion failed", "deck_id", deckID, "format", format, "error", err)
+		return
+	}
+
+	slog.Error("marp conversion failed",
+		"deck_id", deckID,
+		"format", format,
+		"code", renderErr.Code,
+		"error", renderErr.Err,
+		"stderr", strings.TrimSpace(renderErr.Stderr),
+	)
+}
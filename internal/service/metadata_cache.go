@@ -0,0 +1,120 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// How long deck metadata read from Supabase is reused. Writes made by this
+// process clear the cache, the TTL bounds staleness of writes made elsewhere.
+// Expired entries are kept until cleared, and served while Supabase is unavailable.
+const metadataCacheTTL = 5 * time.Second
+
+// metadataCache keeps recently read deck records and deck lists so polling
+// clients do not each trigger a Supabase request
+type metadataCache struct {
+	mu    sync.Mutex
+	decks map[string]cachedDeck
+	lists map[string]cachedList
+}
+
+type cachedDeck struct {
+	deck    model.PitchDeckInfo
+	expires time.Time
+}
+
+type cachedList struct {
+	decks   []model.PitchDeckInfo
+	total   int
+	expires time.Time
+}
+
+var deckCache = &metadataCache{
+	decks: make(map[string]cachedDeck),
+	lists: make(map[string]cachedList),
+}
+
+func (c *metadataCache) getDeck(deckID string) (*model.PitchDeckInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.decks[deckID]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	// Callers may modify the deck, hand out a copy
+	deck := entry.deck
+	return &deck, true
+}
+
+// staleDeck returns the last cached record of a deck, even expired
+func (c *metadataCache) staleDeck(deckID string) (*model.PitchDeckInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.decks[deckID]
+	if !ok {
+		return nil, false
+	}
+	deck := entry.deck
+	return &deck, true
+}
+
+func (c *metadataCache) putDeck(deck *model.PitchDeckInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decks[deck.ID] = cachedDeck{deck: *deck, expires: time.Now().Add(metadataCacheTTL)}
+}
+
+func (c *metadataCache) getList(key string) ([]model.PitchDeckInfo, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lists[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, 0, false
+	}
+	return append([]model.PitchDeckInfo(nil), entry.decks...), entry.total, true
+}
+
+// staleList returns the last cached deck list, even expired
+func (c *metadataCache) staleList(key string) ([]model.PitchDeckInfo, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lists[key]
+	if !ok {
+		return nil, 0, false
+	}
+	return append([]model.PitchDeckInfo(nil), entry.decks...), entry.total, true
+}
+
+func (c *metadataCache) putList(key string, decks []model.PitchDeckInfo, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lists[key] = cachedList{
+		decks:   append([]model.PitchDeckInfo(nil), decks...),
+		total:   total,
+		expires: time.Now().Add(metadataCacheTTL),
+	}
+}
+
+// invalidate drops every cached entry, called after any write to pitch_decks
+func (c *metadataCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decks = make(map[string]cachedDeck)
+	c.lists = make(map[string]cachedList)
+}
+
+// writesDecks reports whether a Supabase REST request modifies deck records
+func writesDecks(method, path string) bool {
+	if method == "GET" {
+		return false
+	}
+	path = strings.TrimPrefix(path, "/")
+	return strings.HasPrefix(path, "pitch_decks") || strings.HasPrefix(path, "rpc/record_deck_view")
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// Types of the notifications sent to users
+const (
+	NotificationDeckCompleted = "deck_completed"
+	NotificationDeckFailed    = "deck_failed"
+	NotificationDeckViewed    = "deck_viewed"
+	NotificationCommentAdded  = "comment_added"
+)
+
+const (
+	defaultNotificationLimit = 30
+	maxNotificationLimit     = 100
+	// Unread notifications counted for the bell, more are shown as this number
+	maxUnreadCount = 99
+)
+
+// notify appends a notification to the feed of a user. A failure is logged but does
+// not fail the action that triggered it.
+func notify(userID, kind, deckID, message string, metadata map[string]interface{}) {
+	if userID == "" || userID == demoUserID {
+		return
+	}
+
+	notification := model.Notification{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Type:      kind,
+		DeckID:    deckID,
+		Message:   message,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+	if err := supabaseWrite("POST", "notifications", notification); err != nil {
+		log.Printf("Failed to notify user %s of %s: %v", userID, kind, err)
+	}
+}
+
+// notifyDeckStatus tells the owner of a deck that its generation or render finished
+func (s *PitchDeckService) notifyDeckStatus(deckID string) {
+	deck, err := s.Get(deckID)
+	if err != nil {
+		log.Printf("Failed to load deck %s to notify its owner: %v", deckID, err)
+		return
+	}
+
+	switch deck.Status {
+	case "completed":
+		notify(deck.UserID, NotificationDeckCompleted, deck.ID, fmt.Sprintf("%s is ready", deck.Name), nil)
+	case "failed":
+		notify(deck.UserID, NotificationDeckFailed, deck.ID, fmt.Sprintf("%s could not be generated", deck.Name), map[string]interface{}{
+			"error_code": deck.ErrorCode,
+		})
+	}
+}
+
+// NotificationService serves the notification feed of users
+type NotificationService struct{}
+
+func NewNotificationService() *NotificationService {
+	return &NotificationService{}
+}
+
+// List returns the latest notifications of a user and how many they have not read
+func (s *NotificationService) List(userID string, unreadOnly bool, limit int) (*model.NotificationFeed, error) {
+	if limit <= 0 {
+		limit = defaultNotificationLimit
+	}
+	limit = min(limit, maxNotificationLimit)
+
+	filter := "user_id=eq." + url.QueryEscape(userID)
+	unreadFilter := filter + "&read_at=is.null"
+	if unreadOnly {
+		filter = unreadFilter
+	}
+
+	feed := &model.NotificationFeed{Notifications: []model.Notification{}}
+	path := fmt.Sprintf("notifications?%s&order=created_at.desc&limit=%d", filter, limit)
+	if err := supabaseREST("GET", path, nil, &feed.Notifications); err != nil {
+		return nil, fmt.Errorf("failed to load notifications: %w", err)
+	}
+
+	var unread []model.Notification
+	path = fmt.Sprintf("notifications?select=id&%s&limit=%d", unreadFilter, maxUnreadCount)
+	if err := supabaseREST("GET", path, nil, &unread); err != nil {
+		return nil, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	feed.Unread = len(unread)
+	return feed, nil
+}
+
+// MarkRead marks a notification of a user as read
+func (s *NotificationService) MarkRead(userID, notificationID string) error {
+	var updated []model.Notification
+	path := fmt.Sprintf("notifications?select=id&id=eq.%s&user_id=eq.%s", url.QueryEscape(notificationID), url.QueryEscape(userID))
+	if err := supabaseREST("PATCH", path, map[string]interface{}{"read_at": time.Now()}, &updated); err != nil {
+		return fmt.Errorf("failed to mark notification as read: %w", err)
+	}
+	if len(updated) == 0 {
+		return fmt.Errorf("%w: notification not found", model.ErrNotFound)
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of a user as read
+func (s *NotificationService) MarkAllRead(userID string) error {
+	path := fmt.Sprintf("notifications?user_id=eq.%s&read_at=is.null", url.QueryEscape(userID))
+	if err := supabaseREST("PATCH", path, map[string]interface{}{"read_at": time.Now()}, nil); err != nil {
+		return fmt.Errorf("failed to mark notifications as read: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+var roleRanks = map[string]int{
+	model.RoleViewer: 1,
+	model.RoleEditor: 2,
+	model.RoleOwner:  3,
+}
+
+// OrganizationService manages organizations, their members and invites
+type OrganizationService struct {
+	decks *PitchDeckService
+}
+
+func NewOrganizationService(decks *PitchDeckService) *OrganizationService {
+	return &OrganizationService{
+		decks: decks,
+	}
+}
+
+// memberships returns the organizations of a user with the role held in each
+func memberships(userID string) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	if err := supabaseREST("GET", "organization_members?user_id=eq."+url.QueryEscape(userID), nil, &members); err != nil {
+		return nil, fmt.Errorf("failed to load memberships: %w", err)
+	}
+	return members, nil
+}
+
+// memberRole returns the role of the user in the organization, empty when not a member
+func memberRole(orgID, userID string) (string, error) {
+	var members []model.OrganizationMember
+	path := fmt.Sprintf("organization_members?org_id=eq.%s&user_id=eq.%s", url.QueryEscape(orgID), url.QueryEscape(userID))
+	if err := supabaseREST("GET", path, nil, &members); err != nil {
+		return "", fmt.Errorf("failed to load membership: %w", err)
+	}
+	if len(members) == 0 {
+		return "", nil
+	}
+	return members[0].Role, nil
+}
+
+// requireRole checks that the user holds at least minRole in the organization
+func requireRole(orgID, userID, minRole string) error {
+	role, err := memberRole(orgID, userID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return fmt.Errorf("%w: not a member of this organization", model.ErrForbidden)
+	}
+	if roleRanks[role] < roleRanks[minRole] {
+		return fmt.Errorf("%w: %s role required", model.ErrForbidden, minRole)
+	}
+	return nil
+}
+
+// authorizeResource checks access to a deck or project: its creator has every
+// permission, members of the organization it is shared with get their role
+func authorizeResource(ownerID, orgID, userID, minRole string) error {
+	if ownerID == userID {
+		return nil
+	}
+	if orgID == "" {
+		return fmt.Errorf("%w: belongs to another user", model.ErrForbidden)
+	}
+	return requireRole(orgID, userID, minRole)
+}
+
+// orgFilter returns the PostgREST condition matching the rows created by the user
+// or shared with one of their organizations
+func orgFilter(userID string) (string, error) {
+	members, err := memberships(userID)
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "user_id=eq." + url.QueryEscape(userID), nil
+	}
+
+	orgIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		orgIDs = append(orgIDs, m.OrgID)
+	}
+	return fmt.Sprintf("or=(user_id.eq.%s,org_id.in.(%s))", url.QueryEscape(userID), strings.Join(orgIDs, ",")), nil
+}
+
+func validRole(role string) error {
+	if _, ok := roleRanks[role]; !ok {
+		return fmt.Errorf("%w: role must be viewer, editor or owner", model.ErrInvalidInput)
+	}
+	return nil
+}
+
+func (s *OrganizationService) CreateOrganization(userID, name string) (*model.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: organization name is required", model.ErrInvalidInput)
+	}
+
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	org := model.Organization{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedBy: userID,
+		CreatedAt: time.Now(),
+		TenantID:  tenantID,
+	}
+	if err := supabaseREST("POST", "organizations", org, nil); err != nil {
+		return nil, fmt.Errorf("failed to save organization: %w", err)
+	}
+
+	owner := model.OrganizationMember{OrgID: org.ID, UserID: userID, Role: model.RoleOwner, CreatedAt: org.CreatedAt}
+	if err := supabaseREST("POST", "organization_members", owner, nil); err != nil {
+		return nil, fmt.Errorf("failed to add owner: %w", err)
+	}
+
+	org.Role = model.RoleOwner
+	return &org, nil
+}
+
+func (s *OrganizationService) ListOrganizations(userID string) ([]model.Organization, error) {
+	members, err := memberships(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []model.Organization{}, nil
+	}
+
+	roles := make(map[string]string)
+	orgIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		roles[m.OrgID] = m.Role
+		orgIDs = append(orgIDs, m.OrgID)
+	}
+
+	var orgs []model.Organization
+	path := fmt.Sprintf("organizations?id=in.(%s)&order=name.asc", strings.Join(orgIDs, ","))
+	if err := supabaseREST("GET", path, nil, &orgs); err != nil {
+		return nil, err
+	}
+	for i := range orgs {
+		orgs[i] = withDomainRecord(orgs[i])
+		orgs[i].Role = roles[orgs[i].ID]
+	}
+	return orgs, nil
+}
+
+func (s *OrganizationService) ListMembers(orgID, userID string) ([]model.OrganizationMember, error) {
+	if err := requireRole(orgID, userID, model.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	var members []model.OrganizationMember
+	path := fmt.Sprintf("organization_members?org_id=eq.%s&order=created_at.asc", url.QueryEscape(orgID))
+	if err := supabaseREST("GET", path, nil, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// InviteMember creates an invite, the returned token is sent to the invitee as a link
+func (s *OrganizationService) InviteMember(orgID, userID, email, role string) (*model.OrganizationInvite, error) {
+	if err := requireRole(orgID, userID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := validRole(role); err != nil {
+		return nil, err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("%w: invalid email address", model.ErrInvalidInput)
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	invite := model.OrganizationInvite{
+		ID:        uuid.New().String(),
+		OrgID:     orgID,
+		Email:     email,
+		Role:      role,
+		Token:     token,
+		InvitedBy: userID,
+		CreatedAt: time.Now(),
+	}
+	if err := supabaseREST("POST", "organization_invites", invite, nil); err != nil {
+		return nil, fmt.Errorf("failed to save invite: %w", err)
+	}
+	return &invite, nil
+}
+
+func (s *OrganizationService) AcceptInvite(token, userID string) (*model.OrganizationMember, error) {
+	var invites []model.OrganizationInvite
+	if err := supabaseREST("GET", "organization_invites?token=eq."+url.QueryEscape(token), nil, &invites); err != nil {
+		return nil, err
+	}
+	if len(invites) == 0 || invites[0].AcceptedAt != nil {
+		return nil, fmt.Errorf("%w: invite not found or already used", model.ErrNotFound)
+	}
+	invite := invites[0]
+
+	// Organizations never span tenants, the invite does not exist for other tenants
+	var orgs []model.Organization
+	if err := supabaseREST("GET", "organizations?select=tenant_id&id=eq."+url.QueryEscape(invite.OrgID), nil, &orgs); err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 || checkTenant(orgs[0].TenantID, userID) != nil {
+		return nil, fmt.Errorf("%w: invite not found or already used", model.ErrNotFound)
+	}
+
+	role, err := memberRole(invite.OrgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		return nil, fmt.Errorf("%w: already a member of this organization", model.ErrConflict)
+	}
+
+	member := model.OrganizationMember{OrgID: invite.OrgID, UserID: userID, Role: invite.Role, CreatedAt: time.Now()}
+	if err := supabaseREST("POST", "organization_members", member, nil); err != nil {
+		return nil, fmt.Errorf("failed to add member: %w", err)
+	}
+
+	accepted := map[string]time.Time{"accepted_at": member.CreatedAt}
+	if err := supabaseREST("PATCH", "organization_invites?id=eq."+url.QueryEscape(invite.ID), accepted, nil); err != nil {
+		return nil, fmt.Errorf("failed to mark invite as accepted: %w", err)
+	}
+	return &member, nil
+}
+
+// ensureAnotherOwner keeps organizations from losing their last owner
+func ensureAnotherOwner(orgID, memberID string) error {
+	var owners []model.OrganizationMember
+	path := fmt.Sprintf("organization_members?org_id=eq.%s&role=eq.%s", url.QueryEscape(orgID), model.RoleOwner)
+	if err := supabaseREST("GET", path, nil, &owners); err != nil {
+		return err
+	}
+	for _, owner := range owners {
+		if owner.UserID != memberID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: an organization needs at least one owner", model.ErrInvalidInput)
+}
+
+func (s *OrganizationService) UpdateMemberRole(orgID, userID, memberID, role string) error {
+	if err := requireRole(orgID, userID, model.RoleOwner); err != nil {
+		return err
+	}
+	if err := validRole(role); err != nil {
+		return err
+	}
+
+	current, err := memberRole(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return fmt.Errorf("%w: member not found", model.ErrNotFound)
+	}
+	if current == model.RoleOwner && role != model.RoleOwner {
+		if err := ensureAnotherOwner(orgID, memberID); err != nil {
+			return err
+		}
+	}
+
+	path := fmt.Sprintf("organization_members?org_id=eq.%s&user_id=eq.%s", url.QueryEscape(orgID), url.QueryEscape(memberID))
+	if err := supabaseREST("PATCH", path, map[string]string{"role": role}, nil); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// RemoveMember removes a member, owners can remove anyone and members can leave
+func (s *OrganizationService) RemoveMember(orgID, userID, memberID string) error {
+	if memberID != userID {
+		if err := requireRole(orgID, userID, model.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	role, err := memberRole(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return fmt.Errorf("%w: member not found", model.ErrNotFound)
+	}
+	if role == model.RoleOwner {
+		if err := ensureAnotherOwner(orgID, memberID); err != nil {
+			return err
+		}
+	}
+
+	path := fmt.Sprintf("organization_members?org_id=eq.%s&user_id=eq.%s", url.QueryEscape(orgID), url.QueryEscape(memberID))
+	if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove member: %w", err)
+	}
+	return nil
+}
+
+// ShareDeck shares a deck with an organization, or makes it personal again when orgID is empty
+func (s *OrganizationService) ShareDeck(deckID, orgID, userID string) error {
+	deck, err := s.decks.authorizedDeck(deckID, userID, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+	if err := shareWith("pitch_decks", deck.ID, orgID, userID); err != nil {
+		return err
+	}
+
+	recordAudit(deck.ID, userID, AuditDeckShared, map[string]interface{}{
+		"via":         "organization",
+		"from_org_id": deck.OrgID,
+		"org_id":      orgID,
+	})
+	return nil
+}
+
+// ShareProject shares a project, and with it its brand kit, with an organization
+func (s *OrganizationService) ShareProject(projectID, orgID, userID string) error {
+	project, err := getProject(projectID, userID, model.RoleOwner)
+	if err != nil {
+		return err
+	}
+	return shareWith("projects", project.ID, orgID, userID)
+}
+
+func shareWith(table, id, orgID, userID string) error {
+	update := map[string]interface{}{"org_id": nil}
+	if orgID != "" {
+		if err := requireRole(orgID, userID, model.RoleEditor); err != nil {
+			return err
+		}
+		update["org_id"] = orgID
+	}
+
+	if err := supabaseREST("PATCH", table+"?id=eq."+url.QueryEscape(id), update, nil); err != nil {
+		return fmt.Errorf("failed to update sharing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of outbox entries, by the record written once their files are stored
+const (
+	outboxUserFile  = "user_file"
+	outboxDeckFiles = "deck_files"
+)
+
+const (
+	defaultOutboxInterval = 5 * time.Minute
+
+	// Entries younger than this may belong to an upload still running
+	outboxGracePeriod = 15 * time.Minute
+)
+
+// Entries completed and compensated by the reconciler, published on the admin
+// metrics endpoint
+var outboxMetrics = expvar.NewMap("outbox")
+
+// outboxEntry is the intent of storing files and recording them in the database. It
+// is written before the upload, marked stored once the files are in storage, and
+// removed once the record is written. The entries left behind by a crash or a failed
+// write are completed by the OutboxReconciler.
+type outboxEntry struct {
+	ID        string          `json:"id"`
+	UserID    string          `json:"user_id"`
+	Kind      string          `json:"kind"`
+	Bucket    string          `json:"bucket"`
+	Paths     []string        `json:"paths"`
+	Record    json.RawMessage `json:"record,omitempty"`
+	StoredAt  *time.Time      `json:"stored_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// deckFilesRecord points a deck to the files of a render. It is only applied while
+// the deck still points to the files of the previous render.
+type deckFilesRecord struct {
+	DeckID      string `json:"deck_id"`
+	PreviousPDF string `json:"previous_pdf"`
+	PdfURL      string `json:"pdf_url"`
+	HtmlURL     string `json:"html_url"`
+	MarkdownURL string `json:"markdown_url"`
+}
+
+// beginOutbox records the intent of storing files at paths of a bucket, and of then
+// writing record when it is known already. Nothing must be uploaded when it fails.
+func beginOutbox(kind, userID, bucket string, paths []string, record interface{}) (*outboxEntry, error) {
+	entry := &outboxEntry{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Kind:      kind,
+		Bucket:    bucket,
+		Paths:     paths,
+		CreatedAt: time.Now(),
+	}
+	if entry.Paths == nil {
+		entry.Paths = []string{}
+	}
+	if record != nil {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		entry.Record = data
+	}
+	if err := supabaseREST("POST", "storage_outbox", entry, nil); err != nil {
+		return nil, fmt.Errorf("%w: failed to record upload: %v", model.ErrUnavailable, err)
+	}
+	return entry, nil
+}
+
+// stored marks the files of an entry as uploaded, with the record to write when it
+// was not known before the upload. Unless it succeeds the files are removed by the
+// reconciler, the operation must fail.
+func (e *outboxEntry) stored(record interface{}) error {
+	update := map[string]interface{}{"stored_at": time.Now()}
+	if record != nil {
+		update["record"] = record
+	}
+	if err := supabaseREST("PATCH", "storage_outbox?id=eq."+e.ID, update, nil); err != nil {
+		return fmt.Errorf("failed to confirm upload: %w", err)
+	}
+	return nil
+}
+
+// done removes an entry once its record is written. An entry left behind is applied
+// again by the reconciler, which writes records idempotently.
+func (e *outboxEntry) done() {
+	if err := supabaseREST("DELETE", "storage_outbox?id=eq."+e.ID, nil, nil); err != nil {
+		log.Printf("Failed to remove outbox entry %s: %v", e.ID, err)
+	}
+}
+
+// OutboxReconciler completes the outbox entries older than the grace period: the
+// records of stored files are written, and the files of uploads that were never
+// confirmed are removed
+type OutboxReconciler struct {
+	decks    *PitchDeckService
+	interval time.Duration
+}
+
+// NewOutboxReconciler configures the reconciler from OUTBOX_INTERVAL
+func NewOutboxReconciler(decks *PitchDeckService) *OutboxReconciler {
+	interval, err := time.ParseDuration(os.Getenv("OUTBOX_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	return &OutboxReconciler{
+		decks:    decks,
+		interval: interval,
+	}
+}
+
+// Start runs the reconciliation now and then at every interval, in the background
+func (r *OutboxReconciler) Start() {
+	go func() {
+		for {
+			r.Run()
+			time.Sleep(r.interval)
+		}
+	}()
+}
+
+// Run reconciles a batch of the entries left behind
+func (r *OutboxReconciler) Run() {
+	before := url.QueryEscape(time.Now().Add(-outboxGracePeriod).UTC().Format(time.RFC3339))
+	var entries []outboxEntry
+	path := fmt.Sprintf("storage_outbox?created_at=lt.%s&order=created_at.asc&limit=%d", before, recordBatchSize)
+	if err := supabaseREST("GET", path, nil, &entries); err != nil {
+		log.Printf("Outbox: failed to load entries: %v", err)
+		outboxMetrics.Add("errors", 1)
+		return
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if err := r.reconcile(entry); err != nil {
+			// Tried again at the next run
+			log.Printf("Outbox: failed to reconcile %s entry %s: %v", entry.Kind, entry.ID, err)
+			outboxMetrics.Add("errors", 1)
+			continue
+		}
+		entry.done()
+	}
+}
+
+func (r *OutboxReconciler) reconcile(entry *outboxEntry) error {
+	if entry.StoredAt == nil {
+		// The upload failed or was interrupted, whatever reached storage is removed.
+		// Deck files are named after their content, the storage GC collects them.
+		if len(entry.Paths) > 0 && r.decks.storage != nil {
+			if err := r.decks.storage.DeleteFiles(entry.Bucket, entry.Paths); err != nil {
+				return err
+			}
+		}
+		outboxMetrics.Add("compensated", 1)
+		return nil
+	}
+
+	if err := applyOutboxRecord(entry); err != nil {
+		return err
+	}
+	log.Printf("Outbox: wrote the %s record of entry %s", entry.Kind, entry.ID)
+	outboxMetrics.Add("completed", 1)
+	return nil
+}
+
+// applyOutboxRecord writes the record of an entry whose files are stored
+func applyOutboxRecord(entry *outboxEntry) error {
+	switch entry.Kind {
+	case outboxUserFile:
+		var file model.UserFile
+		if err := json.Unmarshal(entry.Record, &file); err != nil {
+			return err
+		}
+		err := supabaseREST("POST", "user_files", file, nil)
+		// Already written before the entry could be removed
+		var statusErr *resilience.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+			return nil
+		}
+		return err
+
+	case outboxDeckFiles:
+		var files deckFilesRecord
+		if err := json.Unmarshal(entry.Record, &files); err != nil {
+			return err
+		}
+		previous := "pdf_url=eq." + url.QueryEscape(files.PreviousPDF)
+		if files.PreviousPDF == "" {
+			previous = "or=(pdf_url.is.null,pdf_url.eq.)"
+		}
+		update := map[string]string{
+			"pdf_url":      files.PdfURL,
+			"html_url":     files.HtmlURL,
+			"markdown_url": files.MarkdownURL,
+			"status":       "completed",
+		}
+		return supabaseREST("PATCH", fmt.Sprintf("pitch_decks?id=eq.%s&%s", files.DeckID, previous), update, nil)
+	}
+	return fmt.Errorf("unknown outbox entry kind %q", entry.Kind)
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"pitch-deck-generator/internal/render"
+)
+
+// Profiles of the PDF of a deck
+const (
+	ExportStandard = "standard"
+	// PDF/A-2b, for document management systems that archive decks
+	ExportPDFA = "pdfa"
+)
+
+var exportProfiles = map[string]bool{
+	ExportStandard: true,
+	ExportPDFA:     true,
+}
+
+// convertPDFA converts the PDF of a deck to PDF/A in its place. A deck that cannot be
+// converted is still delivered, as a standard PDF.
+func (r *Renderer) convertPDFA(deckID, pdfPath string) {
+	defer r.acquire()()
+	rendererMetrics.Add("pdfa_conversions", 1)
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout)
+	defer cancel()
+
+	pdfaPath := strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + ".pdfa.pdf"
+	if err := render.PDFA(ctx, pdfPath, pdfaPath); err != nil {
+		rendererMetrics.Add("pdfa_failures", 1)
+		log.Printf("Failed to convert the PDF of deck %s to PDF/A: %v", deckID, err)
+		os.Remove(pdfaPath)
+		return
+	}
+	if err := os.Rename(pdfaPath, pdfPath); err != nil {
+		log.Printf("Failed to replace the PDF of deck %s with its PDF/A version: %v", deckID, err)
+	}
+}
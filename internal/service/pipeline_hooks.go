@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+
+	"pitch-deck-generator/internal/hooks"
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/tracing"
+)
+
+// runHooks runs the hooks of a stage of the generation of a deck on payload and
+// returns it as they left it
+func runHooks(ctx context.Context, stage hooks.Stage, deck *model.PitchDeckInfo, payload hooks.Payload) (hooks.Payload, error) {
+	if !hooks.Registered(stage) {
+		return payload, nil
+	}
+	payload.DeckID = deck.ID
+	payload.UserID = deck.UserID
+	payload.TenantID = deck.TenantID
+
+	ctx, span := tracing.Start(ctx, "hooks."+string(stage))
+	defer span.End()
+	err := hooks.Run(ctx, stage, &payload)
+	tracing.RecordError(span, err)
+	return payload, err
+}
+
+// postLLMHooks runs the post-LLM hooks on the markdown generated for a deck
+func (s *PitchDeckService) postLLMHooks(ctx context.Context, deck *model.PitchDeckInfo, markdown string) (string, error) {
+	payload, err := runHooks(ctx, hooks.PostLLM, deck, hooks.Payload{Markdown: markdown})
+	return payload.Markdown, err
+}
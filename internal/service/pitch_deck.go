@@ -2,28 +2,47 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"pitch-deck-generator/internal/chaos"
+	"pitch-deck-generator/internal/hooks"
 	"pitch-deck-generator/internal/model"
 	"pitch-deck-generator/internal/progress"
+	"pitch-deck-generator/internal/render"
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/internal/sanitize"
+	"pitch-deck-generator/internal/tracing"
 	"pitch-deck-generator/prompts"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PitchDeckService struct {
 	storage  model.StorageService
+	repo     model.DeckRepository
 	progress *progress.Tracker
+
+	// Generations running in the background, waited for on shutdown
+	jobsMu   sync.Mutex
+	jobs     sync.WaitGroup
+	running  map[string]*model.PitchDeckInfo
+	draining bool
+	// Spans of the running generations, their failures are recorded on them
+	spans map[string]trace.Span
 }
 
 type InfomaniakRequest struct {
@@ -39,14 +58,84 @@ type Message struct {
 	Content string `json:"content"`
 }
 
-func NewPitchDeckService(storage model.StorageService, progress *progress.Tracker) *PitchDeckService {
+func NewPitchDeckService(storage model.StorageService, repo model.DeckRepository, progress *progress.Tracker) *PitchDeckService {
 	return &PitchDeckService{
 		storage:  storage,
+		repo:     repo,
 		progress: progress,
+		running:  make(map[string]*model.PitchDeckInfo),
+		spans:    make(map[string]trace.Span),
 	}
 }
 
-func (s *PitchDeckService) Create(data model.PitchDeckData, userID string) (*model.PitchDeckInfo, error) {
+// Create starts the generation of a deck. The record is created with the token of
+// the user in ctx, if any, and completed in the background with the service key.
+func (s *PitchDeckService) Create(ctx context.Context, data model.PitchDeckData, userID string) (*model.PitchDeckInfo, error) {
+	if err := s.acceptingJobs(); err != nil {
+		return nil, err
+	}
+
+	if data.ProjectID != "" {
+		project, err := getProject(data.ProjectID, userID, model.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+		applyProjectDefaults(&data, project)
+	}
+
+	if err := checkNewDeck(userID, data.Theme); err != nil {
+		return nil, err
+	}
+	if err := checkGeneration(userID, data.Generation); err != nil {
+		return nil, err
+	}
+	if _, err := deckPrompt(data.DeckType); err != nil {
+		return nil, err
+	}
+	if err := checkFundingStage(data); err != nil {
+		return nil, err
+	}
+	if err := checkCompetitors(data.Competitors); err != nil {
+		return nil, err
+	}
+	if err := checkTeam(data.TeamMembers); err != nil {
+		return nil, err
+	}
+	if err := checkSlideLayouts(data.SlideLayouts); err != nil {
+		return nil, err
+	}
+	if err := checkCustomSlides(data.CustomSlides); err != nil {
+		return nil, err
+	}
+	if data.Website != "" {
+		if _, err := websiteURL(data.Website); err != nil {
+			return nil, err
+		}
+	}
+	if data.ProductDemoVideo != "" {
+		if _, err := parseDemoVideo(data.ProductDemoVideo); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate the syntax highlighting style before starting the generation
+	if data.CodeTheme != "" {
+		if _, ok := codeThemes[strings.ToLower(data.CodeTheme)]; !ok {
+			return nil, fmt.Errorf("%w: unknown code theme %q, expected one of %s",
+				model.ErrInvalidInput, data.CodeTheme, strings.Join(CodeThemes(), ", "))
+		}
+	}
+
+	if data.EmojiPolicy != "" && !emojiPolicies[data.EmojiPolicy] {
+		return nil, fmt.Errorf("%w: unknown emoji policy %q, expected keep, strip or image",
+			model.ErrInvalidInput, data.EmojiPolicy)
+	}
+
+	if data.ExportProfile != "" && !exportProfiles[data.ExportProfile] {
+		return nil, fmt.Errorf("%w: unknown export profile %q, expected standard or pdfa",
+			model.ErrInvalidInput, data.ExportProfile)
+	}
+
 	// Generate unique ID for the deck
 	deckID := uuid.New().String()
 
@@ -58,17 +147,30 @@ func (s *PitchDeckService) Create(data model.PitchDeckData, userID string) (*mod
 		ID:        deckID,
 		UserID:    userID,
 		Name:      data.ProjectName,
+		ProjectID: data.ProjectID,
 		Status:    "processing",
 		CreatedAt: time.Now(),
 	}
 
+	assignSlug(deckInfo)
+
+	// Create the record upfront so failures can be recorded on it
+	if err := s.createRecord(ctx, deckInfo, &data); err != nil {
+		log.Printf("Error creating pitch deck record: %v", err)
+	}
+	recordAudit(deckID, userID, AuditDeckCreated, map[string]interface{}{
+		"name":       deckInfo.Name,
+		"project_id": deckInfo.ProjectID,
+		"source":     "form",
+	})
+
 	// Start async processing
-	go s.processDeck(data, deckInfo, progressChan)
+	s.startJob(deckInfo, func(ctx context.Context) { s.processDeck(ctx, data, deckInfo, progressChan) })
 
 	return deckInfo, nil
 }
 
-func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model.PitchDeckInfo, progressChan chan string) {
+func (s *PitchDeckService) processDeck(ctx context.Context, data model.PitchDeckData, deckInfo *model.PitchDeckInfo, progressChan chan string) {
 	// Create temporary directory for this deck
 	deckDir := filepath.Join("temp", deckInfo.ID)
 	os.MkdirAll(deckDir, os.ModePerm)
@@ -81,8 +183,63 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 		Message:     "Processing images...",
 	})
 
+	// Without an uploaded logo, the logo is taken from the website of the company
+	if data.CompanyLogo == "" && data.Website != "" {
+		if logoURL, err := s.logoFromWebsite(data.Website, deckInfo.UserID); err != nil {
+			log.Printf("Failed to fetch logo of deck %s from its website: %v", deckInfo.ID, err)
+		} else {
+			data.CompanyLogo = logoURL
+			// Saved with the answers, so the deck is regenerated with the same logo
+			if err := s.saveInput(context.Background(), s.repo, deckInfo, data); err != nil {
+				log.Printf("Failed to save logo of deck %s: %v", deckInfo.ID, err)
+			}
+		}
+	}
+
 	// Process images
+	_, span := tracing.Start(ctx, "deck.images")
 	imagePaths := s.processImages(data, deckDir)
+	span.End()
+
+	var competitors []prompts.CompetitorProfile
+	if len(data.Competitors) > 0 {
+		s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
+			Status:      "processing",
+			CurrentStep: 1,
+			Message:     "Researching competitors...",
+		})
+		_, span := tracing.Start(ctx, "deck.research_competitors", attribute.Int("competitors", len(data.Competitors)))
+		competitors = researchCompetitors(data.Competitors)
+		span.End()
+	}
+
+	var team prompts.Team
+	if hasTeamEnrichment(data.TeamMembers) {
+		s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
+			Status:      "processing",
+			CurrentStep: 1,
+			Message:     "Reading team profiles...",
+		})
+		_, span := tracing.Start(ctx, "deck.enrich_team", attribute.Int("team.members", len(data.TeamMembers)))
+		team = s.enrichTeam(data.TeamMembers, deckDir)
+		span.End()
+	}
+
+	// Market sizes left empty are estimated for the industry, the deck is generated
+	// without them when the research fails
+	var marketEstimates []prompts.MarketEstimate
+	if missing := missingMarketSizes(data); len(missing) > 0 && strings.TrimSpace(data.Industry) != "" {
+		s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
+			Status:      "processing",
+			CurrentStep: 1,
+			Message:     "Estimating market size...",
+		})
+		estimates, err := estimateMarket(ctx, data, missing)
+		if err != nil {
+			log.Printf("Failed to estimate market of deck %s: %v", deckInfo.ID, err)
+		}
+		marketEstimates = estimates
+	}
 
 	// Generate markdown content
 	s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
@@ -91,16 +248,143 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 		Message:     "Generating content...",
 	})
 
-	markdown, err := s.generateMarkdown(data, imagePaths)
+	// Hooks may change the answers before the prompt is built from them
+	payload, err := runHooks(ctx, hooks.PrePrompt, deckInfo, hooks.Payload{Data: &data})
+	if err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to prepare content", err)
+		return
+	}
+	if payload.Data != nil {
+		data = *payload.Data
+	}
+
+	promptName, err := deckPrompt(data.DeckType)
 	if err != nil {
-		s.handleError(deckInfo.ID, "Failed to generate content", err)
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to generate content", err)
 		return
 	}
+	// The prompt version is recorded, to know what a deck was generated with
+	prompt := activePrompt(promptName)
+	recordPromptVersion(deckInfo.ID, prompt)
+
+	research := deckResearch{Competitors: competitors, MarketEstimates: marketEstimates, Team: team}
+	markdown, err := s.generateMarkdown(ctx, data, imagePaths, research, prompt.Template)
+	if err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to generate content", err)
+		return
+	}
+	if markdown, err = s.postLLMHooks(ctx, deckInfo, markdown); err != nil {
+		s.handleError(deckInfo.ID, stageGenerate, "Failed to check content", err)
+		return
+	}
+	markdown = insertCustomSlides(markdown, data.CustomSlides)
+	if data.ProductDemoVideo != "" {
+		video, err := parseDemoVideo(data.ProductDemoVideo)
+		if err == nil {
+			markdown, err = insertDemoVideoSlide(markdown, video)
+		}
+		if err != nil {
+			log.Printf("Failed to add the demo video to deck %s: %v", deckInfo.ID, err)
+		}
+	}
+
+	s.renderDeck(ctx, deckInfo, markdown, renderOptionsFor(data), deckDir)
+}
+
+// IndustryTemplates lists the industries whose decks get a specialized structure
+func (s *PitchDeckService) IndustryTemplates() []model.IndustryTemplate {
+	templates := make([]model.IndustryTemplate, 0, len(prompts.IndustryTemplates))
+	for _, t := range prompts.IndustryTemplates {
+		templates = append(templates, model.IndustryTemplate{
+			ID:     t.ID,
+			Name:   t.Name,
+			Slides: t.Slides,
+		})
+	}
+	return templates
+}
+
+// renderOptions controls how generated markdown is turned into PDF and HTML
+type renderOptions struct {
+	Theme       string
+	CodeTheme   string
+	Language    string
+	EmojiPolicy string
+	// Metadata of the PDF, its title defaults to the name of the deck
+	Metadata      render.PDFInfo
+	TaggedPDF     bool
+	ExportProfile string
+}
+
+func renderOptionsFor(data model.PitchDeckData) renderOptions {
+	return renderOptions{
+		Theme:         data.Theme,
+		CodeTheme:     data.CodeTheme,
+		Language:      data.Language,
+		EmojiPolicy:   data.EmojiPolicy,
+		Metadata:      pdfMetadata(data),
+		TaggedPDF:     data.AccessiblePDF,
+		ExportProfile: data.ExportProfile,
+	}
+}
+
+// renderDeck converts the generated markdown to PDF and HTML, uploads the results
+// and finalizes the deck record and progress channel
+func (s *PitchDeckService) renderDeck(ctx context.Context, deckInfo *model.PitchDeckInfo, markdown string, opts renderOptions, deckDir string) {
+	// Hooks see the markdown before it is sanitized, what they add is sanitized too
+	payload, err := runHooks(ctx, hooks.PreRender, deckInfo, hooks.Payload{Markdown: markdown})
+	if err != nil {
+		s.handleError(deckInfo.ID, stageRender, "Failed to prepare rendering", err)
+		return
+	}
+	markdown = payload.Markdown
+
+	// The markdown comes from the LLM or the user, its HTML must not run in viewers
+	markdown = sanitize.Markdown(markdown)
+
+	// Normalize characters that break PDF fonts before anything else touches the markdown
+	markdown, report := normalizeUnicode(markdown, opts.EmojiPolicy)
+	if !report.empty() {
+		log.Printf("Unicode normalization for deck %s (emoji policy %s): %s", deckInfo.ID, opts.EmojiPolicy, report)
+	}
+
+	// Apply the syntax highlighting style for code blocks
+	markdown, err = injectCodeTheme(markdown, opts.CodeTheme)
+	if err != nil {
+		s.handleError(deckInfo.ID, stageRender, "Failed to apply code theme", err)
+		return
+	}
+
+	// Style the slides given a layout that is not part of the theme
+	markdown = injectLayoutCSS(markdown)
+
+	// Mirror the layout for right-to-left languages
+	rtl := isRTLLanguage(opts.Language)
+	if rtl {
+		markdown = applyRTLLayout(markdown)
+	}
+
+	// Decks made on plans with a watermark say so on each slide
+	if watermarked(ctx, deckInfo.UserID) {
+		markdown = insertAfterFrontMatter(markdown, watermarkCSS(productName(deckInfo.TenantID)))
+	}
+
+	// Use a font with CJK glyphs for Chinese, Japanese and Korean decks
+	if font, ok := cjkFontFor(opts.Language); ok {
+		if err := verifyFontCoverage(font); err != nil {
+			s.handleError(deckInfo.ID, stageRender, "Missing fonts for deck language", err)
+			return
+		}
+		markdown = insertAfterFrontMatter(markdown, cjkFontCSS(font))
+	}
+
+	// Describe the images left without alternative text
+	markdown = describeImages(markdown)
 
 	// Save markdown file
 	mdPath := filepath.Join(deckDir, "presentation.md")
 	if err := os.WriteFile(mdPath, []byte(markdown), 0644); err != nil {
-		s.handleError(deckInfo.ID, "Failed to save markdown", err)
+		s.handleError(deckInfo.ID, stageRender, "Failed to save markdown", err)
 		return
 	}
 
@@ -114,13 +398,74 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 	pdfPath := filepath.Join("outputs", deckInfo.ID+".pdf")
 	htmlPath := filepath.Join("outputs", deckInfo.ID+".html")
 
-	if err := s.convertToPDF(mdPath, pdfPath, data.Theme); err != nil {
-		s.handleError(deckInfo.ID, "Failed to convert to PDF", err)
+	if err := s.convertToPDF(ctx, mdPath, pdfPath, opts.Theme); err != nil {
+		logRenderError(deckInfo.ID, "pdf", err)
+		s.handleError(deckInfo.ID, stageRender, "Failed to convert to PDF", err)
 		return
 	}
 
-	if err := s.convertToHTML(mdPath, htmlPath, data.Theme); err != nil {
-		s.handleError(deckInfo.ID, "Failed to convert to HTML", err)
+	if err := s.convertToHTML(ctx, mdPath, htmlPath, opts.Theme); err != nil {
+		logRenderError(deckInfo.ID, "html", err)
+		s.handleError(deckInfo.ID, stageRender, "Failed to convert to HTML", err)
+		return
+	}
+
+	if rtl {
+		if err := setHTMLDirection(htmlPath); err != nil {
+			s.handleError(deckInfo.ID, stageRender, "Failed to apply RTL layout", err)
+			return
+		}
+	}
+
+	if err := embedDemoPlayers(htmlPath); err != nil {
+		s.handleError(deckInfo.ID, stageRender, "Failed to embed demo video", err)
+		return
+	}
+
+	// The native renderer does not highlight code, only marp-cli output is checked
+	if !renderer.native {
+		if err := verifyCodeHighlighting(markdown, htmlPath, pdfPath, opts.CodeTheme); err != nil {
+			s.handleError(deckInfo.ID, stageRender, "Code highlighting check failed", err)
+			return
+		}
+	}
+
+	// A deck that cannot be printed tagged is still delivered, untagged
+	if opts.TaggedPDF {
+		taggedPath := filepath.Join("outputs", deckInfo.ID+".tagged.pdf")
+		_, span := tracing.Start(ctx, "render.tagged_pdf", attribute.String("render.engine", "native"))
+		err := renderer.convertTaggedPDF(mdPath, taggedPath, opts.Theme)
+		tracing.RecordError(span, err)
+		span.End()
+		if err != nil {
+			logRenderError(deckInfo.ID, "tagged_pdf", err)
+			os.Remove(taggedPath)
+		} else if err := os.Rename(taggedPath, pdfPath); err != nil {
+			log.Printf("Failed to replace the PDF of deck %s with its tagged version: %v", deckInfo.ID, err)
+		}
+	}
+
+	metadata := opts.Metadata
+	if metadata.Title == "" {
+		metadata.Title = deckInfo.Name
+	}
+	metadata.Creator = productName(deckInfo.TenantID)
+	if err := render.SetPDFInfo(pdfPath, metadata); err != nil {
+		log.Printf("Failed to set the PDF metadata of deck %s: %v", deckInfo.ID, err)
+	}
+
+	// Converted last, PDF/A requires the metadata to be part of the conversion
+	if opts.ExportProfile == ExportPDFA {
+		renderer.convertPDFA(deckInfo.ID, pdfPath)
+	}
+
+	// Hooks may check the rendered files before they are published
+	_, err = runHooks(ctx, hooks.PostRender, deckInfo, hooks.Payload{
+		Markdown: markdown,
+		Files:    map[string]string{"pdf": pdfPath, "html": htmlPath},
+	})
+	if err != nil {
+		s.handleError(deckInfo.ID, stageRender, "Failed to check rendered files", err)
 		return
 	}
 
@@ -135,24 +480,83 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 
 	// Verify if storage service is not nil
 	if s.storage != nil {
+		bucket, folder, err := tenantStorage(deckInfo.TenantID)
+		if err != nil {
+			s.handleError(deckInfo.ID, stageUpload, "Failed to load storage of tenant", err)
+			return
+		}
+		bucket = deckFilesBucket(bucket, deckInfo.IsPublic)
+
+		// Rendered files are named after their content, the previous render stays
+		// until the storage GC collects it
+		previous := []string{deckInfo.PdfURL, deckInfo.HtmlURL, deckInfo.MarkdownURL}
+
+		outbox, err := beginOutbox(outboxDeckFiles, deckInfo.UserID, bucket, nil, nil)
+		if err != nil {
+			s.handleError(deckInfo.ID, stageUpload, "Failed to record upload", err)
+			return
+		}
+
 		// Upload PDF
-		pdfURL, err = s.storage.UploadFile(pdfPath, "pitch-decks", deckInfo.ID+".pdf")
+		err = traceUpload(ctx, bucket, pdfPath, func() (err error) {
+			pdfURL, err = s.storage.UploadImmutable(pdfPath, bucket, folder+deckInfo.ID+".pdf")
+			return err
+		})
 		if err != nil {
-			s.handleError(deckInfo.ID, "Failed to upload PDF", err)
+			s.handleError(deckInfo.ID, stageUpload, "Failed to upload PDF", err)
 			return
 		}
 
 		// Upload HTML
-		htmlURL, err = s.storage.UploadFile(htmlPath, "pitch-decks", deckInfo.ID+".html")
+		err = traceUpload(ctx, bucket, htmlPath, func() (err error) {
+			htmlURL, err = s.storage.UploadImmutable(htmlPath, bucket, folder+deckInfo.ID+".html")
+			return err
+		})
+		if err != nil {
+			s.handleError(deckInfo.ID, stageUpload, "Failed to upload HTML", err)
+			return
+		}
+
+		// Keep the markdown source so the deck can be exported or edited later
+		err = traceUpload(ctx, bucket, mdPath, func() (err error) {
+			deckInfo.MarkdownURL, err = s.uploadMarkdown(deckInfo, bucket, folder, mdPath, markdown)
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to upload markdown for deck %s: %v", deckInfo.ID, err)
+		}
+		indexDeckContent(deckInfo.ID, markdown)
+		recordDeckVersion(deckInfo.ID, deckInfo.UserID, markdown)
+
+		recordRenderAssets(deckInfo, bucket, []renderAsset{
+			{Kind: assetPDF, URL: pdfURL, LocalPath: pdfPath},
+			{Kind: assetHTML, URL: htmlURL, LocalPath: htmlPath},
+			{Kind: assetMarkdown, URL: deckInfo.MarkdownURL, LocalPath: mdPath},
+		})
+
+		err = outbox.stored(deckFilesRecord{
+			DeckID:      deckInfo.ID,
+			PreviousPDF: deckInfo.PdfURL,
+			PdfURL:      pdfURL,
+			HtmlURL:     htmlURL,
+			MarkdownURL: deckInfo.MarkdownURL,
+		})
 		if err != nil {
-			s.handleError(deckInfo.ID, "Failed to upload HTML", err)
+			s.handleError(deckInfo.ID, stageUpload, "Failed to confirm upload", err)
 			return
 		}
 
-		err = SavePitchDeckRecord(deckInfo.ID, deckInfo.UserID, data.ProjectName, pdfURL, htmlURL)
+		deckInfo.PdfURL = pdfURL
+		deckInfo.HtmlURL = htmlURL
+		deckInfo.Status = "completed"
+		err = s.saveRecord(ctx, deckInfo)
 		if err != nil {
-			log.Printf("Error saving pitch deck record in supabase: %v", err)
+			// The outbox reconciler points the deck to its files later
+			log.Printf("Error saving pitch deck record: %v", err)
+		} else {
+			outbox.done()
 		}
+		purgeCDN(replacedURLs(previous, []string{deckInfo.PdfURL, deckInfo.HtmlURL, deckInfo.MarkdownURL})...)
 	}
 
 	// Update deck info with URLs
@@ -160,19 +564,21 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 	deckInfo.HtmlURL = htmlURL
 	deckInfo.Status = "completed"
 
-	// Send final update
+	// Send final update, with the URLs of the files of a private deck served by the API
+	files := deckInfo.WithFileProxy(fileProxyBaseURL())
 	s.progress.SendUpdate(deckInfo.ID, progress.ProgressUpdate{
 		Status:      "completed",
 		CurrentStep: 5,
 		Message:     "Generation completed",
-		DownloadUrl: pdfURL,
-		ViewUrl:     htmlURL,
+		DownloadUrl: files.PdfURL,
+		ViewUrl:     files.HtmlURL,
 	})
 
 	// Update status in database
 	if err := s.UpdateStatus(deckInfo.ID, "completed"); err != nil {
 		log.Printf("Failed to persist completed status: %v", err)
 	}
+	s.notifyDeckStatus(deckInfo.ID)
 
 	// Cleanup local files after successful upload
 	os.Remove(pdfPath)
@@ -184,216 +590,354 @@ func (s *PitchDeckService) processDeck(data model.PitchDeckData, deckInfo *model
 }
 
 func (s *PitchDeckService) Get(deckID string) (*model.PitchDeckInfo, error) {
-	// Make request to Supabase
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+	if deck, ok := deckCache.getDeck(deckID); ok {
+		return deck, nil
+	}
 
-	apiURL := fmt.Sprintf("%s/rest/v1/pitch_decks?id=eq.%s", supabaseURL, deckID)
-	req, err := http.NewRequest("GET", apiURL, nil)
+	// Shared URLs may use the slug of the deck instead of its ID
+	deck, err := s.repo.Get(context.Background(), deckID)
 	if err != nil {
+		// The last known record is served while Supabase is unavailable
+		if stale, ok := deckCache.staleDeck(deckID); ok && errors.Is(err, model.ErrUnavailable) {
+			log.Printf("Serving cached deck %s: %v", deckID, err)
+			return stale, nil
+		}
 		return nil, err
 	}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
+	deckCache.putDeck(deck)
+	return deck, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// loadMarkdown downloads the markdown source stored for a deck, decrypted
+func (s *PitchDeckService) loadMarkdown(deck *model.PitchDeckInfo) (string, error) {
+	if deck.MarkdownURL == "" {
+		return "", fmt.Errorf("%w: markdown source not available for this deck", model.ErrNotFound)
+	}
+	markdown, err := fetchText(deck.MarkdownURL)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	defer resp.Body.Close()
+	return unseal(markdown)
+}
 
-	var decks []model.PitchDeckInfo
-	if err := json.NewDecoder(resp.Body).Decode(&decks); err != nil {
-		return nil, err
+// uploadMarkdown stores the markdown source of a deck, encrypted when encryption at
+// rest is on. The file at mdPath is left in clear for the renderer.
+func (s *PitchDeckService) uploadMarkdown(deckInfo *model.PitchDeckInfo, bucket, folder, mdPath, markdown string) (string, error) {
+	if !encryptionEnabled() {
+		return s.storage.UploadImmutable(mdPath, bucket, folder+deckInfo.ID+".md")
 	}
-
-	if len(decks) == 0 {
-		return nil, fmt.Errorf("deck not found")
+	sealed, err := seal(deckInfo.UserID, markdown)
+	if err != nil {
+		return "", err
 	}
-
-	return &decks[0], nil
+	sealedPath := mdPath + ".sealed"
+	if err := os.WriteFile(sealedPath, []byte(sealed), 0644); err != nil {
+		return "", err
+	}
+	defer os.Remove(sealedPath)
+	return s.storage.UploadImmutable(sealedPath, bucket, folder+deckInfo.ID+".md")
 }
 
-func SavePitchDeckRecord(deckID, userID, name, pdfURL, htmlURL string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
-
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("supabase credentials not set")
+// saveInput stores the answers a deck is generated from, encrypted when encryption
+// at rest is on
+func (s *PitchDeckService) saveInput(ctx context.Context, repo model.DeckRepository, deckInfo *model.PitchDeckInfo, data model.PitchDeckData) error {
+	data, err := sealInput(deckInfo.UserID, data)
+	if err != nil {
+		return err
 	}
+	return repo.SaveInput(ctx, deckInfo.ID, deckInfo.UserID, data)
+}
 
-	// Create the record
-	record := model.PitchDeckInfo{
-		ID:        deckID,
-		UserID:    userID,
-		Name:      name,
-		PdfURL:    pdfURL,
-		HtmlURL:   htmlURL,
-		IsPublic:  false,       // Default to private
-		Status:    "completed", // Set status to completed
-		CreatedAt: time.Now(),
+// loadInput returns the latest answers of a deck, decrypted, nil when it has none
+func (s *PitchDeckService) loadInput(deckID string) (*model.PitchDeckData, error) {
+	data, err := s.repo.Input(context.Background(), deckID)
+	if err != nil {
+		return nil, err
 	}
+	return unsealInput(data)
+}
 
-	// Convert to JSON
-	jsonData, err := json.Marshal(record)
+// createRecord saves a new deck, and the answers it is generated from when set, in one transaction
+func (s *PitchDeckService) createRecord(ctx context.Context, deckInfo *model.PitchDeckInfo, data *model.PitchDeckData) error {
+	tenantID, err := userTenant(deckInfo.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal record: %w", err)
+		return err
 	}
+	deckInfo.TenantID = tenantID
 
-	// Create the request
-	apiURL := fmt.Sprintf("%s/rest/v1/pitch_decks", supabaseURL)
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	err = s.repo.WithTx(ctx, func(repo model.DeckRepository) error {
+		if err := repo.Save(ctx, deckInfo); err != nil {
+			return err
+		}
+		if data == nil {
+			return nil
+		}
+		return s.saveInput(ctx, repo, deckInfo, *data)
+	})
+	deckCache.invalidate()
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Prefer", "return=minimal")
+	publishDeckStatus(deckInfo)
+	return nil
+}
 
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// saveRecord saves the deck, it is created when the generation starts and completed at the end
+func (s *PitchDeckService) saveRecord(ctx context.Context, deckInfo *model.PitchDeckInfo) error {
+	err := s.repo.Save(ctx, deckInfo)
+	deckCache.invalidate()
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check response
-	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to save record: %s", string(body))
+		return err
 	}
 
+	publishDeckStatus(deckInfo)
 	return nil
 }
 
-func (s *PitchDeckService) UpdateVisibility(deckID string, userID string, isPublic bool) error {
-	// Verify ownership
+// authorizedDeck returns the deck when the user has at least minRole on it
+func (s *PitchDeckService) authorizedDeck(deckID, userID, minRole string) (*model.PitchDeckInfo, error) {
 	deck, err := s.Get(deckID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
 	}
-
-	if deck.UserID != userID {
-		return fmt.Errorf("unauthorized")
+	// The decks of other tenants do not exist for the user
+	if err := checkTenant(deck.TenantID, userID); err != nil {
+		return nil, fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if err := authorizeResource(deck.UserID, deck.OrgID, userID, minRole); err != nil {
+		return nil, err
 	}
+	return deck, nil
+}
 
-	// Update in Supabase
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+// GetForUser returns a deck the user created or that is shared with one of their organizations
+func (s *PitchDeckService) GetForUser(deckID, userID string) (*model.PitchDeckInfo, error) {
+	return s.authorizedDeck(deckID, userID, model.RoleViewer)
+}
 
-	data := map[string]bool{"is_public": isPublic}
-	jsonData, err := json.Marshal(data)
+func (s *PitchDeckService) UpdateVisibility(ctx context.Context, deckID string, userID string, isPublic bool, revision int) error {
+	// Verify permissions
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
 	if err != nil {
 		return err
 	}
-
-	apiURL := fmt.Sprintf("%s/rest/v1/pitch_decks?id=eq.%s", supabaseURL, deckID)
-	req, err := http.NewRequest("PATCH", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
+	if isPublic && deck.TakenDownAt != nil {
+		return fmt.Errorf("%w: this deck was taken down for violating our terms and cannot be made public", model.ErrForbidden)
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+	if err := s.reviseDeck(ctx, deck.ID, revision); err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	// The files of a deck made private leave the public bucket before it is, those
+	// of a deck made public are served through the API until they are moved
+	if !isPublic {
+		if err := s.moveDeckFiles(deck, false); err != nil {
+			deckCache.invalidate()
+			return fmt.Errorf("failed to move the files of the deck to private storage: %w", err)
+		}
+	}
+	err = s.repo.UpdateVisibility(ctx, deck.ID, isPublic)
+	deckCache.invalidate()
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to update visibility")
+	if isPublic {
+		if err := s.moveDeckFiles(deck, true); err != nil {
+			log.Printf("Failed to move the files of deck %s to public storage: %v", deck.ID, err)
+		}
+		deckCache.invalidate()
 	}
 
+	recordAudit(deck.ID, userID, AuditVisibilityChanged, map[string]interface{}{
+		"from": deck.IsPublic,
+		"to":   isPublic,
+	})
 	return nil
 }
 
-func (s *PitchDeckService) ListUserDecks(userID string) ([]model.PitchDeckInfo, error) {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
-
-	apiURL := fmt.Sprintf("%s/rest/v1/pitch_decks?user_id=eq.%s&order=created_at.desc", supabaseURL, userID)
-	req, err := http.NewRequest("GET", apiURL, nil)
+// ListUserDecks lists all the decks of a user, restricted to a project when projectID
+// is set ("none" lists the decks without a project)
+func (s *PitchDeckService) ListUserDecks(ctx context.Context, userID, projectID string) ([]model.PitchDeckInfo, error) {
+	page, err := s.ListDecks(ctx, userID, model.DeckListOptions{ProjectID: projectID})
 	if err != nil {
 		return nil, err
 	}
+	return page.Decks, nil
+}
 
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
+// deckSortColumns are the columns a deck list can be sorted on, with their default order
+var deckSortColumns = map[string]string{
+	"created_at": "desc",
+	"name":       "asc",
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+// ListDecks returns a page of the decks of a user and the number of decks matching
+// the filters. A zero limit returns every deck.
+func (s *PitchDeckService) ListDecks(ctx context.Context, userID string, opts model.DeckListOptions) (*model.DeckPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = "created_at"
+	}
+	defaultOrder, ok := deckSortColumns[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: sort must be created_at or name", model.ErrInvalidInput)
+	}
+	if opts.Order == "" {
+		opts.Order = defaultOrder
+	}
+	if opts.Order != "asc" && opts.Order != "desc" {
+		return nil, fmt.Errorf("%w: order must be asc or desc", model.ErrInvalidInput)
+	}
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must be positive", model.ErrInvalidInput)
+	}
+
+	cacheKey := fmt.Sprintf("%s/%v", userID, opts)
+	if decks, total, ok := deckCache.getList(cacheKey); ok {
+		return &model.DeckPage{Decks: decks, Total: total, Limit: opts.Limit, Offset: opts.Offset}, nil
 	}
-	defer resp.Body.Close()
 
-	var decks []model.PitchDeckInfo
-	if err := json.NewDecoder(resp.Body).Decode(&decks); err != nil {
+	decks, total, err := s.listDecks(ctx, userID, opts)
+	if err != nil {
+		// The last known list is served while Supabase is unavailable
+		if stale, staleTotal, ok := deckCache.staleList(cacheKey); ok && errors.Is(err, model.ErrUnavailable) {
+			log.Printf("Serving cached deck list of user %s: %v", userID, err)
+			return &model.DeckPage{Decks: stale, Total: staleTotal, Limit: opts.Limit, Offset: opts.Offset}, nil
+		}
 		return nil, err
 	}
 
-	return decks, nil
+	deckCache.putList(cacheKey, decks, total)
+	return &model.DeckPage{Decks: decks, Total: total, Limit: opts.Limit, Offset: opts.Offset}, nil
 }
 
-func (s *PitchDeckService) UpdateStatus(deckID string, status string) error {
-	supabaseURL := os.Getenv("SUPABASE_URL")
-	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
-
-	data := map[string]string{"status": status}
-	jsonData, err := json.Marshal(data)
+// listDecks lists the decks of a user and those shared with their organizations
+func (s *PitchDeckService) listDecks(ctx context.Context, userID string, opts model.DeckListOptions) ([]model.PitchDeckInfo, int, error) {
+	// Decks shared with the organizations of the user are listed with their own
+	members, err := memberships(userID)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	orgIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		orgIDs = append(orgIDs, m.OrgID)
+	}
+	if opts.TenantID, err = userTenant(userID); err != nil {
+		return nil, 0, err
 	}
 
-	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("supabase credentials not set")
+	return s.repo.List(ctx, userID, orgIDs, opts)
+}
+
+// A client opening a deck again within this window is not counted as a new view
+const viewDedupeWindow = 30 * time.Minute
+
+// viewLog remembers when each client was last counted as viewing a deck
+type viewLog struct {
+	sync.Mutex
+	seen map[string]time.Time
+}
+
+var recentViews = &viewLog{seen: map[string]time.Time{}}
+
+// first reports whether key was not seen within viewDedupeWindow, and records it
+func (l *viewLog) first(key string) bool {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if seen, ok := l.seen[key]; ok && now.Sub(seen) < viewDedupeWindow {
+		return false
+	}
+	// Drop the expired entries now and then so the log does not grow unbounded
+	if len(l.seen) > 10000 {
+		for k, seen := range l.seen {
+			if now.Sub(seen) >= viewDedupeWindow {
+				delete(l.seen, k)
+			}
+		}
 	}
+	l.seen[key] = now
+	return true
+}
 
-	apiURL := fmt.Sprintf("%s/rest/v1/pitch_decks?id=eq.%s", supabaseURL, deckID)
-	req, err := http.NewRequest("PATCH", apiURL, bytes.NewBuffer(jsonData))
+// RecordView counts a view of a public deck. The counter is incremented by the
+// record_deck_view function in the database so concurrent views are not lost. A
+// client address is counted once per deck within viewDedupeWindow, so reloading
+// the viewer or replaying the request does not inflate the count.
+func (s *PitchDeckService) RecordView(deckID, clientIP string) error {
+	deck, err := s.publicDeck(deckID)
 	if err != nil {
 		return err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("apikey", supabaseKey)
-	req.Header.Set("Authorization", "Bearer "+supabaseKey)
-	req.Header.Set("Prefer", "return=minimal")
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	if !recentViews.first(deck.ID + " " + clientIP) {
+		return nil
 	}
-	defer resp.Body.Close()
 
-	log.Println("Updating status at URL:", apiURL)
+	params := map[string]string{"deck_id": deck.ID}
+	if err := supabaseWrite("POST", "rpc/record_deck_view", params); err != nil {
+		return fmt.Errorf("failed to record view: %w", err)
+	}
+	return nil
+}
 
-	// Check response
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Println("Response:", resp.StatusCode, string(body))
-		return fmt.Errorf("failed to update status: %s", string(body))
+func (s *PitchDeckService) UpdateStatus(deckID string, status string) error {
+	err := s.repo.UpdateStatus(context.Background(), deckID, status)
+	deckCache.invalidate()
+	if err != nil {
+		return err
 	}
 
+	s.publishStoredStatus(deckID)
 	return nil
 }
 
 // Helper methods
-func (s *PitchDeckService) handleError(deckID, message string, err error) {
+func (s *PitchDeckService) handleError(deckID, stage, message string, err error) {
+	tracing.RecordError(s.jobSpan(deckID), fmt.Errorf("%s: %w", message, err))
+
+	// Keep the failure classification on the deck record
+	code := "generation_failed"
+	var details string
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		code = renderErr.Code
+		details = outputTail(renderErr.Stderr)
+	}
+	if errors.Is(err, errInterrupted) {
+		code = "interrupted"
+	}
+	var rejected *hooks.RejectedError
+	if errors.As(err, &rejected) {
+		code = "rejected"
+	}
+	// Rejections by hooks are not errors, panics are reported where recovered
+	if errors.Is(err, errPanicked) {
+		code = "internal_error"
+	} else if code != "rejected" {
+		s.reportFailure(deckID, stage, code, err)
+	}
+
 	s.progress.SendUpdate(deckID, progress.ProgressUpdate{
-		Status:  "failed",
-		Message: fmt.Sprintf("%s: %v", message, err),
+		Status:    "failed",
+		Message:   fmt.Sprintf("%s: %v", message, err),
+		ErrorCode: code,
+		Details:   details,
 	})
-	s.UpdateStatus(deckID, "failed")
+
+	deadLetter(deckID, stage, code, fmt.Sprintf("%s: %v", message, err), err)
+
+	err = s.repo.RecordFailure(context.Background(), deckID, code, fmt.Sprintf("%s: %v", message, err))
+	deckCache.invalidate()
+	if err != nil {
+		log.Printf("Failed to record failure of deck %s: %v", deckID, err)
+		return
+	}
+	s.publishStoredStatus(deckID)
+	s.notifyDeckStatus(deckID)
 }
 
 func (s *PitchDeckService) processImages(data model.PitchDeckData, deckDir string) map[string]string {
@@ -423,87 +967,38 @@ func (s *PitchDeckService) processImages(data model.PitchDeckData, deckDir strin
 	return imagePaths
 }
 
-func (s *PitchDeckService) downloadImage(imageURL, deckDir, prefix string) string {
-	// Validate URL format
-	if !strings.HasPrefix(imageURL, "http") {
-		return imageURL // Return as-is if it's a local path
-	}
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	// Make the request
-	resp, err := client.Get(imageURL)
-	if err != nil {
-		log.Printf("Failed to download image from URL: %v", err)
-		return ""
-	}
-	defer resp.Body.Close()
-
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to download image, status: %d", resp.StatusCode)
-		return ""
-	}
-
-	// Determine file extension from Content-Type
-	contentType := resp.Header.Get("Content-Type")
-	ext := ".jpg" // Default extension
-
-	switch contentType {
-	case "image/jpeg":
-		ext = ".jpg"
-	case "image/png":
-		ext = ".png"
-	case "image/gif":
-		ext = ".gif"
-	case "image/webp":
-		ext = ".webp"
-	case "image/svg+xml":
-		ext = ".svg"
-	}
-
-	// Generate destination filename
-	destFileName := prefix + ext
-	destPath := filepath.Join(deckDir, destFileName)
+func (s *PitchDeckService) generateMarkdown(ctx context.Context, data model.PitchDeckData, imagePaths map[string]string, research deckResearch, template string) (string, error) {
+	// 	// Call the Infomaniak API with the prompt
+	// 	apiKey := os.Getenv("INFOMANIAK_API_KEY")
+	// 	productID := os.Getenv("INFOMANIAK_PRODUCT_ID")
+	// 	if apiKey == "" || productID == "" {
+	// 		return "", fmt.Errorf("missing Infomaniak API credentials")
+	// 	}
 
-	// Create the file
-	out, err := os.Create(destPath)
+	params, err := generationParamsFor(data.Generation)
 	if err != nil {
-		log.Printf("Failed to create file: %v", err)
-		return ""
+		return "", err
 	}
-	defer out.Close()
 
-	// Copy the response body to the file
-	_, err = io.Copy(out, resp.Body)
+	prompt, err := buildPrompt(data, imagePaths, research, template)
 	if err != nil {
-		log.Printf("Failed to save image: %v", err)
-		return ""
+		return "", err
 	}
 
-	// Return the original URL instead of just the filename
-	// This ensures the AI gets the full URL for the image
-	log.Println("HERE MY IMAGE: ", imageURL)
-	return imageURL
+	return s.generateFromPrompt(ctx, prompt, params)
 }
 
-func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths map[string]string) (string, error) {
-	// Get API keys from environment variables
-	googleKey := os.Getenv("GEMINI_API_KEY")
-	if googleKey == "" {
-		return "", fmt.Errorf("missing Gemini API key")
-	}
-
-	// 	// Call the Infomaniak API with the prompt
-	// 	apiKey := os.Getenv("INFOMANIAK_API_KEY")
-	// 	productID := os.Getenv("INFOMANIAK_PRODUCT_ID")
-	// 	if apiKey == "" || productID == "" {
-	// 		return "", fmt.Errorf("missing Infomaniak API credentials")
-	// 	}
+// deckResearch is what was researched for a deck before its generation
+type deckResearch struct {
+	Competitors     []prompts.CompetitorProfile
+	MarketEstimates []prompts.MarketEstimate
+	// Team members with their public profile and photo, nil when not researched
+	Team prompts.Team
+}
 
+// buildPrompt fills a version of the generation prompt with the answers of the form,
+// the paths of the images of the deck and what was researched for it
+func buildPrompt(data model.PitchDeckData, imagePaths map[string]string, research deckResearch, template string) (string, error) {
 	// Convert model.PitchDeckData to prompts.PitchDeckData
 	promptData := prompts.PitchDeckData{
 		// Project Information
@@ -527,6 +1022,11 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 		Valuation:           data.Valuation,
 		InvestmentStructure: data.InvestmentStructure,
 
+		// Business Model & Traction
+		RevenueModel:   data.RevenueModel,
+		Achievements:   data.Traction,
+		NextMilestones: data.NextMilestones,
+
 		// Market Opportunity
 		TAM:          data.TAM,
 		SAM:          data.SAM,
@@ -534,13 +1034,18 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 		TargetNiche:  data.TargetNiche,
 		MarketTrends: data.MarketTrends,
 		Industry:     data.Industry,
+		Geography:    data.Geography,
 
 		// Team Information
 		WhyYou:            data.WhyYou,
 		TeamQualification: data.TeamQualification,
 
 		// Theme and Visual Settings
-		Theme: data.Theme,
+		Theme:    data.Theme,
+		Language: data.Language,
+		RTL:      isRTLLanguage(data.Language),
+
+		AudiencePersona: data.AudiencePersona,
 
 		// Image Paths
 		LogoPath:         imagePaths["logo"],
@@ -549,15 +1054,10 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 	}
 
 	// Convert team members
-	var teamMembers []prompts.TeamMemberNew
-	for _, member := range data.TeamMembers {
-		teamMembers = append(teamMembers, prompts.TeamMemberNew{
-			Name:       member.Name,
-			Role:       member.Role,
-			Experience: member.Experience,
-		})
+	promptData.TeamMembers = research.Team
+	if promptData.TeamMembers == nil {
+		promptData.TeamMembers = teamMembers(data.TeamMembers)
 	}
-	promptData.TeamMembers = teamMembers
 
 	// Set contact info
 	promptData.ContactInfo.Email = data.ContactInfo.Email
@@ -565,11 +1065,76 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 	promptData.ContactInfo.Socials = data.ContactInfo.Socials
 	promptData.KeyTakeaways = data.KeyTakeaways
 
+	if stage, ok := prompts.FundingStages[data.FundingStage]; ok {
+		promptData.FundingStage = &stage
+	}
+	promptData.Competitors = research.Competitors
+	promptData.SlideLayouts = slideLayouts(data.SlideLayouts)
+	promptData.MarketEstimates = research.MarketEstimates
+
 	// Generate the prompt using the template
-	prompt, err := prompts.GeneratePitchDeckPrompt(promptData)
+	prompt, err := prompts.GeneratePitchDeckPromptFrom(template, promptData)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate prompt: %w", err)
 	}
+	return prompt, nil
+}
+
+// generateFromPrompt sends a prompt to the LLM and returns the cleaned Marp markdown
+func (s *PitchDeckService) generateFromPrompt(ctx context.Context, prompt string, params generationParams) (string, error) {
+	stop := timeStep(ctx, stepLLM)
+	markdown, err := callLLM(ctx, prompt, params)
+	stop()
+	if err != nil {
+		return "", err
+	}
+
+	markdown = cleanMarpContent(markdown)
+
+	log.Println("markdown", markdown)
+
+	return markdown, nil
+}
+
+// Model generating the decks by default
+const geminiModel = "gemini-1.5-flash-latest"
+
+// callLLM sends a prompt to the LLM and returns the raw generated text, to Gemini or
+// to the OpenAI-compatible endpoint replacing it (see ConfigureCustomLLM). Calls go
+// through the LLM circuit breaker, so an outage fails generations fast.
+func callLLM(ctx context.Context, prompt string, params generationParams) (string, error) {
+	system, request := "gemini", requestGemini
+	if customLLM != nil {
+		system, request = "openai", customLLM.request
+		params.Model = customLLM.model
+	} else if os.Getenv("GEMINI_API_KEY") == "" {
+		return "", fmt.Errorf("missing Gemini API key")
+	}
+
+	ctx, span := tracing.Start(ctx, "llm.generate",
+		attribute.String("gen_ai.system", system),
+		attribute.String("gen_ai.request.model", params.Model),
+		attribute.Int("gen_ai.request.max_tokens", params.MaxTokens),
+		attribute.Int("llm.prompt_bytes", len(prompt)))
+	defer span.End()
+
+	text, err := resilience.Call(resilience.LLM, func() (string, error) {
+		if chaos.Inject(chaos.LLMTimeout) {
+			time.Sleep(chaos.LLMTimeoutDelay())
+			return "", fmt.Errorf("failed to execute request: %w: %w", chaos.ErrInjected, os.ErrDeadlineExceeded)
+		}
+		return request(ctx, prompt, params)
+	})
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.Int("llm.response_bytes", len(text)))
+	return text, err
+}
+
+// llmClient sends the LLM requests, as client spans of the generation traces
+var llmClient = &http.Client{Transport: tracing.Transport(nil)}
+
+func requestGemini(ctx context.Context, prompt string, params generationParams) (string, error) {
+	googleKey := os.Getenv("GEMINI_API_KEY")
 
 	// Call the Infomaniak API with the prompt
 
@@ -640,8 +1205,17 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 	type GeminiContent struct {
 		Parts []GeminiPart `json:"parts"`
 	}
+	type GeminiGenerationConfig struct {
+		Temperature     float64 `json:"temperature"`
+		MaxOutputTokens int     `json:"maxOutputTokens"`
+	}
+	type GeminiTool struct {
+		GoogleSearchRetrieval *struct{} `json:"google_search_retrieval,omitempty"`
+	}
 	type GeminiRequest struct {
-		Contents []GeminiContent `json:"contents"`
+		Contents         []GeminiContent        `json:"contents"`
+		GenerationConfig GeminiGenerationConfig `json:"generationConfig"`
+		Tools            []GeminiTool           `json:"tools,omitempty"`
 	}
 
 	requestPayload := GeminiRequest{
@@ -654,6 +1228,13 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 				},
 			},
 		},
+		GenerationConfig: GeminiGenerationConfig{
+			Temperature:     params.Temperature,
+			MaxOutputTokens: params.MaxTokens,
+		},
+	}
+	if params.Search {
+		requestPayload.Tools = []GeminiTool{{GoogleSearchRetrieval: &struct{}{}}}
 	}
 
 	jsonData, err := json.Marshal(requestPayload)
@@ -661,18 +1242,17 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Gemini API endpoint for text generation (use gemini-1.5-flash-latest)
-	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/gemini-1.5-flash-latest:generateContent?key=%s", googleKey)
+	// Gemini API endpoint for text generation
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", params.Model, googleKey)
 
 	// Create and execute the HTTP request
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := llmClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute request: %w", err)
 	}
@@ -685,7 +1265,10 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status: %d, body: %s", resp.StatusCode, string(body))
+		return "", &resilience.StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("API request failed with status: %d, body: %s", resp.StatusCode, string(body)),
+		}
 	}
 
 	// Define the expected response structure
@@ -706,19 +1289,15 @@ func (s *PitchDeckService) generateMarkdown(data model.PitchDeckData, imagePaths
 	}
 
 	// Extract the generated text
-	var markdown string
+	var text string
 	if len(geminiResponse.Candidates) > 0 && len(geminiResponse.Candidates[0].Content.Parts) > 0 {
-		markdown = geminiResponse.Candidates[0].Content.Parts[0].Text
+		text = geminiResponse.Candidates[0].Content.Parts[0].Text
 	} else {
 		return "", fmt.Errorf("no generated text found in response: %s", string(body))
 	}
 	// marpContent := apiResponse.Choices[0].Message.Content
 
-	markdown = cleanMarpContent(markdown)
-
-	log.Println("markdown", markdown)
-
-	return markdown, nil
+	return text, nil
 }
 
 // extractMarkdownContent extracts markdown content between triple backticks
@@ -795,41 +1374,109 @@ func (s *PitchDeckService) insertImages(markdown string, imagePaths map[string]s
 	return markdown
 }
 
-func (s *PitchDeckService) convertToPDF(mdPath, pdfPath, theme string) error {
-	args := []string{
-		"@marp-team/marp-cli",
-		mdPath,
-		"--pdf",
-		"--output", pdfPath,
-		"--theme", theme,
-		"--allow-local-files",
+func (s *PitchDeckService) convertToPDF(ctx context.Context, mdPath, pdfPath, theme string) error {
+	defer timeStep(ctx, stepRenderPDF)()
+	_, span := tracing.Start(ctx, "render.pdf", attribute.String("render.engine", renderer.engine()), attribute.String("render.theme", theme))
+	defer span.End()
+
+	if chaos.Inject(chaos.RenderCrash) {
+		err := classifyMarpError(chaos.ErrInjected, "[ ERROR ] Page crashed!")
+		tracing.RecordError(span, err)
+		return err
 	}
-	cmd := exec.Command("npx", args...)
-	return cmd.Run()
+	err := renderer.convertPDF(mdPath, pdfPath, theme)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (s *PitchDeckService) convertToHTML(ctx context.Context, mdPath, htmlPath, theme string) error {
+	defer timeStep(ctx, stepRenderHTML)()
+	_, span := tracing.Start(ctx, "render.html", attribute.String("render.engine", renderer.engine()), attribute.String("render.theme", theme))
+	defer span.End()
+
+	err := renderer.convertHTML(mdPath, htmlPath, theme)
+	tracing.RecordError(span, err)
+	return err
 }
 
-func (s *PitchDeckService) convertToHTML(mdPath, htmlPath, theme string) error {
-	args := []string{
-		"@marp-team/marp-cli",
-		mdPath,
-		"--html",
-		"--output", htmlPath,
-		"--theme", theme,
-		"--allow-local-files",
+// traceUpload records the upload of a rendered file to the storage as a span, and
+// times it as part of the upload step
+func traceUpload(ctx context.Context, bucket, path string, upload func() error) error {
+	defer timeStep(ctx, stepUpload)()
+
+	attrs := []attribute.KeyValue{attribute.String("storage.bucket", bucket), attribute.String("file.name", filepath.Base(path))}
+	if info, err := os.Stat(path); err == nil {
+		attrs = append(attrs, attribute.Int64("file.size", info.Size()))
 	}
-	cmd := exec.Command("npx", args...)
-	return cmd.Run()
+	_, span := tracing.Start(ctx, "storage.upload", attrs...)
+	defer span.End()
+
+	err := upload()
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (s *PitchDeckService) UploadImage(filePath, originalName, userID string) (string, error) {
+	return s.uploadMedia(filePath, originalName, userID, "images/")
 }
 
-func (s *PitchDeckService) UploadImage(filePath string) (string, error) {
+// uploadMedia stores a file uploaded by a user in a folder of their tenant storage
+func (s *PitchDeckService) uploadMedia(filePath, originalName, userID, mediaFolder string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if err := checkUpload(userID, info.Size()); err != nil {
+		return "", err
+	}
+	if err := scanUpload(filePath, originalName, userID); err != nil {
+		return "", err
+	}
+
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return "", err
+	}
+	bucket, folder, err := tenantStorage(tenantID)
+	if err != nil {
+		return "", err
+	}
+
 	// Generate unique filename for storage
-	fileName := "images/" + filepath.Base(filePath)
+	fileName := folder + mediaFolder + filepath.Base(filePath)
+
+	// The upload is recorded first, so a file stored without its record is found
+	outbox, err := beginOutbox(outboxUserFile, userID, bucket, []string{fileName}, nil)
+	if err != nil {
+		return "", err
+	}
 
 	// Upload to storage
-	url, err := s.storage.UploadFile(filePath, "pitch-decks", fileName)
+	url, err := s.storage.UploadFile(filePath, bucket, fileName)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload image: %w", err)
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	// Keep track of the upload so it can be managed later
+	record := model.UserFile{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		OriginalName: originalName,
+		FileURL:      url,
+		StoragePath:  fileName,
+		Size:         info.Size(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if err := outbox.stored(record); err != nil {
+		return "", err
+	}
+	if err := supabaseREST("POST", "user_files", record, nil); err != nil {
+		// The outbox reconciler writes it later
+		log.Printf("Failed to save user file record: %v", err)
+		return url, nil
 	}
+	outbox.done()
 
 	return url, nil
 }
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// Themes bundled with Marp, available on every plan. The others need a plan with
+// custom themes.
+var builtinThemes = map[string]bool{
+	"":        true,
+	"default": true,
+	"gaia":    true,
+	"uncover": true,
+}
+
+// watermarkCSS marks the slides of decks made on plans with a watermark, with the
+// product of their tenant
+func watermarkCSS(product string) string {
+	// A CSS string, which the name of a tenant cannot end or break out of
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "<", `\3C `, "\n", " ")
+	return fmt.Sprintf(watermarkStyle, `"Made with `+escape.Replace(product)+`"`)
+}
+
+const watermarkStyle = `<style>
+section::before {
+  content: %s;
+  position: absolute;
+  left: 30px;
+  bottom: 20px;
+  font-size: 14px;
+  opacity: 0.6;
+}
+</style>`
+
+// planRow is the row returned by the user_plan function
+type planRow struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	DecksPerMonth   *int     `json:"decks_per_month"`
+	MaxUploads      *int     `json:"max_uploads"`
+	MaxStorageBytes *int64   `json:"max_storage_bytes"`
+	Watermark       bool     `json:"watermark"`
+	CustomThemes    bool     `json:"custom_themes"`
+	Models          []string `json:"models"`
+	MaxOutputTokens int      `json:"max_output_tokens"`
+	DecksThisMonth  int      `json:"decks_this_month"`
+	Uploads         int      `json:"uploads"`
+	StorageBytes    int64    `json:"storage_bytes"`
+}
+
+// PlanService tells users what their plan allows
+type PlanService struct {
+	decks *PitchDeckService
+}
+
+func NewPlanService(decks *PitchDeckService) *PlanService {
+	return &PlanService{
+		decks: decks,
+	}
+}
+
+// CurrentPlan returns the plan of the user and their remaining allowance
+func (s *PlanService) CurrentPlan(userID string) (*model.PlanUsage, error) {
+	return planUsage(userID)
+}
+
+// StorageUsage returns the storage the uploads of the user take and what is left of
+// what their plan includes
+func (s *PlanService) StorageUsage(userID string) (*model.StorageUsage, error) {
+	usage, err := planUsage(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StorageUsage{
+		Plan:      usage.Plan.Name,
+		Files:     usage.Uploads,
+		Used:      usage.StorageBytes,
+		Limit:     usage.Plan.MaxStorageBytes,
+		Available: usage.RemainingStorage,
+	}, nil
+}
+
+// planUsage loads the plan of a user and what they used of it. Monthly allowances
+// reset at the start of each month, in UTC.
+func planUsage(userID string) (*model.PlanUsage, error) {
+	return planUsageContext(context.Background(), userID)
+}
+
+func planUsageContext(ctx context.Context, userID string) (*model.PlanUsage, error) {
+	var rows []planRow
+	params := map[string]string{"p_user_id": userID}
+	if err := supabaseRESTContext(ctx, "POST", "rpc/user_plan", params, &rows); err != nil {
+		return nil, fmt.Errorf("failed to load plan: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no plan found for user %s", userID)
+	}
+	row := rows[0]
+
+	now := time.Now().UTC()
+	usage := &model.PlanUsage{
+		Plan: model.Plan{
+			ID:              row.ID,
+			Name:            row.Name,
+			DecksPerMonth:   row.DecksPerMonth,
+			MaxUploads:      row.MaxUploads,
+			MaxStorageBytes: row.MaxStorageBytes,
+			Watermark:       row.Watermark,
+			CustomThemes:    row.CustomThemes,
+			Models:          row.Models,
+			MaxOutputTokens: row.MaxOutputTokens,
+		},
+		DecksThisMonth: row.DecksThisMonth,
+		Uploads:        row.Uploads,
+		StorageBytes:   row.StorageBytes,
+		ResetsAt:       time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if row.DecksPerMonth != nil {
+		remaining := max(*row.DecksPerMonth-row.DecksThisMonth, 0)
+		usage.RemainingDecks = &remaining
+	}
+	if row.MaxUploads != nil {
+		remaining := max(*row.MaxUploads-row.Uploads, 0)
+		usage.RemainingUploads = &remaining
+	}
+	if row.MaxStorageBytes != nil {
+		remaining := max(*row.MaxStorageBytes-row.StorageBytes, 0)
+		usage.RemainingStorage = &remaining
+	}
+	return usage, nil
+}
+
+// checkNewDeck rejects a deck the plan of the user does not allow: over the decks of
+// the month, or with a theme other than Marp's
+func checkNewDeck(userID, theme string) error {
+	if err := checkTenantDeck(userID, theme); err != nil {
+		return err
+	}
+	usage, err := planUsage(userID)
+	if err != nil {
+		return err
+	}
+	if usage.RemainingDecks != nil && *usage.RemainingDecks == 0 {
+		return fmt.Errorf("%w: the %s plan allows %d decks per month, more can be created from %s",
+			model.ErrQuotaExceeded, usage.Plan.Name, *usage.Plan.DecksPerMonth, usage.ResetsAt.Format("January 2"))
+	}
+	if !builtinThemes[strings.ToLower(theme)] && !usage.Plan.CustomThemes {
+		return fmt.Errorf("%w: the %s plan does not include custom themes, use default, gaia or uncover",
+			model.ErrQuotaExceeded, usage.Plan.Name)
+	}
+	return nil
+}
+
+// checkUpload rejects an upload of size bytes over the limits of the plan of the user
+func checkUpload(userID string, size int64) error {
+	usage, err := planUsage(userID)
+	if err != nil {
+		return err
+	}
+	if usage.RemainingUploads != nil && *usage.RemainingUploads == 0 {
+		return fmt.Errorf("%w: the %s plan allows %d uploads, replace or delete one first",
+			model.ErrQuotaExceeded, usage.Plan.Name, *usage.Plan.MaxUploads)
+	}
+	return checkStorage(usage, size)
+}
+
+// checkStorage rejects size more bytes over the storage the plan includes
+func checkStorage(usage *model.PlanUsage, size int64) error {
+	if usage.RemainingStorage != nil && size > *usage.RemainingStorage {
+		return fmt.Errorf("%w: the %s plan includes %s of storage and %s are left, this file takes %s",
+			model.ErrQuotaExceeded, usage.Plan.Name, formatSize(*usage.Plan.MaxStorageBytes),
+			formatSize(*usage.RemainingStorage), formatSize(size))
+	}
+	return nil
+}
+
+// formatSize writes a number of bytes in the largest unit it makes at least one of
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, exp := float64(bytes)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "KMGT"[exp])
+}
+
+// watermarked reports whether the decks of a user are rendered with a watermark. The
+// deck is rendered without one when the plan cannot be loaded.
+func watermarked(ctx context.Context, userID string) bool {
+	usage, err := planUsageContext(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to load plan of user %s, rendering without watermark: %v", userID, err)
+		return false
+	}
+	return usage.Plan.Watermark
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ProjectService manages the projects decks are grouped into
+type ProjectService struct {
+	decks *PitchDeckService
+}
+
+func NewProjectService(decks *PitchDeckService) *ProjectService {
+	return &ProjectService{
+		decks: decks,
+	}
+}
+
+// getProject returns the project when the user has at least minRole on it
+func getProject(projectID, userID, minRole string) (*model.Project, error) {
+	var projects []model.Project
+	if err := supabaseREST("GET", "projects?id=eq."+url.QueryEscape(projectID), nil, &projects); err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("%w: project not found", model.ErrNotFound)
+	}
+	if err := authorizeResource(projects[0].UserID, projects[0].OrgID, userID, minRole); err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// applyProjectDefaults fills the settings left empty in the deck with the project ones
+func applyProjectDefaults(data *model.PitchDeckData, project *model.Project) {
+	if data.Theme == "" {
+		data.Theme = project.BrandKit.Theme
+	}
+	if data.CodeTheme == "" {
+		data.CodeTheme = project.BrandKit.CodeTheme
+	}
+	if data.CompanyLogo == "" {
+		data.CompanyLogo = project.BrandKit.Logo
+	}
+	if data.AudiencePersona == "" {
+		data.AudiencePersona = project.AudiencePersona
+	}
+}
+
+func validateProject(project *model.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if project.Name == "" {
+		return fmt.Errorf("%w: project name is required", model.ErrInvalidInput)
+	}
+
+	codeTheme := project.BrandKit.CodeTheme
+	if codeTheme != "" {
+		if _, ok := codeThemes[strings.ToLower(codeTheme)]; !ok {
+			return fmt.Errorf("%w: unknown code theme %q, expected one of %s",
+				model.ErrInvalidInput, codeTheme, strings.Join(CodeThemes(), ", "))
+		}
+	}
+	return nil
+}
+
+func (s *ProjectService) CreateProject(userID string, project model.Project) (*model.Project, error) {
+	if err := validateProject(&project); err != nil {
+		return nil, err
+	}
+
+	project.ID = uuid.New().String()
+	project.UserID = userID
+	project.CreatedAt = time.Now()
+
+	if err := supabaseREST("POST", "projects", project, nil); err != nil {
+		return nil, fmt.Errorf("failed to save project: %w", err)
+	}
+	return &project, nil
+}
+
+func (s *ProjectService) ListProjects(userID string) ([]model.Project, error) {
+	filter, err := orgFilter(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []model.Project
+	path := fmt.Sprintf("projects?%s&order=created_at.desc", filter)
+	if err := supabaseREST("GET", path, nil, &projects); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (s *ProjectService) GetProject(projectID, userID string) (*model.Project, error) {
+	return getProject(projectID, userID, model.RoleViewer)
+}
+
+// UpdateProject replaces the name and shared settings of a project
+func (s *ProjectService) UpdateProject(projectID, userID string, project model.Project) (*model.Project, error) {
+	existing, err := getProject(projectID, userID, model.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProject(&project); err != nil {
+		return nil, err
+	}
+
+	update := map[string]interface{}{
+		"name":             project.Name,
+		"brand_kit":        project.BrandKit,
+		"audience_persona": project.AudiencePersona,
+	}
+	if err := supabaseREST("PATCH", "projects?id=eq."+url.QueryEscape(projectID), update, nil); err != nil {
+		return nil, fmt.Errorf("failed to update project: %w", err)
+	}
+
+	existing.Name = project.Name
+	existing.BrandKit = project.BrandKit
+	existing.AudiencePersona = project.AudiencePersona
+	return existing, nil
+}
+
+// DeleteProject deletes a project, its decks are kept without a project
+func (s *ProjectService) DeleteProject(projectID, userID string) error {
+	if _, err := getProject(projectID, userID, model.RoleOwner); err != nil {
+		return err
+	}
+
+	unassign := map[string]interface{}{"project_id": nil}
+	if err := supabaseREST("PATCH", "pitch_decks?project_id=eq."+url.QueryEscape(projectID), unassign, nil); err != nil {
+		return fmt.Errorf("failed to detach decks: %w", err)
+	}
+
+	if err := supabaseREST("DELETE", "projects?id=eq."+url.QueryEscape(projectID), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+	return nil
+}
+
+// AssignDeck moves a deck into a project, or out of any project when projectID is empty
+func (s *ProjectService) AssignDeck(deckID, projectID, userID string) error {
+	deck, err := s.decks.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{"project_id": nil}
+	if projectID != "" {
+		if _, err := getProject(projectID, userID, model.RoleEditor); err != nil {
+			return err
+		}
+		update["project_id"] = projectID
+	}
+
+	if err := supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil); err != nil {
+		return fmt.Errorf("failed to assign deck: %w", err)
+	}
+	return nil
+}
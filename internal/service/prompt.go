@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/prompts"
+)
+
+const maxPromptVersions = 100
+
+// PromptService lets administrators edit the LLM prompts: each edit creates a new
+// version, which generations use once it is published
+type PromptService struct{}
+
+func NewPromptService() *PromptService {
+	return &PromptService{}
+}
+
+// ListVersions returns the versions of a prompt, newest first, and the one in use.
+// Active is nil while the template shipped with the server is used.
+func (s *PromptService) ListVersions(name string) ([]model.PromptVersion, *model.PromptVersion, error) {
+	if _, ok := prompts.Builtin(name); !ok {
+		return nil, nil, fmt.Errorf("%w: unknown prompt %s", model.ErrNotFound, name)
+	}
+
+	var versions []model.PromptVersion
+	path := fmt.Sprintf("prompt_versions?name=eq.%s&order=version.desc&limit=%d", url.QueryEscape(name), maxPromptVersions)
+	if err := supabaseREST("GET", path, nil, &versions); err != nil {
+		return nil, nil, fmt.Errorf("failed to list prompt versions: %w", err)
+	}
+
+	var active *model.PromptVersion
+	for i := range versions {
+		if versions[i].PublishedAt != nil && (active == nil || versions[i].PublishedAt.After(*active.PublishedAt)) {
+			active = &versions[i]
+		}
+	}
+	return versions, active, nil
+}
+
+// GetVersion returns a version of a prompt, e.g. the one a deck was generated with
+func (s *PromptService) GetVersion(name string, version int) (*model.PromptVersion, error) {
+	var versions []model.PromptVersion
+	path := fmt.Sprintf("prompt_versions?name=eq.%s&version=eq.%d", url.QueryEscape(name), version)
+	if err := supabaseREST("GET", path, nil, &versions); err != nil {
+		return nil, fmt.Errorf("failed to load prompt version: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: prompt %s has no version %d", model.ErrNotFound, name, version)
+	}
+	return &versions[0], nil
+}
+
+// CreateVersion saves an edited template as the next version of a prompt, unpublished.
+// The template must render with a sample of the answers of the form.
+func (s *PromptService) CreateVersion(name, template, notes, adminID string) (*model.PromptVersion, error) {
+	if _, ok := prompts.Builtin(name); !ok {
+		return nil, fmt.Errorf("%w: unknown prompt %s", model.ErrNotFound, name)
+	}
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("%w: template is required", model.ErrInvalidInput)
+	}
+	if err := prompts.ValidateTemplate(name, template); err != nil {
+		return nil, fmt.Errorf("%w: %v", model.ErrInvalidInput, err)
+	}
+
+	var created []model.PromptVersion
+	params := map[string]string{
+		"p_name":       name,
+		"p_template":   template,
+		"p_notes":      strings.TrimSpace(notes),
+		"p_created_by": adminID,
+	}
+	if err := supabaseREST("POST", "rpc/create_prompt_version", params, &created); err != nil {
+		var statusErr *resilience.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: another version of %s was created at the same time, try again", model.ErrConflict, name)
+		}
+		return nil, fmt.Errorf("failed to create prompt version: %w", err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("failed to create prompt version: no row returned")
+	}
+	log.Printf("Admin %s created version %d of prompt %s", adminID, created[0].Version, name)
+	return &created[0], nil
+}
+
+// PublishVersion makes a version the one used by new generations. Publishing an
+// older version again rolls back to it.
+func (s *PromptService) PublishVersion(name string, version int, adminID string) (*model.PromptVersion, error) {
+	existing, err := s.GetVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var published []model.PromptVersion
+	update := map[string]interface{}{"published_at": time.Now()}
+	if err := supabaseREST("PATCH", "prompt_versions?id=eq."+url.QueryEscape(existing.ID), update, &published); err != nil {
+		return nil, fmt.Errorf("failed to publish prompt version: %w", err)
+	}
+	if len(published) == 0 {
+		return nil, fmt.Errorf("%w: prompt %s has no version %d", model.ErrNotFound, name, version)
+	}
+	log.Printf("Admin %s published version %d of prompt %s", adminID, version, name)
+	return &published[0], nil
+}
+
+// activePrompt returns the published version of a prompt. The template shipped with
+// the server, without an ID, is returned while none is published or when the
+// versions cannot be loaded, so generations do not depend on the prompt store.
+func activePrompt(name string) model.PromptVersion {
+	builtin, _ := prompts.Builtin(name)
+	fallback := model.PromptVersion{Name: name, Template: builtin}
+
+	var versions []model.PromptVersion
+	path := fmt.Sprintf("prompt_versions?name=eq.%s&published_at=not.is.null&order=published_at.desc&limit=1", url.QueryEscape(name))
+	if err := supabaseREST("GET", path, nil, &versions); err != nil {
+		log.Printf("Failed to load prompt %s, using the built-in template: %v", name, err)
+		return fallback
+	}
+	if len(versions) == 0 {
+		return fallback
+	}
+	return versions[0]
+}
+
+// recordPromptVersion records on a deck the version of the prompt it is generated
+// with, cleared for the built-in template
+func recordPromptVersion(deckID string, prompt model.PromptVersion) {
+	var versionID *string
+	if prompt.ID != "" {
+		versionID = &prompt.ID
+	}
+	update := map[string]*string{"prompt_version_id": versionID}
+	if err := supabaseWrite("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deckID), update); err != nil {
+		log.Printf("Failed to record prompt version of deck %s: %v", deckID, err)
+	}
+}
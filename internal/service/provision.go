@@ -0,0 +1,62 @@
+package service
+
+import (
+	"log"
+	"slices"
+
+	"pitch-deck-generator/internal/storage"
+)
+
+// deckBucket returns the bucket decks and uploads are stored in, STORAGE_DECK_BUCKET
+func deckBucket() string {
+	return storage.DeckBucket()
+}
+
+// bucketStorage creates the storage buckets a deployment needs
+type bucketStorage interface {
+	EnsureBucket(bucketName string, public bool) (bool, error)
+}
+
+// ProvisionBuckets creates the buckets the server stores into, public as decks and
+// uploads are served from their public URLs, and the private buckets of the files of
+// private decks. In multi-tenant mode the buckets of the
+// tenants are created too.
+func ProvisionBuckets(buckets bucketStorage) error {
+	names := []string{deckBucket(), storage.MediaBucket()}
+	if MultiTenant() {
+		tenants, _, err := loadTenants()
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			if tenant.Bucket != "" && !slices.Contains(names, tenant.Bucket) {
+				names = append(names, tenant.Bucket)
+			}
+		}
+	}
+
+	for _, bucket := range names {
+		created, err := buckets.EnsureBucket(bucket, true)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("Storage: created bucket %s", bucket)
+		}
+	}
+	// The files of private decks are stored apart, in a private bucket
+	for _, bucket := range names {
+		if bucket == storage.MediaBucket() {
+			continue
+		}
+		private := storage.PrivateBucket(bucket)
+		created, err := buckets.EnsureBucket(private, false)
+		if err != nil {
+			return err
+		}
+		if created {
+			log.Printf("Storage: created bucket %s", private)
+		}
+	}
+	return nil
+}
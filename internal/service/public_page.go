@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	publicFetchTimeout = 10 * time.Second
+	// Pages are read for their head and first headings only
+	maxPublicPage = 1 << 20
+	// Headings read from a page
+	maxPageHeadings = 3
+)
+
+// Addresses of carrier-grade NAT, not covered by net.IP.IsPrivate
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicClient fetches the web pages given by users. It only connects to public
+// addresses, checked when connecting so a DNS answer or redirect cannot point it at
+// the internal network.
+var publicClient = &http.Client{
+	Timeout: publicFetchTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: publicAddressOnly,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
+
+// webPage is how a page presents itself: its title, its meta tags (description,
+// Open Graph) by lower case name, its first headings and its icons, the touch
+// icons first as they are the largest
+type webPage struct {
+	Title    string
+	Meta     map[string]string
+	Headings []string
+	Icons    []string
+}
+
+// fetchPage reads the head and first headings of a public web page
+func fetchPage(u *url.URL) (*webPage, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "PitchTree/1.0 (+research for pitch decks)")
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := publicClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("not a web page: %s", contentType)
+	}
+
+	page := readPage(io.LimitReader(resp.Body, maxPublicPage))
+	return &page, nil
+}
+
+func readPage(r io.Reader) webPage {
+	page := webPage{Meta: make(map[string]string)}
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return page
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := z.Token()
+			switch token.Data {
+			case "title":
+				if z.Next() == html.TextToken && page.Title == "" {
+					page.Title = collapseSpaces(string(z.Text()))
+				}
+			case "meta":
+				var name, content string
+				for _, attr := range token.Attr {
+					switch attr.Key {
+					case "name", "property":
+						name = strings.ToLower(attr.Val)
+					case "content":
+						content = attr.Val
+					}
+				}
+				if name != "" && page.Meta[name] == "" {
+					page.Meta[name] = collapseSpaces(content)
+				}
+			case "link":
+				var rel, href string
+				for _, attr := range token.Attr {
+					switch attr.Key {
+					case "rel":
+						rel = strings.ToLower(attr.Val)
+					case "href":
+						href = strings.TrimSpace(attr.Val)
+					}
+				}
+				switch {
+				case href == "" || !strings.Contains(rel, "icon"):
+				case strings.Contains(rel, "apple-touch-icon"):
+					page.Icons = append([]string{href}, page.Icons...)
+				default:
+					page.Icons = append(page.Icons, href)
+				}
+			case "h1", "h2":
+				if len(page.Headings) < maxPageHeadings && z.Next() == html.TextToken {
+					if heading := collapseSpaces(string(z.Text())); heading != "" {
+						page.Headings = append(page.Headings, heading)
+					}
+				}
+			}
+		}
+	}
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// How often the scheduled visibility changes are applied
+const publishingInterval = time.Minute
+
+// isPublished tells whether a deck is public at a time, taking into account the
+// visibility changes scheduled up to then but not applied yet
+func isPublished(deck *model.PitchDeckInfo, at time.Time) bool {
+	public := deck.IsPublic
+	if deck.PublishAt != nil && !at.Before(*deck.PublishAt) {
+		public = true
+	}
+	if deck.UnpublishAt != nil && !at.Before(*deck.UnpublishAt) {
+		public = false
+	}
+	return public && deck.TakenDownAt == nil
+}
+
+// SchedulePublishing sets when a deck becomes public and when it becomes private
+// again. A nil time clears that part of the schedule.
+func (s *PitchDeckService) SchedulePublishing(deckID, userID string, publishAt, unpublishAt *time.Time, revision int) error {
+	now := time.Now()
+	if (publishAt != nil && !publishAt.After(now)) || (unpublishAt != nil && !unpublishAt.After(now)) {
+		return fmt.Errorf("%w: scheduled times must be in the future", model.ErrInvalidInput)
+	}
+	if publishAt != nil && unpublishAt != nil && !unpublishAt.After(*publishAt) {
+		return fmt.Errorf("%w: the deck must be unpublished after it is published", model.ErrInvalidInput)
+	}
+
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if publishAt != nil && deck.TakenDownAt != nil {
+		return fmt.Errorf("%w: this deck was taken down for violating our terms and cannot be made public", model.ErrForbidden)
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+	if err := s.reviseDeck(context.Background(), deck.ID, revision); err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{
+		"publish_at":   publishAt,
+		"unpublish_at": unpublishAt,
+	}
+	err = supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil)
+	deckCache.invalidate()
+	if err != nil {
+		return fmt.Errorf("failed to schedule publishing: %w", err)
+	}
+
+	recordAudit(deck.ID, userID, AuditPublishingScheduled, map[string]interface{}{
+		"publish_at":   publishAt,
+		"unpublish_at": unpublishAt,
+	})
+	return nil
+}
+
+// StartPublishing applies the scheduled visibility changes now and then at every
+// interval, in the background
+func (s *PitchDeckService) StartPublishing() {
+	go func() {
+		for {
+			s.ApplySchedules()
+			time.Sleep(publishingInterval)
+		}
+	}()
+}
+
+// ApplySchedules makes public the decks whose publication time has come and private
+// the decks whose unpublication time has come, then clears those times. Decks being
+// processed are left for the next run, the end of their processing saves their
+// visibility.
+func (s *PitchDeckService) ApplySchedules() {
+	now := url.QueryEscape(time.Now().UTC().Format(time.RFC3339))
+	changes := []struct {
+		column string
+		filter string
+		public bool
+	}{
+		{column: "publish_at", filter: "&status=eq.completed&taken_down_at=is.null", public: true},
+		{column: "unpublish_at", filter: "&status=neq.processing", public: false},
+	}
+
+	for _, change := range changes {
+		var decks []model.PitchDeckInfo
+		path := fmt.Sprintf("pitch_decks?select=id,user_id,tenant_id,pdf_url,html_url,markdown_url&%s=lte.%s%s", change.column, now, change.filter)
+		update := map[string]interface{}{
+			"is_public":   change.public,
+			change.column: nil,
+		}
+		if err := supabaseREST("PATCH", path, update, &decks); err != nil {
+			log.Printf("Failed to apply the %s schedules: %v", change.column, err)
+			continue
+		}
+		if len(decks) == 0 {
+			continue
+		}
+		deckCache.invalidate()
+
+		for _, deck := range decks {
+			if err := s.moveDeckFiles(&deck, change.public); err != nil {
+				log.Printf("Failed to move the files of deck %s after its %s schedule: %v", deck.ID, change.column, err)
+			}
+			recordAudit(deck.ID, deck.UserID, AuditVisibilityChanged, map[string]interface{}{
+				"to": change.public,
+				"by": "schedule",
+			})
+		}
+		log.Printf("Applied the %s schedule of %d decks", change.column, len(decks))
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+var realtimeClient = &http.Client{Timeout: 5 * time.Second}
+
+// deckStatusEvent is broadcast to the owner of a deck when its status changes
+type deckStatusEvent struct {
+	DeckID       string `json:"deckId"`
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	PdfURL       string `json:"pdfUrl,omitempty"`
+	HtmlURL      string `json:"htmlUrl,omitempty"`
+	ErrorCode    string `json:"errorCode,omitempty"`
+	ErrorMessage string `json:"errorMessage,omitempty"`
+}
+
+// deckStatusTopic is the Realtime channel a user subscribes to, private so that
+// the RLS policies on realtime.messages only let the owner join it
+func deckStatusTopic(userID string) string {
+	return "decks:" + userID
+}
+
+// publishDeckStatus broadcasts the status of a deck on the Realtime channel of its
+// owner. It is best effort: the deck record remains the source of truth for
+// clients that were not listening, e.g. a tab closed during the generation.
+func publishDeckStatus(deck *model.PitchDeckInfo) {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+	if supabaseURL == "" || supabaseKey == "" || deck.UserID == "" {
+		return
+	}
+
+	files := deck.WithFileProxy(fileProxyBaseURL())
+	message := map[string]interface{}{
+		"messages": []map[string]interface{}{{
+			"topic":   deckStatusTopic(deck.UserID),
+			"event":   "deck_status",
+			"private": true,
+			"payload": deckStatusEvent{
+				DeckID:       deck.ID,
+				Name:         deck.Name,
+				Status:       deck.Status,
+				PdfURL:       files.PdfURL,
+				HtmlURL:      files.HtmlURL,
+				ErrorCode:    deck.ErrorCode,
+				ErrorMessage: deck.ErrorMessage,
+			},
+		}},
+	}
+
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal status event of deck %s: %v", deck.ID, err)
+		return
+	}
+
+	apiURL := strings.TrimSuffix(supabaseURL, "/") + "/realtime/v1/api/broadcast"
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Printf("Failed to create broadcast request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", supabaseKey)
+	req.Header.Set("Authorization", "Bearer "+supabaseKey)
+
+	resp, err := realtimeClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to broadcast status of deck %s: %v", deck.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("Failed to broadcast status of deck %s, status: %d, body: %s", deck.ID, resp.StatusCode, string(body))
+	}
+}
+
+// publishStoredStatus broadcasts the status of a deck as stored after an update
+func (s *PitchDeckService) publishStoredStatus(deckID string) {
+	deck, err := s.Get(deckID)
+	if err != nil {
+		log.Printf("Failed to load deck %s to broadcast its status: %v", deckID, err)
+		return
+	}
+	publishDeckStatus(deck)
+}
@@ -0,0 +1,553 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"pitch-deck-generator/internal/render"
+	"pitch-deck-generator/internal/slides"
+)
+
+const (
+	defaultMarpWorkers = 2
+
+	// Time given to a marp-cli server to listen, and to a conversion to complete
+	workerStartTimeout = 30 * time.Second
+	conversionTimeout  = 3 * time.Minute
+
+	// Delay before starting again a worker that failed to start, doubled at each failure
+	workerRestartDelay    = 10 * time.Second
+	maxWorkerRestartDelay = 5 * time.Minute
+)
+
+// marpEngine is the Marp engine given to marp-cli, applying the code theme of decks
+//
+//go:embed marp/engine.cjs
+var marpEngine []byte
+
+// Conversions by kind, published on the admin metrics endpoint
+var rendererMetrics = expvar.NewMap("renderer")
+
+// errWorkerUnavailable is returned when a marp-cli server cannot be reached, the
+// conversion is then run by a one-shot marp-cli process
+var errWorkerUnavailable = errors.New("renderer worker unavailable")
+
+// Renderer runs the marp-cli conversions. The executable is resolved once instead
+// of going through npx at every conversion, and conversions are capped by
+// MARP_CONCURRENCY. PDFs are rendered by a pool of MARP_WORKERS marp-cli servers
+// which keep their browser running between conversions, 0 disables the pool.
+//
+// With RENDERER set to native, or when marp-cli cannot be run, decks are rendered
+// by the native renderer of the render package instead.
+type Renderer struct {
+	command []string
+	native  bool
+	slots   chan struct{}
+	// Arguments given to every marp-cli process, the engine among them
+	args []string
+
+	// Workers are taken from the channel for a conversion and put back after it
+	root    string
+	size    int
+	workers chan *marpWorker
+	mu      sync.Mutex
+	all     map[*marpWorker]bool
+	stopped bool
+}
+
+// renderer is replaced by StartRenderer, conversions before that go through npx
+var renderer = &Renderer{command: []string{"npx", "@marp-team/marp-cli"}}
+
+// engine names what renders the decks, "native" or "marp"
+func (r *Renderer) engine() string {
+	if r.native {
+		return "native"
+	}
+	return "marp"
+}
+
+// marpWorker is a marp-cli process in server mode, converting the files of the
+// temp directory on request
+type marpWorker struct {
+	port int
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// StartRenderer configures the renderer from RENDERER, MARP_CLI_PATH, MARP_CONCURRENCY
+// and MARP_WORKERS and starts its workers in the background
+func StartRenderer() *Renderer {
+	concurrency, err := strconv.Atoi(os.Getenv("MARP_CONCURRENCY"))
+	if err != nil || concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	size, err := strconv.Atoi(os.Getenv("MARP_WORKERS"))
+	if err != nil || size < 0 {
+		size = defaultMarpWorkers
+	}
+
+	root, err := filepath.Abs("temp")
+	if err == nil {
+		err = os.MkdirAll(root, os.ModePerm)
+	}
+	if err != nil {
+		log.Printf("Renderer: failed to resolve temp directory, workers disabled: %v", err)
+		size = 0
+	}
+
+	command := marpCommand()
+	var native bool
+	switch mode := os.Getenv("RENDERER"); mode {
+	case "native":
+		native = true
+	case "marp":
+	default:
+		if err := checkMarp(command); err != nil {
+			log.Printf("Renderer: marp-cli unavailable, using the native renderer: %v", err)
+			native = true
+		}
+	}
+
+	var args []string
+	if !native {
+		engine, err := writeMarpEngine()
+		if err != nil {
+			log.Printf("Renderer: failed to write the Marp engine, code themes disabled: %v", err)
+		} else {
+			args = []string{"--engine", engine}
+		}
+	}
+
+	if native {
+		size = 0
+		log.Printf("Renderer: native, %d concurrent conversions", concurrency)
+	} else {
+		log.Printf("Renderer: %s, %d concurrent conversions, %d workers", command[0], concurrency, size)
+	}
+
+	r := &Renderer{
+		command: command,
+		native:  native,
+		slots:   make(chan struct{}, concurrency),
+		args:    args,
+		root:    root,
+		size:    size,
+		workers: make(chan *marpWorker, size),
+		all:     make(map[*marpWorker]bool),
+	}
+
+	for range size {
+		go r.addWorker()
+	}
+	renderer = r
+	return r
+}
+
+// marpCommand returns the command running marp-cli: MARP_CLI_PATH, the marp
+// executable installed globally, or npx as a last resort
+func marpCommand() []string {
+	if path := os.Getenv("MARP_CLI_PATH"); path != "" {
+		return []string{path}
+	}
+	if path, err := exec.LookPath("marp"); err == nil {
+		return []string{path}
+	}
+	return []string{"npx", "@marp-team/marp-cli"}
+}
+
+// writeMarpEngine writes the Marp engine to a file marp-cli can load
+func writeMarpEngine() (string, error) {
+	dir, err := os.MkdirTemp("", "marp-engine")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "engine.cjs")
+	if err := os.WriteFile(path, marpEngine, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkMarp runs marp-cli once, so a missing Node.js or marp-cli is found at startup
+// rather than by the first generation. npx installs marp-cli when it is missing.
+func checkMarp(command []string) error {
+	if _, err := exec.LookPath(command[0]); err != nil {
+		if filepath.Base(command[0]) == "npx" {
+			return errors.New("npx not found, install Node.js and @marp-team/marp-cli or set MARP_CLI_PATH")
+		}
+		return fmt.Errorf("%s not found, check MARP_CLI_PATH", command[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), workerStartTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, command[0], slices.Concat(command[1:], []string{"--version"})...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s --version failed: %w: %s", strings.Join(command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Stop terminates the workers, conversions in progress fail
+func (r *Renderer) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	for worker := range r.all {
+		worker.stop()
+	}
+}
+
+// acquire waits for a conversion slot and returns the function releasing it
+func (r *Renderer) acquire() func() {
+	if r.slots == nil {
+		return func() {}
+	}
+	r.slots <- struct{}{}
+	return func() { <-r.slots }
+}
+
+// run runs marp-cli once and classifies its output when it fails
+func (r *Renderer) run(args ...string) error {
+	defer r.acquire()()
+	rendererMetrics.Add("process_conversions", 1)
+
+	cmd := exec.Command(r.command[0], slices.Concat(r.command[1:], r.args, args)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return classifyMarpError(err, stdout.String()+stderr.String())
+	}
+	return nil
+}
+
+// convertPDF renders a deck to PDF with a worker when one is idle. Workers cannot be
+// given a theme per deck, decks without a theme directive that need another theme
+// than the default are rendered by a one-shot marp-cli process.
+func (r *Renderer) convertPDF(mdPath, pdfPath, theme string) error {
+	if r.native {
+		return r.nativePDF(mdPath, pdfPath, theme, false)
+	}
+
+	var worker *marpWorker
+	if r.size > 0 && declaresTheme(mdPath, theme) {
+		select {
+		case worker = <-r.workers:
+		default:
+			rendererMetrics.Add("workers_busy", 1)
+		}
+	}
+	if worker == nil {
+		return r.run(mdPath, "--pdf", "--output", pdfPath, "--theme", theme, "--allow-local-files")
+	}
+
+	err := r.convertWithWorker(worker, mdPath, pdfPath)
+	if errors.Is(err, errWorkerUnavailable) {
+		log.Printf("Renderer: worker on port %d failed, replacing it: %v", worker.port, err)
+		r.removeWorker(worker)
+		go r.addWorker()
+		return r.run(mdPath, "--pdf", "--output", pdfPath, "--theme", theme, "--allow-local-files")
+	}
+	r.workers <- worker
+	return err
+}
+
+// convertTaggedPDF renders a deck to a tagged PDF, whatever the configured renderer
+func (r *Renderer) convertTaggedPDF(mdPath, pdfPath, theme string) error {
+	rendererMetrics.Add("tagged_conversions", 1)
+	return r.nativePDF(mdPath, pdfPath, theme, true)
+}
+
+// convertHTML renders a deck to HTML
+func (r *Renderer) convertHTML(mdPath, htmlPath, theme string) error {
+	if r.native {
+		return r.nativeHTML(mdPath, htmlPath, theme)
+	}
+	return runMarp(mdPath, "--html", "--output", htmlPath, "--theme", theme, "--allow-local-files")
+}
+
+// convertPNG renders the slides of a deck to one PNG image, stacked vertically
+func (r *Renderer) convertPNG(mdPath, pngPath, theme string) error {
+	if !r.native {
+		return r.marpPNG(mdPath, pngPath, theme)
+	}
+
+	capturePath := strings.TrimSuffix(mdPath, filepath.Ext(mdPath)) + ".capture.html"
+	if err := r.nativeHTML(mdPath, capturePath, theme); err != nil {
+		return err
+	}
+	defer os.Remove(capturePath)
+
+	defer r.acquire()()
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout)
+	defer cancel()
+
+	if err := render.PNG(ctx, capturePath, pngPath); err != nil {
+		return nativeRenderError(err)
+	}
+	return nil
+}
+
+// marpPNG renders each slide to an image with marp-cli, which numbers them after
+// the output path (deck.001.png, deck.002.png...), and stacks them
+func (r *Renderer) marpPNG(mdPath, pngPath, theme string) error {
+	if err := r.run(mdPath, "--images", "png", "--output", pngPath, "--theme", theme, "--allow-local-files"); err != nil {
+		return err
+	}
+
+	parts, err := filepath.Glob(strings.TrimSuffix(pngPath, ".png") + ".[0-9][0-9][0-9].png")
+	if err != nil {
+		return err
+	}
+	slices.Sort(parts)
+	for _, part := range parts {
+		defer os.Remove(part)
+	}
+
+	images := make([][]byte, 0, len(parts))
+	for _, part := range parts {
+		data, err := os.ReadFile(part)
+		if err != nil {
+			return fmt.Errorf("failed to read slide image: %w", err)
+		}
+		images = append(images, data)
+	}
+
+	stacked, err := render.StackPNG(images)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(pngPath, stacked, 0644); err != nil {
+		return fmt.Errorf("failed to write PNG: %w", err)
+	}
+	return nil
+}
+
+// nativeHTML renders a deck to HTML with the native renderer
+func (r *Renderer) nativeHTML(mdPath, htmlPath, theme string) error {
+	defer r.acquire()()
+	rendererMetrics.Add("native_conversions", 1)
+
+	markdown, err := os.ReadFile(mdPath)
+	if err != nil {
+		return fmt.Errorf("failed to read markdown: %w", err)
+	}
+	doc, err := render.HTML(string(markdown), theme)
+	if err != nil {
+		return nativeRenderError(err)
+	}
+	if err := os.WriteFile(htmlPath, doc, 0644); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+	return nil
+}
+
+// nativePDF renders a deck to HTML next to its markdown, so relative image paths
+// resolve alike, and prints it to PDF. Only the native renderer prints tagged PDFs.
+func (r *Renderer) nativePDF(mdPath, pdfPath, theme string, tagged bool) error {
+	printPath := strings.TrimSuffix(mdPath, filepath.Ext(mdPath)) + ".print.html"
+	if err := r.nativeHTML(mdPath, printPath, theme); err != nil {
+		return err
+	}
+	defer os.Remove(printPath)
+
+	defer r.acquire()()
+	ctx, cancel := context.WithTimeout(context.Background(), conversionTimeout)
+	defer cancel()
+
+	if err := render.PDF(ctx, printPath, pdfPath, tagged); err != nil {
+		return nativeRenderError(err)
+	}
+	return nil
+}
+
+// nativeRenderError classifies a failure of the native renderer like those of marp-cli
+func nativeRenderError(err error) error {
+	if errors.Is(err, render.ErrBrowserNotFound) {
+		return &RenderError{Code: RenderErrChromiumMissing, Message: chromiumMissingMessage, Err: err}
+	}
+	return &RenderError{
+		Code:    RenderErrUnknown,
+		Message: fmt.Sprintf("The slide renderer failed unexpectedly (%v). Please try again.", err),
+		Err:     err,
+	}
+}
+
+// convertWithWorker requests the PDF of a deck of the temp directory from a worker
+func (r *Renderer) convertWithWorker(worker *marpWorker, mdPath, pdfPath string) error {
+	defer r.acquire()()
+	rendererMetrics.Add("worker_conversions", 1)
+
+	absPath, err := filepath.Abs(mdPath)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(r.root, absPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("%w: %s is outside of the temp directory", errWorkerUnavailable, mdPath)
+	}
+
+	select {
+	case <-worker.done:
+		return fmt.Errorf("%w: process exited", errWorkerUnavailable)
+	default:
+	}
+
+	client := http.Client{Timeout: conversionTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/%s?pdf", worker.port, (&url.URL{Path: filepath.ToSlash(rel)}).EscapedPath()))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWorkerUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxRenderOutput))
+		return classifyMarpError(fmt.Errorf("marp-cli server answered %s", resp.Status), string(body))
+	}
+
+	out, err := os.Create(pdfPath)
+	if err != nil {
+		return fmt.Errorf("failed to create PDF: %w", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// declaresTheme reports whether a worker renders a deck with the theme, the theme
+// directive of a deck takes precedence over the one given to marp-cli
+func declaresTheme(mdPath, theme string) bool {
+	if theme == "" {
+		return true
+	}
+	markdown, err := os.ReadFile(mdPath)
+	if err != nil {
+		return false
+	}
+	frontMatter, _ := slides.Split(string(markdown))
+	return slides.FrontMatterValue(frontMatter, "theme") != ""
+}
+
+// addWorker starts a worker and adds it to the pool, trying again until it starts
+func (r *Renderer) addWorker() {
+	delay := workerRestartDelay
+	for {
+		r.mu.Lock()
+		stopped := r.stopped
+		r.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		worker, err := r.startWorker()
+		if err == nil {
+			r.workers <- worker
+			return
+		}
+		log.Printf("Renderer: failed to start worker: %v", err)
+		rendererMetrics.Add("worker_failures", 1)
+		time.Sleep(delay)
+		delay = min(2*delay, maxWorkerRestartDelay)
+	}
+}
+
+// startWorker starts a marp-cli server on a free port and waits until it listens
+func (r *Renderer) startWorker() (*marpWorker, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	args := slices.Concat(r.command[1:], r.args, []string{"--server", "--allow-local-files", r.root})
+	cmd := exec.Command(r.command[0], args...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PORT=%d", port))
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start marp-cli: %w", err)
+	}
+
+	worker := &marpWorker{port: port, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(worker.done)
+	}()
+
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		worker.stop()
+		return nil, fmt.Errorf("renderer stopped")
+	}
+	r.all[worker] = true
+	r.mu.Unlock()
+
+	deadline := time.Now().Add(workerStartTimeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+		if err == nil {
+			conn.Close()
+			return worker, nil
+		}
+		select {
+		case <-worker.done:
+			r.removeWorker(worker)
+			return nil, fmt.Errorf("marp-cli server exited on start")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	r.removeWorker(worker)
+	return nil, fmt.Errorf("marp-cli server did not listen within %s", workerStartTimeout)
+}
+
+// removeWorker stops a worker and forgets it
+func (r *Renderer) removeWorker(worker *marpWorker) {
+	worker.stop()
+	r.mu.Lock()
+	delete(r.all, worker)
+	r.mu.Unlock()
+}
+
+// stop asks the worker to exit, which also closes its browser
+func (w *marpWorker) stop() {
+	select {
+	case <-w.done:
+	default:
+		w.cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
+
+// freePort returns a TCP port free on the loopback interface
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// Output of a marp-cli server read for the classification of a failure
+const maxRenderOutput = 64 << 10
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+)
+
+// rerenderDeck renders the stored markdown of a deck again, without calling the LLM.
+// The transform is applied to the markdown before rendering when not nil.
+func (s *PitchDeckService) rerenderDeck(deck *model.PitchDeckInfo, transform func(string) string) error {
+	if err := s.acceptingJobs(); err != nil {
+		return err
+	}
+
+	markdown, err := s.loadMarkdown(deck)
+	if err != nil {
+		return err
+	}
+	if transform != nil {
+		markdown = transform(markdown)
+	}
+	return s.rerenderMarkdown(deck, markdown)
+}
+
+// rerenderMarkdown renders an edited version of the markdown of a deck in its place
+func (s *PitchDeckService) rerenderMarkdown(deck *model.PitchDeckInfo, markdown string) error {
+	if err := s.acceptingJobs(); err != nil {
+		return err
+	}
+
+	if err := s.lockDeck(deck.ID); err != nil {
+		return err
+	}
+	return s.startRerender(deck, markdown)
+}
+
+// startRerender renders the markdown of a deck locked by the caller
+func (s *PitchDeckService) startRerender(deck *model.PitchDeckInfo, markdown string) error {
+	deckDir := filepath.Join("temp", deck.ID)
+	if err := os.MkdirAll(deckDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create deck directory: %w", err)
+	}
+
+	// Styles were already injected in the stored markdown, only the theme is needed,
+	// and the language for the direction of the HTML. Decks without answers, such as
+	// imported ones, may set it with the Marp lang directive.
+	frontMatter, _ := slides.Split(markdown)
+	opts := renderOptions{
+		Theme:    slides.FrontMatterValue(frontMatter, "theme"),
+		Language: slides.FrontMatterValue(frontMatter, "lang"),
+	}
+	// The PDF keeps the metadata, tagging and profile set by the answers of the deck
+	if data, err := s.loadInput(deck.ID); err != nil {
+		log.Printf("Failed to load the answers of deck %s to rerender it: %v", deck.ID, err)
+	} else if data != nil {
+		opts.Metadata = pdfMetadata(*data)
+		opts.TaggedPDF = data.AccessiblePDF
+		opts.ExportProfile = data.ExportProfile
+		if data.Language != "" {
+			opts.Language = data.Language
+		}
+	}
+
+	s.startJob(deck, func(ctx context.Context) { s.renderDeck(ctx, deck, markdown, opts, deckDir) })
+	return nil
+}
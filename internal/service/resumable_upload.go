@@ -0,0 +1,287 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Directory of the uploads being received, as <id>.part with their <id>.json info
+	resumableUploadDir = "uploads/resumable"
+	// Incomplete uploads are removed after this long without a chunk
+	resumableUploadExpiry = 24 * time.Hour
+)
+
+// mediaType is a type of file accepted as a resumable upload
+type mediaType struct {
+	folder string
+	ext    string
+}
+
+var resumableMediaTypes = map[string]mediaType{
+	"image/png":  {"images/", ".png"},
+	"image/jpeg": {"images/", ".jpg"},
+	"image/gif":  {"images/", ".gif"},
+	"image/webp": {"images/", ".webp"},
+	"video/mp4":  {"videos/", ".mp4"},
+	"video/webm": {"videos/", ".webm"},
+}
+
+// CreateResumableUpload starts an upload of length bytes, received in chunks. The
+// content type given by the client only sets the size limit, the type of the file
+// is checked once complete.
+func (s *UploadService) CreateResumableUpload(userID string, length int64, filename, contentType string) (*model.ResumableUpload, error) {
+	maxSize := int64(model.MaxResumableVideoSize)
+	if strings.HasPrefix(contentType, "image/") {
+		maxSize = model.MaxResumableImageSize
+	}
+	if length <= 0 || length > maxSize {
+		return nil, fmt.Errorf("%w: uploads must be between 1 and %d bytes", model.ErrInvalidInput, maxSize)
+	}
+	// Checked before receiving any byte, and again when the file is stored
+	if err := checkUpload(userID, length); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(resumableUploadDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	upload := &model.ResumableUpload{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Filename:    filepath.Base(filename),
+		ContentType: contentType,
+		Length:      length,
+		ExpiresAt:   time.Now().Add(resumableUploadExpiry),
+	}
+	part, err := os.Create(resumablePartPath(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	part.Close()
+	if err := saveResumableUpload(upload); err != nil {
+		os.Remove(resumablePartPath(upload.ID))
+		return nil, err
+	}
+	return upload, nil
+}
+
+// GetResumableUpload returns an upload of a user, with the number of bytes received
+func (s *UploadService) GetResumableUpload(uploadID, userID string) (*model.ResumableUpload, error) {
+	// The ID names files on disk, anything but a UUID is rejected
+	if _, err := uuid.Parse(uploadID); err != nil {
+		return nil, fmt.Errorf("%w: upload not found", model.ErrNotFound)
+	}
+
+	data, err := os.ReadFile(resumableInfoPath(uploadID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: upload not found", model.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload: %w", err)
+	}
+	var upload model.ResumableUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to load upload: %w", err)
+	}
+	if upload.UserID != userID {
+		return nil, fmt.Errorf("%w: upload belongs to another user", model.ErrForbidden)
+	}
+	if upload.FileURL == "" && time.Now().After(upload.ExpiresAt) {
+		return nil, fmt.Errorf("%w: upload expired, start it again", model.ErrExpired)
+	}
+
+	if upload.FileURL != "" {
+		upload.Offset = upload.Length
+		return &upload, nil
+	}
+	info, err := os.Stat(resumablePartPath(uploadID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload: %w", err)
+	}
+	upload.Offset = info.Size()
+	return &upload, nil
+}
+
+// AppendResumableUpload writes a chunk at the end of an upload, offset must be the
+// number of bytes already received. The bytes of a chunk interrupted midway are
+// kept. The file is stored once complete, an empty chunk retries storing it.
+func (s *UploadService) AppendResumableUpload(uploadID, userID string, offset int64, chunk io.Reader) (*model.ResumableUpload, error) {
+	if _, busy := s.appending.LoadOrStore(uploadID, struct{}{}); busy {
+		return nil, fmt.Errorf("%w: the upload is already receiving a chunk", model.ErrConflict)
+	}
+	defer s.appending.Delete(uploadID)
+
+	upload, err := s.GetResumableUpload(uploadID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.FileURL != "" {
+		return upload, nil
+	}
+	if offset != upload.Offset {
+		return nil, fmt.Errorf("%w: the upload is at offset %d", model.ErrConflict, upload.Offset)
+	}
+
+	part, err := os.OpenFile(resumablePartPath(uploadID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	// One byte more than remains is read to detect chunks over the length
+	written, copyErr := io.Copy(part, io.LimitReader(chunk, upload.Length-upload.Offset+1))
+	if written > upload.Length-upload.Offset {
+		part.Truncate(upload.Offset)
+		part.Close()
+		return nil, fmt.Errorf("%w: the chunk exceeds the length of the upload", model.ErrInvalidInput)
+	}
+	if err := part.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	upload.Offset += written
+
+	// Uploads receiving chunks do not expire
+	upload.ExpiresAt = time.Now().Add(resumableUploadExpiry)
+	if err := saveResumableUpload(upload); err != nil {
+		return nil, err
+	}
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to receive chunk: %w", copyErr)
+	}
+
+	if upload.Offset == upload.Length {
+		if err := s.storeResumableUpload(upload); err != nil {
+			return nil, err
+		}
+	}
+	return upload, nil
+}
+
+// storeResumableUpload validates a complete upload and stores it like the files
+// uploaded in one request
+func (s *UploadService) storeResumableUpload(upload *model.ResumableUpload) error {
+	partPath := resumablePartPath(upload.ID)
+	file, err := os.Open(partPath)
+	if err != nil {
+		return fmt.Errorf("failed to open upload: %w", err)
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	file.Close()
+
+	contentType := http.DetectContentType(head[:n])
+	media, ok := resumableMediaTypes[contentType]
+	if !ok {
+		s.DeleteResumableUpload(upload.ID, upload.UserID)
+		return fmt.Errorf("%w: %s files cannot be uploaded, upload an image or an MP4 or WebM video", model.ErrInvalidInput, contentType)
+	}
+	if media.folder == "images/" && upload.Length > model.MaxResumableImageSize {
+		s.DeleteResumableUpload(upload.ID, upload.UserID)
+		return fmt.Errorf("%w: images must be at most %d bytes", model.ErrInvalidInput, model.MaxResumableImageSize)
+	}
+
+	// Stored under a name of its own, with the extension of its actual type
+	filePath := filepath.Join("uploads", upload.ID+media.ext)
+	if err := os.Link(partPath, filePath); err != nil {
+		return fmt.Errorf("failed to assemble upload: %w", err)
+	}
+	defer os.Remove(filePath)
+
+	upload.ContentType = contentType
+	upload.FileURL, err = s.decks.uploadMedia(filePath, upload.Filename, upload.UserID, media.folder)
+	if err != nil {
+		upload.FileURL = ""
+		// A rejected file is not stored when the client retries
+		if errors.Is(err, model.ErrInvalidInput) {
+			s.DeleteResumableUpload(upload.ID, upload.UserID)
+		}
+		return err
+	}
+	if err := saveResumableUpload(upload); err != nil {
+		return err
+	}
+	os.Remove(partPath)
+	return nil
+}
+
+// DeleteResumableUpload abandons an upload, its file is kept once stored
+func (s *UploadService) DeleteResumableUpload(uploadID, userID string) error {
+	if _, err := s.GetResumableUpload(uploadID, userID); err != nil && !errors.Is(err, model.ErrExpired) {
+		return err
+	}
+	os.Remove(resumablePartPath(uploadID))
+	if err := os.Remove(resumableInfoPath(uploadID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
+
+// removeExpiredUploads removes the uploads that expired and the info of the stored
+// ones, kept until then for clients checking the upload after it completed
+func removeExpiredUploads() (removed int, reclaimed int64) {
+	entries, err := os.ReadDir(resumableUploadDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Janitor: failed to read %s: %v", resumableUploadDir, err)
+		}
+		return 0, 0
+	}
+
+	for _, entry := range entries {
+		uploadID, found := strings.CutSuffix(entry.Name(), ".json")
+		if !found {
+			continue
+		}
+		var upload model.ResumableUpload
+		data, err := os.ReadFile(resumableInfoPath(uploadID))
+		if err == nil {
+			err = json.Unmarshal(data, &upload)
+		}
+		if err == nil && time.Now().Before(upload.ExpiresAt) {
+			continue
+		}
+
+		if info, err := os.Stat(resumablePartPath(uploadID)); err == nil {
+			reclaimed += info.Size()
+		}
+		os.Remove(resumablePartPath(uploadID))
+		os.Remove(resumableInfoPath(uploadID))
+		removed++
+	}
+	return removed, reclaimed
+}
+
+func saveResumableUpload(upload *model.ResumableUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	// Written aside then renamed, a crash never leaves a truncated info
+	tmpPath := resumableInfoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	if err := os.Rename(tmpPath, resumableInfoPath(upload.ID)); err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+	return nil
+}
+
+func resumablePartPath(uploadID string) string {
+	return filepath.Join(resumableUploadDir, uploadID+".part")
+}
+
+func resumableInfoPath(uploadID string) string {
+	return filepath.Join(resumableUploadDir, uploadID+".json")
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// reviseDeck moves a deck to its next revision before a change made from revision,
+// so that of two changes made from the same revision the second one fails with the
+// deck as it is now. Changes made without a revision (0) apply to the current one.
+func (s *PitchDeckService) reviseDeck(ctx context.Context, deckID string, revision int) error {
+	if revision == 0 {
+		current, err := s.repo.Get(ctx, deckID)
+		if err != nil {
+			return err
+		}
+		revision = current.Revision
+	}
+
+	err := s.repo.Revise(ctx, deckID, revision)
+	deckCache.invalidate()
+	if !errors.Is(err, model.ErrConflict) {
+		return err
+	}
+
+	current, getErr := s.repo.Get(ctx, deckID)
+	if getErr != nil {
+		return err
+	}
+	return &model.StaleError{Current: current}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var (
+	searchIgnoredBlock = regexp.MustCompile(`(?is)<!--.*?-->|<style.*?</style>`)
+	searchImage        = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	searchLink         = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	searchTag          = regexp.MustCompile(`<[^>]+>`)
+	searchMarkup       = regexp.MustCompile("[#*_`>|~]+")
+	searchSpaces       = regexp.MustCompile(`\s+`)
+)
+
+// searchMatch is a row returned by the search_decks function
+type searchMatch struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Snippet string `json:"snippet"`
+}
+
+// searchableText reduces the markdown of a deck to the text shown on its slides
+func searchableText(markdown string) string {
+	_, deckSlides := slides.Split(markdown)
+
+	var texts []string
+	for _, slide := range deckSlides {
+		slide = searchIgnoredBlock.ReplaceAllString(slide, " ")
+		slide = searchImage.ReplaceAllString(slide, " ")
+		slide = searchLink.ReplaceAllString(slide, "$1")
+		slide = searchTag.ReplaceAllString(slide, " ")
+		slide = searchMarkup.ReplaceAllString(slide, " ")
+		slide = strings.TrimSpace(searchSpaces.ReplaceAllString(slide, " "))
+		if slide != "" {
+			texts = append(texts, slide)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// indexDeckContent makes the text of a rendered deck searchable. A failure is logged,
+// the deck can still be found by name. With encryption at rest the index would keep
+// the text in clear, decks are then only found by name.
+func indexDeckContent(deckID, markdown string) {
+	if encryptionEnabled() {
+		return
+	}
+	params := map[string]string{"p_deck_id": deckID, "p_content": searchableText(markdown)}
+	if err := supabaseWrite("POST", "rpc/index_deck", params); err != nil {
+		log.Printf("Failed to index deck %s for search: %v", deckID, err)
+	}
+}
+
+// highlight escapes a headline from the database, keeping the <mark> tags around matches
+func highlight(headline string) string {
+	return strings.NewReplacer("&lt;mark&gt;", "<mark>", "&lt;/mark&gt;", "</mark>").Replace(html.EscapeString(headline))
+}
+
+// Search finds the decks of a user, and those shared with their organizations, whose
+// name or content match the query. The query accepts the web search syntax: quoted
+// phrases, or and -excluded words. Results are ranked, name matches first.
+//
+// The index and the search_decks function are created by the deck_search migration.
+func (s *PitchDeckService) Search(userID, query string, limit int) ([]model.DeckSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: query is required", model.ErrInvalidInput)
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	members, err := memberships(userID)
+	if err != nil {
+		return nil, err
+	}
+	orgIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		orgIDs = append(orgIDs, m.OrgID)
+	}
+
+	params := map[string]interface{}{
+		"p_user_id": userID,
+		"p_org_ids": orgIDs,
+		"p_query":   query,
+		"p_limit":   limit,
+	}
+	var matches []searchMatch
+	if err := supabaseREST("POST", "rpc/search_decks", params, &matches); err != nil {
+		return nil, fmt.Errorf("failed to search decks: %w", err)
+	}
+
+	results := []model.DeckSearchResult{}
+	if len(matches) == 0 {
+		return results, nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return nil, err
+	}
+	// Decks of other tenants are left out of the results
+	var decks []model.PitchDeckInfo
+	path := "pitch_decks?id=in.(" + url.QueryEscape(strings.Join(ids, ",")) + ")&tenant_id=eq." + url.QueryEscape(tenantID)
+	if err := supabaseREST("GET", path, nil, &decks); err != nil {
+		return nil, fmt.Errorf("failed to load decks: %w", err)
+	}
+	byID := make(map[string]model.PitchDeckInfo, len(decks))
+	for _, deck := range decks {
+		byID[deck.ID] = deck
+	}
+
+	// Keep the ranking of the database
+	for _, match := range matches {
+		deck, ok := byID[match.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, model.DeckSearchResult{
+			Deck:    deck,
+			Title:   highlight(match.Title),
+			Snippet: highlight(match.Snippet),
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"pitch-deck-generator/internal/render"
+)
+
+// Time given to the browser to start and to the LLM API to answer at startup
+const selfCheckTimeout = 30 * time.Second
+
+// SelfCheck verifies the dependencies of generations at startup, so a broken
+// deployment fails at boot with an actionable error instead of failing decks. The
+// browser, marp-cli when it is required, the storage buckets and the LLM credentials
+// are checked; the LLM being unreachable only degrades the service.
+func SelfCheck(storage bucketStorage) error {
+	if err := checkRenderer(); err != nil {
+		return err
+	}
+
+	if err := ProvisionBuckets(storage); err != nil {
+		return fmt.Errorf("storage: %w (check SUPABASE_URL and that SUPABASE_SERVICE_KEY is the service role key)", err)
+	}
+
+	return checkLLM()
+}
+
+// checkRenderer starts the browser once, which the native renderer and tagged PDFs
+// need, and runs marp-cli when RENDERER requires it
+func checkRenderer() error {
+	if os.Getenv("RENDERER") == "marp" {
+		if err := checkMarp(renderer.command); err != nil {
+			return fmt.Errorf("renderer: %w (or set RENDERER=native)", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	if err := render.Warm(ctx); err != nil {
+		if renderer.native {
+			return fmt.Errorf("renderer: no browser to render decks with: %w (install Chromium or set CHROME_PATH, CHROME_NO_SANDBOX when running as root)", err)
+		}
+		log.Printf("Renderer: no browser, accessible PDFs cannot be printed: %v", err)
+	}
+	return nil
+}
+
+// checkLLM asks Gemini, or the endpoint replacing it, for the model generating the
+// decks, which costs no tokens. Rejected credentials stop the server, an unreachable
+// API is only logged.
+func checkLLM() error {
+	if customLLM != nil {
+		return customLLM.check()
+	}
+
+	key := os.Getenv("GEMINI_API_KEY")
+	if key == "" {
+		log.Println("Deck generation disabled: GEMINI_API_KEY is not set")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	modelURL := "https://generativelanguage.googleapis.com/v1beta/models/" + geminiModel + "?key=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, "GET", modelURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("LLM: Gemini is unreachable, generations will fail until it is: %v", withoutURL(err))
+		return nil
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("LLM: GEMINI_API_KEY was rejected by Gemini (status %d), check the key and that the Generative Language API is enabled", resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("LLM: the model %s is not available to GEMINI_API_KEY", geminiModel)
+	}
+	log.Printf("LLM: Gemini answered with status %d, generations may fail", resp.StatusCode)
+	return nil
+}
+
+// withoutURL drops the URL quoted by HTTP client errors, it holds the API key
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
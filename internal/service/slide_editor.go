@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/slides"
+)
+
+// Length of the title of an inserted slide
+const maxSlideTitle = 200
+
+// Templates of the slides inserted by the slide editor, filled with their title
+var slideTemplates = map[string]struct {
+	Markdown     string
+	DefaultTitle string
+}{
+	"blank":     {Markdown: "## %s", DefaultTitle: "New slide"},
+	"bullets":   {Markdown: "## %s\n\n- First point\n- Second point\n- Third point", DefaultTitle: "New slide"},
+	"statement": {Markdown: "<!-- _class: lead -->\n\n# %s", DefaultTitle: "Key message"},
+	"quote":     {Markdown: "<!-- _class: quote -->\n\n> %s\n\nAuthor", DefaultTitle: "Quote"},
+}
+
+// Stylesheets applying to the whole deck, injected at the top of its first slide
+var globalStyleRegex = regexp.MustCompile(`(?s)^(?:\s*<style>.*?</style>)+\s*`)
+
+// SlideTemplates returns the names of the templates slides can be inserted from
+func SlideTemplates() []string {
+	names := make([]string, 0, len(slideTemplates))
+	for name := range slideTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// editSlides applies an edit to the slides of a completed deck and renders the deck
+// again. The stylesheets of the deck stay on its first slide, whichever slide that is
+// after the edit.
+func (s *PitchDeckService) editSlides(deckID, userID string, revision int, reason string, edit func([]string) ([]string, error)) error {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return err
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return err
+	}
+
+	markdown, err := s.loadMarkdown(deck)
+	if err != nil {
+		return err
+	}
+	frontMatter, deckSlides := slides.Split(markdown)
+	if len(deckSlides) == 0 {
+		return fmt.Errorf("%w: the deck has no slides", model.ErrConflict)
+	}
+
+	styles := globalStyleRegex.FindString(deckSlides[0])
+	deckSlides[0] = deckSlides[0][len(styles):]
+
+	deckSlides, err = edit(deckSlides)
+	if err != nil {
+		return err
+	}
+	if styles != "" {
+		deckSlides[0] = strings.TrimSpace(styles) + "\n\n" + deckSlides[0]
+	}
+	if err := s.reviseDeck(context.Background(), deck.ID, revision); err != nil {
+		return err
+	}
+
+	if err := s.rerenderMarkdown(deck, slides.Join(frontMatter, deckSlides)); err != nil {
+		return err
+	}
+	recordAudit(deck.ID, userID, AuditDeckRegenerated, map[string]interface{}{
+		"reason": reason,
+	})
+	return nil
+}
+
+// ReorderSlides puts the slides of a deck in a new order, given as the numbers of
+// the slides (1 is first) in their new order
+func (s *PitchDeckService) ReorderSlides(deckID, userID string, order []int, revision int) error {
+	return s.editSlides(deckID, userID, revision, "slides_reordered", func(deckSlides []string) ([]string, error) {
+		if len(order) != len(deckSlides) {
+			return nil, fmt.Errorf("%w: the order must list each of the %d slides once", model.ErrInvalidInput, len(deckSlides))
+		}
+		reordered := make([]string, 0, len(deckSlides))
+		seen := make(map[int]bool, len(order))
+		for _, number := range order {
+			if number < 1 || number > len(deckSlides) || seen[number] {
+				return nil, fmt.Errorf("%w: the order must list each of the %d slides once", model.ErrInvalidInput, len(deckSlides))
+			}
+			seen[number] = true
+			reordered = append(reordered, deckSlides[number-1])
+		}
+		return reordered, nil
+	})
+}
+
+// DeleteSlide removes a slide from a deck, by its number (1 is first)
+func (s *PitchDeckService) DeleteSlide(deckID, userID string, number, revision int) error {
+	return s.editSlides(deckID, userID, revision, "slide_deleted", func(deckSlides []string) ([]string, error) {
+		if number < 1 || number > len(deckSlides) {
+			return nil, fmt.Errorf("%w: the deck has no slide %d", model.ErrNotFound, number)
+		}
+		if len(deckSlides) == 1 {
+			return nil, fmt.Errorf("%w: the last slide of a deck cannot be deleted", model.ErrConflict)
+		}
+		return slices.Delete(deckSlides, number-1, number), nil
+	})
+}
+
+// InsertSlide adds a slide made from a template to a deck. It becomes the slide at
+// position (1 is first), or the last one when position is 0.
+func (s *PitchDeckService) InsertSlide(deckID, userID string, position int, template, title string, revision int) error {
+	if template == "" {
+		template = "blank"
+	}
+	tmpl, ok := slideTemplates[template]
+	if !ok {
+		return fmt.Errorf("%w: unknown slide template %q, expected one of %s",
+			model.ErrInvalidInput, template, strings.Join(SlideTemplates(), ", "))
+	}
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = tmpl.DefaultTitle
+	}
+	if utf8.RuneCountInString(title) > maxSlideTitle || strings.ContainsAny(title, "\r\n") {
+		return fmt.Errorf("%w: the slide title must be a single line of at most %d characters", model.ErrInvalidInput, maxSlideTitle)
+	}
+	slide := fmt.Sprintf(tmpl.Markdown, title)
+
+	return s.editSlides(deckID, userID, revision, "slide_inserted", func(deckSlides []string) ([]string, error) {
+		if position == 0 {
+			position = len(deckSlides) + 1
+		}
+		if position < 1 || position > len(deckSlides)+1 {
+			return nil, fmt.Errorf("%w: position must be between 1 and %d", model.ErrInvalidInput, len(deckSlides)+1)
+		}
+		return slices.Insert(deckSlides, position-1, slide), nil
+	})
+}
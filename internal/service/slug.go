@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"pitch-deck-generator/internal/model"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	minSlugLength = 3
+	maxSlugLength = 60
+)
+
+var slugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// IsDeckRef reports whether a deck is referred to by a valid ID or slug, the forms
+// its public URLs use
+func IsDeckRef(ref string) bool {
+	if len(ref) == 36 {
+		if _, err := uuid.Parse(ref); err == nil {
+			return true
+		}
+	}
+	return len(ref) >= minSlugLength && len(ref) <= maxSlugLength && slugRegex.MatchString(ref)
+}
+
+// slugify turns a deck name into a URL friendly slug, "Acme – Série A" gives "acme-serie-a"
+func slugify(name string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Accents are dropped after decomposition
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+
+	slug := sb.String()
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if len(slug) < minSlugLength {
+		slug = "deck-" + slug
+	}
+	return strings.TrimRight(slug, "-")
+}
+
+// slugOwner returns the ID of the deck using the slug, or an empty string
+func slugOwner(slug string) (string, error) {
+	var decks []struct {
+		ID string `json:"id"`
+	}
+	if err := supabaseREST("GET", "pitch_decks?select=id&slug=eq."+url.QueryEscape(slug), nil, &decks); err != nil {
+		return "", fmt.Errorf("failed to check slug: %w", err)
+	}
+	if len(decks) == 0 {
+		return "", nil
+	}
+	return decks[0].ID, nil
+}
+
+// uniqueSlug derives a free slug from the deck name. On collision the year is
+// appended, then a counter (acme-seed, acme-seed-2024, acme-seed-2024-2...).
+// The unique index on pitch_decks.slug rejects the rare concurrent duplicates.
+func uniqueSlug(name string) (string, error) {
+	base := slugify(name)
+	candidates := []string{base, base + "-" + strconv.Itoa(time.Now().Year())}
+
+	for i := 0; i < 20; i++ {
+		if i >= len(candidates) {
+			candidates = append(candidates, fmt.Sprintf("%s-%d", candidates[1], i))
+		}
+		owner, err := slugOwner(candidates[i])
+		if err != nil {
+			return "", err
+		}
+		if owner == "" {
+			return candidates[i], nil
+		}
+	}
+
+	// Give up on readability rather than failing the generation
+	return base + "-" + uuid.New().String()[:8], nil
+}
+
+// assignSlug sets a unique slug on a new deck, a deck without slug stays reachable by ID
+func assignSlug(deckInfo *model.PitchDeckInfo) {
+	slug, err := uniqueSlug(deckInfo.Name)
+	if err != nil {
+		return
+	}
+	deckInfo.Slug = slug
+}
+
+// UpdateSlug lets the owner choose the slug of a deck and returns the normalized slug
+func (s *PitchDeckService) UpdateSlug(deckID, userID, slug string, revision int) (string, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleEditor)
+	if err != nil {
+		return "", err
+	}
+
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if len(slug) < minSlugLength || len(slug) > maxSlugLength || !slugRegex.MatchString(slug) {
+		return "", fmt.Errorf("%w: slug must be %d to %d lowercase letters, digits or dashes",
+			model.ErrInvalidInput, minSlugLength, maxSlugLength)
+	}
+	// A slug shaped like an ID would be ambiguous in deck URLs
+	if _, err := uuid.Parse(slug); err == nil {
+		return "", fmt.Errorf("%w: slug cannot be a deck ID", model.ErrInvalidInput)
+	}
+
+	owner, err := slugOwner(slug)
+	if err != nil {
+		return "", err
+	}
+	if owner != "" && owner != deck.ID {
+		return "", fmt.Errorf("%w: slug %q is already taken", model.ErrConflict, slug)
+	}
+	if err := checkUnlocked(deck); err != nil {
+		return "", err
+	}
+	if err := s.reviseDeck(context.Background(), deck.ID, revision); err != nil {
+		return "", err
+	}
+
+	update := map[string]string{"slug": slug}
+	if err := supabaseREST("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deck.ID), update, nil); err != nil {
+		return "", fmt.Errorf("failed to update slug: %w", err)
+	}
+	return slug, nil
+}
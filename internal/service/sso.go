@@ -0,0 +1,22 @@
+package service
+
+import "fmt"
+
+// ResolveSSOIdentity returns the user an identity of an enterprise identity provider
+// is mapped to, mapping it on first sign in (see middleware.ConfigureSSO)
+func ResolveSSOIdentity(issuer, subject, email string, linkEmail bool) (string, error) {
+	params := map[string]interface{}{
+		"p_issuer":     issuer,
+		"p_subject":    subject,
+		"p_email":      email,
+		"p_link_email": linkEmail,
+	}
+	var userID string
+	if err := supabaseREST("POST", "rpc/resolve_sso_identity", params, &userID); err != nil {
+		return "", fmt.Errorf("failed to resolve SSO identity: %w", err)
+	}
+	if userID == "" {
+		return "", fmt.Errorf("failed to resolve SSO identity")
+	}
+	return userID, nil
+}
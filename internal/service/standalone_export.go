@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/storage"
+)
+
+const (
+	// Assets larger than this are left as links
+	maxInlinedAsset = 10 << 20
+	// Beyond this total the remaining assets are left as links
+	maxInlinedTotal = 50 << 20
+	// Stylesheets imported by imported stylesheets are followed this deep
+	maxImportDepth = 3
+)
+
+var (
+	stylesheetLinkRegex = regexp.MustCompile(`(?i)<link\b[^>]*\brel=["']?stylesheet["']?[^>]*>`)
+	hrefRegex           = regexp.MustCompile(`(?i)\bhref=["']([^"']+)["']`)
+	srcAttrRegex        = regexp.MustCompile(`(?i)(<(?:img|source|video|audio)\b[^>]*?\ssrc=)(["'])([^"']+)(["'])`)
+	cssImportRegex      = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?(?:"|'|&quot;)?([^"'()\s;&]+)(?:"|'|&quot;)?\s*\)?\s*([^;]*);`)
+	cssURLRegex         = regexp.MustCompile(`(?i)url\(\s*(&quot;|"|')?([^"'()]+?)(&quot;|"|')?\s*\)`)
+)
+
+// assetInliner fetches the assets of one document once and replaces their links by
+// data URIs
+type assetInliner struct {
+	base   *url.URL
+	cache  map[string]string
+	total  int
+	failed int
+}
+
+// StandaloneHTML returns the HTML viewer of a deck as a single file, its stylesheets
+// embedded and its images and fonts inlined as data URIs, to be emailed or opened
+// offline. The file is named after the deck.
+func (s *PitchDeckService) StandaloneHTML(deckID, userID string) ([]byte, string, error) {
+	deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+	if err != nil {
+		return nil, "", err
+	}
+	if deck.Status != "completed" || deck.HtmlURL == "" {
+		return nil, "", fmt.Errorf("%w: the deck is not rendered yet", model.ErrConflict)
+	}
+
+	document, err := fetchText(deck.HtmlURL)
+	if err != nil {
+		return nil, "", err
+	}
+	base, err := url.Parse(deck.HtmlURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid deck URL: %w", err)
+	}
+
+	inliner := &assetInliner{base: base, cache: make(map[string]string)}
+	document = inliner.inlineHTML(document)
+	if inliner.failed > 0 {
+		log.Printf("Standalone export of deck %s: %d assets left as links", deck.ID, inliner.failed)
+	}
+	return []byte(document), slugify(deck.Name) + ".html", nil
+}
+
+func (a *assetInliner) inlineHTML(document string) string {
+	document = stylesheetLinkRegex.ReplaceAllStringFunc(document, func(tag string) string {
+		match := hrefRegex.FindStringSubmatch(tag)
+		if match == nil {
+			return tag
+		}
+		ref, err := a.base.Parse(html.UnescapeString(match[1]))
+		if err != nil {
+			return tag
+		}
+		stylesheet, err := a.fetch(ref)
+		if err != nil {
+			a.skip(ref, err)
+			return tag
+		}
+		// A closing tag in the stylesheet would end the style element
+		css := strings.ReplaceAll(a.inlineCSS(string(stylesheet.data), ref, 0), "</style", `<\/style`)
+		return "<style>\n" + css + "\n</style>"
+	})
+
+	document = srcAttrRegex.ReplaceAllStringFunc(document, func(attr string) string {
+		match := srcAttrRegex.FindStringSubmatch(attr)
+		ref, err := a.base.Parse(html.UnescapeString(match[3]))
+		if err != nil {
+			return attr
+		}
+		if uri, ok := a.dataURI(ref); ok {
+			return match[1] + match[2] + uri + match[4]
+		}
+		return attr
+	})
+
+	// Stylesheets and style attributes of the document itself, where Marp puts
+	// background images
+	return a.inlineCSS(document, a.base, 0)
+}
+
+// inlineCSS embeds the imported stylesheets and inlines the url() of a stylesheet
+// loaded from base
+func (a *assetInliner) inlineCSS(css string, base *url.URL, depth int) string {
+	css = cssImportRegex.ReplaceAllStringFunc(css, func(rule string) string {
+		match := cssImportRegex.FindStringSubmatch(rule)
+		ref, err := base.Parse(html.UnescapeString(match[1]))
+		if err != nil || depth >= maxImportDepth || (ref.Scheme != "http" && ref.Scheme != "https") {
+			return rule
+		}
+		imported, err := a.fetch(ref)
+		if err != nil {
+			a.skip(ref, err)
+			return rule
+		}
+		content := a.inlineCSS(string(imported.data), ref, depth+1)
+		// Imports with media queries keep applying to their media only
+		if media := strings.TrimSpace(match[2]); media != "" {
+			return "@media " + media + " {\n" + content + "\n}"
+		}
+		return content
+	})
+
+	return cssURLRegex.ReplaceAllStringFunc(css, func(value string) string {
+		match := cssURLRegex.FindStringSubmatch(value)
+		raw := html.UnescapeString(strings.TrimSpace(match[2]))
+		if strings.HasPrefix(raw, "data:") || strings.HasPrefix(raw, "#") {
+			return value
+		}
+		ref, err := base.Parse(raw)
+		if err != nil {
+			return value
+		}
+		// The quotes are kept, &quot; in style attributes
+		if uri, ok := a.dataURI(ref); ok {
+			return "url(" + match[1] + uri + match[3] + ")"
+		}
+		return value
+	})
+}
+
+// dataURI returns the data URI of an asset, false when it cannot be inlined
+func (a *assetInliner) dataURI(ref *url.URL) (string, bool) {
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return "", false
+	}
+	key := ref.String()
+	if uri, ok := a.cache[key]; ok {
+		return uri, uri != ""
+	}
+	asset, err := a.fetch(ref)
+	if err != nil {
+		a.skip(ref, err)
+		a.cache[key] = ""
+		return "", false
+	}
+	uri := "data:" + asset.contentType + ";base64," + base64.StdEncoding.EncodeToString(asset.data)
+	a.cache[key] = uri
+	return uri, true
+}
+
+type fetchedAsset struct {
+	data        []byte
+	contentType string
+}
+
+// fetch downloads an asset within the size limits. Assets of the deck storage are
+// fetched like the images of the answers, redirected to the storage only, the
+// others come from users and only from public addresses.
+func (a *assetInliner) fetch(ref *url.URL) (*fetchedAsset, error) {
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q", ref.Scheme)
+	}
+	if a.total >= maxInlinedTotal {
+		return nil, fmt.Errorf("export size limit reached")
+	}
+
+	client := publicClient
+	if ref.Host == a.base.Host {
+		client = storageImageClient
+	}
+	req, err := http.NewRequest("GET", ref.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	storage.AuthorizeObject(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxInlinedAsset+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxInlinedAsset {
+		return nil, fmt.Errorf("larger than %d bytes", maxInlinedAsset)
+	}
+	a.total += len(data)
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(path.Ext(ref.Path)); byExt != "" {
+			contentType, _, _ = mime.ParseMediaType(byExt)
+		} else {
+			contentType = http.DetectContentType(data)
+		}
+	}
+	return &fetchedAsset{data: data, contentType: contentType}, nil
+}
+
+func (a *assetInliner) skip(ref *url.URL, err error) {
+	a.failed++
+	log.Printf("Failed to inline %s: %v", ref.Redacted(), err)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Steps of a generation whose durations are recorded on its deck, with its total
+const (
+	stepLLM        = "llm"
+	stepRenderPDF  = "render_pdf"
+	stepRenderHTML = "render_html"
+	stepUpload     = "upload"
+	stepTotal      = "total"
+)
+
+// stepTimings adds up the durations of the steps of a generation, the uploads of the
+// files of a deck are one step
+type stepTimings struct {
+	mu        sync.Mutex
+	durations map[string]time.Duration
+}
+
+type stepTimingsKey struct{}
+
+// withStepTimings returns a context in which the steps timed are added to timings
+func withStepTimings(ctx context.Context) (context.Context, *stepTimings) {
+	timings := &stepTimings{durations: make(map[string]time.Duration)}
+	return context.WithValue(ctx, stepTimingsKey{}, timings), timings
+}
+
+// timeStep starts timing a step of the generation of ctx, the returned func stops it.
+// Steps outside of a generation are not timed.
+func timeStep(ctx context.Context, step string) func() {
+	timings, _ := ctx.Value(stepTimingsKey{}).(*stepTimings)
+	if timings == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		timings.mu.Lock()
+		defer timings.mu.Unlock()
+		timings.durations[step] += time.Since(start)
+	}
+}
+
+// milliseconds returns the durations of the steps in milliseconds
+func (t *stepTimings) milliseconds() map[string]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ms := make(map[string]int64, len(t.durations))
+	for step, d := range t.durations {
+		ms[step] = d.Milliseconds()
+	}
+	return ms
+}
+
+// recordStepTimings saves on a deck the durations of the steps of its last
+// generation, failed or not, replacing those of the previous one
+func recordStepTimings(deckID string, timings *stepTimings, total time.Duration) {
+	ms := timings.milliseconds()
+	ms[stepTotal] = total.Milliseconds()
+	update := map[string]map[string]int64{"step_timings": ms}
+	if err := supabaseWrite("PATCH", "pitch_decks?id=eq."+url.QueryEscape(deckID), update); err != nil {
+		log.Printf("Failed to record step timings of deck %s: %v", deckID, err)
+	}
+}
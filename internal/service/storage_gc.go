@@ -0,0 +1,242 @@
+package service
+
+import (
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultStorageGCInterval = 24 * time.Hour
+
+	// Objects younger than this are never orphans, their record may not be saved yet
+	storageGCMinAge = 24 * time.Hour
+)
+
+// Orphans found and removed by the storage GC, published on the admin metrics endpoint
+var storageGCMetrics = expvar.NewMap("storage_gc")
+
+// StorageGC reconciles the deck bucket and its private bucket with the database. Rendered files are listed
+// in the asset manifest of their deck, or named after it for older renders
+// (<id>-<hash>.pdf, or <id>.pdf before content hashes), and images are tracked in
+// user_files; objects without a matching record are
+// orphans, as are the files of previous renders their deck no longer points to. Orphans are only
+// reported unless STORAGE_GC_DELETE is true, and objects that do not follow a known
+// naming scheme are left alone.
+type StorageGC struct {
+	decks    *PitchDeckService
+	interval time.Duration
+	delete   bool
+
+	// Runs do not overlap when one is triggered from the admin API
+	mu sync.Mutex
+}
+
+// NewStorageGC configures the collector from STORAGE_GC_INTERVAL and STORAGE_GC_DELETE
+func NewStorageGC(decks *PitchDeckService) *StorageGC {
+	interval, err := time.ParseDuration(os.Getenv("STORAGE_GC_INTERVAL"))
+	if err != nil || interval <= 0 {
+		interval = defaultStorageGCInterval
+	}
+
+	return &StorageGC{
+		decks:    decks,
+		interval: interval,
+		delete:   os.Getenv("STORAGE_GC_DELETE") == "true",
+	}
+}
+
+// Start runs the reconciliation at every interval, in the background
+func (g *StorageGC) Start() {
+	go func() {
+		for {
+			time.Sleep(g.interval)
+			if _, err := g.Run(); err != nil {
+				log.Printf("Storage GC failed: %v", err)
+				storageGCMetrics.Add("errors", 1)
+			}
+		}
+	}()
+}
+
+// Run lists the deck bucket and its private bucket, finds the objects without a
+// record and deletes them when enabled
+func (g *StorageGC) Run() (*model.StorageGCReport, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.decks.storage == nil {
+		return nil, fmt.Errorf("storage not configured")
+	}
+
+	report := &model.StorageGCReport{
+		Orphans: []string{},
+		DryRun:  !g.delete,
+		RanAt:   time.Now(),
+	}
+	for _, bucket := range []string{deckBucket(), storage.PrivateBucket(deckBucket())} {
+		if err := g.collect(bucket, report); err != nil {
+			return nil, err
+		}
+	}
+
+	storageGCMetrics.Add("scanned", int64(report.Scanned))
+	storageGCMetrics.Add("orphans", int64(len(report.Orphans)))
+	storageGCMetrics.Add("deleted", int64(report.Deleted))
+	storageGCMetrics.Add("reclaimed_bytes", report.ReclaimedBytes)
+
+	log.Printf("Storage GC: %d objects scanned, %d orphans, %d deleted, %d bytes reclaimed",
+		report.Scanned, len(report.Orphans), report.Deleted, report.ReclaimedBytes)
+	return report, nil
+}
+
+// collect adds the orphans of a bucket to the report, and deletes them when enabled
+func (g *StorageGC) collect(bucket string, report *model.StorageGCReport) error {
+	objects, err := g.decks.storage.ListFiles(bucket, "")
+	if err != nil {
+		return err
+	}
+	report.Scanned += len(objects)
+
+	var candidates []model.StorageObject
+	candidatePaths := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if time.Since(object.CreatedAt) >= storageGCMinAge {
+			candidates = append(candidates, object)
+			candidatePaths = append(candidatePaths, object.Path)
+		}
+	}
+	assets, err := assetsByPath(bucket, candidatePaths)
+	if err != nil {
+		return err
+	}
+
+	// Group the objects old enough to be collected by the record they belong to. The
+	// deck of an object is taken from the asset manifest, from its name for the
+	// files rendered before the manifest.
+	deckObjects := make(map[string][]model.StorageObject)
+	imageObjects := make(map[string]model.StorageObject)
+	for _, object := range candidates {
+		if asset, ok := assets[object.Path]; ok {
+			deckObjects[asset.DeckID] = append(deckObjects[asset.DeckID], object)
+		} else if deckID, ok := renderedDeckID(object.Path); ok {
+			deckObjects[deckID] = append(deckObjects[deckID], object)
+		} else if strings.HasPrefix(object.Path, "images/") {
+			imageObjects[object.Path] = object
+		}
+	}
+
+	var orphans []model.StorageObject
+
+	deckIDs := make([]string, 0, len(deckObjects))
+	for id := range deckObjects {
+		deckIDs = append(deckIDs, id)
+	}
+	existing, err := deckStates(deckIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load decks: %w", err)
+	}
+	for id, objects := range deckObjects {
+		deck, ok := existing[id]
+		if !ok {
+			orphans = append(orphans, objects...)
+			continue
+		}
+		current := []string{deck.PdfURL, deck.HtmlURL, deck.MarkdownURL}
+		for _, object := range objects {
+			// A file moved to the other bucket keeps its name, only its copy in this bucket is in use
+			inUse := func(u string) bool {
+				p, err := storedPath(u, bucket)
+				return err == nil && p == object.Path
+			}
+			if !slices.ContainsFunc(current, inUse) {
+				orphans = append(orphans, object)
+			}
+		}
+	}
+
+	imagePaths := make([]string, 0, len(imageObjects))
+	for p := range imageObjects {
+		imagePaths = append(imagePaths, p)
+	}
+	tracked, err := trackedUploads(imagePaths)
+	if err != nil {
+		return fmt.Errorf("failed to load uploads: %w", err)
+	}
+	for p, object := range imageObjects {
+		if !tracked[p] {
+			orphans = append(orphans, object)
+		}
+	}
+
+	var paths []string
+	for _, object := range orphans {
+		paths = append(paths, object.Path)
+		report.Orphans = append(report.Orphans, object.Path)
+	}
+
+	if g.delete && len(paths) > 0 {
+		if err := g.decks.storage.DeleteFiles(bucket, paths); err != nil {
+			return err
+		}
+		if err := forgetAssets(bucket, paths); err != nil {
+			log.Printf("Storage GC: %v", err)
+		}
+		report.Deleted += len(paths)
+		for _, object := range orphans {
+			report.ReclaimedBytes += object.Size
+		}
+	}
+
+	return nil
+}
+
+// renderedDeckID returns the deck of a rendered file stored at the root of the bucket
+func renderedDeckID(objectPath string) (string, bool) {
+	if strings.Contains(objectPath, "/") {
+		return "", false
+	}
+	switch path.Ext(objectPath) {
+	case ".pdf", ".html", ".md":
+	default:
+		return "", false
+	}
+	// <id>, or <id>-<hash> for the files named after their content
+	name := strings.TrimSuffix(objectPath, path.Ext(objectPath))
+	if len(name) > 37 && name[36] == '-' {
+		name = name[:36]
+	}
+	if _, err := uuid.Parse(name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// trackedUploads returns the storage paths that have a user_files record
+func trackedUploads(paths []string) (map[string]bool, error) {
+	tracked := make(map[string]bool)
+	for start := 0; start < len(paths); start += recordBatchSize {
+		batch := paths[start:min(start+recordBatchSize, len(paths))]
+
+		var files []model.UserFile
+		filter := "user_files?select=storage_path&storage_path=in.(" + quotedList(batch) + ")"
+		if err := supabaseREST("GET", filter, nil, &files); err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			tracked[file.StoragePath] = true
+		}
+	}
+	return tracked, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"pitch-deck-generator/internal/resilience"
+	"pitch-deck-generator/internal/storage"
+	"pitch-deck-generator/internal/tracing"
+)
+
+// supabaseClient sends the requests to Supabase, as client spans of the traces of
+// their context
+var supabaseClient = &http.Client{Transport: tracing.Transport(nil)}
+
+// supabaseREST sends a request to the Supabase REST API with the service key.
+// The body is sent as JSON when not nil, and the response is decoded into out when
+// out is not nil (the representation of written rows is requested in that case).
+func supabaseREST(method, path string, body interface{}, out interface{}) error {
+	return supabaseRESTContext(context.Background(), method, path, body, out)
+}
+
+// supabaseRESTContext is supabaseREST for a request made within the trace of ctx
+func supabaseRESTContext(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_SERVICE_KEY")
+
+	if supabaseURL == "" || supabaseKey == "" {
+		return fmt.Errorf("supabase credentials not set")
+	}
+
+	var jsonData []byte
+	if body != nil {
+		var err error
+		if jsonData, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal body: %w", err)
+		}
+	}
+
+	// Inserts and RPCs may not be idempotent, they are not retried
+	dependency := resilience.Supabase
+	if method == "POST" {
+		dependency = dependency.Once()
+	}
+
+	respBody, err := resilience.Call(dependency, func() ([]byte, error) {
+		var reader io.Reader
+		if jsonData != nil {
+			reader = bytes.NewReader(jsonData)
+		}
+
+		apiURL := fmt.Sprintf("%s/rest/v1/%s", strings.TrimSuffix(supabaseURL, "/"), strings.TrimPrefix(path, "/"))
+		req, err := http.NewRequestWithContext(ctx, method, apiURL, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("apikey", supabaseKey)
+		req.Header.Set("Authorization", "Bearer "+supabaseKey)
+		if out == nil {
+			req.Header.Set("Prefer", "return=minimal")
+		} else {
+			req.Header.Set("Prefer", "return=representation")
+		}
+
+		resp, err := supabaseClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, &resilience.StatusError{
+				StatusCode: resp.StatusCode,
+				Message:    fmt.Sprintf("supabase request failed with status %d: %s", resp.StatusCode, string(respBody)),
+			}
+		}
+		return respBody, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if writesDecks(method, path) {
+		deckCache.invalidate()
+	}
+
+	if out != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fetchText downloads a text document, such as a stored deck markdown, into memory
+func fetchText(url string) (string, error) {
+	body, err := fetchBytes(url)
+	return string(body), err
+}
+
+// fetchBytes downloads a file, such as a stored deck PDF, into memory. The files of
+// private decks are read with the service key.
+func fetchBytes(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	storage.AuthorizeObject(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s, status: %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	return body, nil
+}
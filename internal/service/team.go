@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/prompts"
+)
+
+const (
+	// Length of the profile text pasted for a team member
+	maxPastedProfile = 4000
+	// Length of a profile once in the prompt
+	maxMemberProfile = 800
+)
+
+// checkTeam validates the profiles and photos of the team members before the deck
+// is generated
+func checkTeam(members []model.TeamMember) error {
+	for _, member := range members {
+		if member.LinkedIn != "" {
+			if _, err := linkedInURL(member.LinkedIn); err != nil {
+				return err
+			}
+		}
+		if utf8.RuneCountInString(member.Profile) > maxPastedProfile {
+			return fmt.Errorf("%w: the profile of %s exceeds %d characters", model.ErrInvalidInput, member.Name, maxPastedProfile)
+		}
+		if member.Photo != "" {
+			if u, err := url.Parse(member.Photo); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: invalid photo URL for %s", model.ErrInvalidInput, member.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// linkedInURL parses the URL of a public LinkedIn profile, https is assumed without
+// scheme
+func linkedInURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: invalid LinkedIn URL %q", model.ErrInvalidInput, raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	if (host != "linkedin.com" && !strings.HasSuffix(host, ".linkedin.com")) || !strings.HasPrefix(u.Path, "/in/") {
+		return nil, fmt.Errorf("%w: %q is not a LinkedIn profile URL", model.ErrInvalidInput, raw)
+	}
+	u.Scheme = "https"
+	return u, nil
+}
+
+// hasTeamEnrichment reports whether any team member has a profile to read or a photo
+func hasTeamEnrichment(members []model.TeamMember) bool {
+	for _, member := range members {
+		if member.LinkedIn != "" || member.Photo != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichTeam completes the team members with their public profile and photo. The
+// pasted profile text is preferred to the LinkedIn profile, which is read for its
+// photo when none was given. Profiles that cannot be read are skipped, the member
+// is presented from the answers of the form.
+func (s *PitchDeckService) enrichTeam(members []model.TeamMember, deckDir string) prompts.Team {
+	team := teamMembers(members)
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, member model.TeamMember) {
+			defer wg.Done()
+			photo := member.Photo
+			if member.LinkedIn != "" && (member.Profile == "" || photo == "") {
+				profile, profilePhoto, err := fetchLinkedInProfile(member.LinkedIn)
+				if err != nil {
+					log.Printf("Failed to read LinkedIn profile %s: %v", member.LinkedIn, err)
+				}
+				if team[i].Profile == "" {
+					team[i].Profile = profile
+				}
+				if photo == "" {
+					photo = profilePhoto
+				}
+			}
+			if photo != "" {
+				team[i].PhotoPath = s.downloadImage(photo, deckDir, fmt.Sprintf("member-%d", i+1))
+			}
+		}(i, member)
+	}
+	wg.Wait()
+	return team
+}
+
+// teamMembers returns the team members as given in the form, with their pasted profile
+func teamMembers(members []model.TeamMember) prompts.Team {
+	team := make(prompts.Team, len(members))
+	for i, member := range members {
+		team[i] = prompts.TeamMemberNew{
+			Name:       member.Name,
+			Role:       member.Role,
+			Experience: member.Experience,
+			Profile:    truncateRunes(collapseSpaces(member.Profile), maxMemberProfile),
+		}
+	}
+	return team
+}
+
+// fetchLinkedInProfile reads the public preview of a LinkedIn profile: its headline
+// and summary, and the profile photo
+func fetchLinkedInProfile(raw string) (string, string, error) {
+	u, err := linkedInURL(raw)
+	if err != nil {
+		return "", "", err
+	}
+	page, err := fetchPage(u)
+	if err != nil {
+		return "", "", err
+	}
+
+	var parts []string
+	for _, part := range []string{page.Meta["og:title"], page.Meta["og:description"], page.Meta["description"]} {
+		if part != "" && !slices.Contains(parts, part) {
+			parts = append(parts, part)
+		}
+	}
+	photo := page.Meta["og:image"]
+	if u, err := url.Parse(photo); err != nil || u.Scheme != "https" {
+		photo = ""
+	}
+	return truncateRunes(strings.Join(parts, " | "), maxMemberProfile), photo, nil
+}
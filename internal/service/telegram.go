@@ -0,0 +1,348 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/storage"
+	"pitch-deck-generator/internal/telegram"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Link tokens are short lived, they only travel through the deep link
+	telegramLinkTTL = time.Hour
+
+	telegramPollInterval    = 5 * time.Second
+	telegramDeliveryTimeout = 20 * time.Minute
+)
+
+const (
+	telegramNotLinked = "Hi! To build a pitch deck here, open PitchTree, go to Integrations and connect Telegram. " +
+		"The link will bring you back to this chat."
+	telegramHelp = "Answer my questions to build your deck. You can send your logo as a photo at any time.\n\n" +
+		"/generate builds the deck once every question is answered\n/new starts over with a new deck"
+	telegramGenerateHint = "\n\nSend /generate when you are ready."
+)
+
+// TelegramService runs the conversational intake in Telegram chats
+type TelegramService struct {
+	intake *IntakeService
+	decks  *PitchDeckService
+	bot    *telegram.Client
+
+	// Messages of a chat are handled one at a time so answers do not overwrite each other
+	chatLocks sync.Map
+}
+
+// telegramChat is a row of the telegram_chats table, linking a chat to a user
+// and to the intake session in progress
+type telegramChat struct {
+	ChatID    int64     `json:"chat_id"`
+	UserID    string    `json:"user_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	LinkedAt  time.Time `json:"linked_at"`
+}
+
+// telegramLink is a row of the telegram_links table, a one-time token put in the
+// deep link so the chat can be tied to the account that requested it
+type telegramLink struct {
+	Token     string    `json:"token"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewTelegramService creates the service, the bot client may be nil when the
+// integration is not configured
+func NewTelegramService(intake *IntakeService, decks *PitchDeckService, bot *telegram.Client) *TelegramService {
+	return &TelegramService{
+		intake: intake,
+		decks:  decks,
+		bot:    bot,
+	}
+}
+
+// LinkURL returns the deep link connecting a Telegram chat to the user
+func (s *TelegramService) LinkURL(userID string) (string, error) {
+	if s.bot == nil {
+		return "", fmt.Errorf("telegram integration not configured")
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+
+	link := telegramLink{Token: token, UserID: userID, CreatedAt: time.Now()}
+	if err := supabaseREST("POST", "telegram_links", link, nil); err != nil {
+		return "", fmt.Errorf("failed to save telegram link: %w", err)
+	}
+	return s.bot.StartURL(token), nil
+}
+
+// HandleUpdate checks the webhook secret and processes the update in the
+// background, so Telegram does not retry while the LLM answers
+func (s *TelegramService) HandleUpdate(secret string, update telegram.Update) error {
+	if s.bot == nil {
+		return fmt.Errorf("telegram integration not configured")
+	}
+	if !s.bot.VerifySecret(secret) {
+		return fmt.Errorf("%w: invalid webhook secret", model.ErrForbidden)
+	}
+
+	// Only private chats are supported, a group would mix the answers of several people
+	if update.Message == nil || update.Message.Chat.Type != "private" {
+		return nil
+	}
+
+	go s.handleMessage(update.Message)
+	return nil
+}
+
+func (s *TelegramService) handleMessage(msg *telegram.Message) {
+	lock, _ := s.chatLocks.LoadOrStore(msg.Chat.ID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	chatID := msg.Chat.ID
+	text := strings.TrimSpace(msg.Text)
+	command, payload, _ := strings.Cut(text, " ")
+
+	if command == "/start" && strings.TrimSpace(payload) != "" {
+		s.linkChat(chatID, strings.TrimSpace(payload))
+		return
+	}
+
+	chat, err := s.chat(chatID)
+	if err != nil {
+		log.Printf("Failed to load telegram chat %d: %v", chatID, err)
+		s.send(chatID, "Something went wrong on our side, please try again in a moment.")
+		return
+	}
+	if chat == nil {
+		s.send(chatID, telegramNotLinked)
+		return
+	}
+
+	switch {
+	case command == "/start" || command == "/help":
+		s.send(chatID, telegramHelp)
+	case command == "/new" || chat.SessionID == "":
+		s.startIntake(chat)
+	case command == "/generate":
+		s.generate(chat)
+	case len(msg.Photo) > 0 || (msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/")):
+		s.attachLogo(chat, msg)
+	case text != "":
+		s.reply(chat, text)
+	default:
+		s.send(chatID, "I can only read text answers and images for now.")
+	}
+}
+
+// linkChat ties the chat to the account that created the link token and starts an intake
+func (s *TelegramService) linkChat(chatID int64, token string) {
+	var links []telegramLink
+	if err := supabaseREST("GET", "telegram_links?token=eq."+url.QueryEscape(token), nil, &links); err != nil {
+		log.Printf("Failed to load telegram link: %v", err)
+		s.send(chatID, "Something went wrong on our side, please try again in a moment.")
+		return
+	}
+	if len(links) == 0 || time.Since(links[0].CreatedAt) > telegramLinkTTL {
+		s.send(chatID, "This link has expired. Open PitchTree and connect Telegram again to get a new one.")
+		return
+	}
+	if err := supabaseREST("DELETE", "telegram_links?token=eq."+url.QueryEscape(token), nil, nil); err != nil {
+		log.Printf("Failed to consume telegram link: %v", err)
+	}
+
+	chat := telegramChat{ChatID: chatID, UserID: links[0].UserID, LinkedAt: time.Now()}
+
+	// Replace any previous link of the chat
+	filter := "telegram_chats?chat_id=eq." + strconv.FormatInt(chatID, 10)
+	if err := supabaseREST("DELETE", filter, nil, nil); err != nil {
+		log.Printf("Failed to remove previous link of telegram chat %d: %v", chatID, err)
+	}
+	if err := supabaseREST("POST", "telegram_chats", chat, nil); err != nil {
+		log.Printf("Failed to save telegram chat %d: %v", chatID, err)
+		s.send(chatID, "Something went wrong on our side, please try again in a moment.")
+		return
+	}
+
+	s.send(chatID, "Your Telegram account is now connected to PitchTree.\n\n"+telegramHelp)
+	s.startIntake(&chat)
+}
+
+func (s *TelegramService) chat(chatID int64) (*telegramChat, error) {
+	var chats []telegramChat
+	if err := supabaseREST("GET", "telegram_chats?chat_id=eq."+strconv.FormatInt(chatID, 10), nil, &chats); err != nil {
+		return nil, err
+	}
+	if len(chats) == 0 {
+		return nil, nil
+	}
+	return &chats[0], nil
+}
+
+func (s *TelegramService) startIntake(chat *telegramChat) {
+	session, err := s.intake.StartSession(chat.UserID)
+	if err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+
+	update := map[string]string{"session_id": session.ID}
+	if err := supabaseREST("PATCH", "telegram_chats?chat_id=eq."+strconv.FormatInt(chat.ChatID, 10), update, nil); err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+	chat.SessionID = session.ID
+
+	s.sendLastQuestion(chat.ChatID, session)
+}
+
+func (s *TelegramService) reply(chat *telegramChat, text string) {
+	session, err := s.intake.Reply(chat.SessionID, chat.UserID, text)
+	if err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+	s.sendLastQuestion(chat.ChatID, session)
+}
+
+// attachLogo stores the largest version of the image with the user's uploads and
+// uses it as the company logo
+func (s *TelegramService) attachLogo(chat *telegramChat, msg *telegram.Message) {
+	fileID, ext := "", ".jpg"
+	if len(msg.Photo) > 0 {
+		fileID = msg.Photo[len(msg.Photo)-1].FileID
+	} else {
+		fileID = msg.Document.FileID
+		if e := filepath.Ext(msg.Document.FileName); e != "" {
+			ext = e
+		}
+	}
+
+	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+	filePath := filepath.Join("uploads", uuid.New().String()+ext)
+	defer os.Remove(filePath)
+
+	if err := s.bot.DownloadFile(fileID, filePath); err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+
+	logoURL, err := s.decks.UploadImage(filePath, "telegram-logo"+ext, chat.UserID)
+	if err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+
+	session, err := s.intake.AttachLogo(chat.SessionID, chat.UserID, logoURL)
+	if err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+
+	s.send(chat.ChatID, "Got your logo, it will appear on your slides.")
+	s.sendLastQuestion(chat.ChatID, session)
+}
+
+func (s *TelegramService) generate(chat *telegramChat) {
+	deck, err := s.intake.Generate(chat.SessionID, chat.UserID)
+	if err != nil {
+		s.sendError(chat.ChatID, err)
+		return
+	}
+
+	s.send(chat.ChatID, "Generating your deck, this takes a few minutes. I'll send you the PDF here when it is ready.")
+	go s.deliverDeck(chat.ChatID, deck.ID)
+}
+
+// deliverPDF sends the PDF of a completed deck to the chat: the link of a public deck,
+// the file of a private one, whose files have no public URL
+func (s *TelegramService) deliverPDF(chatID int64, deck *model.PitchDeckInfo) {
+	if deck.IsPublic && !storage.IsPrivateURL(deck.PdfURL) {
+		s.send(chatID, fmt.Sprintf("Your deck %q is ready: %s\n\nSend /new to build another one.", deck.Name, deck.PdfURL))
+		return
+	}
+
+	content, err := fetchBytes(deck.PdfURL)
+	if err == nil {
+		caption := fmt.Sprintf("Your deck %q is ready.\n\nSend /new to build another one.", deck.Name)
+		err = s.bot.SendDocument(chatID, slugify(deck.Name)+".pdf", content, caption)
+	}
+	if err != nil {
+		log.Printf("Failed to send the PDF of deck %s to telegram chat %d: %v", deck.ID, chatID, err)
+		s.send(chatID, fmt.Sprintf("Your deck %q is ready, you will find it in your PitchTree dashboard.\n\nSend /new to build another one.", deck.Name))
+	}
+}
+
+// deliverDeck waits for the generation to finish and sends the PDF to the chat
+func (s *TelegramService) deliverDeck(chatID int64, deckID string) {
+	deadline := time.Now().Add(telegramDeliveryTimeout)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(telegramPollInterval)
+
+		deck, err := s.decks.Get(deckID)
+		if err != nil {
+			log.Printf("Failed to check deck %s for telegram chat %d: %v", deckID, chatID, err)
+			continue
+		}
+
+		switch deck.Status {
+		case "completed":
+			s.deliverPDF(chatID, deck)
+			return
+		case "failed":
+			s.send(chatID, fmt.Sprintf("Sorry, the generation of your deck failed: %s\n\nSend /new to start over.", deck.ErrorMessage))
+			return
+		}
+	}
+
+	log.Printf("Deck %s was not ready after %s, telegram chat %d not notified", deckID, telegramDeliveryTimeout, chatID)
+	s.send(chatID, "Your deck is taking longer than expected. You will find it in your PitchTree dashboard once it is ready.")
+}
+
+func (s *TelegramService) sendLastQuestion(chatID int64, session *model.IntakeSession) {
+	if len(session.Messages) == 0 {
+		return
+	}
+	text := session.Messages[len(session.Messages)-1].Content
+	if session.Complete {
+		text += telegramGenerateHint
+	}
+	s.send(chatID, text)
+}
+
+// sendError tells the user what went wrong when it is something they can act upon
+func (s *TelegramService) sendError(chatID int64, err error) {
+	for _, sentinel := range []error{model.ErrInvalidInput, model.ErrConflict, model.ErrNotFound, model.ErrForbidden} {
+		if errors.Is(err, sentinel) {
+			s.send(chatID, strings.TrimPrefix(err.Error(), sentinel.Error()+": "))
+			return
+		}
+	}
+	log.Printf("Telegram chat %d: %v", chatID, err)
+	s.send(chatID, "Something went wrong on our side, please try again in a moment.")
+}
+
+func (s *TelegramService) send(chatID int64, text string) {
+	if err := s.bot.SendMessage(chatID, text); err != nil {
+		log.Printf("Failed to send message to telegram chat %d: %v", chatID, err)
+	}
+}
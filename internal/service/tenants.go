@@ -0,0 +1,253 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+const (
+	// How long the tenants are cached, changes to their records apply within it
+	tenantCacheTTL = time.Minute
+	// Folder of the files of the tenants without a bucket of their own
+	tenantFolder       = "tenants/"
+	defaultProductName = "PitchTree"
+)
+
+var (
+	tenantsMu       sync.Mutex
+	tenantsByID     map[string]*model.Tenant
+	tenantsByHost   map[string]*model.Tenant
+	tenantsLoadedAt time.Time
+
+	// tenantUsers caches the tenant of users by ID, users never change tenant
+	tenantUsers sync.Map
+)
+
+// MultiTenant tells whether the deployment serves several tenants (MULTI_TENANT)
+func MultiTenant() bool {
+	return os.Getenv("MULTI_TENANT") == "true"
+}
+
+// loadTenants returns the tenants by ID and by hostname, reloaded when stale
+func loadTenants() (map[string]*model.Tenant, map[string]*model.Tenant, error) {
+	tenantsMu.Lock()
+	defer tenantsMu.Unlock()
+	if tenantsByID != nil && time.Since(tenantsLoadedAt) < tenantCacheTTL {
+		return tenantsByID, tenantsByHost, nil
+	}
+
+	var records []model.Tenant
+	if err := supabaseREST("GET", "tenants?select=id,name,hostnames,bucket,themes,decks_per_month,branding", nil, &records); err != nil {
+		// The last known tenants are kept while Supabase is unavailable
+		if tenantsByID != nil {
+			log.Printf("Failed to reload tenants, keeping the cached ones: %v", err)
+			return tenantsByID, tenantsByHost, nil
+		}
+		return nil, nil, fmt.Errorf("failed to load tenants: %w", err)
+	}
+	byID := make(map[string]*model.Tenant, len(records))
+	byHost := make(map[string]*model.Tenant)
+	for i := range records {
+		tenant := &records[i]
+		byID[tenant.ID] = tenant
+		for _, host := range tenant.Hostnames {
+			byHost[strings.ToLower(host)] = tenant
+		}
+	}
+	tenantsByID, tenantsByHost, tenantsLoadedAt = byID, byHost, time.Now()
+	return byID, byHost, nil
+}
+
+// tenantByID returns a tenant, nil for the deployment's own
+func tenantByID(tenantID string) (*model.Tenant, error) {
+	if tenantID == "" {
+		return nil, nil
+	}
+	byID, _, err := loadTenants()
+	if err != nil {
+		return nil, err
+	}
+	tenant, ok := byID[tenantID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown tenant %q", model.ErrNotFound, tenantID)
+	}
+	return tenant, nil
+}
+
+// TenantForHost returns the tenant served on a hostname, empty for the deployment's own
+func TenantForHost(host string) (string, error) {
+	_, byHost, err := loadTenants()
+	if err != nil {
+		return "", err
+	}
+	if tenant, ok := byHost[strings.ToLower(host)]; ok {
+		return tenant.ID, nil
+	}
+	return "", nil
+}
+
+// userTenant returns the tenant of a user, empty outside of multi-tenant mode and for
+// the users of the deployment's own tenant
+func userTenant(userID string) (string, error) {
+	if !MultiTenant() {
+		return "", nil
+	}
+	tenantID, _, err := enrolledTenant(userID)
+	return tenantID, err
+}
+
+// enrolledTenant returns the tenant a user signed in to first, ok is false before
+func enrolledTenant(userID string) (tenantID string, ok bool, err error) {
+	if cached, ok := tenantUsers.Load(userID); ok {
+		return cached.(string), true, nil
+	}
+	var records []struct {
+		TenantID string `json:"tenant_id"`
+	}
+	if err := supabaseREST("GET", "tenant_users?select=tenant_id&user_id=eq."+url.QueryEscape(userID), nil, &records); err != nil {
+		return "", false, fmt.Errorf("failed to load tenant of user: %w", err)
+	}
+	if len(records) == 0 {
+		return "", false, nil
+	}
+	tenantID = records[0].TenantID
+	tenantUsers.Store(userID, tenantID)
+	return tenantID, true, nil
+}
+
+// EnrollTenantUser checks that a user signs in to their tenant, enrolling them in it on
+// their first sign in. ErrForbidden when they belong to another tenant.
+func EnrollTenantUser(userID, tenantID string) error {
+	if _, err := tenantByID(tenantID); err != nil {
+		return fmt.Errorf("%w: %v", model.ErrForbidden, err)
+	}
+
+	current, ok, err := enrolledTenant(userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		record := map[string]string{"user_id": userID, "tenant_id": tenantID}
+		if err := supabaseREST("POST", "tenant_users", record, nil); err != nil {
+			// A concurrent request may have enrolled them first
+			if current, ok, err = enrolledTenant(userID); err != nil || !ok {
+				return fmt.Errorf("failed to enroll user in tenant: %w", err)
+			}
+		} else {
+			current = tenantID
+			tenantUsers.Store(userID, tenantID)
+		}
+	}
+	if current != tenantID {
+		return fmt.Errorf("%w: this account belongs to another workspace", model.ErrForbidden)
+	}
+	return nil
+}
+
+// checkTenant keeps users from the records of other tenants
+func checkTenant(tenantID, userID string) error {
+	current, err := userTenant(userID)
+	if err != nil {
+		return err
+	}
+	if current != tenantID {
+		return fmt.Errorf("%w: belongs to another tenant", model.ErrForbidden)
+	}
+	return nil
+}
+
+// checkTenantDeck rejects a deck the tenant of the user does not allow: with a theme
+// outside of its themes, or over its decks of the month
+func checkTenantDeck(userID, theme string) error {
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return err
+	}
+	tenant, err := tenantByID(tenantID)
+	if err != nil || tenant == nil {
+		return err
+	}
+
+	if theme == "" {
+		theme = "default"
+	}
+	if len(tenant.Themes) > 0 && !slices.ContainsFunc(tenant.Themes, func(t string) bool { return strings.EqualFold(t, theme) }) {
+		return fmt.Errorf("%w: the theme %q is not available, use one of %s",
+			model.ErrInvalidInput, theme, strings.Join(tenant.Themes, ", "))
+	}
+	if tenant.DecksPerMonth != nil {
+		now := time.Now().UTC()
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		var decks []model.PitchDeckInfo
+		path := fmt.Sprintf("pitch_decks?select=id&tenant_id=eq.%s&created_at=gte.%s&limit=%d",
+			url.QueryEscape(tenant.ID), url.QueryEscape(monthStart.Format(time.RFC3339)), *tenant.DecksPerMonth)
+		if err := supabaseREST("GET", path, nil, &decks); err != nil {
+			return fmt.Errorf("failed to count the decks of the tenant: %w", err)
+		}
+		if len(decks) >= *tenant.DecksPerMonth {
+			return fmt.Errorf("%w: %s allows %d decks per month, more can be created from %s",
+				model.ErrQuotaExceeded, tenant.Name, *tenant.DecksPerMonth, monthStart.AddDate(0, 1, 0).Format("January 2"))
+		}
+	}
+	return nil
+}
+
+// tenantStorage returns the bucket of the files of a tenant and the folder they are
+// stored in, the root of the shared bucket for the deployment's own tenant
+func tenantStorage(tenantID string) (bucket, folder string, err error) {
+	tenant, err := tenantByID(tenantID)
+	if err != nil {
+		return "", "", err
+	}
+	switch {
+	case tenant == nil:
+		return deckBucket(), "", nil
+	case tenant.Bucket != "":
+		return tenant.Bucket, "", nil
+	default:
+		return deckBucket(), tenantFolder + tenant.ID + "/", nil
+	}
+}
+
+// productName returns the name decks of a tenant are branded with
+func productName(tenantID string) string {
+	tenant, err := tenantByID(tenantID)
+	if err != nil {
+		log.Printf("Failed to load tenant %s, branding with %s: %v", tenantID, defaultProductName, err)
+	}
+	if tenant == nil || tenant.Branding.ProductName == "" {
+		return defaultProductName
+	}
+	return tenant.Branding.ProductName
+}
+
+// TenantService serves the branding of the tenants to their apps
+type TenantService struct{}
+
+func NewTenantService() *TenantService {
+	return &TenantService{}
+}
+
+// Branding returns the branding of a tenant, PitchTree's for the deployment's own
+func (s *TenantService) Branding(tenantID string) (*model.TenantBranding, error) {
+	tenant, err := tenantByID(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	branding := model.TenantBranding{}
+	if tenant != nil {
+		branding = tenant.Branding
+	}
+	if branding.ProductName == "" {
+		branding.ProductName = defaultProductName
+	}
+	return &branding, nil
+}
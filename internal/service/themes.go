@@ -0,0 +1,158 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/sanitize"
+	"pitch-deck-generator/internal/slides"
+	"pitch-deck-generator/prompts"
+
+	"github.com/google/uuid"
+)
+
+// Previews of the themes, rendered once per instance from their example deck
+var (
+	themePreviewsMu sync.Mutex
+	themePreviews   = make(map[string][]byte)
+)
+
+// Themes lists the themes decks can be generated with in a tenant, their colors and
+// the URL of their preview
+func (s *PitchDeckService) Themes(baseURL, tenantID string) []model.Theme {
+	tenant, err := tenantByID(tenantID)
+	if err != nil {
+		log.Printf("Failed to load tenant %s, listing every theme: %v", tenantID, err)
+	}
+
+	themes := make([]model.Theme, 0, len(prompts.Themes))
+	for _, theme := range prompts.Themes {
+		if tenant != nil && len(tenant.Themes) > 0 && !slices.ContainsFunc(tenant.Themes, func(t string) bool { return strings.EqualFold(t, theme.ID) }) {
+			continue
+		}
+		themes = append(themes, model.Theme{
+			ID:   theme.ID,
+			Name: theme.Name,
+			Palette: model.ThemePalette{
+				Background: theme.Background,
+				Text:       theme.Text,
+				Accent:     theme.Accent,
+			},
+			PreviewURL: baseURL + "/api/themes/" + url.PathEscape(theme.ID) + "/preview.png",
+		})
+	}
+	return themes
+}
+
+// ThemePreview returns the first slide of the example deck of a theme as a PNG image
+func (s *PitchDeckService) ThemePreview(themeID string) ([]byte, error) {
+	theme, ok := prompts.ThemeFor(themeID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown theme %q", model.ErrNotFound, themeID)
+	}
+
+	// Previews are rendered one at a time, a theme is not rendered twice
+	themePreviewsMu.Lock()
+	defer themePreviewsMu.Unlock()
+	if image, ok := themePreviews[theme.ID]; ok {
+		return image, nil
+	}
+
+	// The example decks have two slides, the preview shows the first one
+	frontMatter, exampleSlides := slides.Split(prompts.ThemePreview(theme.ID))
+	image, err := renderPreview(theme.ID, slides.Join(frontMatter, exampleSlides[:min(1, len(exampleSlides))]), theme.ID)
+	if err != nil {
+		return nil, err
+	}
+	themePreviews[theme.ID] = image
+	return image, nil
+}
+
+// PreviewTheme renders the first slides of the user's content in a theme, so themes
+// can be compared before a deck is generated. The slides come from the stored
+// markdown of a deck of the user when deckID is set, from the answers of the form
+// otherwise.
+func (s *PitchDeckService) PreviewTheme(themeID, userID, deckID string, data *model.PitchDeckData) ([]byte, error) {
+	theme, ok := prompts.ThemeFor(themeID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown theme %q", model.ErrNotFound, themeID)
+	}
+
+	var previewSlides []string
+	switch {
+	case deckID != "":
+		deck, err := s.authorizedDeck(deckID, userID, model.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+		markdown, err := s.loadMarkdown(deck)
+		if err != nil {
+			return nil, err
+		}
+		_, previewSlides = slides.Split(markdown)
+	case data != nil && strings.TrimSpace(data.ProjectName) != "":
+		previewSlides = answerSlides(*data)
+	default:
+		return nil, fmt.Errorf("%w: a deck ID or the answers of the form with a project name are required", model.ErrInvalidInput)
+	}
+	if len(previewSlides) == 0 {
+		return nil, fmt.Errorf("%w: the deck has no slides", model.ErrInvalidInput)
+	}
+	previewSlides = previewSlides[:min(themePreviewSlides, len(previewSlides))]
+
+	// The front matter of the deck is replaced, its colors belong to its own theme
+	markdown := slides.Join(fmt.Sprintf("marp: true\ntheme: %s", theme.ID), previewSlides)
+	return renderPreview(uuid.New().String(), sanitize.Markdown(markdown), theme.ID)
+}
+
+// Slides of the user's content shown by a theme preview
+const themePreviewSlides = 2
+
+// answerSlides drafts a title slide and a problem slide from the answers of the form,
+// as they would open the generated deck
+func answerSlides(data model.PitchDeckData) []string {
+	title := "<!-- _class: lead -->\n\n# " + strings.TrimSpace(data.ProjectName)
+	if idea := strings.TrimSpace(data.BigIdea); idea != "" {
+		title += "\n\n" + idea
+	}
+	previewSlides := []string{title}
+
+	if problem := strings.TrimSpace(data.Problem); problem != "" {
+		previewSlides = append(previewSlides, "## The Problem\n\n"+problem)
+	} else if solution := strings.TrimSpace(data.Solution); solution != "" {
+		previewSlides = append(previewSlides, "## Our Solution\n\n"+solution)
+	}
+	return previewSlides
+}
+
+// renderPreview renders a markdown document to a PNG image in the previews directory,
+// removing the files once read
+func renderPreview(name, markdown, theme string) ([]byte, error) {
+	dir := filepath.Join("temp", "theme-previews")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	mdPath := filepath.Join(dir, name+".md")
+	pngPath := filepath.Join(dir, name+".png")
+	if err := os.WriteFile(mdPath, []byte(markdown), 0644); err != nil {
+		return nil, fmt.Errorf("failed to save preview slides: %w", err)
+	}
+	defer os.Remove(mdPath)
+	defer os.Remove(pngPath)
+
+	if err := renderer.convertPNG(mdPath, pngPath, theme); err != nil {
+		return nil, fmt.Errorf("failed to render preview in theme %s: %w", theme, err)
+	}
+	image, err := os.ReadFile(pngPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview in theme %s: %w", theme, err)
+	}
+	return image, nil
+}
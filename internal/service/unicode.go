@@ -0,0 +1,155 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Emoji policies applied to the generated markdown before rendering
+const (
+	EmojiKeep    = "keep"
+	EmojiStrip   = "strip"
+	EmojiReplace = "image"
+)
+
+var emojiPolicies = map[string]bool{
+	EmojiKeep:    true,
+	EmojiStrip:   true,
+	EmojiReplace: true,
+}
+
+// Twemoji assets used when emoji are replaced by images
+const twemojiBaseURL = "https://cdn.jsdelivr.net/gh/twitter/twemoji@14.0.2/assets/svg/"
+
+// Characters that render as boxes with common PDF fonts, and their replacement
+var unicodeReplacements = map[rune]string{
+	'\u00A0': " ",  // no-break space
+	'\u2007': " ",  // figure space
+	'\u2009': " ",  // thin space
+	'\u202F': " ",  // narrow no-break space
+	'\u200B': "",   // zero width space
+	'\u2060': "",   // word joiner
+	'\uFEFF': "",   // byte order mark
+	'\u2011': "-",  // non-breaking hyphen
+	'\u2212': "-",  // minus sign
+	'\u2028': "\n", // line separator
+	'\u2029': "\n", // paragraph separator
+}
+
+// normalizationReport summarizes the substitutions made in a deck
+type normalizationReport struct {
+	Emoji       map[string]int
+	Replaced    int
+	Unsupported int
+}
+
+func (r normalizationReport) empty() bool {
+	return len(r.Emoji) == 0 && r.Replaced == 0 && r.Unsupported == 0
+}
+
+func (r normalizationReport) String() string {
+	var parts []string
+	if len(r.Emoji) > 0 {
+		emoji := make([]string, 0, len(r.Emoji))
+		for e, n := range r.Emoji {
+			emoji = append(emoji, fmt.Sprintf("%s x%d", e, n))
+		}
+		sort.Strings(emoji)
+		parts = append(parts, "emoji: "+strings.Join(emoji, ", "))
+	}
+	if r.Replaced > 0 {
+		parts = append(parts, fmt.Sprintf("%d special characters replaced", r.Replaced))
+	}
+	if r.Unsupported > 0 {
+		parts = append(parts, fmt.Sprintf("%d unsupported characters removed", r.Unsupported))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) || // pictographs, emoticons, transport, flags
+		(r >= 0x2600 && r <= 0x27BF) || // misc symbols and dingbats
+		(r >= 0x2B00 && r <= 0x2BFF) || // arrows and stars
+		r == 0x2122 || r == 0x2139 || r == 0x3030 || r == 0x303D
+}
+
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0x20E3 || (r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F)
+}
+
+func isUnsupported(r rune) bool {
+	return (r >= 0xE000 && r <= 0xF8FF) || // private use area
+		(r < 0x20 && r != '\n' && r != '\r' && r != '\t') ||
+		r == 0xFFFD
+}
+
+// normalizeUnicode applies NFC normalization, replaces characters that break PDF
+// fonts and handles emoji according to the policy
+func normalizeUnicode(markdown, policy string) (string, normalizationReport) {
+	report := normalizationReport{Emoji: make(map[string]int)}
+	if policy == "" {
+		policy = EmojiKeep
+	}
+
+	runes := []rune(norm.NFC.String(markdown))
+	var sb strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if replacement, ok := unicodeReplacements[r]; ok {
+			sb.WriteString(replacement)
+			report.Replaced++
+			continue
+		}
+		if isUnsupported(r) {
+			report.Unsupported++
+			continue
+		}
+		if !isEmoji(r) || policy == EmojiKeep {
+			sb.WriteRune(r)
+			continue
+		}
+
+		// Collect the whole sequence: modifiers, ZWJ joined emoji and flag pairs
+		seq := []rune{r}
+		for i+1 < len(runes) {
+			next := runes[i+1]
+			if isEmojiModifier(next) || (r >= 0x1F1E6 && r <= 0x1F1FF && next >= 0x1F1E6 && next <= 0x1F1FF && len(seq) == 1) {
+				seq = append(seq, next)
+				i++
+			} else if next == 0x200D && i+2 < len(runes) && isEmoji(runes[i+2]) {
+				seq = append(seq, next, runes[i+2])
+				i += 2
+			} else {
+				break
+			}
+		}
+
+		report.Emoji[string(seq)]++
+		if policy == EmojiReplace {
+			sb.WriteString(fmt.Sprintf(`![%s h:1em](%s%s.svg)`, string(seq), twemojiBaseURL, twemojiCode(seq)))
+		} else if i+1 < len(runes) && runes[i+1] == ' ' {
+			// Avoid leaving a double space where the emoji was removed
+			i++
+		}
+	}
+
+	return sb.String(), report
+}
+
+// twemojiCode returns the Twemoji asset name of an emoji sequence
+func twemojiCode(seq []rune) string {
+	codes := make([]string, 0, len(seq))
+	for _, r := range seq {
+		// Twemoji drops the variation selector unless the sequence contains a ZWJ
+		if r == 0xFE0F && !strings.ContainsRune(string(seq), 0x200D) {
+			continue
+		}
+		codes = append(codes, fmt.Sprintf("%x", r))
+	}
+	return strings.Join(codes, "-")
+}
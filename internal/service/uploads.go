@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+)
+
+// UploadService manages the images uploaded by users
+type UploadService struct {
+	decks *PitchDeckService
+	// Resumable uploads receiving a chunk, one at a time per upload
+	appending sync.Map
+}
+
+func NewUploadService(decks *PitchDeckService) *UploadService {
+	return &UploadService{
+		decks: decks,
+	}
+}
+
+func (s *UploadService) ListUploads(userID string) ([]model.UserFile, error) {
+	var files []model.UserFile
+	path := fmt.Sprintf("user_files?user_id=eq.%s&order=created_at.desc", url.QueryEscape(userID))
+	if err := supabaseREST("GET", path, nil, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s *UploadService) getUpload(fileID, userID string) (*model.UserFile, error) {
+	var files []model.UserFile
+	if err := supabaseREST("GET", "user_files?id=eq."+url.QueryEscape(fileID), nil, &files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: upload not found", model.ErrNotFound)
+	}
+	if files[0].UserID != userID {
+		return nil, fmt.Errorf("%w: upload belongs to another user", model.ErrForbidden)
+	}
+	return &files[0], nil
+}
+
+// ReplaceUpload overwrites the content of an uploaded image, keeping its URL, and
+// returns the IDs of the decks referencing it
+func (s *UploadService) ReplaceUpload(fileID, userID, filePath string) (*model.UserFile, []string, error) {
+	file, err := s.getUpload(fileID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	// Only what the new content adds to the storage of the user counts
+	usage, err := planUsage(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkStorage(usage, info.Size()-file.Size); err != nil {
+		return nil, nil, err
+	}
+	if err := scanUpload(filePath, file.OriginalName, userID); err != nil {
+		return nil, nil, err
+	}
+
+	tenantID, err := userTenant(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	bucket, _, err := tenantStorage(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.decks.storage.UploadFile(filePath, bucket, file.StoragePath); err != nil {
+		return nil, nil, fmt.Errorf("failed to replace image: %w", err)
+	}
+	purgeCDN(file.FileURL)
+
+	file.Size = info.Size()
+	file.UpdatedAt = time.Now()
+	update := map[string]interface{}{"size": file.Size, "updated_at": file.UpdatedAt}
+	if err := supabaseREST("PATCH", "user_files?id=eq."+url.QueryEscape(fileID), update, nil); err != nil {
+		log.Printf("Failed to update user file record %s: %v", fileID, err)
+	}
+
+	affected, err := s.affectedDecks(file)
+	if err != nil {
+		return file, nil, err
+	}
+
+	ids := make([]string, 0, len(affected))
+	for _, deck := range affected {
+		ids = append(ids, deck.ID)
+	}
+	return file, ids, nil
+}
+
+// RerenderAffectedDecks renders again, in the background, every deck of the user
+// that references the image. A cache-busting version is added to the image URL so
+// the renderer and the CDN do not serve the previous image.
+func (s *UploadService) RerenderAffectedDecks(fileID, userID string) ([]string, error) {
+	file, err := s.getUpload(fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := s.affectedDecks(file)
+	if err != nil {
+		return nil, err
+	}
+
+	imageURL := regexp.MustCompile(regexp.QuoteMeta(file.FileURL) + `(\?v=\d+)?`)
+	versioned := fmt.Sprintf("%s?v=%d", file.FileURL, file.UpdatedAt.Unix())
+
+	var queued []string
+	for i := range affected {
+		deck := &affected[i]
+		err := s.decks.rerenderDeck(deck, func(markdown string) string {
+			return imageURL.ReplaceAllString(markdown, versioned)
+		})
+		if err != nil {
+			log.Printf("Failed to re-render deck %s: %v", deck.ID, err)
+			continue
+		}
+		recordAudit(deck.ID, userID, AuditDeckRegenerated, map[string]interface{}{
+			"reason":  "upload_replaced",
+			"file_id": file.ID,
+		})
+		queued = append(queued, deck.ID)
+	}
+
+	return queued, nil
+}
+
+// affectedDecks returns the completed decks of the owner whose markdown references the image
+func (s *UploadService) affectedDecks(file *model.UserFile) ([]model.PitchDeckInfo, error) {
+	decks, err := s.decks.ListUserDecks(context.Background(), file.UserID, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list decks: %w", err)
+	}
+
+	var affected []model.PitchDeckInfo
+	for _, deck := range decks {
+		// Decks shared by other organization members are left to their owners
+		if deck.UserID != file.UserID || deck.Status != "completed" || deck.MarkdownURL == "" {
+			continue
+		}
+		markdown, err := s.decks.loadMarkdown(&deck)
+		if err != nil {
+			log.Printf("Failed to load markdown of deck %s: %v", deck.ID, err)
+			continue
+		}
+		if strings.Contains(markdown, file.FileURL) {
+			affected = append(affected, deck)
+		}
+	}
+	return affected, nil
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+)
+
+const (
+	// How long the branding of the organizations is cached by the viewers
+	viewerBrandingTTL = time.Minute
+	maxLogoURL        = 2048
+)
+
+var (
+	hexColorRegex = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	hostnameRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// brandedOrg is what the viewers need of an organization with a branding or a domain
+type brandedOrg struct {
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	ViewerBranding *model.ViewerBranding `json:"viewer_branding"`
+	CustomDomain   string                `json:"custom_domain"`
+	// The domain serves the viewers once verified
+	CustomDomainVerifiedAt *time.Time `json:"custom_domain_verified_at"`
+}
+
+var (
+	brandedOrgsMu       sync.Mutex
+	brandedOrgsByID     map[string]brandedOrg
+	brandedOrgsByDomain map[string]brandedOrg
+	brandedOrgsLoadedAt time.Time
+)
+
+// loadBrandedOrgs returns the organizations with a viewer branding or a custom domain,
+// by ID and by verified domain, reloaded when stale
+func loadBrandedOrgs() (map[string]brandedOrg, map[string]brandedOrg) {
+	brandedOrgsMu.Lock()
+	defer brandedOrgsMu.Unlock()
+	if brandedOrgsByID != nil && time.Since(brandedOrgsLoadedAt) < viewerBrandingTTL {
+		return brandedOrgsByID, brandedOrgsByDomain
+	}
+
+	var orgs []brandedOrg
+	path := "organizations?select=id,name,viewer_branding,custom_domain,custom_domain_verified_at&or=(viewer_branding.not.is.null,custom_domain.not.is.null)"
+	if err := supabaseREST("GET", path, nil, &orgs); err != nil {
+		// The last known branding is kept while Supabase is unavailable, and retried
+		// after the TTL rather than on every request
+		log.Printf("Failed to load viewer branding: %v", err)
+		if brandedOrgsByID == nil {
+			brandedOrgsByID, brandedOrgsByDomain = map[string]brandedOrg{}, map[string]brandedOrg{}
+		}
+		brandedOrgsLoadedAt = time.Now()
+		return brandedOrgsByID, brandedOrgsByDomain
+	}
+	byID := make(map[string]brandedOrg, len(orgs))
+	byDomain := make(map[string]brandedOrg)
+	for _, org := range orgs {
+		byID[org.ID] = org
+		if org.CustomDomain != "" && org.CustomDomainVerifiedAt != nil {
+			byDomain[org.CustomDomain] = org
+		}
+	}
+	brandedOrgsByID, brandedOrgsByDomain, brandedOrgsLoadedAt = byID, byDomain, time.Now()
+	return byID, byDomain
+}
+
+func invalidateBrandedOrgs() {
+	brandedOrgsMu.Lock()
+	brandedOrgsByID = nil
+	brandedOrgsMu.Unlock()
+}
+
+// hostname returns the host of a request without its port, in lower case
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// IsViewerDomain tells whether a host is the verified custom domain of an organization
+// or a user, which serves the viewers of their decks only
+func IsViewerDomain(host string) bool {
+	_, byDomain := loadBrandedOrgs()
+	_, ok := byDomain[hostname(host)]
+	return ok || domainOwner(host) != ""
+}
+
+// brandViewer applies the branding of the organization of a public deck to its HTML
+// viewer. On the custom domain of an organization or a user only their decks are served.
+func brandViewer(deck *model.PitchDeckInfo, host, document string) (string, error) {
+	byID, byDomain := loadBrandedOrgs()
+	if domainOrg, ok := byDomain[hostname(host)]; ok && domainOrg.ID != deck.OrgID {
+		return "", fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	if owner := domainOwner(host); owner != "" && owner != deck.UserID {
+		return "", fmt.Errorf("%w: deck not found", model.ErrNotFound)
+	}
+	org, ok := byID[deck.OrgID]
+	if !ok || org.ViewerBranding == nil {
+		return document, nil
+	}
+	return applyViewerBranding(document, *org.ViewerBranding), nil
+}
+
+// viewerProviderName returns the name the viewer of a deck is presented with to the
+// sites embedding it
+func viewerProviderName(deck *model.PitchDeckInfo) string {
+	byID, _ := loadBrandedOrgs()
+	if org, ok := byID[deck.OrgID]; ok && org.ViewerBranding != nil && org.ViewerBranding.HideProductBranding {
+		return org.Name
+	}
+	return productName(deck.TenantID)
+}
+
+// applyViewerBranding styles a Marp viewer with the colors and logo of a branding.
+// The values were validated when the branding was saved.
+func applyViewerBranding(document string, branding model.ViewerBranding) string {
+	var css strings.Builder
+	if branding.BackgroundColor != "" {
+		fmt.Fprintf(&css, "  html, body, .bespoke-marp-parent { background: %s !important; }\n", branding.BackgroundColor)
+	}
+	if branding.AccentColor != "" {
+		fmt.Fprintf(&css, "  .bespoke-marp-osc { background: %s !important; }\n", branding.AccentColor)
+	}
+	if branding.LogoURL != "" {
+		css.WriteString("  .pt-viewer-logo { position: fixed; top: 16px; right: 16px; max-height: 40px; max-width: 160px; z-index: 10; pointer-events: none; }\n")
+	}
+	// The watermark of decks made on plans with one, see watermarkCSS
+	if branding.HideProductBranding && strings.Contains(document, `content: "Made with `) {
+		css.WriteString("  section::before { content: none !important; }\n")
+	}
+
+	if css.Len() > 0 {
+		style := "<style>\n" + css.String() + "</style>\n"
+		if i := strings.Index(document, "</head>"); i != -1 {
+			document = document[:i] + style + document[i:]
+		}
+	}
+	if branding.LogoURL != "" {
+		document = injectBeforeBodyEnd(document, fmt.Sprintf(`<img class="pt-viewer-logo" src="%s" alt="">`+"\n", html.EscapeString(branding.LogoURL)))
+	}
+	return document
+}
+
+// UpdateViewerBranding sets the branding of the viewer of the decks shared with an
+// organization and the domain it is also served on, removed when empty. A new domain
+// is served once verified with VerifyCustomDomain. Hiding the product branding needs
+// a plan without watermark.
+func (s *OrganizationService) UpdateViewerBranding(orgID, userID string, branding model.ViewerBranding, customDomain string) (*model.Organization, error) {
+	if err := requireRole(orgID, userID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := validateViewerBranding(branding); err != nil {
+		return nil, err
+	}
+	customDomain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(customDomain)), ".")
+	if err := validateCustomDomain(customDomain); err != nil {
+		return nil, err
+	}
+	current, err := organizationDomain(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if customDomain != "" && customDomain != current.CustomDomain {
+		// The verified domains of users and other organizations serve their decks
+		if _, byDomain := loadBrandedOrgs(); domainOwner(customDomain) != "" || byDomain[customDomain].ID != "" {
+			return nil, fmt.Errorf("%w: %s is already used", model.ErrConflict, customDomain)
+		}
+	}
+	if branding.HideProductBranding {
+		usage, err := planUsage(userID)
+		if err != nil {
+			return nil, err
+		}
+		if usage.Plan.Watermark {
+			return nil, fmt.Errorf("%w: the %s plan does not allow removing the PitchTree branding", model.ErrQuotaExceeded, usage.Plan.Name)
+		}
+	}
+
+	update := map[string]interface{}{"viewer_branding": nil}
+	if branding != (model.ViewerBranding{}) {
+		update["viewer_branding"] = branding
+	}
+	// The domain kept stays verified, a new one needs a new record
+	if customDomain != current.CustomDomain {
+		update["custom_domain"], update["custom_domain_token"], update["custom_domain_verified_at"] = nil, nil, nil
+		if customDomain != "" {
+			token, err := newShareToken()
+			if err != nil {
+				return nil, err
+			}
+			update["custom_domain"], update["custom_domain_token"] = customDomain, token
+		}
+	}
+	var orgs []model.Organization
+	if err := supabaseREST("PATCH", "organizations?id=eq."+url.QueryEscape(orgID), update, &orgs); err != nil {
+		var statusErr *resilience.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%w: %s is already used by another organization", model.ErrConflict, customDomain)
+		}
+		return nil, fmt.Errorf("failed to save viewer branding: %w", err)
+	}
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("%w: organization not found", model.ErrNotFound)
+	}
+
+	// Applied now on this instance, within viewerBrandingTTL on the others
+	invalidateBrandedOrgs()
+
+	org := withDomainRecord(orgs[0])
+	org.Role = model.RoleOwner
+	return &org, nil
+}
+
+// VerifyCustomDomain looks up the TXT record of the custom domain of an organization
+// and serves its viewers on it when the record holds its token. The claims of users
+// on the domain are dropped.
+func (s *OrganizationService) VerifyCustomDomain(orgID, userID string) (*model.Organization, error) {
+	if err := requireRole(orgID, userID, model.RoleOwner); err != nil {
+		return nil, err
+	}
+	org, err := organizationDomain(orgID)
+	if err != nil {
+		return nil, err
+	}
+	if org.CustomDomain == "" {
+		return nil, fmt.Errorf("%w: the organization has no custom domain", model.ErrInvalidInput)
+	}
+	if org.CustomDomainVerifiedAt == nil {
+		if domainOwner(org.CustomDomain) != "" {
+			return nil, fmt.Errorf("%w: %s is already used", model.ErrConflict, org.CustomDomain)
+		}
+		if err := checkVerificationRecord(org.CustomDomain, org.CustomDomainToken); err != nil {
+			return nil, err
+		}
+
+		// The domain must not have changed since it was looked up
+		var orgs []model.Organization
+		path := fmt.Sprintf("organizations?id=eq.%s&custom_domain=eq.%s", url.QueryEscape(orgID), url.QueryEscape(org.CustomDomain))
+		if err := supabaseREST("PATCH", path, map[string]interface{}{"custom_domain_verified_at": time.Now()}, &orgs); err != nil {
+			// Only one organization can verify a domain
+			var statusErr *resilience.StatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusConflict {
+				return nil, fmt.Errorf("%w: %s was verified by another organization", model.ErrConflict, org.CustomDomain)
+			}
+			return nil, fmt.Errorf("failed to verify custom domain: %w", err)
+		}
+		if len(orgs) == 0 {
+			return nil, fmt.Errorf("%w: the custom domain of the organization changed", model.ErrConflict)
+		}
+		org = &orgs[0]
+		invalidateBrandedOrgs()
+
+		path = "custom_domains?verified_at=is.null&domain=eq." + url.QueryEscape(org.CustomDomain)
+		if err := supabaseREST("DELETE", path, nil, nil); err != nil {
+			log.Printf("Failed to drop the claims of %s: %v", org.CustomDomain, err)
+		}
+	}
+
+	verified := withDomainRecord(*org)
+	verified.Role = model.RoleOwner
+	return &verified, nil
+}
+
+// organizationDomain returns an organization with its custom domain
+func organizationDomain(orgID string) (*model.Organization, error) {
+	var orgs []model.Organization
+	if err := supabaseREST("GET", "organizations?id=eq."+url.QueryEscape(orgID), nil, &orgs); err != nil {
+		return nil, fmt.Errorf("failed to load organization: %w", err)
+	}
+	if len(orgs) == 0 {
+		return nil, fmt.Errorf("%w: organization not found", model.ErrNotFound)
+	}
+	return &orgs[0], nil
+}
+
+// withDomainRecord fills the TXT record the custom domain of an organization is
+// verified with
+func withDomainRecord(org model.Organization) model.Organization {
+	if org.CustomDomain != "" && org.CustomDomainToken != "" {
+		org.RecordName, org.RecordValue = verificationRecord(org.CustomDomain, org.CustomDomainToken)
+	}
+	return org
+}
+
+func validateViewerBranding(branding model.ViewerBranding) error {
+	for name, color := range map[string]string{"accentColor": branding.AccentColor, "backgroundColor": branding.BackgroundColor} {
+		if color != "" && !hexColorRegex.MatchString(color) {
+			return fmt.Errorf("%w: %s must be a hex color such as #1a2b3c", model.ErrInvalidInput, name)
+		}
+	}
+	if branding.LogoURL != "" {
+		logo, err := url.Parse(branding.LogoURL)
+		if err != nil || logo.Scheme != "https" || logo.Host == "" || len(branding.LogoURL) > maxLogoURL {
+			return fmt.Errorf("%w: logoUrl must be an https URL", model.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// validateCustomDomain rejects the domains that are not hostnames and those of the
+// app and the viewer
+func validateCustomDomain(domain string) error {
+	if domain == "" {
+		return nil
+	}
+	if !hostnameRegex.MatchString(domain) {
+		return fmt.Errorf("%w: %q is not a domain name", model.ErrInvalidInput, domain)
+	}
+	for _, name := range []string{"APP_URL", "VIEWER_URL"} {
+		if u, err := url.Parse(os.Getenv(name)); err == nil && hostname(u.Host) == domain {
+			return fmt.Errorf("%w: %s is the domain of PitchTree", model.ErrInvalidInput, domain)
+		}
+	}
+	return nil
+}
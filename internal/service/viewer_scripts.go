@@ -0,0 +1,86 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Inline scripts of an HTML document, with their attributes and content
+var scriptRegex = regexp.MustCompile(`(?is)<script([^>]*)>(.*?)</script>`)
+
+// viewerScriptDeck is rendered at startup to find the scripts the renderer adds to
+// the HTML viewer of every deck
+const viewerScriptDeck = "---\nmarp: true\n---\n\n# Viewer\n\n---\n\n# Scripts\n"
+
+// Hashes of the scripts allowed to run in deck viewers, see ViewerScriptHashes
+var viewerScripts struct {
+	sync.RWMutex
+	hashes []string
+}
+
+// HashViewerScripts renders a sample deck to HTML and keeps the hashes of the inline
+// scripts of the renderer, along with the scripts injected into viewers by the
+// server. Called at startup, before viewers are served: their scripts are known
+// in advance and not taken from the documents served, so a script that gets past
+// the sanitization of a deck does not run.
+func HashViewerScripts() error {
+	hashes := []string{scriptHash(viewerTrackingScript), scriptHash(embedScript)}
+	defer func() {
+		viewerScripts.Lock()
+		viewerScripts.hashes = hashes
+		viewerScripts.Unlock()
+	}()
+
+	dir, err := os.MkdirTemp("", "viewer-scripts")
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mdPath := filepath.Join(dir, "deck.md")
+	htmlPath := filepath.Join(dir, "deck.html")
+	if err := os.WriteFile(mdPath, []byte(viewerScriptDeck), 0644); err != nil {
+		return fmt.Errorf("failed to write sample deck: %w", err)
+	}
+	if err := renderer.convertHTML(mdPath, htmlPath, "default"); err != nil {
+		return fmt.Errorf("failed to render sample deck: %w", err)
+	}
+	document, err := os.ReadFile(htmlPath)
+	if err != nil {
+		return fmt.Errorf("failed to read sample deck: %w", err)
+	}
+
+	for _, match := range scriptRegex.FindAllStringSubmatch(string(document), -1) {
+		if !strings.Contains(strings.ToLower(match[1]), "src") {
+			hashes = append(hashes, "'sha256-"+hashScript(match[2])+"'")
+		}
+	}
+	return nil
+}
+
+// ViewerScriptHashes returns the CSP sources of the scripts allowed in deck viewers
+func ViewerScriptHashes() []string {
+	viewerScripts.RLock()
+	defer viewerScripts.RUnlock()
+	return viewerScripts.hashes
+}
+
+// scriptHash returns the CSP source of a script element
+func scriptHash(element string) string {
+	match := scriptRegex.FindStringSubmatch(element)
+	return "'sha256-" + hashScript(match[2]) + "'"
+}
+
+// hashScript hashes the content of a script as browsers do, with its line breaks
+// normalized
+func hashScript(content string) string {
+	content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n")
+	sum := sha256.Sum256([]byte(content))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"pitch-deck-generator/internal/resilience"
+)
+
+const (
+	// Writes kept while Supabase is unavailable, the oldest are dropped beyond it
+	maxQueuedWrites = 1000
+	// Times a queued write is sent again before it is dropped
+	maxWriteAttempts = 10
+	// Delay between the attempts to send the queued writes
+	writeQueueInterval = 10 * time.Second
+)
+
+// Writes queued, sent late and dropped, published on the admin metrics endpoint
+var writeQueueMetrics = expvar.NewMap("write_queue")
+
+type queuedWrite struct {
+	method   string
+	path     string
+	body     interface{}
+	attempts int
+}
+
+// writeQueue keeps the writes that could not be sent to Supabase, and sends them in
+// order once it is available again
+type writeQueue struct {
+	mu       sync.Mutex
+	writes   []queuedWrite
+	flushing bool
+}
+
+var pendingWrites = &writeQueue{}
+
+// supabaseWrite sends a write whose loss must not fail the request it is made for,
+// such as an audit event or a view. When Supabase is unavailable the write is queued
+// and sent later, only writes rejected by Supabase return an error.
+func supabaseWrite(method, path string, body interface{}) error {
+	err := supabaseREST(method, path, body, nil)
+	if !resilience.Transient(err) {
+		return err
+	}
+	pendingWrites.push(queuedWrite{method: method, path: path, body: body}, err)
+	return nil
+}
+
+func (q *writeQueue) push(write queuedWrite, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	log.Printf("Queuing %s %s until Supabase is available: %v", write.method, write.path, err)
+	if len(q.writes) >= maxQueuedWrites {
+		log.Printf("Write queue full, dropping %s %s", q.writes[0].method, q.writes[0].path)
+		q.writes = q.writes[1:]
+		writeQueueMetrics.Add("dropped", 1)
+	}
+	q.writes = append(q.writes, write)
+	writeQueueMetrics.Add("queued", 1)
+
+	if !q.flushing {
+		q.flushing = true
+		go q.flush()
+	}
+}
+
+// flush sends the queued writes until the queue is empty
+func (q *writeQueue) flush() {
+	for {
+		time.Sleep(writeQueueInterval)
+		if !resilience.Supabase.Available() {
+			continue
+		}
+
+		for {
+			q.mu.Lock()
+			if len(q.writes) == 0 {
+				q.flushing = false
+				q.mu.Unlock()
+				return
+			}
+			write := q.writes[0]
+			q.mu.Unlock()
+
+			err := supabaseREST(write.method, write.path, write.body, nil)
+			write.attempts++
+			if resilience.Transient(err) && write.attempts < maxWriteAttempts {
+				q.mu.Lock()
+				q.writes[0] = write
+				q.mu.Unlock()
+				break
+			}
+
+			if err != nil {
+				log.Printf("Dropping queued %s %s after %d attempts: %v", write.method, write.path, write.attempts, err)
+				writeQueueMetrics.Add("dropped", 1)
+			} else {
+				writeQueueMetrics.Add("sent", 1)
+			}
+			q.mu.Lock()
+			q.writes = q.writes[1:]
+			q.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package slides
+
+import (
+	"regexp"
+	"strings"
+)
+
+var headingRegex = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+
+// Split separates a Marp document into its front-matter (without the --- fences)
+// and its slides. Slide separators inside fenced code blocks are ignored.
+func Split(markdown string) (string, []string) {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+
+	frontMatter := ""
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				frontMatter = strings.Join(lines[1:i], "\n")
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	var slides []string
+	var current []string
+	inCode := false
+
+	for _, line := range lines[start:] {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCode = !inCode
+		}
+		if !inCode && trimmed == "---" {
+			slides = appendSlide(slides, current)
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+	slides = appendSlide(slides, current)
+
+	return frontMatter, slides
+}
+
+func appendSlide(slides []string, lines []string) []string {
+	content := strings.TrimSpace(strings.Join(lines, "\n"))
+	if content == "" {
+		return slides
+	}
+	return append(slides, content)
+}
+
+// Join rebuilds a Marp document from its front-matter and slides
+func Join(frontMatter string, slides []string) string {
+	var sb strings.Builder
+	if frontMatter != "" {
+		sb.WriteString("---\n")
+		sb.WriteString(strings.TrimSpace(frontMatter))
+		sb.WriteString("\n---\n\n")
+	}
+	sb.WriteString(strings.Join(slides, "\n\n---\n\n"))
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// Title returns the first heading of a slide, or an empty string
+func Title(slide string) string {
+	if match := headingRegex.FindStringSubmatch(slide); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	return ""
+}
+
+// FrontMatterValue returns the value of a global directive of the front-matter
+func FrontMatterValue(frontMatter, key string) string {
+	for _, line := range strings.Split(frontMatter, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.TrimSpace(name) == key {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
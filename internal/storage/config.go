@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Buckets used unless STORAGE_DECK_BUCKET and STORAGE_MEDIA_BUCKET name others
+const (
+	defaultDeckBucket  = "pitch-decks"
+	defaultMediaBucket = "user-media"
+
+	privateSuffix = "-private"
+)
+
+// DeckBucket returns the bucket of the rendered decks and of the uploads
+func DeckBucket() string {
+	if bucket := os.Getenv("STORAGE_DECK_BUCKET"); bucket != "" {
+		return bucket
+	}
+	return defaultDeckBucket
+}
+
+// PrivateBucket returns the private bucket paired with a bucket, <bucket>-private,
+// where the files of private decks are stored. Its objects have no public URL and are
+// read by the server with the service key.
+func PrivateBucket(bucket string) string {
+	return bucket + privateSuffix
+}
+
+// IsPrivateBucket reports whether a bucket holds the files of private decks
+func IsPrivateBucket(bucket string) bool {
+	return strings.HasSuffix(bucket, privateSuffix)
+}
+
+// MediaBucket returns the bucket of the uploads of the standalone server in main.go
+func MediaBucket() string {
+	if bucket := os.Getenv("STORAGE_MEDIA_BUCKET"); bucket != "" {
+		return bucket
+	}
+	return defaultMediaBucket
+}
+
+// Prefix returns the folder every object is stored under, set by STORAGE_PREFIX
+// ("staging" gives "staging/"), so environments sharing a Supabase project keep
+// their objects apart. Empty when not set.
+func Prefix() string {
+	prefix := strings.Trim(os.Getenv("STORAGE_PREFIX"), "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// privateObjectURL returns the base URL of the objects of the private buckets, read
+// with the service key
+func privateObjectURL() string {
+	return strings.TrimSuffix(os.Getenv("SUPABASE_URL"), "/") + "/storage/v1/object/authenticated/"
+}
+
+// IsPrivateURL reports whether a URL is the URL of an object of a private bucket
+func IsPrivateURL(fileURL string) bool {
+	return os.Getenv("SUPABASE_URL") != "" && strings.HasPrefix(fileURL, privateObjectURL())
+}
+
+// AuthorizeObject adds the service key to a request for an object of a private
+// bucket. Other requests are left as they are, the key is only sent to the storage.
+func AuthorizeObject(req *http.Request) {
+	if !IsPrivateURL(req.URL.String()) {
+		return
+	}
+	key := os.Getenv("SUPABASE_SERVICE_KEY")
+	req.Header.Set("apikey", key)
+	req.Header.Set("Authorization", "Bearer "+key)
+}
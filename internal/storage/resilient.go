@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
+)
+
+// Resilient calls a storage service through the storage circuit breaker, retrying
+// failed calls. Uploads overwrite the object and deletes ignore missing ones, so
+// every call can be retried.
+type Resilient struct {
+	storage model.StorageService
+}
+
+func NewResilient(storage model.StorageService) *Resilient {
+	return &Resilient{
+		storage: storage,
+	}
+}
+
+func (r *Resilient) UploadFile(filePath, bucketName, fileName string) (string, error) {
+	return resilience.Call(resilience.Storage, func() (string, error) {
+		return r.storage.UploadFile(filePath, bucketName, fileName)
+	})
+}
+
+func (r *Resilient) UploadImmutable(filePath, bucketName, fileName string) (string, error) {
+	return resilience.Call(resilience.Storage, func() (string, error) {
+		return r.storage.UploadImmutable(filePath, bucketName, fileName)
+	})
+}
+
+func (r *Resilient) DownloadFile(url string, destPath string) error {
+	return resilience.Do(resilience.Storage, func() error {
+		return r.storage.DownloadFile(url, destPath)
+	})
+}
+
+func (r *Resilient) ListFiles(bucketName, folder string) ([]model.StorageObject, error) {
+	return resilience.Call(resilience.Storage, func() ([]model.StorageObject, error) {
+		return r.storage.ListFiles(bucketName, folder)
+	})
+}
+
+func (r *Resilient) DeleteFiles(bucketName string, paths []string) error {
+	return resilience.Do(resilience.Storage, func() error {
+		return r.storage.DeleteFiles(bucketName, paths)
+	})
+}
@@ -2,21 +2,45 @@ package storage
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"pitch-deck-generator/internal/chaos"
+	"pitch-deck-generator/internal/model"
+	"pitch-deck-generator/internal/resilience"
 
 	storage "github.com/supabase-community/storage-go"
 )
 
+const (
+	// Seconds browsers keep a stored object before revalidating it with its ETag.
+	// Uploads are overwritten when replaced, the default hour kept the old versions on
+	// screen.
+	objectMaxAge = "60"
+	// Objects named after their content never change, caches keep them for a year
+	immutableMaxAge = "31536000"
+)
+
+// SupabaseStorage stores the objects of the environment under its STORAGE_PREFIX.
+// Paths given to and returned by its methods are relative to the prefix, the URLs
+// of objects include it.
 type SupabaseStorage struct {
-	client  *storage.Client
-	baseURL string
+	client *storage.Client
+	// Base URL the public buckets are served from, objects are at
+	// <publicURL>/<bucket>/<path>. Objects of private buckets are at their
+	// authenticated URL.
+	publicURL string
+	prefix    string
 }
 
 func NewSupabaseStorage() (*SupabaseStorage, error) {
@@ -29,9 +53,16 @@ func NewSupabaseStorage() (*SupabaseStorage, error) {
 
 	client := storage.NewClient(supabaseURL+"/storage/v1", supabaseKey, nil)
 
+	// A CDN in front of the public buckets serves them under CDN_URL
+	publicURL := os.Getenv("CDN_URL")
+	if publicURL == "" {
+		publicURL = supabaseURL + "/storage/v1/object/public"
+	}
+
 	return &SupabaseStorage{
-		client:  client,
-		baseURL: supabaseURL,
+		client:    client,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		prefix:    Prefix(),
 	}, nil
 }
 
@@ -42,43 +73,77 @@ func (s *SupabaseStorage) UploadFile(filePath, bucketName, fileName string) (str
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return s.upload(fileContent, bucketName, fileName, objectMaxAge)
+}
+
+// UploadImmutable stores a file under fileName with a hash of its content before the
+// extension (<id>.pdf gives <id>-<hash>.pdf), so caches and CDNs can keep it forever:
+// a new content gets a new name.
+func (s *SupabaseStorage) UploadImmutable(filePath, bucketName, fileName string) (string, error) {
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	sum := sha256.Sum256(fileContent)
+	ext := path.Ext(fileName)
+	hashedName := strings.TrimSuffix(fileName, ext) + "-" + hex.EncodeToString(sum[:8]) + ext
+	return s.upload(fileContent, bucketName, hashedName, immutableMaxAge)
+}
+
+func (s *SupabaseStorage) upload(fileContent []byte, bucketName, fileName, cacheControl string) (string, error) {
+	fileName = s.prefix + fileName
 	log.Println("upload our file:", bucketName, fileName)
 
+	if chaos.Inject(chaos.StorageError) {
+		return "", fmt.Errorf("failed to upload file: %w: status 500", chaos.ErrInjected)
+	}
+
 	// Detect MIME type
 	contentType := mime.TypeByExtension(filepath.Ext(fileName))
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	// Upload to Supabase Storage
-	_, err = s.client.UploadFile(
+	// Upload to Supabase Storage, overwriting the object when it is re-rendered or replaced
+	upsert := true
+	_, err := s.client.UploadFile(
 		bucketName,
 		fileName,
 		bytes.NewReader(fileContent),
-		storage.FileOptions{ContentType: &contentType},
+		storage.FileOptions{ContentType: &contentType, Upsert: &upsert, CacheControl: &cacheControl},
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 
-	// Generate public URL
-	publicURL := fmt.Sprintf("%s/storage/v1/object/public/%s/%s",
-		strings.TrimSuffix(s.baseURL, "/"),
-		bucketName,
-		fileName)
-
-	return publicURL, nil
+	if IsPrivateBucket(bucketName) {
+		return privateObjectURL() + bucketName + "/" + fileName, nil
+	}
+	return s.publicURL + "/" + bucketName + "/" + fileName, nil
 }
 
 func (s *SupabaseStorage) DownloadFile(url string, destPath string) error {
-	resp, err := http.Get(url)
+	if chaos.Inject(chaos.StorageError) {
+		return fmt.Errorf("failed to download file, status: 500: %w", chaos.ErrInjected)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	AuthorizeObject(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to download file: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file, status: %d", resp.StatusCode)
+		return &resilience.StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("failed to download file, status: %d", resp.StatusCode),
+		}
 	}
 
 	out, err := os.Create(destPath)
@@ -94,3 +159,103 @@ func (s *SupabaseStorage) DownloadFile(url string, destPath string) error {
 
 	return nil
 }
+
+// Objects listed per request
+const listPageSize = 1000
+
+// EnsureBucket creates a bucket when it does not exist yet, or sets its visibility
+// when it differs, and reports whether it was created
+func (s *SupabaseStorage) EnsureBucket(bucketName string, public bool) (bool, error) {
+	buckets, err := s.client.ListBuckets()
+	if err != nil {
+		return false, fmt.Errorf("failed to list buckets: %w", err)
+	}
+	for _, bucket := range buckets {
+		if bucket.Id != bucketName {
+			continue
+		}
+		if bucket.Public != public {
+			if _, err := s.client.UpdateBucket(bucketName, storage.BucketOptions{Public: public}); err != nil {
+				return false, fmt.Errorf("failed to update bucket %s: %w", bucketName, err)
+			}
+			log.Printf("Storage: set bucket %s public=%t", bucketName, public)
+		}
+		return false, nil
+	}
+	if _, err := s.client.CreateBucket(bucketName, storage.BucketOptions{Public: public}); err != nil {
+		return false, fmt.Errorf("failed to create bucket %s: %w", bucketName, err)
+	}
+	return true, nil
+}
+
+// ListFiles lists the objects of a folder of the bucket and of its subfolders
+func (s *SupabaseStorage) ListFiles(bucketName, folder string) ([]model.StorageObject, error) {
+	objects, err := s.list(bucketName, strings.TrimSuffix(s.prefix+folder, "/"))
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Path = strings.TrimPrefix(objects[i].Path, s.prefix)
+	}
+	return objects, nil
+}
+
+// list lists the objects of a folder of the bucket given its full path
+func (s *SupabaseStorage) list(bucketName, folder string) ([]model.StorageObject, error) {
+	var objects []model.StorageObject
+
+	for offset := 0; ; offset += listPageSize {
+		page, err := s.client.ListFiles(bucketName, folder, storage.FileSearchOptions{
+			Limit:  listPageSize,
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of %s/%s: %w", bucketName, folder, err)
+		}
+
+		for _, file := range page {
+			path := file.Name
+			if folder != "" {
+				path = folder + "/" + file.Name
+			}
+
+			// Folders are listed without an ID
+			if file.Id == "" {
+				children, err := s.list(bucketName, path)
+				if err != nil {
+					return nil, err
+				}
+				objects = append(objects, children...)
+				continue
+			}
+
+			object := model.StorageObject{Path: path}
+			object.CreatedAt, _ = time.Parse(time.RFC3339, file.CreatedAt)
+			if metadata, ok := file.Metadata.(map[string]interface{}); ok {
+				if size, ok := metadata["size"].(float64); ok {
+					object.Size = int64(size)
+				}
+			}
+			objects = append(objects, object)
+		}
+
+		if len(page) < listPageSize {
+			return objects, nil
+		}
+	}
+}
+
+// DeleteFiles removes objects from the bucket
+func (s *SupabaseStorage) DeleteFiles(bucketName string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(paths))
+	for i, p := range paths {
+		prefixed[i] = s.prefix + p
+	}
+	if _, err := s.client.RemoveFile(bucketName, prefixed); err != nil {
+		return fmt.Errorf("failed to delete files: %w", err)
+	}
+	return nil
+}
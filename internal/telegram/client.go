@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const apiURL = "https://api.telegram.org"
+
+// Client talks to the Telegram Bot API on behalf of the PitchTree bot
+type Client struct {
+	token         string
+	username      string
+	webhookSecret string
+	httpClient    *http.Client
+}
+
+// Update is an incoming event delivered to the webhook
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// Message is a message sent to the bot in a private chat
+type Message struct {
+	MessageID int         `json:"message_id"`
+	Chat      Chat        `json:"chat"`
+	Text      string      `json:"text,omitempty"`
+	Caption   string      `json:"caption,omitempty"`
+	Photo     []PhotoSize `json:"photo,omitempty"`
+	Document  *Document   `json:"document,omitempty"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+// PhotoSize is one resolution of a photo, Telegram sends them from smallest to largest
+type PhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+// Document is a file sent without compression, e.g. a PNG logo with transparency
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+// NewClient configures the bot from the environment. TELEGRAM_WEBHOOK_SECRET is
+// required: it is the only proof a webhook request comes from Telegram.
+func NewClient() (*Client, error) {
+	token := os.Getenv("TELEGRAM_BOT_TOKEN")
+	username := os.Getenv("TELEGRAM_BOT_USERNAME")
+	webhookSecret := os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+
+	if token == "" || username == "" {
+		return nil, fmt.Errorf("telegram bot credentials not set")
+	}
+	if webhookSecret == "" {
+		return nil, fmt.Errorf("TELEGRAM_WEBHOOK_SECRET not set")
+	}
+
+	return &Client{
+		token:         token,
+		username:      username,
+		webhookSecret: webhookSecret,
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// StartURL returns the deep link opening the bot with a /start payload
+func (c *Client) StartURL(payload string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", c.username, url.QueryEscape(payload))
+}
+
+// VerifySecret checks the secret token Telegram sends with every webhook request
+func (c *Client) VerifySecret(secret string) bool {
+	return c.webhookSecret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(c.webhookSecret)) == 1
+}
+
+// SendMessage sends a plain text message to a chat
+func (c *Client) SendMessage(chatID int64, text string) error {
+	body := map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	}
+	return c.call("sendMessage", body, nil)
+}
+
+// SendDocument sends a file to a chat, with a caption
+func (c *Client) SendDocument(chatID int64, filename string, content []byte, caption string) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("chat_id", strconv.FormatInt(chatID, 10))
+	form.WriteField("caption", caption)
+	part, err := form.CreateFormFile("document", filename)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	part.Write(content)
+	if err := form.Close(); err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	return c.send("sendDocument", form.FormDataContentType(), &body, nil)
+}
+
+// DownloadFile saves a file sent to the bot to destPath
+func (c *Client) DownloadFile(fileID, destPath string) error {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := c.call("getFile", map[string]string{"file_id": fileID}, &file); err != nil {
+		return err
+	}
+	if file.FilePath == "" {
+		return fmt.Errorf("file %s is not available for download", fileID)
+	}
+
+	resp, err := c.httpClient.Get(fmt.Sprintf("%s/file/bot%s/%s", apiURL, c.token, file.FilePath))
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download file, status: %d", resp.StatusCode)
+	}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) call(method string, body interface{}, out interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return c.send(method, "application/json", bytes.NewBuffer(jsonData), out)
+}
+
+func (c *Client) send(method, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/bot%s/%s", apiURL, c.token, method), body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram API error, method: %s, status: %d, description: %s", method, resp.StatusCode, result.Description)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(result.Result, out); err != nil {
+			return fmt.Errorf("failed to parse result: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package tracing sets up OpenTelemetry: spans of the requests and generations are
+// exported over OTLP/HTTP to a collector, Jaeger or Tempo. Traces continue the W3C
+// traceparent of the callers, sampled or not as they decided, and are propagated
+// to the APIs called.
+//
+// Until Configure found an endpoint, the global tracer provider of otel is a no-op
+// one: spans are not recorded and cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// Name of the service in the traces, unless OTEL_SERVICE_NAME is set
+	defaultServiceName = "pitchtree-backend"
+	// Name of the tracer of the spans started by the server
+	instrumentationName = "pitch-deck-generator"
+)
+
+var provider atomic.Pointer[sdktrace.TracerProvider]
+
+// Configure enables tracing when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or
+// OTEL_EXPORTER_OTLP_ENDPOINT (the traces are sent to <endpoint>/v1/traces), is set.
+// The exporter, the sampler and the resource follow the other OTEL_* variables,
+// such as OTEL_EXPORTER_OTLP_HEADERS, OTEL_TRACES_SAMPLER and OTEL_SERVICE_NAME.
+func Configure() bool {
+	if os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return false
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		log.Printf("Failed to create the trace exporter: %v", err)
+		return false
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		log.Printf("Failed to describe the service in traces: %v", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(redactQuery{}),
+		sdktrace.WithBatcher(exporter),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	provider.Store(tp)
+	return true
+}
+
+// Enabled reports whether spans are recorded
+func Enabled() bool {
+	return provider.Load() != nil
+}
+
+// Shutdown exports the spans still queued, waiting until ctx is done at most
+func Shutdown(ctx context.Context) {
+	if tp := provider.Load(); tp != nil {
+		if err := tp.Shutdown(ctx); err != nil {
+			log.Printf("Failed to export the last spans: %v", err)
+		}
+	}
+}
+
+// Start starts a span, a child of the span of ctx or the root of a new trace, and
+// returns a context carrying it. End must be called once the operation is over.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks a span as failed with err, nil errors are ignored
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// TraceID returns the ID of the trace of a span in hex, empty when it is not recorded
+func TraceID(span trace.Span) string {
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// Transport records a client span for each request sent with a context carrying a
+// span, and propagates the trace to the server. Requests outside of a trace are
+// sent as they are.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithFilter(func(req *http.Request) bool {
+			return trace.SpanContextFromContext(req.Context()).IsValid()
+		}),
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return req.Method + " " + req.URL.Host
+		}),
+	)
+}
+
+// redactQuery drops the query of the URLs recorded by the client spans, it may hold
+// filters with personal data or keys
+type redactQuery struct{}
+
+func (redactQuery) OnStart(_ context.Context, span sdktrace.ReadWriteSpan) {
+	for _, attr := range span.Attributes() {
+		if attr.Key != "url.full" && attr.Key != "http.url" {
+			continue
+		}
+		if u, err := url.Parse(attr.Value.AsString()); err == nil && u.RawQuery != "" {
+			u.RawQuery = ""
+			span.SetAttributes(attribute.String(string(attr.Key), u.Redacted()))
+		}
+	}
+}
+
+func (redactQuery) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (redactQuery) Shutdown(context.Context) error   { return nil }
+func (redactQuery) ForceFlush(context.Context) error { return nil }
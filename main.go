@@ -13,6 +13,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"pitch-deck-generator/internal/middleware"
+	storageconfig "pitch-deck-generator/internal/storage"
 	"pitch-deck-generator/prompts"
 	"strings"
 	"sync"
@@ -365,6 +367,11 @@ func setupHtmlRoute(r *gin.Engine) {
 	// Add endpoint to view HTML presentation without authentication
 	r.GET("/view/:deckId", func(c *gin.Context) {
 		deckID := c.Param("deckId")
+		// The ID ends up in a file path, only the form of deck IDs gets there
+		if !middleware.IsUUID(deckID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Presentation not found"})
+			return
+		}
 		log.Printf("Attempting to view deck ID: %s", deckID)
 
 		// Try to get deck info from Supabase
@@ -472,8 +479,8 @@ func uploadImage(c *gin.Context) {
 	uniqueID := uuid.New().String()
 	fileName := uniqueID + fileExt
 
-	// Create a path with user ID for organization
-	filePath := fmt.Sprintf("uploads/%s/%s", userID, fileName)
+	// Create a path with user ID for organization, in the folder of the environment
+	filePath := fmt.Sprintf("%suploads/%s/%s", storageconfig.Prefix(), userID, fileName)
 
 	// Determine content type based on file extension
 	contentType := mime.TypeByExtension(fileExt)
@@ -492,7 +499,7 @@ func uploadImage(c *gin.Context) {
 		ContentType: &contentType,
 	}
 
-	_, err = storageClient.UploadFile("user-media", filePath, bytes.NewReader(fileBytes), fileOptions)
+	_, err = storageClient.UploadFile(storageconfig.MediaBucket(), filePath, bytes.NewReader(fileBytes), fileOptions)
 	if err != nil {
 		log.Printf("Error uploading to Supabase: %v", err)
 		// Fall back to local storage
@@ -503,8 +510,9 @@ func uploadImage(c *gin.Context) {
 	// Get the public URL
 	supabaseURL := os.Getenv("SUPABASE_URL")
 	filePath = strings.TrimPrefix(filePath, "/")
-	publicURL := fmt.Sprintf("%s/storage/v1/object/public/user-media/%s",
+	publicURL := fmt.Sprintf("%s/storage/v1/object/public/%s/%s",
 		strings.TrimSuffix(supabaseURL, "/"),
+		storageconfig.MediaBucket(),
 		filePath)
 
 	// Save the file metadata to the database (optional)
@@ -941,7 +949,7 @@ func processPitchDeck(data PitchDeckData, deckID string, userID string) {
 	if storageClient != nil {
 		// Upload PDF to Supabase
 		pdfFileName := deckID + ".pdf"
-		uploadedPdfURL, err := uploadToSupabase(storageClient, pdfOutputPath, "pitch-decks", pdfFileName)
+		uploadedPdfURL, err := uploadToSupabase(storageClient, pdfOutputPath, storageconfig.DeckBucket(), pdfFileName)
 		if err != nil {
 			log.Printf("Error uploading PDF to Supabase: %v", err)
 			// Continue with local URLs if upload fails
@@ -951,7 +959,7 @@ func processPitchDeck(data PitchDeckData, deckID string, userID string) {
 		}
 
 		// Upload HTML to Supabase Storage
-		uploadedHtmlURL, err := uploadToSupabase(storageClient, htmlOutputPath, "pitch-decks", deckID+".html")
+		uploadedHtmlURL, err := uploadToSupabase(storageClient, htmlOutputPath, storageconfig.DeckBucket(), deckID+".html")
 		if err != nil {
 			log.Printf("Error uploading HTML to Supabase: %v", err)
 			// Continue with local URLs if upload fails
@@ -1255,8 +1263,8 @@ func uploadToSupabase(storageClient *storage.Client, filePath, bucketName, fileN
 		contentType = "application/octet-stream" // Default fallback
 	}
 
-	// Ensure fileName doesn't have a leading slash
-	fileName = strings.TrimPrefix(fileName, "/")
+	// Ensure fileName doesn't have a leading slash, and store it in the folder of the environment
+	fileName = storageconfig.Prefix() + strings.TrimPrefix(fileName, "/")
 
 	// Upload to Supabase Storage with correct content type
 	_, err = storageClient.UploadFile(
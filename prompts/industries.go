@@ -0,0 +1,157 @@
+package prompts
+
+import "strings"
+
+// IndustryTemplate adapts the structure and vocabulary of a deck to an industry. It
+// replaces the default slide structure of the generation prompt.
+type IndustryTemplate struct {
+	ID   string
+	Name string
+	// Industries selecting the template besides its ID and name, in lower case
+	Aliases []string
+	// Slides of the deck after the title slide, in order
+	Slides []string
+	// What investors of the industry expect, and the terms they use
+	Guidance string
+}
+
+// Industries with a specialized template, other industries use the default structure
+var IndustryTemplates = []IndustryTemplate{
+	{
+		ID:      "saas",
+		Name:    "SaaS",
+		Aliases: []string{"software", "b2b software", "enterprise software", "cloud", "software as a service"},
+		Slides: []string{
+			"Problem (the workflow pain and what it costs customers today)",
+			"Solution & Product (core workflow, integrations, product screenshots)",
+			"Why Now (platform shifts and buying trends)",
+			"Market Opportunity (TAM, SAM, SOM from seats or accounts times price)",
+			"Business Model (pricing tiers, ACV, expansion revenue)",
+			"Traction (ARR or MRR, growth rate, logos, net revenue retention)",
+			"Unit Economics (CAC, LTV, LTV/CAC, payback period, gross margin)",
+			"Go-to-Market (self-serve, product-led or sales-led motion)",
+			"Competition (positioning against incumbents and point solutions)",
+			"Team",
+			"Funding Ask & Use of Funds (runway and ARR milestones)",
+			"Call to Action & Contact Information",
+		},
+		Guidance: "Investors judge SaaS decks on recurring revenue metrics: state ARR, MRR, growth, churn, " +
+			"net revenue retention, CAC payback and gross margin whenever the information allows it, and " +
+			"never invent figures that were not provided.",
+	},
+	{
+		ID:      "biotech",
+		Name:    "Biotech",
+		Aliases: []string{"biotechnology", "life sciences", "pharma", "pharmaceuticals", "medtech", "healthcare", "therapeutics", "drug discovery"},
+		Slides: []string{
+			"Unmet Medical Need (disease burden, patient population, standard of care and its limits)",
+			"Scientific Approach (mechanism of action, platform or modality)",
+			"Data & Validation (preclinical or clinical results, key publications)",
+			"Pipeline (programs, indications and development stage)",
+			"Development & Regulatory Path (trial design, FDA or EMA pathway, timelines)",
+			"Intellectual Property (patents, exclusivity, freedom to operate)",
+			"Market Opportunity (patients addressed, pricing, reimbursement)",
+			"Competitive Landscape (approved therapies and competing programs)",
+			"Partnering & Exit Strategy (licensing, pharma deals, comparable transactions)",
+			"Team & Scientific Advisors",
+			"Funding Ask & Milestones (value inflection points the round reaches)",
+			"Call to Action & Contact Information",
+		},
+		Guidance: "Use precise scientific and regulatory vocabulary (indication, endpoint, IND, Phase I/II/III, " +
+			"proof of concept). Tie the funding to de-risking milestones, and state data only as provided, " +
+			"without overstating efficacy or safety.",
+	},
+	{
+		ID:      "fintech",
+		Name:    "Fintech",
+		Aliases: []string{"financial services", "finance", "payments", "banking", "insurtech", "lending", "crypto", "web3"},
+		Slides: []string{
+			"Problem (friction, cost or exclusion in today's financial services)",
+			"Solution & Product (customer experience and how money moves)",
+			"Market Opportunity (transaction volume, assets or customers addressed)",
+			"Business Model (interchange, fees, take rate or interest margin)",
+			"Traction (TPV, users, revenue, retention)",
+			"Regulation & Compliance (licenses, partner banks, KYC/AML, security)",
+			"Risk Management (fraud, credit and operational risk controls)",
+			"Competition (incumbent banks and fintech players)",
+			"Go-to-Market & Distribution (partnerships, channels, acquisition cost)",
+			"Team",
+			"Funding Ask & Use of Funds",
+			"Call to Action & Contact Information",
+		},
+		Guidance: "Investors expect regulatory clarity and trust: cover licensing, compliance and risk explicitly, " +
+			"and use fintech metrics (TPV, take rate, loss rate, CAC) where the information supports them.",
+	},
+	{
+		ID:      "hardware",
+		Name:    "Hardware",
+		Aliases: []string{"iot", "devices", "consumer electronics", "robotics", "deep tech", "manufacturing", "climate tech", "energy"},
+		Slides: []string{
+			"Problem (who has it and what it costs them)",
+			"Product (the device, how it works, product photos)",
+			"Technology & Defensibility (engineering breakthroughs, patents)",
+			"Market Opportunity (units, price points, TAM, SAM, SOM)",
+			"Business Model (hardware margin, recurring software or service revenue)",
+			"Manufacturing & Supply Chain (partners, bill of materials, cost down roadmap)",
+			"Certification & Roadmap (prototype, EVT/DVT/PVT stages, certifications)",
+			"Traction (pilots, pre-orders, letters of intent)",
+			"Competition",
+			"Team",
+			"Funding Ask & Use of Funds (tooling, inventory, production milestones)",
+			"Call to Action & Contact Information",
+		},
+		Guidance: "Address the capital intensity of hardware: show the path from prototype to mass production, " +
+			"unit costs and margins at scale, and any recurring revenue attached to the device.",
+	},
+	{
+		ID:      "marketplace",
+		Name:    "Marketplace",
+		Aliases: []string{"two-sided marketplace", "platform", "e-commerce", "ecommerce", "gig economy"},
+		Slides: []string{
+			"Problem (on both the supply and the demand side)",
+			"Solution (how the marketplace matches both sides)",
+			"Market Opportunity (total transaction value, TAM, SAM, SOM)",
+			"Business Model (take rate, fees, value-added services)",
+			"Traction (GMV, liquidity, repeat rate, growth)",
+			"Network Effects & Defensibility",
+			"Supply & Demand Acquisition (how each side is grown, acquisition costs)",
+			"Unit Economics (contribution margin per transaction, CAC per side)",
+			"Competition (incumbents and disintermediation risk)",
+			"Team",
+			"Funding Ask & Use of Funds",
+			"Call to Action & Contact Information",
+		},
+		Guidance: "Frame the deck around liquidity and network effects, treat supply and demand as two " +
+			"distinct acquisition problems, and use marketplace metrics (GMV, take rate, liquidity, repeat rate) " +
+			"where the information supports them.",
+	},
+}
+
+// IndustryTemplateFor returns the template of an industry, matched on the ID, name or
+// aliases of the templates, then on the ID anywhere in the industry. It returns nil
+// for industries without a template.
+func IndustryTemplateFor(industry string) *IndustryTemplate {
+	industry = strings.ToLower(strings.TrimSpace(industry))
+	if industry == "" {
+		return nil
+	}
+	for i, t := range IndustryTemplates {
+		if industry == t.ID || industry == strings.ToLower(t.Name) {
+			return &IndustryTemplates[i]
+		}
+	}
+	for i, t := range IndustryTemplates {
+		for _, alias := range t.Aliases {
+			if industry == alias {
+				return &IndustryTemplates[i]
+			}
+		}
+	}
+	// e.g. "B2B SaaS" or "Fintech for SMBs"
+	for i, t := range IndustryTemplates {
+		if strings.Contains(industry, t.ID) {
+			return &IndustryTemplates[i]
+		}
+	}
+	return nil
+}
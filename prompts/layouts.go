@@ -0,0 +1,42 @@
+package prompts
+
+// Layout is a slide layout users can ask for, applied by the Marp class directive
+// the generated slide starts with
+type Layout struct {
+	ID string
+	// Class of the slide, styled by the stylesheet injected when it is used
+	Class string
+	// Instructions added to the generation prompt on how to fill the slide
+	Guidance string
+}
+
+// Layouts slides can be given, by ID
+var Layouts = map[string]Layout{
+	"two-column": {
+		ID:       "two-column",
+		Class:    "two-column",
+		Guidance: "a heading followed by exactly two blocks shown side by side, such as two bullet lists or a bullet list and an image.",
+	},
+	"full-bleed-image": {
+		ID:       "full-bleed-image",
+		Class:    "full-bleed",
+		Guidance: "the image of the slide as a full background (![bg](image)) with only a heading and one short line over it.",
+	},
+	"quote": {
+		ID:       "quote",
+		Class:    "quote",
+		Guidance: "a single blockquote (> text) of one or two sentences, followed by its author on its own line, and nothing else.",
+	},
+	"statement": {
+		ID:       "statement",
+		Class:    "lead",
+		Guidance: "one centered heading stating the key message of the slide, with at most one line of text under it.",
+	},
+}
+
+// SlideLayout is the layout a user chose for a slide of the deck
+type SlideLayout struct {
+	// Slide the layout applies to, as named by the user (e.g. "Team")
+	Section string
+	Layout
+}
@@ -0,0 +1,34 @@
+package prompts
+
+// FundingStage tunes what a fundraising deck emphasizes for the round it raises
+type FundingStage struct {
+	ID   string
+	Name string
+	// Instructions added to the generation prompt
+	Guidance string
+}
+
+// Stages of the rounds decks are tuned for, by ID
+var FundingStages = map[string]FundingStage{
+	"pre_seed": {
+		ID:   "pre_seed",
+		Name: "Pre-seed",
+		Guidance: "Investors at this stage back the founders and the vision: lead with the size of the problem, " +
+			"the insight behind the solution and why this team is the one to build it. Present early signals " +
+			"(interviews, waitlists, prototypes) as validation, and do not pad the deck with metrics it does not have.",
+	},
+	"seed": {
+		ID:   "seed",
+		Name: "Seed",
+		Guidance: "Investors at this stage look for product-market fit: balance the vision with evidence such as " +
+			"first customers, usage, revenue or pilots, and show how the round gets the company to the " +
+			"milestones of a Series A.",
+	},
+	"series_a": {
+		ID:   "series_a",
+		Name: "Series A",
+		Guidance: "Investors at this stage fund a repeatable business: lead with traction and metrics (revenue, " +
+			"growth rate, retention, unit economics), show the go-to-market engine that scales, and tie the use " +
+			"of funds to measurable growth targets. Keep the vision to a single slide.",
+	},
+}
@@ -0,0 +1,42 @@
+package prompts
+
+import "strings"
+
+// Theme describes a deck theme and its main colors, as set by its stylesheet
+type Theme struct {
+	ID         string
+	Name       string
+	Background string
+	Text       string
+	Accent     string
+}
+
+// Themes decks can be generated with
+var Themes = []Theme{
+	{ID: "default", Name: "Default", Background: "#ffffff", Text: "#24292f", Accent: "#0969da"},
+	{ID: "gaia", Name: "Gaia", Background: "#fff8e1", Text: "#455a64", Accent: "#0288d1"},
+	{ID: "uncover", Name: "Uncover", Background: "#fdfcff", Text: "#202228", Accent: "#0288d1"},
+	{ID: "rose-pine", Name: "Rosé Pine", Background: "#191724", Text: "#e0def4", Accent: "#ebbcba"},
+}
+
+// ThemeFor returns the theme with an ID, in any case
+func ThemeFor(id string) (Theme, bool) {
+	for _, theme := range Themes {
+		if strings.EqualFold(theme.ID, id) {
+			return theme, true
+		}
+	}
+	return Theme{}, false
+}
+
+// ThemePreview returns the example deck of a theme, without the logo header of the
+// generated decks, to be rendered as its preview
+func ThemePreview(id string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(GetThemeExample(id)), "\n") {
+		if !strings.HasPrefix(line, "header:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}